@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	alertAttempts   = 3
+	alertRetryDelay = 5 * time.Second
+	// number of alerts waiting for the webhook, the alerts over it are dropped
+	alertQueueSize = 20
+)
+
+// payload POSTed to the alerting webhook
+type alert struct {
+	File    string            `json:"file"`
+	Sensors map[string]string `json:"sensors"`
+}
+
+// alerter notifies the webhook whenever a log file contains the sensors with one
+// of the configured brandings. The alerts are sent in the background, one by one, so that
+// the retries of a failing webhook don't hold up the processing of the log files.
+type alerter struct {
+	url        string
+	brandings  map[string]bool
+	attempts   int
+	retryDelay time.Duration
+	client     *http.Client
+	queue      chan []byte
+	done       chan struct{}
+}
+
+// create new alerter; empty url means the alerts are disabled
+func newAlerter(url string, brandings []string) *alerter {
+	a := &alerter{
+		url:        url,
+		brandings:  make(map[string]bool),
+		attempts:   alertAttempts,
+		retryDelay: alertRetryDelay,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		queue:      make(chan []byte, alertQueueSize),
+		done:       make(chan struct{}),
+	}
+	for _, b := range brandings {
+		a.brandings[b] = true
+	}
+	go a.run()
+	return a
+}
+
+// Send the queued alerts until the queue is closed
+func (a *alerter) run() {
+	defer close(a.done)
+	for body := range a.queue {
+		if err := a.deliver(body); err != nil {
+			fmt.Printf("Error sending alert: %s\n", err.Error())
+		}
+	}
+}
+
+// Wait until the queued alerts are sent (or given up); no alerts can be checked after that
+func (a *alerter) close() {
+	close(a.queue)
+	<-a.done
+}
+
+// Check the brandings of sensors from given log file and queue the alert if any of them
+// has the branding we're alerting on; the alert is dropped when the queue is full
+func (a *alerter) check(fileName string, brandings map[string]string) error {
+	if a.url == "" {
+		return nil
+	}
+	offending := make(map[string]string)
+	for name, branding := range brandings {
+		if a.brandings[branding] {
+			offending[name] = branding
+		}
+	}
+	if len(offending) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(alert{File: fileName, Sensors: offending})
+	if err != nil {
+		return errors.Wrap(err, "failed creating alert")
+	}
+	select {
+	case a.queue <- body:
+		return nil
+	default:
+		return errors.New(fmt.Sprintf("alert queue is full, dropping the alert of %s", fileName))
+	}
+}
+
+// Send the alert to the webhook, retrying the failed attempts
+func (a *alerter) deliver(body []byte) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = a.send(body)
+		if err == nil || attempt >= a.attempts {
+			break
+		}
+		fmt.Printf("Failed sending alert (attempt %d/%d): %s\n", attempt, a.attempts, err.Error())
+		time.Sleep(a.retryDelay)
+	}
+	return errors.Wrap(err, "failed sending alert to "+a.url)
+}
+
+func (a *alerter) send(body []byte) error {
+	resp, err := a.client.Post(a.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.New(fmt.Sprintf("unexpected response status %s", resp.Status))
+	}
+	return nil
+}
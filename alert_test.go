@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"sensors/pkg/sensors"
+)
+
+func TestAlerts(t *testing.T) {
+	brandings := map[string]string{
+		"temp-1": sensors.ThermometerPrecise,
+		"hum-1":  sensors.HumiditySensorKeep,
+		"hum-2":  sensors.HumiditySensorDiscard,
+	}
+
+	t.Run("alert sent with retry", func(t *testing.T) {
+		requests := 0
+		var received alert
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			// fail the first attempt
+			if requests == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			body, _ := ioutil.ReadAll(r.Body)
+			if err := json.Unmarshal(body, &received); err != nil {
+				t.Errorf("failed decoding alert %q: %s", body, err)
+			}
+		}))
+		defer server.Close()
+
+		a := newAlerter(server.URL, []string{sensors.HumiditySensorDiscard})
+		a.retryDelay = 0
+		err := a.check("log-1.txt", brandings)
+		assertError(t, err, nil)
+		a.close()
+		assertInt(t, requests, 2)
+		assertString(t, received.File, "log-1.txt")
+		assertInt(t, len(received.Sensors), 1)
+		assertString(t, received.Sensors["hum-2"], sensors.HumiditySensorDiscard)
+	})
+
+	t.Run("no offending sensors", func(t *testing.T) {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+		}))
+		defer server.Close()
+
+		a := newAlerter(server.URL, []string{sensors.ThermometerVeryPrecise})
+		err := a.check("log-1.txt", brandings)
+		assertError(t, err, nil)
+		a.close()
+		assertInt(t, requests, 0)
+	})
+
+	t.Run("webhook keeps failing", func(t *testing.T) {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+
+		a := newAlerter(server.URL, []string{sensors.HumiditySensorDiscard})
+		a.retryDelay = 0
+		err := a.check("log-1.txt", brandings)
+		assertError(t, err, nil)
+		a.close()
+		assertInt(t, requests, alertAttempts)
+		assertErrorMessageSubString(t, a.deliver([]byte("{}")), "failed sending alert")
+	})
+
+	t.Run("dead webhook doesn't hold up the checks", func(t *testing.T) {
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+
+		a := newAlerter(server.URL, []string{sensors.HumiditySensorDiscard})
+		a.attempts = 1
+		var err error
+		// the first alert is being sent, the rest waits in the queue until it's full
+		for i := 0; i <= alertQueueSize+1 && err == nil; i++ {
+			err = a.check("log-1.txt", brandings)
+		}
+		assertErrorMessageSubString(t, err, "alert queue is full")
+		close(release)
+		a.close()
+	})
+}
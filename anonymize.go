@@ -0,0 +1,40 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"sensors/pkg/sensors"
+)
+
+// length of the hashed sensor names in hex digits; 64 bits make collisions within a station improbable
+const anonymizedNameLength = 16
+
+// anonymizer replaces the sensor names in the output by their HMAC, which is stable for the same key,
+// so that the results can be shared without the internal sensor identifiers. The mapping isn't kept
+// nor logged, whoever has the key can hash the names to find the sensor of a hash.
+type anonymizer struct {
+	key []byte
+}
+
+func newAnonymizer(key string) *anonymizer {
+	return &anonymizer{key: []byte(key)}
+}
+
+// Return the hashed sensor name
+func (a *anonymizer) hash(name string) string {
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(name))
+	return hex.EncodeToString(mac.Sum(nil))[:anonymizedNameLength]
+}
+
+// Return the copy of the result with the hashed sensor names
+func (a *anonymizer) anonymize(res *sensors.Result) *sensors.Result {
+	ret := &sensors.Result{Sensors: make([]sensors.SensorResult, len(res.Sensors))}
+	for i, s := range res.Sensors {
+		s.Name = a.hash(s.Name)
+		ret.Sensors[i] = s
+	}
+	return ret
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"sensors/pkg/sensors"
+)
+
+func TestAnonymize(t *testing.T) {
+	res, err := sensors.ProcessReader(strings.NewReader(`reference 100 45
+thermometer temp-1
+2007-04-05T22:00 100
+humidity hum-1
+2007-04-05T22:00 50`), sensors.Options{})
+	assertError(t, err, nil)
+
+	cfg := Config{Anonymize: true, AnonymizeKey: "secret", anonymizer: newAnonymizer("secret")}
+	var out map[string]string
+	if err := json.Unmarshal([]byte(formatResult(res, cfg)), &out); err != nil {
+		t.Fatal(err)
+	}
+	assertInt(t, len(out), 2)
+
+	a := cfg.anonymizer
+	for _, name := range []string{"temp-1", "hum-1"} {
+		hash := a.hash(name)
+		assertInt(t, len(hash), anonymizedNameLength)
+		if strings.Contains(hash, name) {
+			t.Errorf("got hash %s containing the name", hash)
+		}
+		// the brandings are kept under the hashed names
+		assertString(t, out[hash], res.Brandings()[name])
+	}
+
+	// stable for the same key, also across the runs
+	assertString(t, newAnonymizer("secret").hash("temp-1"), a.hash("temp-1"))
+	if newAnonymizer("other").hash("temp-1") == a.hash("temp-1") {
+		t.Error("got the same hash for a different key")
+	}
+	// the original result is unchanged
+	assertString(t, res.Sensors[0].Name, "temp-1")
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sony/gobreaker"
+)
+
+const (
+	defaultBreakerFailures = 5
+	defaultBreakerTimeout  = time.Minute
+)
+
+// breakerSource guards the log source by a circuit breaker: after the given number of consecutive
+// failures the breaker opens and the requests fail right away with gobreaker.ErrOpenState, without
+// reaching the remote server. After the timeout one request is let through; the breaker closes when
+// it succeeds and opens again when it fails.
+type breakerSource struct {
+	source LogSource
+	cb     *gobreaker.CircuitBreaker
+}
+
+func newBreakerSource(source LogSource, failures int, timeout time.Duration) *breakerSource {
+	cb := gobreaker.NewCircuitBreaker(gobreaker.Settings{
+		Name:    "remote server",
+		Timeout: timeout,
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			return counts.ConsecutiveFailures >= uint32(failures)
+		},
+		IsSuccessful: remoteSuccess,
+		OnStateChange: func(name string, from, to gobreaker.State) {
+			fmt.Printf("circuit breaker of the %s changed from %s to %s\n", name, from, to)
+			breakerState.Set(float64(to))
+			if to == gobreaker.StateOpen {
+				breakerTrips.Inc()
+			}
+		},
+	})
+	breakerState.Set(float64(cb.State()))
+	return &breakerSource{source: source, cb: cb}
+}
+
+// Tell if the error of the log source means the remote server works; REDIS errors while listing
+// the unprocessed files are not the server's fault
+func remoteSuccess(err error) bool {
+	var sizeErr *FileTooLargeError
+	return err == nil || err == ErrEmptyListing || errors.As(err, &sizeErr) || isCacheUnavailable(err)
+}
+
+func (s *breakerSource) Unprocessed(cache Cache) ([]string, error) {
+	var ret []string
+	_, err := s.cb.Execute(func() (interface{}, error) {
+		var err error
+		ret, err = s.source.Unprocessed(cache)
+		return nil, err
+	})
+	return ret, err
+}
+
+func (s *breakerSource) Fetch(ctx context.Context, logFile, dir string) (string, error) {
+	var ret string
+	_, err := s.cb.Execute(func() (interface{}, error) {
+		var err error
+		ret, err = s.source.Fetch(ctx, logFile, dir)
+		return nil, err
+	})
+	return ret, err
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sony/gobreaker"
+)
+
+// fakeSource lists the files, or fails with err
+type fakeSource struct {
+	files []string
+	err   error
+	calls int
+}
+
+func (s *fakeSource) Unprocessed(cache Cache) ([]string, error) {
+	s.calls++
+	return s.files, s.err
+}
+
+func (s *fakeSource) Fetch(ctx context.Context, logFile, dir string) (string, error) {
+	s.calls++
+	return "", s.err
+}
+
+func TestBreakerSource(t *testing.T) {
+	remote := &fakeSource{files: []string{"log-1.txt"}, err: errors.New("connection refused")}
+	s := newBreakerSource(remote, 2, 50*time.Millisecond)
+	trips := testutil.ToFloat64(breakerTrips)
+	assertInt(t, int(testutil.ToFloat64(breakerState)), int(gobreaker.StateClosed))
+
+	// consecutive failures open the breaker
+	for i := 0; i < 2; i++ {
+		_, err := s.Unprocessed(newMemCache())
+		assertErrorMessageSubString(t, err, "connection refused")
+	}
+	assertInt(t, int(testutil.ToFloat64(breakerState)), int(gobreaker.StateOpen))
+	assertInt(t, int(testutil.ToFloat64(breakerTrips)), int(trips+1))
+
+	// then the remote server is not bothered
+	_, err := s.Fetch(context.Background(), "log-1.txt", t.TempDir())
+	assertError(t, err, gobreaker.ErrOpenState)
+	assertInt(t, remote.calls, 2)
+
+	// until the timeout, when a successful request closes it
+	time.Sleep(60 * time.Millisecond)
+	remote.err = nil
+	files, err := s.Unprocessed(newMemCache())
+	assertError(t, err, nil)
+	assertInt(t, len(files), 1)
+	assertInt(t, int(testutil.ToFloat64(breakerState)), int(gobreaker.StateClosed))
+
+	// server that works but has no log files doesn't trip it
+	remote.err = ErrEmptyListing
+	for i := 0; i < 3; i++ {
+		_, err = s.Unprocessed(newMemCache())
+		assertError(t, err, ErrEmptyListing)
+	}
+	assertInt(t, int(testutil.ToFloat64(breakerState)), int(gobreaker.StateClosed))
+}
@@ -0,0 +1,473 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/pkg/errors"
+
+	"sensors/pkg/sensors"
+)
+
+const (
+	RedisOutageRetry = "retry"
+	RedisOutageExit  = "exit"
+
+	// list of the most recently processed log files, newest first
+	recentFilesKey = "recent-files"
+	maxRecentFiles = 100
+	// REDIS operations slower than this are logged by default
+	defaultRedisSlowThreshold = 100 * time.Millisecond
+	// placeholder of REDIS_KEY_PREFIX replaced by the remote directory
+	redisKeyDirPlaceholder = "{dir}"
+	// prefix of the keys with the hash of the brandings of processed log file
+	resultHashKeyPrefix = "hash:"
+)
+
+// ErrCacheMiss is returned by Cache.Get when the key is not present
+var ErrCacheMiss = sensors.ErrNotFound
+
+// cacheUnavailableError is returned by the cache when REDIS can't be reached, as opposed to a missing key
+type cacheUnavailableError struct {
+	err error
+}
+
+func (e *cacheUnavailableError) Error() string {
+	return "REDIS unavailable: " + e.err.Error()
+}
+
+func (e *cacheUnavailableError) Unwrap() error {
+	return e.err
+}
+
+// Tell if the error (possibly wrapped) means that REDIS can't be reached
+func isCacheUnavailable(err error) bool {
+	var unavailable *cacheUnavailableError
+	return errors.As(err, &unavailable)
+}
+
+// Mark the error of REDIS client as unavailable REDIS
+func redisError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &cacheUnavailableError{err: err}
+}
+
+// Cache is the storage for processed file markers and results.
+// It's implemented by REDIS in production; having an interface here makes it possible
+// to test the code that depends on it without running REDIS server.
+type Cache interface {
+	// Get and Set of values; Get returns ErrCacheMiss for missing keys
+	sensors.Store
+	// Prepend adds the value at the start of the list stored under the key,
+	// keeping at most max items in the list
+	Prepend(key, value string, max int) error
+	// List returns up to n first items of the list stored under the key
+	List(key string, n int) ([]string, error)
+	// AddScored adds the member with the score to the sorted set stored under the key, or updates
+	// the score of the member already there, and removes the members scored below min
+	AddScored(key, member string, score, min float64) error
+	// RangeScored returns the members of the sorted set stored under the key scored at least min,
+	// the highest score first
+	RangeScored(key string, min float64) ([]scoredMember, error)
+}
+
+// scoredMember is the member of a sorted set with its score
+type scoredMember struct {
+	member string
+	score  float64
+}
+
+// Sort the members like REDIS does for RangeScored, by the score and then by the member, both descending
+func sortScored(members []scoredMember) {
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].score != members[j].score {
+			return members[i].score > members[j].score
+		}
+		return members[i].member > members[j].member
+	})
+}
+
+// redisCache keeps the values in REDIS, with the keys namespaced by the prefix
+type redisCache struct {
+	rdb    *redis.Client
+	prefix string
+}
+
+func newRedisCache(rdb *redis.Client, prefix string) *redisCache {
+	return &redisCache{rdb: rdb, prefix: prefix}
+}
+
+// Return the key prefix given by the template, with {dir} replaced by the remote directory
+// without the scheme, e.g. sensors:{dir}: for http://example.com/logs/ is sensors:example.com/logs:
+func redisKeyPrefix(template, remoteDir string) string {
+	dir := remoteDir
+	if i := strings.Index(dir, "://"); i >= 0 {
+		dir = dir[i+3:]
+	}
+	dir = strings.TrimSuffix(dir, "/")
+	return strings.ReplaceAll(template, redisKeyDirPlaceholder, dir)
+}
+
+// Return the REDIS key of the cache key
+func (c *redisCache) key(key string) string {
+	return c.prefix + key
+}
+
+func (c *redisCache) Get(key string) (string, error) {
+	val, err := c.rdb.Get(c.key(key)).Result()
+	if err == redis.Nil {
+		return "", ErrCacheMiss
+	}
+	return val, redisError(err)
+}
+
+func (c *redisCache) Set(key, value string) error {
+	return redisError(c.rdb.Set(c.key(key), value, 0).Err())
+}
+
+func (c *redisCache) Prepend(key, value string, max int) error {
+	if err := c.rdb.LPush(c.key(key), value).Err(); err != nil {
+		return redisError(err)
+	}
+	return redisError(c.rdb.LTrim(c.key(key), 0, int64(max-1)).Err())
+}
+
+func (c *redisCache) List(key string, n int) ([]string, error) {
+	val, err := c.rdb.LRange(c.key(key), 0, int64(n-1)).Result()
+	return val, redisError(err)
+}
+
+func (c *redisCache) AddScored(key, member string, score, min float64) error {
+	if err := c.rdb.ZAdd(c.key(key), redis.Z{Score: score, Member: member}).Err(); err != nil {
+		return redisError(err)
+	}
+	// exclusive, the members scored exactly min are kept
+	return redisError(c.rdb.ZRemRangeByScore(c.key(key), "-inf", "("+strconv.FormatFloat(min, 'f', -1, 64)).Err())
+}
+
+func (c *redisCache) RangeScored(key string, min float64) ([]scoredMember, error) {
+	opt := redis.ZRangeBy{Min: strconv.FormatFloat(min, 'f', -1, 64), Max: "+inf"}
+	val, err := c.rdb.ZRevRangeByScoreWithScores(c.key(key), opt).Result()
+	if err != nil {
+		return nil, redisError(err)
+	}
+	ret := make([]scoredMember, len(val))
+	for i, z := range val {
+		ret[i] = scoredMember{member: z.Member.(string), score: z.Score}
+	}
+	return ret, nil
+}
+
+// timedCache records the duration of the operations of the next cache in the redisLatency metric
+// and logs the operations slower than slow (zero disables the logging)
+type timedCache struct {
+	Cache
+	slow time.Duration
+}
+
+func newTimedCache(next Cache, slow time.Duration) *timedCache {
+	return &timedCache{Cache: next, slow: slow}
+}
+
+// Record the duration of the operation on the key since start
+func (c *timedCache) observe(operation, key string, start time.Time) {
+	d := time.Since(start)
+	redisLatency.WithLabelValues(operation).Observe(d.Seconds())
+	if c.slow > 0 && d > c.slow {
+		fmt.Printf("Slow REDIS %s of %s took %s\n", operation, key, d)
+	}
+}
+
+func (c *timedCache) Get(key string) (string, error) {
+	defer c.observe("get", key, time.Now())
+	return c.Cache.Get(key)
+}
+
+func (c *timedCache) Set(key, value string) error {
+	defer c.observe("set", key, time.Now())
+	return c.Cache.Set(key, value)
+}
+
+func (c *timedCache) Prepend(key, value string, max int) error {
+	defer c.observe("prepend", key, time.Now())
+	return c.Cache.Prepend(key, value, max)
+}
+
+func (c *timedCache) List(key string, n int) ([]string, error) {
+	defer c.observe("list", key, time.Now())
+	return c.Cache.List(key, n)
+}
+
+func (c *timedCache) AddScored(key, member string, score, min float64) error {
+	defer c.observe("zadd", key, time.Now())
+	return c.Cache.AddScored(key, member, score, min)
+}
+
+func (c *timedCache) RangeScored(key string, min float64) ([]scoredMember, error) {
+	defer c.observe("zrange", key, time.Now())
+	return c.Cache.RangeScored(key, min)
+}
+
+// limitedCache bounds the number of concurrent operations of the next cache, so that the goroutines
+// sharing it don't exhaust the REDIS connection pool; the operations over the limit wait for a free slot
+type limitedCache struct {
+	Cache
+	slots chan struct{}
+}
+
+func newLimitedCache(next Cache, max int) *limitedCache {
+	return &limitedCache{Cache: next, slots: make(chan struct{}, max)}
+}
+
+// Wait for a free slot; the returned function frees it
+func (c *limitedCache) acquire() func() {
+	c.slots <- struct{}{}
+	return func() { <-c.slots }
+}
+
+func (c *limitedCache) Get(key string) (string, error) {
+	defer c.acquire()()
+	return c.Cache.Get(key)
+}
+
+func (c *limitedCache) Set(key, value string) error {
+	defer c.acquire()()
+	return c.Cache.Set(key, value)
+}
+
+func (c *limitedCache) Prepend(key, value string, max int) error {
+	defer c.acquire()()
+	return c.Cache.Prepend(key, value, max)
+}
+
+func (c *limitedCache) List(key string, n int) ([]string, error) {
+	defer c.acquire()()
+	return c.Cache.List(key, n)
+}
+
+func (c *limitedCache) AddScored(key, member string, score, min float64) error {
+	defer c.acquire()()
+	return c.Cache.AddScored(key, member, score, min)
+}
+
+func (c *limitedCache) RangeScored(key string, min float64) ([]scoredMember, error) {
+	defer c.acquire()()
+	return c.Cache.RangeScored(key, min)
+}
+
+// lruCache keeps the recently read values of the next cache in memory for up to ttl (zero means until
+// they are evicted), it's safe for concurrent use. The values are expected to change rarely (as the results
+// of processed files); a value changed or deleted by other instance of the application, e.g. the results
+// of the invalidated files, is seen only after ttl.
+type lruCache struct {
+	Cache
+	values *lru.Cache
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+// lruEntry is the value kept by lruCache, with the time it was read
+type lruEntry struct {
+	value string
+	added time.Time
+}
+
+// Wrap the cache with in-memory LRU cache of size values, each kept for ttl
+func newLRUCache(next Cache, size int, ttl time.Duration) (*lruCache, error) {
+	values, err := lru.New(size)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed creating LRU cache")
+	}
+	return &lruCache{Cache: next, values: values, ttl: ttl, now: time.Now}, nil
+}
+
+func (c *lruCache) Get(key string) (string, error) {
+	if val, ok := c.values.Get(key); ok {
+		e := val.(lruEntry)
+		if c.ttl == 0 || c.now().Sub(e.added) < c.ttl {
+			return e.value, nil
+		}
+		c.values.Remove(key)
+	}
+	val, err := c.Cache.Get(key)
+	if err != nil {
+		return "", err
+	}
+	c.values.Add(key, lruEntry{value: val, added: c.now()})
+	return val, nil
+}
+
+func (c *lruCache) Set(key, value string) error {
+	if err := c.Cache.Set(key, value); err != nil {
+		return err
+	}
+	c.values.Add(key, lruEntry{value: value, added: c.now()})
+	return nil
+}
+
+// pendingWrite is a write to the cache waiting for REDIS to recover
+type pendingWrite struct {
+	key, value string
+	// prepend to the list of at most max items, instead of setting the value
+	prepend bool
+	max     int
+	// add to the sorted set with the score, instead of setting the value
+	scored     bool
+	score, min float64
+}
+
+// bufferedCache keeps up to size writes that failed because REDIS was unavailable and replays them,
+// in the original order, once it's reachable again; it's safe for concurrent use. The buffered values
+// are seen by Get and List meanwhile, but they are lost if the application exits before the replay.
+type bufferedCache struct {
+	Cache
+	size    int
+	mu      sync.Mutex
+	pending []pendingWrite
+}
+
+func newBufferedCache(next Cache, size int) *bufferedCache {
+	return &bufferedCache{Cache: next, size: size}
+}
+
+// Replay the pending writes, until the first one that fails; must be called with the lock held
+func (c *bufferedCache) flush() error {
+	for len(c.pending) > 0 {
+		w := c.pending[0]
+		var err error
+		switch {
+		case w.prepend:
+			err = c.Cache.Prepend(w.key, w.value, w.max)
+		case w.scored:
+			err = c.Cache.AddScored(w.key, w.value, w.score, w.min)
+		default:
+			err = c.Cache.Set(w.key, w.value)
+		}
+		if err != nil {
+			return err
+		}
+		c.pending = c.pending[1:]
+	}
+	return nil
+}
+
+// Do the write, or buffer it when REDIS is unavailable; must be called with the lock held
+func (c *bufferedCache) write(w pendingWrite, do func() error) error {
+	// the order of writes is kept, so nothing goes to REDIS before the pending ones
+	err := c.flush()
+	if err == nil {
+		err = do()
+	}
+	if err == nil || !isCacheUnavailable(err) {
+		return err
+	}
+	if len(c.pending) >= c.size {
+		return errors.Wrap(err, "REDIS write buffer full")
+	}
+	c.pending = append(c.pending, w)
+	return nil
+}
+
+func (c *bufferedCache) Get(key string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.pending) > 0 && c.flush() != nil {
+		// the last pending value is the current one
+		for i := len(c.pending) - 1; i >= 0; i-- {
+			if w := c.pending[i]; !w.prepend && !w.scored && w.key == key {
+				return w.value, nil
+			}
+		}
+	}
+	return c.Cache.Get(key)
+}
+
+func (c *bufferedCache) Set(key, value string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.write(pendingWrite{key: key, value: value}, func() error {
+		return c.Cache.Set(key, value)
+	})
+}
+
+func (c *bufferedCache) Prepend(key, value string, max int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.write(pendingWrite{key: key, value: value, prepend: true, max: max}, func() error {
+		return c.Cache.Prepend(key, value, max)
+	})
+}
+
+func (c *bufferedCache) List(key string, n int) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.pending) > 0 && c.flush() != nil {
+		// REDIS is down, the pending items are all there is
+		ret := make([]string, 0)
+		for i := len(c.pending) - 1; i >= 0 && len(ret) < n; i-- {
+			if w := c.pending[i]; w.prepend && w.key == key {
+				ret = append(ret, w.value)
+			}
+		}
+		return ret, nil
+	}
+	return c.Cache.List(key, n)
+}
+
+func (c *bufferedCache) AddScored(key, member string, score, min float64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.write(pendingWrite{key: key, value: member, scored: true, score: score, min: min}, func() error {
+		return c.Cache.AddScored(key, member, score, min)
+	})
+}
+
+func (c *bufferedCache) RangeScored(key string, min float64) ([]scoredMember, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.pending) > 0 && c.flush() != nil {
+		// REDIS is down, the pending members are all there is, with their last score
+		scores := make(map[string]float64)
+		for _, w := range c.pending {
+			if w.scored && w.key == key {
+				scores[w.value] = w.score
+			}
+		}
+		ret := make([]scoredMember, 0)
+		for member, score := range scores {
+			if score >= min {
+				ret = append(ret, scoredMember{member: member, score: score})
+			}
+		}
+		sortScored(ret)
+		return ret, nil
+	}
+	return c.Cache.RangeScored(key, min)
+}
+
+// save the result of processing a log file and remember it among the recent ones
+func storeResult(cache Cache, fileName, result string) error {
+	if err := cache.Set(fileName, result); err != nil {
+		return errors.Wrap(err, "failed saving result of "+fileName)
+	}
+	if err := cache.Prepend(recentFilesKey, fileName, maxRecentFiles); err != nil {
+		return errors.Wrap(err, "failed updating list of recent files")
+	}
+	return nil
+}
+
+// Store the hash of the brandings of processed log file, see sensors.Result.Hash
+func storeResultHash(cache Cache, fileName, hash string) error {
+	if err := cache.Set(resultHashKeyPrefix+fileName, hash); err != nil {
+		return errors.Wrap(err, "failed saving result hash of "+fileName)
+	}
+	return nil
+}
@@ -0,0 +1,442 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// in-memory implementation of Cache used by tests
+type memCache struct {
+	values map[string]string
+	lists  map[string][]string
+	sets   map[string]map[string]float64
+	// the min of the last trimming of each set, to trim only when it grows
+	trimmed map[string]float64
+}
+
+func newMemCache() *memCache {
+	return &memCache{
+		values:  make(map[string]string),
+		lists:   make(map[string][]string),
+		sets:    make(map[string]map[string]float64),
+		trimmed: make(map[string]float64),
+	}
+}
+
+func (c *memCache) Get(key string) (string, error) {
+	val, ok := c.values[key]
+	if !ok {
+		return "", ErrCacheMiss
+	}
+	return val, nil
+}
+
+func (c *memCache) Set(key, value string) error {
+	c.values[key] = value
+	return nil
+}
+
+func (c *memCache) Prepend(key, value string, max int) error {
+	list := append([]string{value}, c.lists[key]...)
+	if len(list) > max {
+		list = list[:max]
+	}
+	c.lists[key] = list
+	return nil
+}
+
+func (c *memCache) List(key string, n int) ([]string, error) {
+	list := c.lists[key]
+	if len(list) > n {
+		list = list[:n]
+	}
+	return list, nil
+}
+
+func (c *memCache) AddScored(key, member string, score, min float64) error {
+	set, ok := c.sets[key]
+	if !ok {
+		set = make(map[string]float64)
+		c.sets[key] = set
+	}
+	set[member] = score
+	if t, ok := c.trimmed[key]; !ok || min > t || score < min {
+		for m, s := range set {
+			if s < min {
+				delete(set, m)
+			}
+		}
+		c.trimmed[key] = min
+	}
+	return nil
+}
+
+func (c *memCache) RangeScored(key string, min float64) ([]scoredMember, error) {
+	ret := make([]scoredMember, 0)
+	for m, s := range c.sets[key] {
+		if s >= min {
+			ret = append(ret, scoredMember{member: m, score: s})
+		}
+	}
+	sortScored(ret)
+	return ret, nil
+}
+
+func TestStoreResult(t *testing.T) {
+	cache := newMemCache()
+
+	for i := 0; i < maxRecentFiles+1; i++ {
+		if err := storeResult(cache, "log-"+string(rune('a'+i%26)), "{}"); err != nil {
+			t.Fatalf("unexpected error %q", err)
+		}
+	}
+	if err := storeResult(cache, "log-newest.txt", `{"temp-1": "precise"}`); err != nil {
+		t.Fatalf("unexpected error %q", err)
+	}
+
+	val, err := cache.Get("log-newest.txt")
+	assertError(t, err, nil)
+	assertString(t, val, `{"temp-1": "precise"}`)
+
+	recent, _ := cache.List(recentFilesKey, maxRecentFiles+10)
+	if len(recent) != maxRecentFiles {
+		t.Errorf("got %d recent files, want %d", len(recent), maxRecentFiles)
+	}
+	assertString(t, recent[0], "log-newest.txt")
+}
+
+// countingCache counts the reads of the underlying cache; it's safe for concurrent use
+type countingCache struct {
+	Cache
+	mu   sync.Mutex
+	gets int
+}
+
+func (c *countingCache) Get(key string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gets++
+	return c.Cache.Get(key)
+}
+
+func TestLRUCache(t *testing.T) {
+	next := &countingCache{Cache: newMemCache()}
+	next.Cache.Set("log-1.txt", "one")
+	next.Cache.Set("log-2.txt", "two")
+	next.Cache.Set("log-3.txt", "three")
+	cache, err := newLRUCache(next, 2, 0)
+	assertError(t, err, nil)
+
+	t.Run("miss reads the next cache", func(t *testing.T) {
+		val, err := cache.Get("log-1.txt")
+		assertError(t, err, nil)
+		assertString(t, val, "one")
+		assertInt(t, next.gets, 1)
+	})
+
+	t.Run("hit", func(t *testing.T) {
+		val, err := cache.Get("log-1.txt")
+		assertError(t, err, nil)
+		assertString(t, val, "one")
+		assertInt(t, next.gets, 1)
+	})
+
+	t.Run("unknown key is not cached", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			_, err := cache.Get("log-4.txt")
+			assertError(t, err, ErrCacheMiss)
+		}
+		assertInt(t, next.gets, 3)
+	})
+
+	t.Run("eviction", func(t *testing.T) {
+		cache.Get("log-2.txt")
+		cache.Get("log-3.txt")
+		assertInt(t, next.gets, 5)
+		// least recently used log-1.txt was evicted by log-3.txt
+		cache.Get("log-1.txt")
+		assertInt(t, next.gets, 6)
+		cache.Get("log-3.txt")
+		assertInt(t, next.gets, 6)
+	})
+
+	t.Run("set writes through", func(t *testing.T) {
+		assertError(t, cache.Set("log-5.txt", "five"), nil)
+		val, err := next.Cache.Get("log-5.txt")
+		assertError(t, err, nil)
+		assertString(t, val, "five")
+		gets := next.gets
+		val, _ = cache.Get("log-5.txt")
+		assertString(t, val, "five")
+		assertInt(t, next.gets, gets)
+	})
+
+	t.Run("concurrent reads", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					if _, err := cache.Get(fmt.Sprintf("log-%d.txt", (i+j)%3+1)); err != nil {
+						t.Errorf("unexpected error %q", err)
+					}
+				}
+			}(i)
+		}
+		wg.Wait()
+	})
+}
+
+func TestLRUCacheTTL(t *testing.T) {
+	next := newMemCache()
+	next.Set("log-1.txt", "old")
+	cache, err := newLRUCache(next, 10, time.Minute)
+	assertError(t, err, nil)
+	now := time.Date(2021, 11, 5, 12, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+
+	val, err := cache.Get("log-1.txt")
+	assertError(t, err, nil)
+	assertString(t, val, "old")
+	// invalidated and processed again by the worker, past this cache
+	next.Set("log-1.txt", "new")
+	now = now.Add(59 * time.Second)
+	val, _ = cache.Get("log-1.txt")
+	assertString(t, val, "old")
+	now = now.Add(time.Second)
+	val, _ = cache.Get("log-1.txt")
+	assertString(t, val, "new")
+
+	// invalidated and not processed yet
+	delete(next.values, "log-1.txt")
+	now = now.Add(time.Minute)
+	_, err = cache.Get("log-1.txt")
+	assertError(t, err, ErrCacheMiss)
+}
+
+func TestRedisKeys(t *testing.T) {
+	tests := []struct {
+		template, remoteDir, want string
+	}{
+		{"", "http://example.com/logs/", ""},
+		{"sensors:", "http://example.com/logs/", "sensors:"},
+		{"sensors:results:{dir}:", "http://example.com/logs/", "sensors:results:example.com/logs:"},
+		{"sensors:{dir}:", "sftp://user@host/var/logs", "sensors:user@host/var/logs:"},
+		{"sensors:{dir}:", "", "sensors::"},
+	}
+	for _, tt := range tests {
+		assertString(t, redisKeyPrefix(tt.template, tt.remoteDir), tt.want)
+	}
+
+	// bare keys by default, as before the prefix existed
+	assertString(t, newRedisCache(nil, "").key("log-1.txt"), "log-1.txt")
+	c := newRedisCache(nil, redisKeyPrefix("sensors:results:{dir}:", "http://example.com/logs/"))
+	assertString(t, c.key("log-1.txt"), "sensors:results:example.com/logs:log-1.txt")
+	assertString(t, c.key(recentFilesKey), "sensors:results:example.com/logs:recent-files")
+}
+
+// flakyCache is the cache that fails with unavailable REDIS while it's down
+type flakyCache struct {
+	*memCache
+	down bool
+}
+
+var errConnectionRefused = &cacheUnavailableError{err: fmt.Errorf("dial tcp: connection refused")}
+
+func (c *flakyCache) Get(key string) (string, error) {
+	if c.down {
+		return "", errConnectionRefused
+	}
+	return c.memCache.Get(key)
+}
+
+func (c *flakyCache) Set(key, value string) error {
+	if c.down {
+		return errConnectionRefused
+	}
+	return c.memCache.Set(key, value)
+}
+
+func (c *flakyCache) Prepend(key, value string, max int) error {
+	if c.down {
+		return errConnectionRefused
+	}
+	return c.memCache.Prepend(key, value, max)
+}
+
+func (c *flakyCache) List(key string, n int) ([]string, error) {
+	if c.down {
+		return nil, errConnectionRefused
+	}
+	return c.memCache.List(key, n)
+}
+
+func (c *flakyCache) AddScored(key, member string, score, min float64) error {
+	if c.down {
+		return errConnectionRefused
+	}
+	return c.memCache.AddScored(key, member, score, min)
+}
+
+func (c *flakyCache) RangeScored(key string, min float64) ([]scoredMember, error) {
+	if c.down {
+		return nil, errConnectionRefused
+	}
+	return c.memCache.RangeScored(key, min)
+}
+
+func TestRedisOutage(t *testing.T) {
+	redis := &flakyCache{memCache: newMemCache()}
+	cache := newBufferedCache(redis, 3)
+	assertError(t, storeResult(cache, "log-1.txt", `{"temp-1": "precise"}`), nil)
+
+	redis.down = true
+	_, err := cache.Get("log-0.txt")
+	if !isCacheUnavailable(err) {
+		t.Errorf("got error %v, want unavailable REDIS", err)
+	}
+	// the writes are buffered, and seen meanwhile
+	assertError(t, storeResult(cache, "log-2.txt", `{"temp-1": "ultra precise"}`), nil)
+	assertError(t, storeResultHash(cache, "log-2.txt", "abc"), nil)
+	val, err := cache.Get("log-2.txt")
+	assertError(t, err, nil)
+	assertString(t, val, `{"temp-1": "ultra precise"}`)
+	recent, err := cache.List(recentFilesKey, 10)
+	assertError(t, err, nil)
+	assertString(t, strings.Join(recent, ","), "log-2.txt")
+	// until the buffer is full
+	err = storeResult(cache, "log-3.txt", `{}`)
+	assertErrorMessageSubString(t, err, "REDIS write buffer full")
+	if !isCacheUnavailable(err) {
+		t.Errorf("got error %v, want unavailable REDIS", err)
+	}
+	_, err = redis.memCache.Get("log-2.txt")
+	assertError(t, err, ErrCacheMiss)
+
+	// the writes are replayed on recovery
+	redis.down = false
+	_, err = cache.Get("log-0.txt")
+	assertError(t, err, ErrCacheMiss)
+	val, err = redis.memCache.Get("log-2.txt")
+	assertError(t, err, nil)
+	assertString(t, val, `{"temp-1": "ultra precise"}`)
+	val, err = redis.memCache.Get(resultHashKeyPrefix + "log-2.txt")
+	assertError(t, err, nil)
+	assertString(t, val, "abc")
+	recent, err = redis.memCache.List(recentFilesKey, 10)
+	assertError(t, err, nil)
+	assertString(t, strings.Join(recent, ","), "log-2.txt,log-1.txt")
+	assertInt(t, len(cache.pending), 0)
+}
+
+func TestRedisOutageSortedSet(t *testing.T) {
+	redis := &flakyCache{memCache: newMemCache()}
+	cache := newBufferedCache(redis, 3)
+	redis.down = true
+	assertError(t, cache.AddScored("set", "a", 2, 0), nil)
+	assertError(t, cache.AddScored("set", "b", 3, 0), nil)
+	assertError(t, cache.AddScored("set", "a", 4, 0), nil)
+	members, err := cache.RangeScored("set", 3)
+	assertError(t, err, nil)
+	assertInt(t, len(members), 2)
+	assertString(t, members[0].member, "a")
+	assertString(t, members[1].member, "b")
+
+	redis.down = false
+	members, err = cache.RangeScored("set", 0)
+	assertError(t, err, nil)
+	assertInt(t, len(members), 2)
+	assertInt(t, len(redis.memCache.sets["set"]), 2)
+	assertInt(t, int(redis.memCache.sets["set"]["a"]), 4)
+}
+
+func TestBackoff(t *testing.T) {
+	b := newBackoff(10*time.Second, 30*time.Second)
+	for _, want := range []time.Duration{10 * time.Second, 20 * time.Second, 30 * time.Second, 30 * time.Second} {
+		if got := b.next(); got != want {
+			t.Errorf("got wait %s, want %s", got, want)
+		}
+	}
+	b.reset()
+	if got := b.next(); got != 10*time.Second {
+		t.Errorf("got wait %s after reset, want 10s", got)
+	}
+}
+
+// Return the number of observations of the REDIS operation in the latency histogram
+func redisLatencyCount(t *testing.T, operation string) uint64 {
+	t.Helper()
+
+	var m dto.Metric
+	if err := redisLatency.WithLabelValues(operation).(prometheus.Histogram).Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestTimedCache(t *testing.T) {
+	cache := newTimedCache(newMemCache(), time.Nanosecond)
+	gets, sets := redisLatencyCount(t, "get"), redisLatencyCount(t, "set")
+
+	assertError(t, cache.Set("log-1.txt", "{}"), nil)
+	val, err := cache.Get("log-1.txt")
+	assertError(t, err, nil)
+	assertString(t, val, "{}")
+	// the misses take time as well
+	_, err = cache.Get("log-2.txt")
+	assertError(t, err, ErrCacheMiss)
+
+	assertInt(t, int(redisLatencyCount(t, "set")-sets), 1)
+	assertInt(t, int(redisLatencyCount(t, "get")-gets), 2)
+}
+
+// concurrencyCache tracks the maximal number of its operations running at the same time
+type concurrencyCache struct {
+	*memCache
+	mu              sync.Mutex
+	active, maxSeen int
+}
+
+func (c *concurrencyCache) Set(key, value string) error {
+	c.mu.Lock()
+	c.active++
+	if c.active > c.maxSeen {
+		c.maxSeen = c.active
+	}
+	c.values[key] = value
+	c.mu.Unlock()
+	time.Sleep(time.Millisecond)
+	c.mu.Lock()
+	c.active--
+	c.mu.Unlock()
+	return nil
+}
+
+func TestLimitedCache(t *testing.T) {
+	next := &concurrencyCache{memCache: newMemCache()}
+	cache := newLimitedCache(next, 3)
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := cache.Set(fmt.Sprintf("log-%d.txt", i), "{}"); err != nil {
+				t.Errorf("unexpected error %q", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if next.maxSeen > 3 {
+		t.Errorf("got %d concurrent operations, want at most 3", next.maxSeen)
+	}
+	assertInt(t, len(next.values), 50)
+	// the slots are freed
+	assertInt(t, len(cache.slots), 0)
+}
@@ -0,0 +1,628 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"sensors/pkg/sensors"
+)
+
+// Config contains the settings of the log file processing.
+// The Store of Options is not read from the environment; the caller has to set it.
+type Config struct {
+	// Options are the settings of the sensors branding itself
+	sensors.Options
+
+	// AlertWebhookURL is the URL that gets notified about the sensors with one of AlertBrandings.
+	// Empty value disables the alerts.
+	AlertWebhookURL string
+	// AlertBrandings lists the brandings that trigger the alert
+	AlertBrandings []string
+
+	// FailOnDiscard makes processing of the log file fail when any sensor is discarded
+	FailOnDiscard bool
+
+	// OutputFilter selects the sensors in the output: OutputFilterAll, or OutputFilterProblems for the
+	// sensors that failed the quality control only
+	OutputFilter string
+
+	// IncludeConfidence adds the confidence of the branding of each sensor to the output,
+	// see sensors.SensorResult.Confidence
+	IncludeConfidence bool
+
+	// StationSummary adds the station-wide aggregates of the sensors to the output, see sensors.StationSummary
+	StationSummary bool
+
+	// Anonymize replaces the sensor names in the output by their HMAC with AnonymizeKey, see anonymizer
+	Anonymize    bool
+	AnonymizeKey string
+	// anonymizer of the run, when Anonymize is set
+	anonymizer *anonymizer
+
+	// OutputOrder is the order of the sensors in the output, OutputOrderName, OutputOrderFile or OutputOrderType
+	OutputOrder string
+
+	// ProcessingTimeout limits the processing of a log file, 0 means no limit
+	ProcessingTimeout time.Duration
+
+	// OutputSink is where the results are written besides the cache: comma separated list of SinkStdout,
+	// file:<path template> or http(s) URL, see newOutputSink
+	OutputSink string
+	// OutputCompression is CompressionNone or CompressionGzip for the results written by OutputSink
+	OutputCompression string
+
+	// Manifest lists the sensors expected in every log file; the missing and unexpected sensors are
+	// reported. Empty manifest disables the check.
+	Manifest []string
+
+	// SourceType is the format of the remote directory listing, SourceHTML or SourceJSON,
+	// or SourceSFTP for the directory on SFTP server
+	SourceType string
+	// UnsortedListing means the html listing is not sorted from the newest file, so the whole listing must be
+	// read and sorted
+	UnsortedListing bool
+	// ReferenceFile is the path or http(s) URL of the sidecar file with the reference line, for the log
+	// files without one
+	ReferenceFile string
+
+	// LogFileExtensions are the extensions of the log files in the listing (e.g. ".txt"), any extension
+	// when empty
+	LogFileExtensions []string
+
+	// SFTPPassword or SFTPKeyFile (path to the private key) authenticate to the SFTP server,
+	// SFTPKnownHosts is the known_hosts file with the server's key
+	SFTPPassword   string
+	SFTPKeyFile    string
+	SFTPKnownHosts string
+
+	// MaxFileSize is the maximum size of downloaded log file in bytes, 0 means no limit
+	MaxFileSize int64
+
+	// IncompleteTimeout is how long the log file without the end marker (see EndMarker) is tried again,
+	// with the newer files waiting for it; then it's marked as processed with the incomplete file error
+	IncompleteTimeout time.Duration
+
+	// HTTPHeaders are added to all requests to the remote directory with log files
+	HTTPHeaders map[string]string
+
+	// RateLimit is the maximum number of requests per second to the remote directory, 0 means no limit
+	RateLimit float64
+
+	// BreakerFailures is the number of consecutive failed requests to the remote server that open
+	// the circuit breaker, BreakerTimeout is how long it stays open before the next try
+	BreakerFailures int
+	BreakerTimeout  time.Duration
+
+	// RedisOutage says what the worker does when REDIS becomes unavailable: RedisOutageRetry keeps
+	// polling with growing pauses, RedisOutageExit exits
+	RedisOutage string
+	// RedisBufferSize is the number of writes kept while REDIS is unavailable, to be replayed when
+	// it recovers; 0 disables the buffer
+	RedisBufferSize int
+	// RedisMaxConcurrency is the maximal number of REDIS operations running at the same time, 0 means
+	// no limit
+	RedisMaxConcurrency int
+	// DownloadConcurrency is the number of log files of the backlog downloaded at the same time, ahead
+	// of their processing
+	DownloadConcurrency int
+	// BrandingIndex are the brandings whose sensors are indexed in REDIS, to be listed by the /brandings
+	// endpoint; empty disables the index
+	BrandingIndex []string
+	// BrandingIndexRetention is how long the sensors stay in the branding index, by the date of their
+	// log file
+	BrandingIndexRetention time.Duration
+	// BrandingHistory is the number of the latest brandings of each sensor kept in REDIS, to report
+	// the sensors whose branding keeps changing; 0 disables the history
+	BrandingHistory int
+	// HeartbeatInterval is how often the worker writes its heartbeat key to REDIS, 0 disables it
+	HeartbeatInterval time.Duration
+	// RedisSlowThreshold is the duration of REDIS operations over which they are logged, 0 disables the logging
+	RedisSlowThreshold time.Duration
+}
+
+// Read the configuration from the environment variables, missing ones get the default values
+func configFromEnv() (cfg Config, err error) {
+	if cfg.MaxReadings, err = envInt("MAX_READINGS", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxReadings < 0 {
+		return cfg, errors.New("MAX_READINGS must not be negative")
+	}
+	if cfg.MinReadings, err = envInt("MIN_READINGS", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.MinReadings < 0 {
+		return cfg, errors.New("MIN_READINGS must not be negative")
+	}
+	if cfg.Decoders, err = decodersFromEnv(); err != nil {
+		return cfg, err
+	}
+	if cfg.Units, err = envMap("READING_UNITS"); err != nil {
+		return cfg, err
+	}
+	if cfg.Thresholds, err = thresholdsFromEnv(); err != nil {
+		return cfg, err
+	}
+	if cfg.GapMultiplier, err = envFloat("GAP_MULTIPLIER", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.GapMultiplier < 0 {
+		return cfg, errors.New("GAP_MULTIPLIER must not be negative")
+	}
+	if cfg.FlatlineMinReadings, err = envInt("FLATLINE_MIN_READINGS", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.FlatlineMinReadings < 0 {
+		return cfg, errors.New("FLATLINE_MIN_READINGS must not be negative")
+	}
+	if cfg.Parallelism, err = envInt("BRANDING_PARALLELISM", 1); err != nil {
+		return cfg, err
+	}
+	if cfg.Parallelism < 1 {
+		return cfg, errors.New("BRANDING_PARALLELISM must be at least 1")
+	}
+	if cfg.OutlierMAD, err = envFloat("OUTLIER_MAD", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.OutlierMAD < 0 {
+		return cfg, errors.New("OUTLIER_MAD must not be negative")
+	}
+	if cfg.FlatlineStdDev, err = envFloat("FLATLINE_STD", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.FlatlineStdDev < 0 {
+		return cfg, errors.New("FLATLINE_STD must not be negative")
+	}
+	if cfg.DriftThreshold, err = envFloat("DRIFT_THRESHOLD", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.DriftThreshold < 0 {
+		return cfg, errors.New("DRIFT_THRESHOLD must not be negative")
+	}
+	if cfg.HysteresisMargin, err = envFloat("HYSTERESIS_MARGIN", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.HysteresisMargin < 0 {
+		return cfg, errors.New("HYSTERESIS_MARGIN must not be negative")
+	}
+	cfg.AlertWebhookURL = envString("ALERT_WEBHOOK_URL", "")
+	cfg.AlertBrandings = envList("ALERT_BRANDINGS", []string{sensors.HumiditySensorDiscard})
+	if cfg.FailOnDiscard, err = envBool("FAIL_ON_DISCARD", false); err != nil {
+		return cfg, err
+	}
+	if cfg.UseBaseline, err = envBool("USE_BASELINE", false); err != nil {
+		return cfg, err
+	}
+	if cfg.PreviousReference, err = envBool("PREVIOUS_REFERENCE", false); err != nil {
+		return cfg, err
+	}
+	if cfg.UseBaseline && cfg.PreviousReference {
+		return cfg, errors.New("USE_BASELINE and PREVIOUS_REFERENCE must not be used together")
+	}
+	if cfg.InheritReference, err = envBool("INHERIT_REFERENCE", false); err != nil {
+		return cfg, err
+	}
+	if cfg.ReferenceAnywhere, err = envBool("REFERENCE_ANYWHERE", false); err != nil {
+		return cfg, err
+	}
+	if cfg.StrictReference, err = envBool("STRICT_REFERENCE", false); err != nil {
+		return cfg, err
+	}
+	cfg.DefaultSensorType = envString("DEFAULT_SENSOR_TYPE", "")
+	if cfg.DefaultSensorType != "" && sensors.NewSensor(cfg.DefaultSensorType, "", sensors.Thresholds{}) == nil {
+		return cfg, errors.New(fmt.Sprintf("invalid value of DEFAULT_SENSOR_TYPE: unknown sensor type %q", cfg.DefaultSensorType))
+	}
+	cfg.DefaultSensorName = envString("DEFAULT_SENSOR_NAME", sensors.DefaultSensorName)
+	cfg.NamePolicy = envString("NAME_POLICY", sensors.NamePolicyNone)
+	switch cfg.NamePolicy {
+	case sensors.NamePolicyNone, sensors.NamePolicyReject, sensors.NamePolicySanitize:
+	default:
+		return cfg, errors.New(fmt.Sprintf("invalid value of NAME_POLICY: %q", cfg.NamePolicy))
+	}
+	if cfg.SensorFilter, err = sensors.ParseSensorFilter(envString("SENSOR_FILTER", "")); err != nil {
+		return cfg, errors.Wrap(err, "invalid value of SENSOR_FILTER")
+	}
+	cfg.EndMarker = envString("END_MARKER", "")
+	if cfg.IncompleteTimeout, err = envDuration("INCOMPLETE_TIMEOUT", defaultIncompleteTimeout); err != nil {
+		return cfg, err
+	}
+	if cfg.IncompleteTimeout <= 0 {
+		return cfg, errors.New("INCOMPLETE_TIMEOUT must be positive")
+	}
+	if cfg.MaxLineLength, err = envInt("MAX_LINE_LENGTH", sensors.DefaultMaxLineLength); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxLineLength <= 0 {
+		return cfg, errors.New("MAX_LINE_LENGTH must be positive")
+	}
+	cfg.ReadingOrder = envString("READING_ORDER", sensors.ReadingOrderTimeFirst)
+	switch cfg.ReadingOrder {
+	case sensors.ReadingOrderTimeFirst, sensors.ReadingOrderValueFirst, sensors.ReadingOrderAuto:
+	default:
+		return cfg, errors.New(fmt.Sprintf("invalid value of READING_ORDER: %q", cfg.ReadingOrder))
+	}
+	cfg.InputFormat = envString("INPUT_FORMAT", sensors.InputFormatText)
+	switch cfg.InputFormat {
+	case sensors.InputFormatText, sensors.InputFormatJSON, sensors.InputFormatAuto:
+	default:
+		return cfg, errors.New(fmt.Sprintf("invalid value of INPUT_FORMAT: %q", cfg.InputFormat))
+	}
+	cfg.NonFinitePolicy = envString("NON_FINITE_POLICY", sensors.NonFiniteReject)
+	switch cfg.NonFinitePolicy {
+	case sensors.NonFiniteReject, sensors.NonFiniteDrop, sensors.NonFiniteKeep:
+	default:
+		return cfg, errors.New(fmt.Sprintf("invalid value of NON_FINITE_POLICY: %q", cfg.NonFinitePolicy))
+	}
+	cfg.UnknownTypePolicy = envString("UNKNOWN_TYPE_POLICY", sensors.UnknownTypeReject)
+	switch cfg.UnknownTypePolicy {
+	case sensors.UnknownTypeReject, sensors.UnknownTypeSkip, sensors.UnknownTypeWarn:
+	default:
+		return cfg, errors.New(fmt.Sprintf("invalid value of UNKNOWN_TYPE_POLICY: %q", cfg.UnknownTypePolicy))
+	}
+	if cfg.NameMaxLength, err = envInt("NAME_MAX_LENGTH", sensors.DefaultNameMaxLength); err != nil {
+		return cfg, err
+	}
+	cfg.OutputFilter = envString("OUTPUT_FILTER", OutputFilterAll)
+	if cfg.OutputFilter != OutputFilterAll && cfg.OutputFilter != OutputFilterProblems {
+		return cfg, errors.New(fmt.Sprintf("invalid value of OUTPUT_FILTER: %q", cfg.OutputFilter))
+	}
+	cfg.OutputOrder = envString("OUTPUT_ORDER", OutputOrderName)
+	switch cfg.OutputOrder {
+	case OutputOrderName, OutputOrderFile, OutputOrderType:
+	default:
+		return cfg, errors.New(fmt.Sprintf("invalid value of OUTPUT_ORDER: %q", cfg.OutputOrder))
+	}
+	if cfg.IncludeReadings, err = envBool("INCLUDE_READINGS", false); err != nil {
+		return cfg, err
+	}
+	if cfg.IncludeConfidence, err = envBool("INCLUDE_CONFIDENCE", false); err != nil {
+		return cfg, err
+	}
+	if cfg.IncludeReference, err = envBool("INCLUDE_REFERENCE", false); err != nil {
+		return cfg, err
+	}
+	if cfg.StationSummary, err = envBool("STATION_SUMMARY", false); err != nil {
+		return cfg, err
+	}
+	if cfg.Anonymize, err = envBool("ANONYMIZE", false); err != nil {
+		return cfg, err
+	}
+	cfg.AnonymizeKey = envString("ANONYMIZE_KEY", "")
+	if cfg.Anonymize {
+		// without the key, anyone could hash the guessed names and compare
+		if cfg.AnonymizeKey == "" {
+			return cfg, errors.New("ANONYMIZE_KEY must be set for ANONYMIZE")
+		}
+		cfg.anonymizer = newAnonymizer(cfg.AnonymizeKey)
+	}
+	if cfg.ProcessingTimeout, err = envDuration("PROCESSING_TIMEOUT", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.ProcessingTimeout < 0 {
+		return cfg, errors.New("PROCESSING_TIMEOUT must not be negative")
+	}
+	cfg.OutputCompression = envString("OUTPUT_COMPRESSION", CompressionNone)
+	if cfg.OutputCompression != CompressionNone && cfg.OutputCompression != CompressionGzip {
+		return cfg, errors.New(fmt.Sprintf("invalid value of OUTPUT_COMPRESSION: %q", cfg.OutputCompression))
+	}
+	cfg.OutputSink = envString("OUTPUT_SINK", SinkStdout)
+	if _, err = newOutputSink(cfg.OutputSink, cfg.OutputCompression); err != nil {
+		return cfg, err
+	}
+	if manifestFile := envString("MANIFEST_FILE", ""); manifestFile != "" {
+		if cfg.Manifest, err = readManifest(manifestFile); err != nil {
+			return cfg, err
+		}
+	}
+	cfg.SourceType = envString("REMOTE_LOGS_FORMAT", SourceHTML)
+	if cfg.UnsortedListing, err = envBool("REMOTE_LOGS_UNSORTED", false); err != nil {
+		return cfg, err
+	}
+	cfg.ReferenceFile = envString("REFERENCE_FILE", "")
+	cfg.LogFileExtensions = envList("LOG_FILE_EXTENSIONS", nil)
+	for _, ext := range cfg.LogFileExtensions {
+		if !strings.HasPrefix(ext, ".") {
+			return cfg, errors.New(fmt.Sprintf("invalid value of LOG_FILE_EXTENSIONS: %q must start with a dot", ext))
+		}
+	}
+	cfg.SFTPPassword = envString("SFTP_PASSWORD", "")
+	cfg.SFTPKeyFile = envString("SFTP_KEY_FILE", "")
+	cfg.SFTPKnownHosts = envString("SFTP_KNOWN_HOSTS", "")
+	maxFileSize, err := envInt("MAX_FILE_SIZE", 0)
+	if err != nil {
+		return cfg, err
+	}
+	if maxFileSize < 0 {
+		return cfg, errors.New("MAX_FILE_SIZE must not be negative")
+	}
+	cfg.MaxFileSize = int64(maxFileSize)
+	if cfg.HTTPHeaders, err = envMap("HTTP_HEADERS"); err != nil {
+		return cfg, err
+	}
+	if cfg.RateLimit, err = envFloat("RATE_LIMIT", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.RateLimit < 0 {
+		return cfg, errors.New("RATE_LIMIT must not be negative")
+	}
+	if cfg.BreakerFailures, err = envInt("BREAKER_FAILURES", defaultBreakerFailures); err != nil {
+		return cfg, err
+	}
+	if cfg.BreakerFailures < 1 {
+		return cfg, errors.New("BREAKER_FAILURES must be positive")
+	}
+	if cfg.BreakerTimeout, err = envDuration("BREAKER_TIMEOUT", defaultBreakerTimeout); err != nil {
+		return cfg, err
+	}
+	if cfg.BreakerTimeout <= 0 {
+		return cfg, errors.New("BREAKER_TIMEOUT must be positive")
+	}
+	cfg.RedisOutage = envString("REDIS_OUTAGE", RedisOutageRetry)
+	switch cfg.RedisOutage {
+	case RedisOutageRetry, RedisOutageExit:
+	default:
+		return cfg, errors.New(fmt.Sprintf("invalid value of REDIS_OUTAGE: %q", cfg.RedisOutage))
+	}
+	if cfg.RedisBufferSize, err = envInt("REDIS_BUFFER_SIZE", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.RedisBufferSize < 0 {
+		return cfg, errors.New("REDIS_BUFFER_SIZE must not be negative")
+	}
+	if cfg.RedisMaxConcurrency, err = envInt("REDIS_MAX_CONCURRENCY", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.RedisMaxConcurrency < 0 {
+		return cfg, errors.New("REDIS_MAX_CONCURRENCY must not be negative")
+	}
+	if cfg.DownloadConcurrency, err = envInt("DOWNLOAD_CONCURRENCY", 1); err != nil {
+		return cfg, err
+	}
+	if cfg.DownloadConcurrency < 1 {
+		return cfg, errors.New("DOWNLOAD_CONCURRENCY must be at least 1")
+	}
+	cfg.BrandingIndex = envList("BRANDING_INDEX", nil)
+	for _, b := range cfg.BrandingIndex {
+		if !knownBranding(b) {
+			return cfg, errors.New(fmt.Sprintf("invalid value of BRANDING_INDEX: unknown branding %q", b))
+		}
+	}
+	if cfg.BrandingIndexRetention, err = envDuration("BRANDING_INDEX_RETENTION", defaultBrandingIndexRetention); err != nil {
+		return cfg, err
+	}
+	if cfg.BrandingIndexRetention <= 0 {
+		return cfg, errors.New("BRANDING_INDEX_RETENTION must be positive")
+	}
+	if cfg.BrandingHistory, err = envInt("BRANDING_HISTORY", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.BrandingHistory < 0 {
+		return cfg, errors.New("BRANDING_HISTORY must not be negative")
+	}
+	if cfg.HeartbeatInterval, err = envDuration("HEARTBEAT_INTERVAL", defaultHeartbeatInterval); err != nil {
+		return cfg, err
+	}
+	if cfg.HeartbeatInterval < 0 {
+		return cfg, errors.New("HEARTBEAT_INTERVAL must not be negative")
+	}
+	if cfg.RedisSlowThreshold, err = envDuration("REDIS_SLOW_THRESHOLD", defaultRedisSlowThreshold); err != nil {
+		return cfg, err
+	}
+	if cfg.RedisSlowThreshold < 0 {
+		return cfg, errors.New("REDIS_SLOW_THRESHOLD must not be negative")
+	}
+	return cfg, nil
+}
+
+// Return the value of environment variable, or the default one if the variable is not set
+func envString(name string, defaultValue string) string {
+	val, exists := os.LookupEnv(name)
+	if !exists {
+		return defaultValue
+	}
+	return val
+}
+
+// Return the comma separated values of environment variable, or the default ones if the variable is not set;
+// see splitList
+func envList(name string, defaultValue []string) []string {
+	val, exists := os.LookupEnv(name)
+	if !exists {
+		return defaultValue
+	}
+	return splitList(val)
+}
+
+// Split the comma separated list into its trimmed non-empty items; a comma escaped by backslash
+// belongs to the item, e.g. to the URL in http://example.com/results?sensors=1\,2
+func splitList(val string) []string {
+	ret := make([]string, 0)
+	var item strings.Builder
+	add := func() {
+		if s := strings.TrimSpace(item.String()); s != "" {
+			ret = append(ret, s)
+		}
+		item.Reset()
+	}
+	for i := 0; i < len(val); i++ {
+		switch {
+		case val[i] == '\\' && i+1 < len(val) && val[i+1] == ',':
+			item.WriteByte(',')
+			i++
+		case val[i] == ',':
+			add()
+		default:
+			item.WriteByte(val[i])
+		}
+	}
+	add()
+	return ret
+}
+
+// Return the comma separated key=value pairs of environment variable as a map
+func envMap(name string) (map[string]string, error) {
+	ret := make(map[string]string)
+	for _, item := range envList(name, nil) {
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, errors.New(fmt.Sprintf("invalid value of %s: %q is not a key=value pair", name, item))
+		}
+		ret[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return ret, nil
+}
+
+// Return the boolean value of environment variable, or the default one if the variable is not set
+func envBool(name string, defaultValue bool) (bool, error) {
+	return lookupBool(os.LookupEnv, name, defaultValue)
+}
+
+// Return the boolean value found by lookup, or the default one if there's none
+func lookupBool(lookup func(string) (string, bool), name string, defaultValue bool) (bool, error) {
+	val, exists := lookup(name)
+	if !exists {
+		return defaultValue, nil
+	}
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		return false, errors.Wrap(err, fmt.Sprintf("invalid value of %s", name))
+	}
+	return b, nil
+}
+
+// Return the float value of environment variable, or the default one if the variable is not set
+func envFloat(name string, defaultValue float64) (float64, error) {
+	return lookupFloat(os.LookupEnv, name, defaultValue)
+}
+
+// Return the float value found by lookup, or the default one if there's none
+func lookupFloat(lookup func(string) (string, bool), name string, defaultValue float64) (float64, error) {
+	val, exists := lookup(name)
+	if !exists {
+		return defaultValue, nil
+	}
+	f, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		return 0, errors.Wrap(err, fmt.Sprintf("invalid value of %s", name))
+	}
+	return f, nil
+}
+
+// Return the integer value of environment variable, or the default one if the variable is not set
+func envInt(name string, defaultValue int) (int, error) {
+	val, exists := os.LookupEnv(name)
+	if !exists {
+		return defaultValue, nil
+	}
+	i, err := strconv.Atoi(val)
+	if err != nil {
+		return 0, errors.Wrap(err, fmt.Sprintf("invalid value of %s", name))
+	}
+	return i, nil
+}
+
+// Return the duration value (e.g. 30s) of environment variable, or the default one if the variable is not set
+func envDuration(name string, defaultValue time.Duration) (time.Duration, error) {
+	val, exists := os.LookupEnv(name)
+	if !exists {
+		return defaultValue, nil
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		return 0, errors.Wrap(err, fmt.Sprintf("invalid value of %s", name))
+	}
+	return d, nil
+}
+
+// Read the branding thresholds from the environment variables
+func thresholdsFromEnv() (sensors.Thresholds, error) {
+	return thresholdsFrom(os.LookupEnv)
+}
+
+// Read the branding thresholds found by lookup under the names of their environment variables,
+// on top of the tolerances config given by TOLERANCES and the built-in profile given by PROFILE
+func thresholdsFrom(lookup func(string) (string, bool)) (t sensors.Thresholds, err error) {
+	// the profile is the base, the individual thresholds override it
+	if name, ok := lookup("PROFILE"); ok && name != "" {
+		if t, ok = sensors.Profile(name); !ok {
+			return t, errors.New(fmt.Sprintf("invalid value of PROFILE: unknown profile %q, expected one of %s",
+				name, strings.Join(sensors.ProfileNames(), ", ")))
+		}
+	}
+	if blob, ok := lookup("TOLERANCES"); ok && blob != "" {
+		if t, err = sensors.ParseTolerances([]byte(blob), t); err != nil {
+			return t, errors.Wrap(err, "invalid value of TOLERANCES")
+		}
+	}
+	floats := []struct {
+		name  string
+		value *float64
+	}{
+		{"THERMOMETER_MEAN_TOLERANCE", &t.MeanTolerance},
+		{"THERMOMETER_ULTRA_PRECISE_STD", &t.UltraPreciseStdDev},
+		{"THERMOMETER_VERY_PRECISE_STD", &t.VeryPreciseStdDev},
+		{"HUMIDITY_BAND", &t.HumidityBand},
+		{"FLOW_BAND", &t.FlowBand},
+		{"SOUND_LIMIT", &t.SoundLimit},
+		{"SOUND_EXCESSIVE_MARGIN", &t.SoundExcessiveMargin},
+	}
+	for _, f := range floats {
+		if *f.value, err = lookupFloat(lookup, f.name, *f.value); err != nil {
+			return t, err
+		}
+		if *f.value < 0 {
+			return t, errors.New(fmt.Sprintf("%s must not be negative", f.name))
+		}
+	}
+	bools := []struct {
+		name  string
+		value *bool
+	}{
+		{"THERMOMETER_MEAN_INCLUSIVE", &t.MeanInclusive},
+		{"THERMOMETER_ULTRA_PRECISE_INCLUSIVE", &t.UltraPreciseInclusive},
+		{"THERMOMETER_VERY_PRECISE_INCLUSIVE", &t.VeryPreciseInclusive},
+		{"HUMIDITY_ABSOLUTE", &t.HumidityAbsolute},
+		{"INVERT_HUMIDITY", &t.InvertHumidity},
+	}
+	for _, b := range bools {
+		if *b.value, err = lookupBool(lookup, b.name, *b.value); err != nil {
+			return t, err
+		}
+	}
+	return t, nil
+}
+
+// Read the decoders of raw reading values from READING_DECODERS, comma separated items
+// <sensor type>=<encoding>[:<scale>[:signed]], e.g. thermometer=hex:0.01:signed
+func decodersFromEnv() (map[string]sensors.Decoder, error) {
+	items, err := envMap("READING_DECODERS")
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[string]sensors.Decoder)
+	for sensorType, spec := range items {
+		parts := strings.Split(spec, ":")
+		d := sensors.Decoder{Encoding: parts[0]}
+		if d.Encoding != sensors.EncodingHex && d.Encoding != sensors.EncodingBase64 {
+			return nil, errors.New(fmt.Sprintf("invalid value of READING_DECODERS: unknown encoding %q", d.Encoding))
+		}
+		if len(parts) > 1 {
+			if d.Scale, err = strconv.ParseFloat(parts[1], 64); err != nil {
+				return nil, errors.Wrap(err, "invalid value of READING_DECODERS")
+			}
+		}
+		if len(parts) > 2 {
+			if parts[2] != "signed" || len(parts) > 3 {
+				return nil, errors.New(fmt.Sprintf("invalid value of READING_DECODERS: %q", spec))
+			}
+			d.Signed = true
+		}
+		ret[sensorType] = d
+	}
+	return ret, nil
+}
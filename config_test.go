@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"sensors/pkg/sensors"
+)
+
+func TestSplitList(t *testing.T) {
+	assertString(t, strings.Join(splitList(" a, b,,c "), "|"), "a|b|c")
+	assertString(t, strings.Join(splitList(`http://example.com/?ids=1\,2,stdout`), "|"), "http://example.com/?ids=1,2|stdout")
+	assertString(t, strings.Join(splitList(`a\b,c\`), "|"), `a\b|c\`)
+	assertInt(t, len(splitList("")), 0)
+}
+
+func TestEnvMap(t *testing.T) {
+	os.Setenv("TEST_HTTP_HEADERS", "User-Agent=sensors, X-Api-Key=a=b")
+	defer os.Unsetenv("TEST_HTTP_HEADERS")
+
+	headers, err := envMap("TEST_HTTP_HEADERS")
+	assertError(t, err, nil)
+	assertString(t, headers["User-Agent"], "sensors")
+	assertString(t, headers["X-Api-Key"], "a=b")
+
+	os.Setenv("TEST_HTTP_HEADERS", "User-Agent")
+	_, err = envMap("TEST_HTTP_HEADERS")
+	assertErrorMessageSubString(t, err, "not a key=value pair")
+}
+
+func TestDecodersFromEnv(t *testing.T) {
+	os.Setenv("READING_DECODERS", "thermometer=hex:0.01:signed, humidity=base64")
+	defer os.Unsetenv("READING_DECODERS")
+
+	decoders, err := decodersFromEnv()
+	assertError(t, err, nil)
+	assertInt(t, len(decoders), 2)
+	if d := decoders["thermometer"]; d.Encoding != "hex" || d.Scale != 0.01 || !d.Signed {
+		t.Errorf("got thermometer decoder %+v", d)
+	}
+	if d := decoders["humidity"]; d.Encoding != "base64" || d.Scale != 0 || d.Signed {
+		t.Errorf("got humidity decoder %+v", d)
+	}
+
+	for _, invalid := range []string{"thermometer=octal", "thermometer=hex:x", "thermometer=hex:1:unsigned"} {
+		os.Setenv("READING_DECODERS", invalid)
+		if _, err := decodersFromEnv(); err == nil {
+			t.Errorf("got no error for %q", invalid)
+		}
+	}
+}
+
+func TestThresholdsProfile(t *testing.T) {
+	env := map[string]string{"PROFILE": "strict", "THERMOMETER_MEAN_TOLERANCE": "0.3"}
+	lookup := func(name string) (string, bool) {
+		val, ok := env[name]
+		return val, ok
+	}
+	thresholds, err := thresholdsFrom(lookup)
+	assertError(t, err, nil)
+	strict, _ := sensors.Profile("strict")
+	// the individual setting overrides the profile, the rest comes from it
+	if thresholds.MeanTolerance != 0.3 || thresholds.UltraPreciseStdDev != strict.UltraPreciseStdDev || thresholds.FlowBand != strict.FlowBand {
+		t.Errorf("got thresholds %+v", thresholds)
+	}
+
+	env = map[string]string{"PROFILE": "lenient", "THERMOMETER_MEAN_INCLUSIVE": "false"}
+	thresholds, err = thresholdsFrom(lookup)
+	assertError(t, err, nil)
+	if thresholds.MeanInclusive || !thresholds.UltraPreciseInclusive {
+		t.Errorf("got thresholds %+v", thresholds)
+	}
+
+	env = map[string]string{"PROFILE": "paranoid"}
+	_, err = thresholdsFrom(lookup)
+	assertErrorMessageSubString(t, err, `unknown profile "paranoid"`)
+}
+
+func TestThresholdsTolerances(t *testing.T) {
+	env := map[string]string{
+		"PROFILE":    "strict",
+		"TOLERANCES": `{"thermometer": {"mean": 0.3}, "humidity": {"absolute": true}, "flow": {"band": 8}}`,
+		"FLOW_BAND":  "7",
+	}
+	lookup := func(name string) (string, bool) {
+		val, ok := env[name]
+		return val, ok
+	}
+	thresholds, err := thresholdsFrom(lookup)
+	assertError(t, err, nil)
+	strict, _ := sensors.Profile("strict")
+	// the config overrides the profile and the individual setting overrides the config
+	if thresholds.MeanTolerance != 0.3 || !thresholds.HumidityAbsolute || thresholds.FlowBand != 7 ||
+		thresholds.UltraPreciseStdDev != strict.UltraPreciseStdDev {
+		t.Errorf("got thresholds %+v", thresholds)
+	}
+
+	env = map[string]string{"TOLERANCES": `{"thermometer": {"mean": "0.3"}}`}
+	_, err = thresholdsFrom(lookup)
+	assertErrorMessageSubString(t, err, "invalid value of TOLERANCES")
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// syncWriter serializes the writes of several goroutines into the writer
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
+
+// result of downloading a log file ahead
+type fetchResult struct {
+	path string
+	err  error
+}
+
+// prefetcher downloads the log files of the backlog ahead of their processing, at most n at a time,
+// in the order of the processing; the processing then waits just for the file it needs. At most 2n files
+// are downloaded (or being downloaded) but not yet processed, so the backlog doesn't fill the disk ahead of
+// the processing. The failed downloads don't leave partial files behind, the sources remove them.
+type prefetcher struct {
+	source LogSource
+	// the result of each log file, created upfront so that it's safe to read concurrently
+	results map[string]chan fetchResult
+	// the position of each log file in the order of the processing
+	order map[string]int
+	done  chan struct{}
+	wg    sync.WaitGroup
+	// number of files downloaded ahead of the processing at most
+	ahead int
+	// signals that the processing moved on to the next files
+	fetched chan struct{}
+
+	// the progress of the downloads, shared by the goroutines, and the number of files the processing
+	// fetched or skipped, in the order of the processing
+	mu       sync.Mutex
+	progress *progress
+	consumed int
+}
+
+// Start downloading the log files into the directory
+func newPrefetcher(source LogSource, dir string, files []string, n int, p *progress) *prefetcher {
+	pf := &prefetcher{
+		source:   source,
+		results:  make(map[string]chan fetchResult, len(files)),
+		order:    make(map[string]int, len(files)),
+		done:     make(chan struct{}),
+		ahead:    2 * n,
+		fetched:  make(chan struct{}, 1),
+		progress: p,
+	}
+	for i, f := range files {
+		pf.results[f] = make(chan fetchResult, 1)
+		pf.order[f] = i
+	}
+	jobs := make(chan string)
+	go func() {
+		defer close(jobs)
+		for i, f := range files {
+			if !pf.waitAhead(i) {
+				return
+			}
+			select {
+			case jobs <- f:
+			case <-pf.done:
+				return
+			}
+		}
+	}()
+	for i := 0; i < n; i++ {
+		pf.wg.Add(1)
+		go func() {
+			defer pf.wg.Done()
+			for f := range jobs {
+				// ahead of the processing, outside of its trace
+				path, err := source.Fetch(context.Background(), f, dir)
+				if err == nil {
+					pf.mu.Lock()
+					pf.progress.step()
+					pf.mu.Unlock()
+				}
+				pf.results[f] <- fetchResult{path: path, err: err}
+			}
+		}()
+	}
+	return pf
+}
+
+// Wait until the file at the position in the order of the processing may be downloaded; false means
+// the prefetcher was closed
+func (pf *prefetcher) waitAhead(i int) bool {
+	for {
+		pf.mu.Lock()
+		ok := i < pf.consumed+pf.ahead
+		pf.mu.Unlock()
+		if ok {
+			return true
+		}
+		select {
+		case <-pf.fetched:
+		case <-pf.done:
+			return false
+		}
+	}
+}
+
+// Fetch returns the log file downloaded ahead, once its download is done; the files outside
+// of the backlog are fetched right away
+func (pf *prefetcher) Fetch(ctx context.Context, logFile, dir string) (string, error) {
+	ch, ok := pf.results[logFile]
+	if !ok {
+		return pf.source.Fetch(ctx, logFile, dir)
+	}
+	// the files before it won't be fetched any more, e.g. when they were processed meanwhile
+	pf.mu.Lock()
+	if i := pf.order[logFile]; i >= pf.consumed {
+		pf.consumed = i + 1
+	}
+	pf.mu.Unlock()
+	select {
+	case pf.fetched <- struct{}{}:
+	default:
+	}
+	r := <-ch
+	// for the next call, e.g. when the file is tried again
+	ch <- r
+	return r.path, r.err
+}
+
+// Stop starting new downloads and wait for the running ones, e.g. when the processing of the backlog
+// stopped on an error
+func (pf *prefetcher) close() {
+	close(pf.done)
+	pf.wg.Wait()
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Serve the listing of the files (newest first) with the log, each download taking a while; the files
+// in truncated are cut short. maxActive is the maximal number of downloads at the same time, requests
+// the number of all downloads.
+type slowRemoteDir struct {
+	files     []string
+	truncated map[string]bool
+
+	mu                          sync.Mutex
+	active, maxActive, requests int
+}
+
+func (d *slowRemoteDir) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/" {
+		fmt.Fprint(w, "<html><body>")
+		for _, f := range d.files {
+			fmt.Fprintf(w, `<a href="%s">%s</a>`, f, f)
+		}
+		fmt.Fprint(w, "</body></html>")
+		return
+	}
+	d.mu.Lock()
+	d.requests++
+	d.active++
+	if d.active > d.maxActive {
+		d.maxActive = d.active
+	}
+	d.mu.Unlock()
+	time.Sleep(20 * time.Millisecond)
+	d.mu.Lock()
+	d.active--
+	d.mu.Unlock()
+	if d.truncated[strings.TrimPrefix(r.URL.Path, "/")] {
+		// the client gets unexpected EOF
+		w.Header().Set("Content-Length", fmt.Sprint(len(tempUltraPrecise)+100))
+	}
+	fmt.Fprint(w, tempUltraPrecise)
+}
+
+func TestConcurrentDownloads(t *testing.T) {
+	files := []string{"log-6.txt", "log-5.txt", "log-4.txt", "log-3.txt", "log-2.txt", "log-1.txt"}
+	remote := &slowRemoteDir{files: files}
+	server := httptest.NewServer(remote)
+	defer server.Close()
+
+	w := newTestWorker(t, &htmlSource{client: newHTTPClient(nil, 0), dirURL: server.URL + "/"})
+	w.cfg.DownloadConcurrency = 3
+	logFiles, err := w.source.Unprocessed(w.cache)
+	assertError(t, err, nil)
+	assertError(t, w.processBacklog(context.Background(), logFiles), nil)
+
+	if remote.maxActive < 2 || remote.maxActive > 3 {
+		t.Errorf("got %d downloads at the same time, want 2 to 3", remote.maxActive)
+	}
+	// still processed from the oldest one
+	recent, _ := w.cache.List(recentFilesKey, maxRecentFiles)
+	assertString(t, strings.Join(recent, ","), strings.Join(files, ","))
+	out := w.out.(*bytes.Buffer).String()
+	assertSubString(t, out, "downloaded 6/6 log files (100%)")
+	assertSubString(t, out, "processed 6/6 log files (100%)")
+}
+
+func TestDownloadsAhead(t *testing.T) {
+	files := []string{"log-1.txt", "log-2.txt", "log-3.txt", "log-4.txt", "log-5.txt", "log-6.txt"}
+	remote := &slowRemoteDir{files: files}
+	server := httptest.NewServer(remote)
+	defer server.Close()
+
+	w := newTestWorker(t, &htmlSource{client: newHTTPClient(nil, 0), dirURL: server.URL + "/"})
+	pf := newPrefetcher(w.source, w.tmpDir, files, 1, newProgress(&syncWriter{w: w.out}, "downloaded", len(files), time.Hour))
+	defer pf.close()
+	requests := func() int {
+		remote.mu.Lock()
+		defer remote.mu.Unlock()
+		return remote.requests
+	}
+
+	// nothing is processed yet, so only 2 files are downloaded ahead
+	time.Sleep(200 * time.Millisecond)
+	assertInt(t, requests(), 2)
+	// processing the third file (the first two skipped) lets the files up to the fifth be downloaded
+	_, err := pf.Fetch(context.Background(), "log-3.txt", w.tmpDir)
+	assertError(t, err, nil)
+	time.Sleep(200 * time.Millisecond)
+	assertInt(t, requests(), 5)
+}
+
+func TestConcurrentDownloadFailure(t *testing.T) {
+	files := []string{"log-4.txt", "log-3.txt", "log-2.txt", "log-1.txt"}
+	remote := &slowRemoteDir{files: files, truncated: map[string]bool{"log-2.txt": true}}
+	server := httptest.NewServer(remote)
+	defer server.Close()
+
+	w := newTestWorker(t, &htmlSource{client: newHTTPClient(nil, 0), dirURL: server.URL + "/"})
+	w.cfg.DownloadConcurrency = 2
+	logFiles, err := w.source.Unprocessed(w.cache)
+	assertError(t, err, nil)
+	err = w.processBacklog(context.Background(), logFiles)
+	assertErrorMessageSubString(t, err, "Failed fetching latest log file")
+
+	// the newer files wait for the failed one
+	recent, _ := w.cache.List(recentFilesKey, maxRecentFiles)
+	assertString(t, strings.Join(recent, ","), "log-1.txt")
+	if _, err := os.Stat(filepath.Join(w.tmpDir, "log-2.txt")); !os.IsNotExist(err) {
+		t.Errorf("partial download of log-2.txt left behind: %v", err)
+	}
+}
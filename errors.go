@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DiscardedSensorsError is returned when failing on discarded sensors is enabled and some sensors
+// failed the quality control
+type DiscardedSensorsError struct {
+	// Sensors are the names of discarded sensors, sorted
+	Sensors []string
+}
+
+func (e *DiscardedSensorsError) Error() string {
+	return ErrSensorsDiscarded + ": " + strings.Join(e.Sensors, ", ")
+}
+
+// FileTooLargeError is returned when the downloaded log file exceeds the maximum size
+type FileTooLargeError struct {
+	URL     string
+	MaxSize int64
+}
+
+func (e *FileTooLargeError) Error() string {
+	return fmt.Sprintf("%s: %s is larger than %d bytes", ErrFileTooLarge, e.URL, e.MaxSize)
+}
+
+// SidecarReferenceError is returned when the sidecar reference file (REFERENCE_FILE) can't be read
+// for the log file without a reference line
+type SidecarReferenceError struct {
+	// Err is the reason, e.g. the failed request
+	Err error
+}
+
+func (e *SidecarReferenceError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *SidecarReferenceError) Unwrap() error {
+	return e.Err
+}
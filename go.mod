@@ -3,9 +3,21 @@ module sensors
 go 1.16
 
 require (
+	github.com/eclipse/paho.mqtt.golang v1.3.5
 	github.com/go-redis/redis v6.15.9+incompatible
+	github.com/hashicorp/golang-lru v0.5.4
 	github.com/pkg/errors v0.9.1
+	github.com/pkg/sftp v1.13.4
+	github.com/prometheus/client_golang v1.11.0
+	github.com/prometheus/client_model v0.2.0
+	github.com/sony/gobreaker v0.5.0
+	go.opentelemetry.io/otel v1.3.0
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp v1.3.0
+	go.opentelemetry.io/otel/sdk v1.3.0
+	go.opentelemetry.io/otel/trace v1.3.0
+	golang.org/x/crypto v0.0.0-20211108221036-ceb1ce70b4fa
 	golang.org/x/exp v0.0.0-20211105205138-14c72366447f // indirect
 	golang.org/x/net v0.0.0-20211105192438-b53810dc28af
+	golang.org/x/time v0.0.0-20211116232009-f0f3c7e86c11
 	gonum.org/v1/gonum v0.9.3
 )
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	defaultHeartbeatInterval = 30 * time.Second
+	// the heartbeat expires after this many missed intervals
+	heartbeatTTLIntervals = 3
+)
+
+// expiringStore is the cache that can store values expiring after the ttl, as REDIS can
+type expiringStore interface {
+	SetExpiring(key, value string, ttl time.Duration) error
+}
+
+func (c *redisCache) SetExpiring(key, value string, ttl time.Duration) error {
+	return redisError(c.rdb.Set(c.key(key), value, ttl).Err())
+}
+
+// heartbeat is the value of the heartbeat key of a worker
+type heartbeat struct {
+	Worker string    `json:"worker"`
+	Time   time.Time `json:"time"`
+}
+
+// Return the key of the heartbeat of the worker, under the prefix of the cache
+func heartbeatKey(workerID string) string {
+	return fmt.Sprintf("worker:%s:heartbeat", workerID)
+}
+
+// Return the id of this worker: WORKER_ID, or the host name and the process id
+func workerID() string {
+	if id := envString("WORKER_ID", ""); id != "" {
+		return id
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// Write the heartbeat of the worker every interval, until stop is closed; the key expires after
+// heartbeatTTLIntervals intervals, so that the external monitoring finds out the worker is dead
+// even when its HTTP port is unreachable
+func runHeartbeat(store expiringStore, workerID string, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		value, _ := json.Marshal(heartbeat{Worker: workerID, Time: time.Now().UTC()})
+		if err := store.SetExpiring(heartbeatKey(workerID), string(value), heartbeatTTLIntervals*interval); err != nil {
+			fmt.Printf("Error writing heartbeat: %s\n", err.Error())
+		}
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+)
+
+// expiringStore recording the writes
+type heartbeatStore struct {
+	mu     sync.Mutex
+	writes []string
+	keys   []string
+	ttl    time.Duration
+}
+
+func (s *heartbeatStore) SetExpiring(key, value string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = append(s.keys, key)
+	s.writes = append(s.writes, value)
+	s.ttl = ttl
+	return nil
+}
+
+func (s *heartbeatStore) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.writes)
+}
+
+func TestHeartbeat(t *testing.T) {
+	store := &heartbeatStore{}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		runHeartbeat(store, "worker-1", 10*time.Millisecond, stop)
+		close(done)
+	}()
+	deadline := time.Now().Add(time.Second)
+	for store.count() < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	close(stop)
+	<-done
+
+	if store.count() < 2 {
+		t.Fatalf("got %d heartbeats, want the heartbeat refreshed", store.count())
+	}
+	assertString(t, store.keys[0], "worker:worker-1:heartbeat")
+	assertString(t, store.keys[1], store.keys[0])
+	if store.ttl != 30*time.Millisecond {
+		t.Errorf("got ttl %s, want 3 intervals", store.ttl)
+	}
+	var first, second heartbeat
+	if err := json.Unmarshal([]byte(store.writes[0]), &first); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(store.writes[1]), &second); err != nil {
+		t.Fatal(err)
+	}
+	assertString(t, first.Worker, "worker-1")
+	if !second.Time.After(first.Time) {
+		t.Errorf("got heartbeat times %s and %s, want the later one refreshed", first.Time, second.Time)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// prefix of the keys with the branding history of each sensor, followed by the sensor name
+const historyKeyPrefix = "history:"
+
+// brandingEntry is one item of the branding history of a sensor
+type brandingEntry struct {
+	File     string `json:"file"`
+	Branding string `json:"branding"`
+}
+
+// brandingFlip is the sensor whose branding changed within its history
+type brandingFlip struct {
+	Sensor string
+	// brandings of the history, newest first
+	Brandings []string
+}
+
+// Prepend the brandings of the log file to the history of each sensor, capped at max entries, and return
+// the sensors whose history has different brandings, sorted by name. Processing the same log file again
+// doesn't add it twice.
+func recordBrandingHistory(cache Cache, fileName string, brandings map[string]string, max int) ([]brandingFlip, error) {
+	names := make([]string, 0, len(brandings))
+	for name := range brandings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	flips := make([]brandingFlip, 0)
+	for _, name := range names {
+		history, err := brandingHistory(cache, name, max)
+		if err != nil {
+			return flips, err
+		}
+		entry := brandingEntry{File: fileName, Branding: brandings[name]}
+		if len(history) == 0 || history[0].File != fileName {
+			value, err := json.Marshal(entry)
+			if err != nil {
+				return flips, errors.Wrap(err, "failed encoding branding history")
+			}
+			if err := cache.Prepend(historyKeyPrefix+name, string(value), max); err != nil {
+				return flips, errors.Wrap(err, "failed saving branding history of "+name)
+			}
+			history = append([]brandingEntry{entry}, history...)
+			if len(history) > max {
+				history = history[:max]
+			}
+		}
+		flip := brandingFlip{Sensor: name}
+		changed := false
+		for _, e := range history {
+			flip.Brandings = append(flip.Brandings, e.Branding)
+			changed = changed || e.Branding != entry.Branding
+		}
+		if changed {
+			flips = append(flips, flip)
+		}
+	}
+	return flips, nil
+}
+
+// Return up to max latest entries of the branding history of the sensor, newest first
+func brandingHistory(cache Cache, sensor string, max int) ([]brandingEntry, error) {
+	items, err := cache.List(historyKeyPrefix+sensor, max)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed reading branding history of "+sensor)
+	}
+	ret := make([]brandingEntry, 0, len(items))
+	for _, item := range items {
+		var e brandingEntry
+		if err := json.Unmarshal([]byte(item), &e); err != nil {
+			return nil, errors.Wrap(err, "invalid branding history of "+sensor)
+		}
+		ret = append(ret, e)
+	}
+	return ret, nil
+}
+
+// Describe the sensors whose branding changed recently, e.g.
+//
+//	log-2.txt: branding changed recently: temp-1 (precise, ultra precise)
+//
+// with the history of each sensor newest first; return empty string when there are none
+func historyReport(fileName string, flips []brandingFlip) string {
+	if len(flips) == 0 {
+		return ""
+	}
+	sensors := make([]string, 0, len(flips))
+	for _, f := range flips {
+		sensors = append(sensors, fmt.Sprintf("%s (%s)", f.Sensor, strings.Join(f.Brandings, ", ")))
+	}
+	return fmt.Sprintf("%s: branding changed recently: %s", fileName, strings.Join(sensors, "; "))
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBrandingHistory(t *testing.T) {
+	logs := map[string]string{
+		"log-1.txt": tempUltraPrecise,
+		"log-2.txt": "reference 70.0 45.0\nthermometer temp-1\n2007-04-05T22:00 60\n2007-04-05T22:01 80\n",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, logs[strings.TrimPrefix(r.URL.Path, "/")])
+	}))
+	defer server.Close()
+
+	w := newTestWorker(t, &htmlSource{client: newHTTPClient(nil, 0), dirURL: server.URL + "/"})
+	w.cfg.BrandingHistory = 3
+	out := &bytes.Buffer{}
+	w.out = out
+
+	// the first run has nothing to compare with
+	assertError(t, w.processFile("log-1.txt"), nil)
+	assertString(t, out.String(), "")
+	assertError(t, w.processFile("log-2.txt"), nil)
+	assertString(t, out.String(), "log-2.txt: branding changed recently: temp-1 (precise, ultra precise)\n")
+
+	history, err := brandingHistory(w.cache, "temp-1", 10)
+	assertError(t, err, nil)
+	assertInt(t, len(history), 2)
+	assertString(t, history[0].File, "log-2.txt")
+	assertString(t, history[1].Branding, "ultra precise")
+
+	// processing the file again doesn't add it to the history
+	flips, err := recordBrandingHistory(w.cache, "log-2.txt", map[string]string{"temp-1": "precise"}, 3)
+	assertError(t, err, nil)
+	assertInt(t, len(flips), 1)
+	history, _ = brandingHistory(w.cache, "temp-1", 10)
+	assertInt(t, len(history), 2)
+
+	// the old brandings fall out of the capped history
+	for _, f := range []string{"log-3.txt", "log-4.txt"} {
+		flips, err = recordBrandingHistory(w.cache, f, map[string]string{"temp-1": "precise"}, 3)
+		assertError(t, err, nil)
+	}
+	assertInt(t, len(flips), 0)
+	history, _ = brandingHistory(w.cache, "temp-1", 10)
+	assertInt(t, len(history), 3)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+
+	"golang.org/x/time/rate"
+)
+
+// headerTransport adds the configured headers to every request
+type headerTransport struct {
+	headers map[string]string
+	next    http.RoundTripper
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTripper must not modify the original request
+	req = req.Clone(req.Context())
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	return t.next.RoundTrip(req)
+}
+
+// rateLimitTransport delays the requests so that they don't exceed the limit of the limiter
+type rateLimitTransport struct {
+	limiter *rate.Limiter
+	next    http.RoundTripper
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.Wait(req.Context()); err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(req)
+}
+
+// Create the client used for all requests to the remote directory with log files.
+// rateLimit is the maximum number of requests per second, 0 means no limit.
+func newHTTPClient(headers map[string]string, rateLimit float64) *http.Client {
+	var transport http.RoundTripper = http.DefaultTransport
+	if len(headers) > 0 {
+		transport = &headerTransport{headers: headers, next: transport}
+	}
+	if rateLimit > 0 {
+		transport = &rateLimitTransport{limiter: rate.NewLimiter(rate.Limit(rateLimit), 1), next: transport}
+	}
+	return &http.Client{Transport: transport}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestHTTPHeaders(t *testing.T) {
+	headers := map[string]string{
+		"User-Agent": "sensors-test",
+		"X-Api-Key":  "secret",
+	}
+	missing := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for k, v := range headers {
+			if r.Header.Get(k) != v {
+				missing = append(missing, fmt.Sprintf("%s %s", r.URL.Path, k))
+			}
+		}
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<html><body><a href="log-1.txt">log-1.txt</a></body></html>`)
+		case "/log-1.txt":
+			fmt.Fprint(w, tempUltraPrecise)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tmpDir, err := ioutil.TempDir("", "sensor-logs")
+	if err != nil {
+		t.Fatal("Error creating temp directory")
+	}
+	defer os.RemoveAll(tmpDir)
+
+	client := newHTTPClient(headers, 0)
+	logFiles, err := getUprocessedLogFiles(client, server.URL+"/", newMemCache(), false, nil)
+	assertError(t, err, nil)
+	assertInt(t, len(logFiles), 1)
+
+	_, err = fetchLogFile(context.Background(), client, logFiles[0], server.URL, tmpDir, 0)
+	assertError(t, err, nil)
+
+	if len(missing) > 0 {
+		t.Errorf("headers missing in requests: %v", missing)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	times := make([]time.Time, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		times = append(times, time.Now())
+	}))
+	defer server.Close()
+
+	// 20 requests per second, i.e. one every 50ms; the first request goes through immediately
+	client := newHTTPClient(nil, 20)
+	for i := 0; i < 4; i++ {
+		resp, err := client.Get(server.URL)
+		assertError(t, err, nil)
+		resp.Body.Close()
+	}
+	assertInt(t, len(times), 4)
+	for i := 1; i < len(times); i++ {
+		// allow for some timer imprecision
+		if gap := times[i].Sub(times[i-1]); gap < 40*time.Millisecond {
+			t.Errorf("request %d came %s after the previous one, want at least 50ms", i, gap)
+		}
+	}
+}
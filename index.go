@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"sensors/pkg/sensors"
+)
+
+const (
+	brandingsPath = "/brandings"
+	// prefix of the sorted sets of the sensors with each indexed branding, followed by the branding;
+	// the sensors are scored by the date of their log file
+	brandingIndexKeyPrefix = "branding:"
+	// the index keeps the sensors of the log files dated up to this long ago by default
+	defaultBrandingIndexRetention = 30 * 24 * time.Hour
+)
+
+// brandingIndexEntry is the sensor of a log file in the branding index, with the date of the log file
+type brandingIndexEntry struct {
+	File   string    `json:"file"`
+	Sensor string    `json:"sensor"`
+	Time   time.Time `json:"time"`
+}
+
+// brandingIndexMember is the member of the sorted set of the branding index, the date is its score
+type brandingIndexMember struct {
+	File   string `json:"file"`
+	Sensor string `json:"sensor"`
+}
+
+// Tell if any sensor type can get the branding, see sensors.SensorTypes
+func knownBranding(branding string) bool {
+	for _, t := range sensors.SensorTypes() {
+		for _, b := range t.Brandings {
+			if b == branding {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Add the sensors of the log file with one of the indexed brandings to the branding index. The entries have
+// the date of the log file (from its name log-YYYYMMDD...), or the time of processing for other names;
+// a log file processed again only updates the date of its entries. The entries dated more than retention
+// before now are removed.
+func indexBrandings(cache Cache, fileName string, brandings map[string]string, indexed []string, retention time.Duration, now time.Time) error {
+	index := make(map[string]bool)
+	for _, b := range indexed {
+		index[b] = true
+	}
+	date, ok := logFileDate(fileName)
+	if !ok {
+		date = now
+	}
+	min := float64(now.Add(-retention).Unix())
+	names := make([]string, 0, len(brandings))
+	for name := range brandings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		branding := brandings[name]
+		if !index[branding] {
+			continue
+		}
+		member, err := json.Marshal(brandingIndexMember{File: fileName, Sensor: name})
+		if err != nil {
+			return errors.Wrap(err, "failed encoding branding index entry")
+		}
+		if err := cache.AddScored(brandingIndexKeyPrefix+branding, string(member), float64(date.Unix()), min); err != nil {
+			return errors.Wrap(err, "failed updating index of branding "+branding)
+		}
+	}
+	return nil
+}
+
+// Return the sensors with the branding in the log files since the time, newest first
+func queryBranding(cache Cache, branding string, since time.Time) ([]brandingIndexEntry, error) {
+	members, err := cache.RangeScored(brandingIndexKeyPrefix+branding, float64(since.Unix()))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed reading index of branding "+branding)
+	}
+	ret := make([]brandingIndexEntry, 0, len(members))
+	for _, m := range members {
+		var e brandingIndexMember
+		if err := json.Unmarshal([]byte(m.member), &e); err != nil {
+			return nil, errors.Wrap(err, "invalid index of branding "+branding)
+		}
+		ret = append(ret, brandingIndexEntry{File: e.File, Sensor: e.Sensor, Time: time.Unix(int64(m.score), 0).UTC()})
+	}
+	return ret, nil
+}
+
+// Serve the sensors with the branding of the path, /brandings/{branding}?since=168h, see queryBranding;
+// without since, all the indexed sensors are listed
+func listBranding(w http.ResponseWriter, r *http.Request, cache Cache) {
+	branding := strings.TrimPrefix(r.URL.Path, brandingsPath+"/")
+	if branding == "" {
+		http.Error(w, "missing branding, expected "+brandingsPath+"/{branding}", http.StatusNotFound)
+		return
+	}
+	var since time.Time
+	if s := r.URL.Query().Get("since"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid value of since: %q, expected duration like 168h", s), http.StatusBadRequest)
+			return
+		}
+		since = time.Now().Add(-d)
+	}
+	entries, err := queryBranding(cache, branding, since)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBrandingsEndpoint(t *testing.T) {
+	cache := newMemCache()
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	indexed := []string{"discard", "flatline"}
+	assertError(t, indexBrandings(cache, "log-20261001.txt", map[string]string{"hum-1": "discard", "temp-1": "precise"}, indexed, defaultBrandingIndexRetention, now), nil)
+	assertError(t, indexBrandings(cache, "log-20261013.txt", map[string]string{"hum-1": "discard", "hum-2": "discard"}, indexed, defaultBrandingIndexRetention, now), nil)
+	// processed again
+	assertError(t, indexBrandings(cache, "log-20261013.txt", map[string]string{"hum-1": "discard", "hum-2": "discard"}, indexed, defaultBrandingIndexRetention, now), nil)
+	assertError(t, indexBrandings(cache, "station.log", map[string]string{"hum-3": "discard"}, indexed, defaultBrandingIndexRetention, now.Add(-time.Hour)), nil)
+	// processed again later, only its date changes
+	assertError(t, indexBrandings(cache, "station.log", map[string]string{"hum-3": "discard"}, indexed, defaultBrandingIndexRetention, now), nil)
+
+	entries, err := queryBranding(cache, "discard", now.Add(-7*24*time.Hour))
+	assertError(t, err, nil)
+	j, _ := json.Marshal(entries)
+	assertString(t, string(j), `[{"file":"station.log","sensor":"hum-3","time":"2026-10-14T12:00:00Z"},`+
+		`{"file":"log-20261013.txt","sensor":"hum-2","time":"2026-10-13T00:00:00Z"},`+
+		`{"file":"log-20261013.txt","sensor":"hum-1","time":"2026-10-13T00:00:00Z"}]`)
+	// the branding not indexed
+	entries, _ = queryBranding(cache, "precise", time.Time{})
+	assertInt(t, len(entries), 0)
+
+	server := newServer(cache)
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest("GET", "/brandings/discard", nil))
+	assertInt(t, rec.Code, http.StatusOK)
+	assertString(t, rec.Header().Get("Content-Type"), "application/json")
+	var all []brandingIndexEntry
+	assertError(t, json.Unmarshal(rec.Body.Bytes(), &all), nil)
+	assertInt(t, len(all), 4)
+
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest("GET", "/brandings/discard?since=week", nil))
+	assertInt(t, rec.Code, http.StatusBadRequest)
+}
+
+func TestBrandingIndexUpdated(t *testing.T) {
+	server := newTestRemoteDir([]string{"log-1.txt"}, mixedSensors)
+	defer server.Close()
+	w := newTestWorker(t, &htmlSource{client: newHTTPClient(nil, 0), dirURL: server.URL + "/"})
+	w.cfg.BrandingIndex = []string{"discard"}
+	w.cfg.BrandingIndexRetention = defaultBrandingIndexRetention
+	assertError(t, w.processFile("log-1.txt"), nil)
+
+	entries, err := queryBranding(w.cache, "discard", time.Now().Add(-time.Hour))
+	assertError(t, err, nil)
+	assertInt(t, len(entries), 1)
+	assertString(t, entries[0].File, "log-1.txt")
+	assertString(t, entries[0].Sensor, "hum-2")
+}
+
+func TestBrandingIndexRetention(t *testing.T) {
+	cache := newMemCache()
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	indexed := []string{"discard"}
+	assertError(t, indexBrandings(cache, "log-20260801.txt", map[string]string{"hum-1": "discard"}, indexed, 30*24*time.Hour, now), nil)
+	assertError(t, indexBrandings(cache, "log-20261001.txt", map[string]string{"hum-1": "discard"}, indexed, 30*24*time.Hour, now), nil)
+
+	// the sensors of the old log files are dropped, whatever the since of the query
+	entries, err := queryBranding(cache, "discard", time.Time{})
+	assertError(t, err, nil)
+	assertInt(t, len(entries), 1)
+	assertString(t, entries[0].File, "log-20261001.txt")
+
+	// the sensors of many log files are all listed
+	brandings := make(map[string]string)
+	for i := 0; i < 2000; i++ {
+		brandings[fmt.Sprintf("hum-%d", i)] = "discard"
+	}
+	for day := 8; day <= 13; day++ {
+		fileName := fmt.Sprintf("log-202610%02d.txt", day)
+		assertError(t, indexBrandings(cache, fileName, brandings, indexed, 30*24*time.Hour, now), nil)
+	}
+	entries, err = queryBranding(cache, "discard", now.Add(-7*24*time.Hour))
+	assertError(t, err, nil)
+	assertInt(t, len(entries), 12000)
+	assertString(t, entries[0].File, "log-20261013.txt")
+}
+
+func TestUnknownBranding(t *testing.T) {
+	if !knownBranding("discard") || !knownBranding("drifting up") {
+		t.Error("known branding not recognized")
+	}
+	if knownBranding("discarded") {
+		t.Error("unknown branding recognized")
+	}
+}
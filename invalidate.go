@@ -0,0 +1,138 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// number of keys asked for by one SCAN of REDIS
+const scanCount = 1000
+
+// keyStore is the cache that can find and delete its keys, as needed by the invalidate subcommand
+type keyStore interface {
+	// Keys returns the keys matching the glob pattern (*, ? and [...] with the REDIS semantics)
+	Keys(pattern string) ([]string, error)
+	// Delete removes the keys, the missing ones are ignored
+	Delete(keys ...string) error
+	// Remove removes all the occurrences of the value from the list stored under the key
+	Remove(key, value string) error
+}
+
+// Escape the glob special characters of the REDIS key prefix, so it matches only itself
+func escapeGlob(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+	return r.Replace(s)
+}
+
+func (c *redisCache) Keys(pattern string) ([]string, error) {
+	ret := make([]string, 0)
+	var cursor uint64
+	for {
+		keys, next, err := c.rdb.Scan(cursor, escapeGlob(c.prefix)+pattern, scanCount).Result()
+		if err != nil {
+			return nil, redisError(err)
+		}
+		for _, k := range keys {
+			ret = append(ret, strings.TrimPrefix(k, c.prefix))
+		}
+		if next == 0 {
+			return ret, nil
+		}
+		cursor = next
+	}
+}
+
+func (c *redisCache) Delete(keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	prefixed := make([]string, len(keys))
+	for i, k := range keys {
+		prefixed[i] = c.key(k)
+	}
+	return redisError(c.rdb.Del(prefixed...).Err())
+}
+
+func (c *redisCache) Remove(key, value string) error {
+	return redisError(c.rdb.LRem(c.key(key), 0, value).Err())
+}
+
+// Delete the stored results (the processed markers) and the result hashes of the log files matching
+// any of the patterns, the file names or glob patterns like log-202111*, and remove them from the recent
+// files; with dryRun, only list them. Return the invalidated log files, sorted.
+func invalidate(store keyStore, patterns []string, dryRun bool) ([]string, error) {
+	matched := make(map[string]bool)
+	for _, p := range patterns {
+		keys, err := store.Keys(p)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed listing keys matching "+p)
+		}
+		for _, k := range keys {
+			// other keys (recent files, baselines, ...) aren't markers of log files
+			if strings.HasPrefix(k, logFilePrefix) {
+				matched[k] = true
+			}
+		}
+	}
+	files := make([]string, 0, len(matched))
+	for f := range matched {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	if dryRun {
+		return files, nil
+	}
+	for _, f := range files {
+		if err := store.Delete(f, resultHashKeyPrefix+f); err != nil {
+			return nil, errors.Wrap(err, "failed deleting result of "+f)
+		}
+		if err := store.Remove(recentFilesKey, f); err != nil {
+			return nil, errors.Wrap(err, "failed updating list of recent files")
+		}
+	}
+	return files, nil
+}
+
+// invalidate subcommand: make the log files matching the patterns unprocessed, so that the worker
+// processes them again; the endpoint of a running worker keeps serving the old results it read
+// recently for up to RESULTS_CACHE_TTL
+func runInvalidate(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("invalidate", flag.ContinueOnError)
+	flags.SetOutput(out)
+	dryRun := flags.Bool("dry-run", false, "only list the log files that would be invalidated")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return errors.New("usage: sensors invalidate [-dry-run] FILE|PATTERN...")
+	}
+	rdb := getRedis()
+	if _, err := rdb.Ping().Result(); err != nil {
+		return errors.Wrap(err, "Error connecting to REDIS")
+	}
+	cache := newRedisCache(rdb, redisKeyPrefix(envString("REDIS_KEY_PREFIX", ""), os.Getenv("REMOTE_LOGS_DIR")))
+	return printInvalidated(cache, flags.Args(), *dryRun, out)
+}
+
+// Invalidate the log files and report them
+func printInvalidated(store keyStore, patterns []string, dryRun bool, out io.Writer) error {
+	files, err := invalidate(store, patterns, dryRun)
+	if err != nil {
+		return err
+	}
+	action, summary := "invalidated", "invalidated"
+	if dryRun {
+		action, summary = "would invalidate", "would be invalidated"
+	}
+	for _, f := range files {
+		fmt.Fprintf(out, "%s %s\n", action, f)
+	}
+	fmt.Fprintf(out, "%d log files %s\n", len(files), summary)
+	return nil
+}
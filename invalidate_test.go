@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func (c *memCache) Keys(pattern string) ([]string, error) {
+	ret := make([]string, 0)
+	for k := range c.values {
+		if redisMatch(pattern, k) {
+			ret = append(ret, k)
+		}
+	}
+	for k := range c.lists {
+		if redisMatch(pattern, k) {
+			ret = append(ret, k)
+		}
+	}
+	return ret, nil
+}
+
+func (c *memCache) Delete(keys ...string) error {
+	for _, k := range keys {
+		delete(c.values, k)
+		delete(c.lists, k)
+	}
+	return nil
+}
+
+func (c *memCache) Remove(key, value string) error {
+	list := make([]string, 0, len(c.lists[key]))
+	for _, v := range c.lists[key] {
+		if v != value {
+			list = append(list, v)
+		}
+	}
+	c.lists[key] = list
+	return nil
+}
+
+// Tell if the key matches the glob pattern the way REDIS matches them (stringmatchlen): * and ? match
+// any characters including /, [...] has ranges and ^ negates it, \ escapes the next character
+func redisMatch(pattern, key string) bool {
+	for len(pattern) > 0 && len(key) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for ; len(key) > 0; key = key[1:] {
+				if redisMatch(pattern[1:], key) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			key = key[1:]
+		case '[':
+			pattern = pattern[1:]
+			not := len(pattern) > 0 && pattern[0] == '^'
+			if not {
+				pattern = pattern[1:]
+			}
+			match := false
+			for len(pattern) > 0 && pattern[0] != ']' {
+				switch {
+				case pattern[0] == '\\' && len(pattern) >= 2:
+					pattern = pattern[1:]
+					match = match || pattern[0] == key[0]
+				case len(pattern) >= 3 && pattern[1] == '-':
+					start, end := pattern[0], pattern[2]
+					if start > end {
+						start, end = end, start
+					}
+					match = match || (key[0] >= start && key[0] <= end)
+					pattern = pattern[2:]
+				default:
+					match = match || pattern[0] == key[0]
+				}
+				pattern = pattern[1:]
+			}
+			// the closing ] is skipped below, an unterminated [ ends with the pattern
+			if not {
+				match = !match
+			}
+			if !match {
+				return false
+			}
+			key = key[1:]
+		default:
+			if pattern[0] == '\\' && len(pattern) >= 2 {
+				pattern = pattern[1:]
+			}
+			if pattern[0] != key[0] {
+				return false
+			}
+			key = key[1:]
+		}
+		if len(pattern) > 0 {
+			pattern = pattern[1:]
+		}
+		if len(key) == 0 {
+			pattern = strings.TrimLeft(pattern, "*")
+		}
+	}
+	return len(pattern) == 0 && len(key) == 0
+}
+
+// Create the cache with the results of the log files
+func newProcessedCache(t *testing.T, files ...string) *memCache {
+	cache := newMemCache()
+	for _, f := range files {
+		assertError(t, storeResult(cache, f, "{}"), nil)
+		assertError(t, storeResultHash(cache, f, "abc"), nil)
+	}
+	return cache
+}
+
+func TestInvalidate(t *testing.T) {
+	t.Run("exact name", func(t *testing.T) {
+		cache := newProcessedCache(t, "log-20211105.txt", "log-20211106.txt")
+		var out bytes.Buffer
+		assertError(t, printInvalidated(cache, []string{"log-20211105.txt"}, false, &out), nil)
+		assertString(t, out.String(), "invalidated log-20211105.txt\n1 log files invalidated\n")
+
+		_, err := cache.Get("log-20211105.txt")
+		assertError(t, err, ErrCacheMiss)
+		_, err = cache.Get(resultHashKeyPrefix + "log-20211105.txt")
+		assertError(t, err, ErrCacheMiss)
+		_, err = cache.Get("log-20211106.txt")
+		assertError(t, err, nil)
+		_, err = cache.Get(resultHashKeyPrefix + "log-20211106.txt")
+		assertError(t, err, nil)
+		recent, _ := cache.List(recentFilesKey, maxRecentFiles)
+		assertString(t, strings.Join(recent, ","), "log-20211106.txt")
+	})
+
+	t.Run("pattern", func(t *testing.T) {
+		cache := newProcessedCache(t, "log-20211105.txt", "log-20211106.txt", "log-20211201.txt")
+		assertError(t, cache.Set("baseline:thermometer:temp-1", "{}"), nil)
+		files, err := invalidate(cache, []string{"log-202111*", "log-2021120?.txt"}, false)
+		assertError(t, err, nil)
+		assertString(t, strings.Join(files, ","), "log-20211105.txt,log-20211106.txt,log-20211201.txt")
+		assertInt(t, len(cache.values), 1)
+
+		// keys other than the log file markers stay, even when matched
+		files, err = invalidate(cache, []string{"*"}, false)
+		assertError(t, err, nil)
+		assertInt(t, len(files), 0)
+		_, err = cache.Get("baseline:thermometer:temp-1")
+		assertError(t, err, nil)
+		_, err = cache.Get(resultHashKeyPrefix + "log-20211105.txt")
+		assertError(t, err, ErrCacheMiss)
+	})
+
+	t.Run("pattern across path segments", func(t *testing.T) {
+		// like REDIS, * matches / as well
+		cache := newProcessedCache(t, "log-archive/20211105.txt", "log-20211106.txt")
+		files, err := invalidate(cache, []string{"log-*.txt"}, false)
+		assertError(t, err, nil)
+		assertString(t, strings.Join(files, ","), "log-20211106.txt,log-archive/20211105.txt")
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		cache := newProcessedCache(t, "log-20211105.txt", "log-20211106.txt")
+		var out bytes.Buffer
+		assertError(t, printInvalidated(cache, []string{"log-*"}, true, &out), nil)
+		assertString(t, out.String(), `would invalidate log-20211105.txt
+would invalidate log-20211106.txt
+2 log files would be invalidated
+`)
+		assertInt(t, len(cache.values), 4)
+		recent, _ := cache.List(recentFilesKey, maxRecentFiles)
+		assertInt(t, len(recent), 2)
+	})
+
+	t.Run("no match", func(t *testing.T) {
+		cache := newProcessedCache(t, "log-20211105.txt")
+		files, err := invalidate(cache, []string{"log-2020*"}, false)
+		assertError(t, err, nil)
+		assertInt(t, len(files), 0)
+		assertInt(t, len(cache.values), 2)
+	})
+}
+
+func TestRedisMatch(t *testing.T) {
+	cases := []struct {
+		pattern, key string
+		want         bool
+	}{
+		{"log-*", "log-1.txt", true},
+		{"log-*", "log-a/b.txt", true},
+		{"log-?.txt", "log-1.txt", true},
+		{"log-?.txt", "log-12.txt", false},
+		{"log-[12].txt", "log-2.txt", true},
+		{"log-[^12].txt", "log-2.txt", false},
+		{"log-[0-9].txt", "log-5.txt", true},
+		{"log-[9-0].txt", "log-5.txt", true},
+		{"log-[a-z].txt", "log-5.txt", false},
+		{`log-\*`, "log-*", true},
+		{`log-\*`, "log-1", false},
+		{"log-[", "log-[", false},
+		{"log-[1", "log-1", true},
+		{"log-1*", "log-1", true},
+		{"*", "", false},
+		{"log-1.txt", "log-1.txt.gz", false},
+	}
+	for _, c := range cases {
+		if got := redisMatch(c.pattern, c.key); got != c.want {
+			t.Errorf("got %v matching %q with %q, want %v", got, c.key, c.pattern, c.want)
+		}
+	}
+}
+
+func TestEscapeGlob(t *testing.T) {
+	assertString(t, escapeGlob("sensors:example.com/logs:"), "sensors:example.com/logs:")
+	assertString(t, escapeGlob(`a*b?c[d]\`), `a\*b\?c\[d\]\\`)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"sensors/pkg/sensors"
+)
+
+// Read the manifest of expected sensors: one sensor name per line, empty lines and lines
+// starting with # are ignored
+func readManifest(filePath string) ([]string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed opening manifest")
+	}
+	defer file.Close()
+
+	ret := make([]string, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ret = append(ret, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed reading manifest")
+	}
+	return ret, nil
+}
+
+// Compare the sensors of the result with the manifest; return the expected sensors missing
+// in the result and the sensors of the result not in the manifest, both sorted
+func checkManifest(manifest []string, res *sensors.Result) (missing, unexpected []string) {
+	expected := make(map[string]bool)
+	for _, name := range manifest {
+		expected[name] = true
+	}
+	found := make(map[string]bool)
+	unexpected = make([]string, 0)
+	for _, s := range res.Sensors {
+		if !expected[s.Name] && !found[s.Name] {
+			unexpected = append(unexpected, s.Name)
+		}
+		found[s.Name] = true
+	}
+	missing = make([]string, 0)
+	for name := range expected {
+		if !found[name] {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(unexpected)
+	return missing, unexpected
+}
+
+// Describe the differences between the sensors of the log file and the manifest; return empty
+// string when the log file matches it
+func manifestReport(fileName string, manifest []string, res *sensors.Result) string {
+	missing, unexpected := checkManifest(manifest, res)
+	problems := make([]string, 0, 2)
+	if len(missing) > 0 {
+		problems = append(problems, "missing sensors "+strings.Join(missing, ", "))
+	}
+	if len(unexpected) > 0 {
+		problems = append(problems, "unexpected sensors "+strings.Join(unexpected, ", "))
+	}
+	if len(problems) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s does not match the manifest: %s", fileName, strings.Join(problems, "; "))
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestReadManifest(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "manifest")
+	if err != nil {
+		t.Fatal("Error creating manifest file")
+	}
+	defer os.Remove(tmpFile.Name())
+	if err := writeTestLogFile(tmpFile, "# station 1\ntemp-1\n\n  hum-1\n"); err != nil {
+		t.Fatal("Error writing manifest file")
+	}
+	manifest, err := readManifest(tmpFile.Name())
+	assertError(t, err, nil)
+	assertString(t, strings.Join(manifest, ","), "temp-1,hum-1")
+
+	_, err = readManifest("nofile.txt")
+	assertErrorMessageSubString(t, err, "failed opening manifest")
+}
+
+func TestManifest(t *testing.T) {
+	files := []string{"log-1.txt"}
+	server := newTestRemoteDir(files, tempUltraPrecise)
+	defer server.Close()
+
+	t.Run("missing sensor", func(t *testing.T) {
+		w := newTestWorker(t, &htmlSource{client: newHTTPClient(nil, 0), dirURL: server.URL + "/"})
+		w.cfg.Manifest = []string{"temp-1", "temp-2", "hum-1"}
+		err := w.processFile("log-1.txt")
+		assertError(t, err, nil)
+		assertString(t, strings.TrimSpace(w.out.(*bytes.Buffer).String()),
+			"log-1.txt does not match the manifest: missing sensors hum-1, temp-2")
+	})
+
+	t.Run("unexpected sensor", func(t *testing.T) {
+		w := newTestWorker(t, &htmlSource{client: newHTTPClient(nil, 0), dirURL: server.URL + "/"})
+		w.cfg.Manifest = []string{"hum-1"}
+		err := w.processFile("log-1.txt")
+		assertError(t, err, nil)
+		assertString(t, strings.TrimSpace(w.out.(*bytes.Buffer).String()),
+			"log-1.txt does not match the manifest: missing sensors hum-1; unexpected sensors temp-1")
+	})
+
+	t.Run("matching", func(t *testing.T) {
+		w := newTestWorker(t, &htmlSource{client: newHTTPClient(nil, 0), dirURL: server.URL + "/"})
+		w.cfg.Manifest = []string{"temp-1"}
+		err := w.processFile("log-1.txt")
+		assertError(t, err, nil)
+		assertString(t, w.out.(*bytes.Buffer).String(), "")
+	})
+}
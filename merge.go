@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+
+	"sensors/pkg/sensors"
+)
+
+// merge subcommand: print the combined branding of sensors from the log files given as arguments
+func runMerge(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("usage: sensors merge FILE...")
+	}
+	cfg, err := configFromEnv()
+	if err != nil {
+		return errors.Wrap(err, "Error reading configuration")
+	}
+	res, err := sensors.MergeLogFiles(args, cfg.Options)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, formatResult(res, cfg))
+	return nil
+}
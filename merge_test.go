@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+const mergeFirstDay = `reference 100 45
+thermometer temp-1
+2007-04-05T22:00 100
+2007-04-05T22:01 104
+humidity hum-1
+2007-04-05T22:00 45.1`
+
+const mergeSecondDay = `reference 100 45
+thermometer temp-1
+2007-04-06T22:00 96
+2007-04-06T22:01 100`
+
+func TestMergeCommand(t *testing.T) {
+	files := make([]string, 0)
+	for _, content := range []string{mergeFirstDay, mergeSecondDay} {
+		tmpFile, err := ioutil.TempFile("", "sensors")
+		if err != nil {
+			t.Fatal("Error creating test log file")
+		}
+		defer os.Remove(tmpFile.Name())
+		if err := writeTestLogFile(tmpFile, content); err != nil {
+			t.Fatal("Error writing test log file")
+		}
+		files = append(files, tmpFile.Name())
+	}
+
+	t.Run("merged", func(t *testing.T) {
+		var out bytes.Buffer
+		err := run(append([]string{"merge"}, files...), &out)
+		assertError(t, err, nil)
+		assertSubString(t, out.String(), `"temp-1": "very precise"`)
+	})
+
+	t.Run("usage", func(t *testing.T) {
+		err := run([]string{"merge"}, ioutil.Discard)
+		assertErrorMessageSubString(t, err, "usage")
+	})
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// path of the Prometheus metrics on the HTTP server
+const metricsPath = "/metrics"
+
+var (
+	breakerState = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "sensors_remote_breaker_state",
+		Help: "State of the circuit breaker around the remote server: 0 closed, 1 half-open, 2 open.",
+	})
+	breakerTrips = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "sensors_remote_breaker_trips_total",
+		Help: "Number of times the circuit breaker around the remote server opened.",
+	})
+	redisLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "sensors_redis_operation_duration_seconds",
+		Help: "Duration of the REDIS operations, by the cache operation (get, set, prepend, list, zadd, zrange).",
+		// from 0.1 ms, REDIS usually answers in less than a millisecond
+		Buckets: prometheus.ExponentialBuckets(0.0001, 4, 10),
+	}, []string{"operation"})
+)
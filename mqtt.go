@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/pkg/errors"
+
+	"sensors/pkg/sensors"
+)
+
+const (
+	defaultMQTTTopic       = "sensors/#"
+	defaultMQTTResultTopic = "sensor-results"
+	defaultMQTTWindow      = time.Minute
+	mqttClientID           = "sensors"
+	mqttQoS                = 1
+)
+
+// mqttClient is the part of MQTT client used by mqttConsumer; having an interface here makes it
+// possible to test the consumer without MQTT broker
+type mqttClient interface {
+	Subscribe(topic string, handler func(topic string, payload []byte)) error
+	Publish(topic string, payload []byte) error
+}
+
+// pahoClient is mqttClient connected to MQTT broker
+type pahoClient struct {
+	client mqtt.Client
+}
+
+// Connect to the MQTT broker, given by URL like tcp://localhost:1883
+func newPahoClient(broker string) (*pahoClient, error) {
+	opts := mqtt.NewClientOptions().AddBroker(broker).SetClientID(mqttClientID).SetAutoReconnect(true)
+	client := mqtt.NewClient(opts)
+	if token := client.Connect(); token.Wait() && token.Error() != nil {
+		return nil, errors.Wrap(token.Error(), "failed connecting to MQTT broker "+broker)
+	}
+	return &pahoClient{client: client}, nil
+}
+
+func (c *pahoClient) Subscribe(topic string, handler func(topic string, payload []byte)) error {
+	token := c.client.Subscribe(topic, mqttQoS, func(_ mqtt.Client, m mqtt.Message) {
+		handler(m.Topic(), m.Payload())
+	})
+	token.Wait()
+	return errors.Wrap(token.Error(), "failed subscribing to "+topic)
+}
+
+func (c *pahoClient) Publish(topic string, payload []byte) error {
+	token := c.client.Publish(topic, mqttQoS, false, payload)
+	token.Wait()
+	return errors.Wrap(token.Error(), "failed publishing to "+topic)
+}
+
+// mqttConsumer collects the live readings of sensors and brands them once per window, the same
+// way as the sensors of a log file. The readings are published to the topics
+//
+//	<prefix>/<sensor type>/<sensor name>   with payload <timestamp> <value>
+//	<prefix>/reference                     with payload <temperature> <humidity> [<flow>]
+//
+// i.e. the lines of the log file without their labels. The reference stays valid for the
+// following windows, until a new one comes.
+type mqttConsumer struct {
+	client      mqttClient
+	cfg         Config
+	resultTopic string
+	out         io.Writer
+
+	mu        sync.Mutex
+	reference string
+	// reading lines of the current window, by the sensor header
+	readings map[string][]string
+	// sensor headers in the order of their first reading in the window
+	headers []string
+}
+
+func newMQTTConsumer(client mqttClient, cfg Config, resultTopic string, out io.Writer) *mqttConsumer {
+	return &mqttConsumer{
+		client:      client,
+		cfg:         cfg,
+		resultTopic: resultTopic,
+		out:         out,
+		readings:    make(map[string][]string),
+	}
+}
+
+// Buffer the message with the reading or the reference
+func (c *mqttConsumer) handle(topic string, payload []byte) {
+	parts := strings.Split(topic, "/")
+	value := strings.TrimSpace(string(payload))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if parts[len(parts)-1] == sensors.ReferenceLabel {
+		// an invalid reference would fail all the following windows
+		reference := sensors.ReferenceLabel + " " + value
+		if strings.ContainsAny(value, "\r\n") {
+			fmt.Fprintf(c.out, "Ignoring MQTT message from %s: invalid reference %q\n", topic, value)
+			return
+		}
+		if _, err := sensors.ReadReference(strings.NewReader(reference)); err != nil {
+			fmt.Fprintf(c.out, "Ignoring MQTT message from %s: %s\n", topic, err.Error())
+			return
+		}
+		c.reference = reference
+		return
+	}
+	if len(parts) < 2 {
+		fmt.Fprintf(c.out, "Ignoring MQTT message from %s: topic has no sensor type\n", topic)
+		return
+	}
+	sensorType, name := parts[len(parts)-2], parts[len(parts)-1]
+	if sensors.NewSensor(sensorType, name, sensors.Thresholds{}) == nil {
+		fmt.Fprintf(c.out, "Ignoring MQTT message from %s: unknown sensor type %q\n", topic, sensorType)
+		return
+	}
+	name, ok := headerName(name)
+	if !ok {
+		fmt.Fprintf(c.out, "Ignoring MQTT message from %s: invalid sensor name\n", topic)
+		return
+	}
+	// a malformed reading would fail the whole window
+	if len(strings.Split(value, " ")) != 2 || strings.ContainsAny(value, "\r\n") {
+		fmt.Fprintf(c.out, "Ignoring MQTT message from %s: invalid reading %q\n", topic, value)
+		return
+	}
+	header := sensorType + " " + name
+	if _, ok := c.readings[header]; !ok {
+		c.headers = append(c.headers, header)
+	}
+	c.readings[header] = append(c.readings[header], value)
+}
+
+// Return the sensor name of the topic as it's written on the sensor header, quoted when it has spaces;
+// ok is false for the names the header can't have
+func headerName(name string) (string, bool) {
+	if name == "" || strings.ContainsAny(name, "\"\r\n") {
+		return "", false
+	}
+	if strings.Contains(name, " ") {
+		return `"` + name + `"`, true
+	}
+	return name, true
+}
+
+// Brand the sensors with the readings of the current window and publish the result;
+// the next window starts empty
+func (c *mqttConsumer) flush() error {
+	c.mu.Lock()
+	if len(c.headers) == 0 {
+		c.mu.Unlock()
+		return nil
+	}
+	var log strings.Builder
+	if c.reference != "" {
+		log.WriteString(c.reference + "\n")
+	}
+	for _, header := range c.headers {
+		log.WriteString(header + "\n")
+		for _, r := range c.readings[header] {
+			log.WriteString(r + "\n")
+		}
+	}
+	c.readings = make(map[string][]string)
+	c.headers = nil
+	c.mu.Unlock()
+
+	var processed string
+	res, err := sensors.ProcessReader(strings.NewReader(log.String()), c.cfg.Options)
+	if err != nil {
+		fmt.Fprintf(c.out, "Error processing MQTT readings: %s\n", err.Error())
+		processed = err.Error()
+	} else {
+		processed = formatResult(res, c.cfg)
+	}
+	return c.client.Publish(c.resultTopic, []byte(processed))
+}
+
+// Consume the readings from the topic, brand them every window until stopped
+func (c *mqttConsumer) run(topic string, window time.Duration, stop <-chan struct{}) error {
+	if err := c.client.Subscribe(topic, c.handle); err != nil {
+		return err
+	}
+	ticker := time.NewTicker(window)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.flush(); err != nil {
+				fmt.Fprintf(c.out, "Error publishing the result: %s\n", err.Error())
+			}
+		case <-stop:
+			return c.flush()
+		}
+	}
+}
+
+// mqtt subcommand: brand the live readings from MQTT broker until interrupted
+func runMQTT(args []string, out io.Writer) error {
+	if len(args) > 0 {
+		return errors.New("usage: sensors mqtt")
+	}
+	cfg, err := configFromEnv()
+	if err != nil {
+		return errors.Wrap(err, "Error reading configuration")
+	}
+	broker := envString("MQTT_BROKER", "")
+	if broker == "" {
+		return errors.New("MQTT_BROKER must be set for the mqtt command")
+	}
+	window, err := envDuration("MQTT_WINDOW", defaultMQTTWindow)
+	if err != nil {
+		return err
+	}
+	if window <= 0 {
+		return errors.New("MQTT_WINDOW must be positive")
+	}
+	if cfg.UseBaseline || cfg.PreviousReference || cfg.InheritReference || cfg.HysteresisMargin > 0 {
+		rdb := getRedis()
+		if _, err := rdb.Ping().Result(); err != nil {
+			return errors.Wrap(err, "Error connecting to REDIS")
+		}
+		cfg.Store = newRedisCache(rdb, redisKeyPrefix(envString("REDIS_KEY_PREFIX", ""), ""))
+	}
+	client, err := newPahoClient(broker)
+	if err != nil {
+		return err
+	}
+
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		close(stop)
+	}()
+	c := newMQTTConsumer(client, cfg, envString("MQTT_RESULT_TOPIC", defaultMQTTResultTopic), out)
+	return c.run(envString("MQTT_TOPIC", defaultMQTTTopic), window, stop)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// fakeMQTT is mqttClient delivering the published messages to the subscriber directly
+type fakeMQTT struct {
+	handler    func(topic string, payload []byte)
+	subscribed chan struct{}
+	published  map[string][]string
+}
+
+func newFakeMQTT() *fakeMQTT {
+	return &fakeMQTT{subscribed: make(chan struct{}), published: make(map[string][]string)}
+}
+
+func (m *fakeMQTT) Subscribe(topic string, handler func(topic string, payload []byte)) error {
+	m.handler = handler
+	close(m.subscribed)
+	return nil
+}
+
+func (m *fakeMQTT) Publish(topic string, payload []byte) error {
+	m.published[topic] = append(m.published[topic], string(payload))
+	return nil
+}
+
+// deliver the message to the subscriber
+func (m *fakeMQTT) send(topic, payload string) {
+	m.handler(topic, []byte(payload))
+}
+
+func TestMQTTConsumer(t *testing.T) {
+	client := newFakeMQTT()
+	out := &bytes.Buffer{}
+	c := newMQTTConsumer(client, Config{}, "results", out)
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- c.run("sensors/#", time.Hour, stop)
+	}()
+	<-client.subscribed
+
+	client.send("sensors/reference", "100 45")
+	client.send("sensors/thermometer/temp-1", "2007-04-05T22:00 100")
+	client.send("sensors/humidity/hum-1", "2007-04-05T22:00 47")
+	client.send("sensors/thermometer/temp-1", "2007-04-05T22:01 100.1")
+	client.send("sensors/barometer/bar-1", "2007-04-05T22:01 1013")
+	client.send("sensors/thermometer/temp-2", "100")
+	if err := c.flush(); err != nil {
+		t.Fatalf("got error %q, want nil", err)
+	}
+	assertInt(t, len(client.published["results"]), 1)
+	assertString(t, client.published["results"][0], `{
+  "hum-1": "discard",
+  "temp-1": "ultra precise"
+}`)
+	assertSubString(t, out.String(), `unknown sensor type "barometer"`)
+	assertSubString(t, out.String(), `invalid reading "100"`)
+
+	t.Run("reference kept for next window", func(t *testing.T) {
+		client.send("sensors/humidity/hum-1", "2007-04-05T22:02 45.1")
+		close(stop)
+		if err := <-done; err != nil {
+			t.Fatalf("got error %q, want nil", err)
+		}
+		assertInt(t, len(client.published["results"]), 2)
+		assertString(t, client.published["results"][1], `{
+  "hum-1": "keep"
+}`)
+	})
+
+	t.Run("empty window not published", func(t *testing.T) {
+		if err := c.flush(); err != nil {
+			t.Fatalf("got error %q, want nil", err)
+		}
+		assertInt(t, len(client.published["results"]), 2)
+	})
+
+	t.Run("invalid reference dropped", func(t *testing.T) {
+		client.send("sensors/reference", "100 forty")
+		client.send("sensors/reference", "100 45\nthermometer temp-9")
+		client.send("sensors/humidity/hum-1", "2007-04-05T22:03 45.2")
+		if err := c.flush(); err != nil {
+			t.Fatalf("got error %q, want nil", err)
+		}
+		assertSubString(t, out.String(), "failed converting reference humidity to float")
+		assertSubString(t, out.String(), `invalid reference "100 45\nthermometer temp-9"`)
+		assertString(t, client.published["results"][2], `{
+  "hum-1": "keep"
+}`)
+	})
+
+	t.Run("sensor name of the topic", func(t *testing.T) {
+		client.send("sensors/humidity/north room", "2007-04-05T22:04 46")
+		client.send("sensors/humidity/hum \"2\"", "2007-04-05T22:04 46")
+		client.send("sensors/humidity/", "2007-04-05T22:04 46")
+		if err := c.flush(); err != nil {
+			t.Fatalf("got error %q, want nil", err)
+		}
+		assertString(t, client.published["results"][3], `{
+  "north room": "discard"
+}`)
+		assertSubString(t, out.String(), `Ignoring MQTT message from sensors/humidity/hum "2": invalid sensor name`)
+		assertSubString(t, out.String(), "Ignoring MQTT message from sensors/humidity/: invalid sensor name")
+	})
+}
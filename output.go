@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"strings"
+
+	"sensors/pkg/sensors"
+)
+
+const (
+	OutputFilterAll      = "all"
+	OutputFilterProblems = "problems"
+
+	OutputOrderFile = "file"
+	OutputOrderName = "name"
+	OutputOrderType = "type"
+)
+
+// order of the sensor types with OutputOrderType
+var outputTypeOrder = []string{
+	sensors.ThermometerLabel,
+	sensors.HumiditySensorLabel,
+	sensors.FlowSensorLabel,
+	sensors.SoundSensorLabel,
+}
+
+// Format the brandings of sensors as the json output
+func formatBrandings(brandings map[string]string) string {
+	// is the output format supposed to be a json?
+	// Note: when using a map, we lose the original order of the sensors in the log file ...
+	j, _ := json.MarshalIndent(brandings, "", outputIndent)
+	return string(j)
+}
+
+// sensorOutput is the output of a sensor with its readings, the confidence of its branding or the number
+// of removed outliers, see Options.IncludeReadings, Config.IncludeConfidence and Options.OutlierMAD;
+// the pointers are nil when not included
+type sensorOutput struct {
+	Branding   string             `json:"branding"`
+	Confidence *float64           `json:"confidence,omitempty"`
+	Outliers   *int               `json:"outliers,omitempty"`
+	Readings   *[]sensors.Reading `json:"readings,omitempty"`
+	Reference  map[string]float64 `json:"reference,omitempty"`
+}
+
+// Format the result of processing the log file as the json output: the map of sensor names to their
+// branding, or to the objects with the branding and the readings, the confidence, the number of outliers
+// or the reference when they are included.
+// The sensors are in the order given by cfg.OutputOrder. With cfg.StationSummary, the output is an object
+// with the sensors and the summary of the whole station, which covers all the sensors regardless
+// of cfg.OutputFilter. With cfg.Anonymize, the sensor names are hashed.
+func formatResult(res *sensors.Result, cfg Config) string {
+	if cfg.anonymizer != nil {
+		res = cfg.anonymizer.anonymize(res)
+	}
+	if !cfg.StationSummary {
+		return formatSensors(res, cfg)
+	}
+	// the summary can't be a key of the sensors map, any name could be a sensor name
+	out := map[string]interface{}{
+		"sensors": json.RawMessage(formatSensors(res, cfg)),
+		"summary": res.Summary(),
+	}
+	j, _ := json.MarshalIndent(out, "", outputIndent)
+	return string(j)
+}
+
+// Format the sensors of the result, see formatResult
+func formatSensors(res *sensors.Result, cfg Config) string {
+	brandings := filterBrandings(res.Brandings(), cfg.OutputFilter)
+	names := orderSensors(res, brandings, cfg.OutputOrder)
+	if !cfg.IncludeReadings && !cfg.IncludeConfidence && !cfg.IncludeReference && cfg.OutlierMAD == 0 {
+		return marshalOrdered(names, func(name string) interface{} { return brandings[name] })
+	}
+	ret := make(map[string]sensorOutput)
+	for _, s := range res.Sensors {
+		if _, ok := brandings[s.Name]; !ok {
+			continue
+		}
+		out := sensorOutput{Branding: s.Branding}
+		if cfg.IncludeConfidence {
+			// more digits would be false precision
+			confidence := math.Round(s.Confidence*1000) / 1000
+			out.Confidence = &confidence
+		}
+		if cfg.OutlierMAD > 0 {
+			outliers := s.Outliers
+			out.Outliers = &outliers
+		}
+		if cfg.IncludeReadings {
+			readings := s.Readings
+			out.Readings = &readings
+		}
+		if cfg.IncludeReference {
+			out.Reference = s.Reference
+		}
+		ret[s.Name] = out
+	}
+	return marshalOrdered(names, func(name string) interface{} { return ret[name] })
+}
+
+// Return the names of the sensors of the result that are in the output, in the order: OutputOrderFile
+// as the sensors appear in the log file, OutputOrderType grouped by the sensor type (in the order of
+// outputTypeOrder) and then by name, OutputOrderName (or any other) by name
+func orderSensors(res *sensors.Result, brandings map[string]string, order string) []string {
+	names := make([]string, 0, len(brandings))
+	types := make(map[string]string)
+	for _, s := range res.Sensors {
+		if _, ok := brandings[s.Name]; !ok {
+			continue
+		}
+		// a sensor appearing more than once keeps its first place
+		if _, ok := types[s.Name]; !ok {
+			names = append(names, s.Name)
+			types[s.Name] = s.Type
+		}
+	}
+	switch order {
+	case OutputOrderFile:
+	case OutputOrderType:
+		sort.SliceStable(names, func(i, j int) bool {
+			ti, tj := typeRank(types[names[i]]), typeRank(types[names[j]])
+			if ti != tj {
+				return ti < tj
+			}
+			if types[names[i]] != types[names[j]] {
+				return types[names[i]] < types[names[j]]
+			}
+			return names[i] < names[j]
+		})
+	default:
+		sort.Strings(names)
+	}
+	return names
+}
+
+// Return the position of the sensor type in outputTypeOrder, the unknown types go last
+func typeRank(sensorType string) int {
+	for i, t := range outputTypeOrder {
+		if t == sensorType {
+			return i
+		}
+	}
+	return len(outputTypeOrder)
+}
+
+// Marshal the json object with the keys in the given order, indented the same way as by json.MarshalIndent
+// (which sorts the keys of maps)
+func marshalOrdered(keys []string, value func(string) interface{}) string {
+	if len(keys) == 0 {
+		return "{}"
+	}
+	var b strings.Builder
+	b.WriteString("{\n")
+	for i, k := range keys {
+		kj, _ := json.Marshal(k)
+		vj, _ := json.MarshalIndent(value(k), outputIndent, outputIndent)
+		b.WriteString(outputIndent)
+		b.Write(kj)
+		b.WriteString(": ")
+		b.Write(vj)
+		if i < len(keys)-1 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// Return only the sensors that should be part of the output: with OutputFilterProblems,
+// only the sensors with one of the problem brandings are kept
+func filterBrandings(brandings map[string]string, filter string) map[string]string {
+	if filter != OutputFilterProblems {
+		return brandings
+	}
+	ret := make(map[string]string)
+	for name, branding := range brandings {
+		if sensors.IsProblem(branding) {
+			ret[name] = branding
+		}
+	}
+	return ret
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"sensors/pkg/sensors"
+)
+
+const mixedSensors = `reference 100 45
+thermometer temp-1
+2007-04-05T22:00 100
+humidity hum-1
+2007-04-05T22:00 45.1
+humidity hum-2
+2007-04-05T22:00 47`
+
+func TestOutputFilter(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "sensors")
+	if err != nil {
+		t.Error("Error creating test log file")
+		return
+	}
+	defer os.Remove(tmpFile.Name())
+	if err := writeTestLogFile(tmpFile, mixedSensors); err != nil {
+		t.Error("Error writing test log file")
+		return
+	}
+
+	t.Run("all sensors", func(t *testing.T) {
+		val, err := processLogFileWithConfig(tmpFile.Name(), Config{OutputFilter: OutputFilterAll})
+		assertError(t, err, nil)
+		assertString(t, val, `{
+  "hum-1": "keep",
+  "hum-2": "discard",
+  "temp-1": "ultra precise"
+}`)
+	})
+
+	t.Run("problems only", func(t *testing.T) {
+		val, err := processLogFileWithConfig(tmpFile.Name(), Config{OutputFilter: OutputFilterProblems})
+		assertError(t, err, nil)
+		assertString(t, val, `{
+  "hum-2": "discard"
+}`)
+	})
+}
+
+func TestIncludeReadings(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "sensors")
+	if err != nil {
+		t.Error("Error creating test log file")
+		return
+	}
+	defer os.Remove(tmpFile.Name())
+	if err := writeTestLogFile(tmpFile, mixedSensors); err != nil {
+		t.Error("Error writing test log file")
+		return
+	}
+
+	t.Run("absent by default", func(t *testing.T) {
+		val, err := processLogFileWithConfig(tmpFile.Name(), Config{OutputFilter: OutputFilterProblems})
+		assertError(t, err, nil)
+		assertString(t, val, `{
+  "hum-2": "discard"
+}`)
+	})
+
+	t.Run("included", func(t *testing.T) {
+		cfg := Config{OutputFilter: OutputFilterProblems}
+		cfg.IncludeReadings = true
+		val, err := processLogFileWithConfig(tmpFile.Name(), cfg)
+		assertError(t, err, nil)
+		assertString(t, val, `{
+  "hum-2": {
+    "branding": "discard",
+    "readings": [
+      {
+        "time": "2007-04-05T22:00:00Z",
+        "value": 47
+      }
+    ]
+  }
+}`)
+	})
+}
+
+func TestIncludeConfidence(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "sensors")
+	if err != nil {
+		t.Error("Error creating test log file")
+		return
+	}
+	defer os.Remove(tmpFile.Name())
+	if err := writeTestLogFile(tmpFile, mixedSensors); err != nil {
+		t.Error("Error writing test log file")
+		return
+	}
+
+	cfg := Config{OutputFilter: OutputFilterAll, IncludeConfidence: true}
+	val, err := processLogFileWithConfig(tmpFile.Name(), cfg)
+	assertError(t, err, nil)
+	// hum-1 is 0.1 from the reference, with the band of 0.45; hum-2 is more than twice the band off
+	assertString(t, val, `{
+  "hum-1": {
+    "branding": "keep",
+    "confidence": 0.778
+  },
+  "hum-2": {
+    "branding": "discard",
+    "confidence": 1
+  },
+  "temp-1": {
+    "branding": "ultra precise",
+    "confidence": 1
+  }
+}`)
+
+	cfg.IncludeReadings = true
+	val, err = processLogFileWithConfig(tmpFile.Name(), cfg)
+	assertError(t, err, nil)
+	assertSubString(t, val, `"confidence": 0.778,
+    "readings": [`)
+}
+
+func TestIncludeReference(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "sensors")
+	if err != nil {
+		t.Error("Error creating test log file")
+		return
+	}
+	defer os.Remove(tmpFile.Name())
+	// each sensor has a different reference: the first line, its own and the second line with the room temperature
+	log := `reference 100 45
+humidity hum-1
+2007-04-05T22:00 45.1
+thermometer temp-1 ref=104
+2007-04-05T22:00 104
+reference 98 45 0 21
+thermometer temp-2
+2007-04-05T22:00 98`
+	if err := writeTestLogFile(tmpFile, log); err != nil {
+		t.Error("Error writing test log file")
+		return
+	}
+
+	cfg := Config{OutputFilter: OutputFilterAll}
+	cfg.IncludeReference = true
+	val, err := processLogFileWithConfig(tmpFile.Name(), cfg)
+	assertError(t, err, nil)
+	assertString(t, val, `{
+  "hum-1": {
+    "branding": "keep",
+    "reference": {
+      "Humidity": 45
+    }
+  },
+  "temp-1": {
+    "branding": "ultra precise",
+    "reference": {
+      "Temperature": 104
+    }
+  },
+  "temp-2": {
+    "branding": "ultra precise",
+    "reference": {
+      "RoomTemperature": 21,
+      "Temperature": 98
+    }
+  }
+}`)
+}
+
+func TestOutputOrder(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "sensors")
+	if err != nil {
+		t.Error("Error creating test log file")
+		return
+	}
+	defer os.Remove(tmpFile.Name())
+	content := `reference 100 45 10
+humidity hum-b
+2007-04-05T22:00 45.1
+thermometer temp-z
+2007-04-05T22:00 100
+flow flow-1
+2007-04-05T22:00 10
+humidity hum-a
+2007-04-05T22:00 45
+thermometer temp-a
+2007-04-05T22:00 100`
+	if err := writeTestLogFile(tmpFile, content); err != nil {
+		t.Error("Error writing test log file")
+		return
+	}
+
+	tests := []struct {
+		order, want string
+	}{
+		{OutputOrderName, `{
+  "flow-1": "normal",
+  "hum-a": "keep",
+  "hum-b": "keep",
+  "temp-a": "ultra precise",
+  "temp-z": "ultra precise"
+}`},
+		{OutputOrderFile, `{
+  "hum-b": "keep",
+  "temp-z": "ultra precise",
+  "flow-1": "normal",
+  "hum-a": "keep",
+  "temp-a": "ultra precise"
+}`},
+		{OutputOrderType, `{
+  "temp-a": "ultra precise",
+  "temp-z": "ultra precise",
+  "hum-a": "keep",
+  "hum-b": "keep",
+  "flow-1": "normal"
+}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.order, func(t *testing.T) {
+			val, err := processLogFileWithConfig(tmpFile.Name(), Config{OutputFilter: OutputFilterAll, OutputOrder: tt.order})
+			assertError(t, err, nil)
+			assertString(t, val, tt.want)
+		})
+	}
+
+	t.Run("with confidence", func(t *testing.T) {
+		cfg := Config{OutputFilter: OutputFilterAll, OutputOrder: OutputOrderType, IncludeConfidence: true}
+		val, err := processLogFileWithConfig(tmpFile.Name(), cfg)
+		assertError(t, err, nil)
+		assertSubString(t, val, `{
+  "temp-a": {
+    "branding": "ultra precise",
+    "confidence": 1
+  },
+  "temp-z": {`)
+	})
+
+	t.Run("no sensors", func(t *testing.T) {
+		val, err := processLogFileWithConfig(tmpFile.Name(), Config{OutputFilter: OutputFilterProblems, OutputOrder: OutputOrderFile})
+		assertError(t, err, nil)
+		assertString(t, val, "{}")
+	})
+}
+
+func TestStationSummary(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "sensors")
+	if err != nil {
+		t.Error("Error creating test log file")
+		return
+	}
+	defer os.Remove(tmpFile.Name())
+	content := `reference 100 45
+thermometer temp-1
+2007-04-05T22:00 100
+2007-04-05T22:01 100.2
+thermometer temp-2
+2007-04-05T22:00 102
+thermometer temp-3
+humidity hum-1
+2007-04-05T22:00 44.75
+humidity hum-2
+2007-04-05T22:00 48
+humidity hum-3
+2007-04-05T22:00 45.25`
+	if err := writeTestLogFile(tmpFile, content); err != nil {
+		t.Error("Error writing test log file")
+		return
+	}
+
+	cfg := Config{OutputFilter: OutputFilterProblems, StationSummary: true}
+	val, err := processLogFileWithConfig(tmpFile.Name(), cfg)
+	assertError(t, err, nil)
+	// temp-3 has no readings, so the station temperature is the mean of 100.1 and 102
+	assertString(t, val, `{
+  "sensors": {
+    "hum-2": "discard"
+  },
+  "summary": {
+    "sensors": 6,
+    "problems": 1,
+    "brandings": {
+      "humidity": {
+        "discard": 1,
+        "keep": 2
+      },
+      "thermometer": {
+        "precise": 2,
+        "ultra precise": 1
+      }
+    },
+    "means": {
+      "humidity": 46,
+      "thermometer": 101.05
+    }
+  }
+}`)
+}
+
+func TestOutliersOutput(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "sensors")
+	if err != nil {
+		t.Error("Error creating test log file")
+		return
+	}
+	defer os.Remove(tmpFile.Name())
+	log := `reference 100 45
+thermometer temp-1
+2007-04-05T22:00 100
+2007-04-05T22:01 101
+2007-04-05T22:02 99
+2007-04-05T22:03 130`
+	if err := writeTestLogFile(tmpFile, log); err != nil {
+		t.Error("Error writing test log file")
+		return
+	}
+
+	val, err := processLogFileWithConfig(tmpFile.Name(), Config{OutputFilter: OutputFilterAll, Options: sensors.Options{OutlierMAD: 3}})
+	assertError(t, err, nil)
+	assertString(t, val, `{
+  "temp-1": {
+    "branding": "ultra precise",
+    "outliers": 1
+  }
+}`)
+}
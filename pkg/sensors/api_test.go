@@ -0,0 +1,221 @@
+package sensors_test
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"sensors/pkg/sensors"
+)
+
+const apiLog = `reference 100 45
+thermometer temp-1
+2007-04-05T22:00 100
+2007-04-05T22:01 100.1
+humidity hum-1
+2007-04-05T22:00 47
+thermometer temp-2
+2007-04-05T22:00 104
+2007-04-05T22:01 96`
+
+func TestProcessReader(t *testing.T) {
+	res, err := sensors.ProcessReader(strings.NewReader(apiLog), sensors.Options{})
+	if err != nil {
+		t.Fatalf("got error %q, want nil", err)
+	}
+	want := []sensors.SensorResult{
+		{Name: "temp-1", Type: sensors.ThermometerLabel, Branding: sensors.ThermometerUltraPrecise},
+		{Name: "hum-1", Type: sensors.HumiditySensorLabel, Branding: sensors.HumiditySensorDiscard},
+		{Name: "temp-2", Type: sensors.ThermometerLabel, Branding: sensors.ThermometerPrecise},
+	}
+	if len(res.Sensors) != len(want) {
+		t.Fatalf("got %d sensors, want %d", len(res.Sensors), len(want))
+	}
+	for i, s := range res.Sensors {
+		if s.Name != want[i].Name || s.Type != want[i].Type || s.Branding != want[i].Branding || s.Readings != nil {
+			t.Errorf("got sensor %+v, want %+v", s, want[i])
+		}
+	}
+	if b := res.Brandings()["hum-1"]; !sensors.IsProblem(b) {
+		t.Errorf("got branding %q of hum-1, want a problem one", b)
+	}
+}
+
+func TestIncludeReadings(t *testing.T) {
+	res, err := sensors.ProcessReader(strings.NewReader(apiLog), sensors.Options{IncludeReadings: true})
+	if err != nil {
+		t.Fatalf("got error %q, want nil", err)
+	}
+	readings := res.Sensors[0].Readings
+	want := []sensors.Reading{
+		{Time: time.Date(2007, 4, 5, 22, 0, 0, 0, time.UTC), Value: 100},
+		{Time: time.Date(2007, 4, 5, 22, 1, 0, 0, time.UTC), Value: 100.1},
+	}
+	if len(readings) != len(want) {
+		t.Fatalf("got %d readings of temp-1, want %d", len(readings), len(want))
+	}
+	for i, r := range readings {
+		if !r.Time.Equal(want[i].Time) || r.Value != want[i].Value {
+			t.Errorf("got reading %+v, want %+v", r, want[i])
+		}
+	}
+	if len(res.Sensors[1].Readings) != 1 {
+		t.Errorf("got %d readings of hum-1, want 1", len(res.Sensors[1].Readings))
+	}
+}
+
+func TestProcessLogFileMissing(t *testing.T) {
+	_, err := sensors.ProcessLogFile("nofile.txt", sensors.Options{})
+	if err == nil || !strings.Contains(err.Error(), sensors.ErrOpenFile) {
+		t.Errorf("got error %v, want to contain %q", err, sensors.ErrOpenFile)
+	}
+}
+
+func TestResultHash(t *testing.T) {
+	res, err := sensors.ProcessReader(strings.NewReader(apiLog), sensors.Options{})
+	if err != nil {
+		t.Fatalf("got error %q, want nil", err)
+	}
+	hash := res.Hash()
+	if len(hash) != 64 {
+		t.Errorf("got hash %q, want 64 hex characters", hash)
+	}
+	if again := res.Hash(); again != hash {
+		t.Errorf("got hash %q, then %q", hash, again)
+	}
+
+	// the same brandings in other order
+	reordered := &sensors.Result{Sensors: []sensors.SensorResult{
+		{Name: "temp-2", Type: sensors.ThermometerLabel, Branding: sensors.ThermometerPrecise},
+		{Name: "hum-1", Type: sensors.HumiditySensorLabel, Branding: sensors.HumiditySensorDiscard},
+		{Name: "temp-1", Type: sensors.ThermometerLabel, Branding: sensors.ThermometerUltraPrecise},
+	}}
+	if h := reordered.Hash(); h != hash {
+		t.Errorf("got hash %q of reordered sensors, want %q", h, hash)
+	}
+
+	changed := &sensors.Result{Sensors: append([]sensors.SensorResult{}, reordered.Sensors...)}
+	changed.Sensors[0].Branding = sensors.ThermometerVeryPrecise
+	if h := changed.Hash(); h == hash {
+		t.Errorf("got the same hash %q for different brandings", h)
+	}
+}
+
+func TestPostProcess(t *testing.T) {
+	blocklist := map[string]bool{"temp-1": true}
+	downgrade := func(r *sensors.SensorResult) error {
+		if blocklist[r.Name] && r.Branding == sensors.ThermometerUltraPrecise {
+			r.Branding = sensors.ThermometerVeryPrecise
+		}
+		return nil
+	}
+	res, err := sensors.ProcessReader(strings.NewReader(apiLog), sensors.Options{PostProcess: downgrade})
+	if err != nil {
+		t.Fatalf("got error %q, want nil", err)
+	}
+	brandings := res.Brandings()
+	if b := brandings["temp-1"]; b != sensors.ThermometerVeryPrecise {
+		t.Errorf("got branding %q of temp-1, want %q", b, sensors.ThermometerVeryPrecise)
+	}
+	if b := brandings["temp-2"]; b != sensors.ThermometerPrecise {
+		t.Errorf("got branding %q of temp-2, want %q", b, sensors.ThermometerPrecise)
+	}
+
+	failing := func(r *sensors.SensorResult) error {
+		return errors.New("blocklist unavailable")
+	}
+	_, err = sensors.ProcessReader(strings.NewReader(apiLog), sensors.Options{PostProcess: failing})
+	if err == nil || !strings.Contains(err.Error(), "blocklist unavailable") || !strings.Contains(err.Error(), "temp-1") {
+		t.Errorf("got error %v, want the error of the post-processor for temp-1", err)
+	}
+}
+
+func TestSensorTypes(t *testing.T) {
+	types := sensors.SensorTypes()
+	labels := make([]string, 0, len(types))
+	byLabel := make(map[string]sensors.SensorTypeInfo)
+	for _, info := range types {
+		labels = append(labels, info.Label)
+		byLabel[info.Label] = info
+		if info.DisplayName == "" {
+			t.Errorf("got no display name of %s", info.Label)
+		}
+		// every listed type can be created
+		if sensors.NewSensor(info.Label, "s", sensors.Thresholds{}) == nil {
+			t.Errorf("got no sensor of listed type %s", info.Label)
+		}
+	}
+	if got, want := strings.Join(labels, ","), "flow,humidity,sound,thermometer"; got != want {
+		t.Errorf("got sensor types %s, want %s", got, want)
+	}
+
+	thermometer := byLabel[sensors.ThermometerLabel]
+	if got := strings.Join(thermometer.ReferenceKeys, ","); got != "Temperature" {
+		t.Errorf("got thermometer reference keys %s", got)
+	}
+	if got := strings.Join(thermometer.OptionalReferenceKeys, ","); got != sensors.RoomTemperatureKey {
+		t.Errorf("got thermometer optional reference keys %s", got)
+	}
+	if got := strings.Join(thermometer.Brandings[:3], ","); got != "ultra precise,very precise,precise" {
+		t.Errorf("got thermometer brandings %s", got)
+	}
+	sound := byLabel[sensors.SoundSensorLabel]
+	if len(sound.ReferenceKeys) != 0 || strings.Join(sound.OptionalReferenceKeys, ",") != "Sound" {
+		t.Errorf("got sound sensor reference keys %v, optional %v", sound.ReferenceKeys, sound.OptionalReferenceKeys)
+	}
+	humidity := byLabel[sensors.HumiditySensorLabel]
+	hasGappy := false
+	for _, b := range humidity.Brandings {
+		hasGappy = hasGappy || b == sensors.SensorGappy
+	}
+	if humidity.Brandings[1] != sensors.HumiditySensorDiscard || !hasGappy {
+		t.Errorf("got humidity sensor brandings %v", humidity.Brandings)
+	}
+}
+
+func TestBrandSensor(t *testing.T) {
+	ref := map[string]float64{"Temperature": 100, "Humidity": 45, "Flow": 12, "Sound": 50}
+	cases := []struct {
+		sensorType string
+		readings   []float64
+		want       string
+	}{
+		{sensors.ThermometerLabel, []float64{100, 100.1, 99.9}, sensors.ThermometerUltraPrecise},
+		{sensors.ThermometerLabel, []float64{96, 104}, sensors.ThermometerPrecise},
+		{sensors.HumiditySensorLabel, []float64{45.2, 44.8}, sensors.HumiditySensorKeep},
+		{sensors.HumiditySensorLabel, []float64{47}, sensors.HumiditySensorDiscard},
+		{sensors.FlowSensorLabel, []float64{12.1, 11.9}, sensors.FlowSensorNormal},
+		{sensors.FlowSensorLabel, []float64{8}, sensors.FlowSensorLow},
+		{sensors.FlowSensorLabel, []float64{20}, sensors.FlowSensorHigh},
+		{sensors.SoundSensorLabel, []float64{30, 32}, sensors.SoundSensorQuiet},
+		{sensors.SoundSensorLabel, []float64{52}, sensors.SoundSensorLoud},
+		{sensors.SoundSensorLabel, []float64{90}, sensors.SoundSensorExcessive},
+	}
+	for _, c := range cases {
+		branding, stats := sensors.BrandSensor(c.sensorType, ref, c.readings)
+		if branding != c.want {
+			t.Errorf("%s %v: got branding %q, want %q", c.sensorType, c.readings, branding, c.want)
+		}
+		if stats.Count != len(c.readings) || stats.Confidence < 0 || stats.Confidence > 1 {
+			t.Errorf("%s %v: got stats %+v", c.sensorType, c.readings, stats)
+		}
+	}
+
+	_, stats := sensors.BrandSensor(sensors.ThermometerLabel, ref, []float64{96, 104})
+	if stats.Mean != 100 || math.Abs(stats.StdDev-math.Sqrt(32)) > 1e-9 {
+		t.Errorf("got stats %+v, want mean 100 and std deviation %f", stats, math.Sqrt(32))
+	}
+	// the same branding as of the log file
+	res, err := sensors.ProcessReader(strings.NewReader(apiLog), sensors.Options{})
+	if err != nil {
+		t.Fatalf("got error %q, want nil", err)
+	}
+	if branding, _ := sensors.BrandSensor(sensors.ThermometerLabel, ref, []float64{104, 96}); branding != res.Brandings()["temp-2"] {
+		t.Errorf("got branding %q, want %q of the log file", branding, res.Brandings()["temp-2"])
+	}
+	if branding, _ := sensors.BrandSensor("barometer", ref, []float64{1}); branding != "" {
+		t.Errorf("got branding %q of unknown sensor type, want none", branding)
+	}
+}
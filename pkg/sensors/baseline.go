@@ -0,0 +1,88 @@
+package sensors
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+const baselineKeyPrefix = "baseline:"
+
+// baseline is the long-term mean of all readings of a sensor seen so far, the energy mean for sound levels
+type baseline struct {
+	Mean  float64 `json:"mean"`
+	Count int     `json:"count"`
+}
+
+func baselineKey(sensorType, name string) string {
+	return fmt.Sprintf("%s%s:%s", baselineKeyPrefix, sensorType, name)
+}
+
+// Read the baseline of a sensor; ok is false if there's no baseline yet
+func getBaseline(store Store, sensorType, name string) (b baseline, ok bool, err error) {
+	val, err := store.Get(baselineKey(sensorType, name))
+	if err == ErrNotFound {
+		return b, false, nil
+	} else if err != nil {
+		return b, false, errors.Wrap(err, "failed reading baseline of "+name)
+	}
+	if err := json.Unmarshal([]byte(val), &b); err != nil {
+		return b, false, errors.Wrap(err, "invalid baseline of "+name)
+	}
+	return b, true, nil
+}
+
+// Add the readings into the baseline of a sensor and save it
+func updateBaseline(store Store, sensorType, name string, b baseline, readings []float64) error {
+	if len(readings) == 0 {
+		return nil
+	}
+	b.Mean = combinedMean(sensorType, b.Mean, b.Count, typeMean(sensorType, readings), len(readings))
+	b.Count += len(readings)
+	j, _ := json.Marshal(b)
+	if err := store.Set(baselineKey(sensorType, name), string(j)); err != nil {
+		return errors.Wrap(err, "failed saving baseline of "+name)
+	}
+	return nil
+}
+
+// Return the mean of the readings of both means, given with their counts; the sound levels are averaged
+// in the energy domain, as by soundLevelMean
+func combinedMean(sensorType string, mean float64, count int, other float64, otherCount int) float64 {
+	total := float64(count + otherCount)
+	if sensorType != SoundSensorLabel {
+		return (mean*float64(count) + other*float64(otherCount)) / total
+	}
+	max := math.Max(mean, other)
+	return max + 10*math.Log10((float64(count)*math.Pow(10, (mean-max)/10)+float64(otherCount)*math.Pow(10, (other-max)/10))/total)
+}
+
+// Return the reference values for a sensor in the baseline mode.
+// When the log file has a reference line, it is used as it is. Otherwise the reference quantity of the sensor
+// is replaced with its stored baseline; on the first run (no baseline yet) the readings are compared with their
+// own mean (see typeMean), which then becomes the baseline.
+// The baseline is updated with the current readings in both cases.
+func baselineReference(store Store, sensorType, name string, referenceValues map[string]float64, referenceFound bool, readings []float64) (map[string]float64, error) {
+	b, ok, err := getBaseline(store, sensorType, name)
+	if err != nil {
+		return nil, err
+	}
+	ret := referenceValues
+	if !referenceFound && len(readings) > 0 {
+		ret = make(map[string]float64)
+		for k, v := range referenceValues {
+			ret[k] = v
+		}
+		if ok {
+			ret[sensorTypes[sensorType].referenceKey] = b.Mean
+		} else {
+			ret[sensorTypes[sensorType].referenceKey] = typeMean(sensorType, readings)
+		}
+	}
+	if err := updateBaseline(store, sensorType, name, b, readings); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
@@ -0,0 +1,139 @@
+package sensors
+
+import (
+	"errors"
+	"io/ioutil"
+	"math"
+	"os"
+	"strings"
+	"testing"
+)
+
+const baselineFirstRun = `thermometer temp-1
+2007-04-05T22:00 100
+2007-04-05T22:01 100.1
+2007-04-05T22:02 99.9
+humidity hum-1
+2007-04-05T22:00 45.1
+2007-04-05T22:01 44.9`
+
+const baselineSecondRun = `thermometer temp-1
+2007-04-06T22:00 105
+2007-04-06T22:01 105.1
+2007-04-06T22:02 104.9
+humidity hum-1
+2007-04-06T22:00 45.2
+2007-04-06T22:01 44.8`
+
+func TestBaseline(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "sensors")
+	if err != nil {
+		t.Error("Error creating test log file")
+		return
+	}
+	defer os.Remove(tmpFile.Name())
+
+	cache := memStore{}
+	opts := Options{UseBaseline: true, Store: cache}
+
+	t.Run("first run establishes the baseline", func(t *testing.T) {
+		if err := writeTestLogFile(tmpFile, baselineFirstRun); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		val, err := processTestLogFile(tmpFile.Name(), opts)
+		assertError(t, err, nil)
+		assertString(t, val, `{
+  "hum-1": "keep",
+  "temp-1": "ultra precise"
+}`)
+		b, ok, err := getBaseline(cache, ThermometerLabel, "temp-1")
+		assertError(t, err, nil)
+		if !ok {
+			t.Fatal("baseline of temp-1 not saved")
+		}
+		assertInt(t, b.Count, 3)
+		if math.Abs(b.Mean-100) > 1e-9 {
+			t.Errorf("got baseline mean %f, want %f", b.Mean, 100.0)
+		}
+	})
+
+	t.Run("second run compares against the baseline", func(t *testing.T) {
+		if err := writeTestLogFile(tmpFile, baselineSecondRun); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		val, err := processTestLogFile(tmpFile.Name(), opts)
+		assertError(t, err, nil)
+		assertString(t, val, `{
+  "hum-1": "keep",
+  "temp-1": "precise"
+}`)
+		b, _, err := getBaseline(cache, ThermometerLabel, "temp-1")
+		assertError(t, err, nil)
+		assertInt(t, b.Count, 6)
+		if math.Abs(b.Mean-102.5) > 1e-9 {
+			t.Errorf("got baseline mean %f, want %f", b.Mean, 102.5)
+		}
+	})
+
+	t.Run("reference line takes precedence", func(t *testing.T) {
+		if err := writeTestLogFile(tmpFile, "reference 105 0\n"+baselineSecondRun); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		val, err := processTestLogFile(tmpFile.Name(), opts)
+		assertError(t, err, nil)
+		assertString(t, val, `{
+  "hum-1": "discard",
+  "temp-1": "ultra precise"
+}`)
+	})
+}
+
+func TestBaselineIncompleteFile(t *testing.T) {
+	cache := memStore{}
+	opts := Options{UseBaseline: true, InheritReference: true, Store: cache, EndMarker: "# EOF"}
+	_, err := ProcessReader(strings.NewReader(baselineFirstRun+"\n# EOF"), opts)
+	assertError(t, err, nil)
+
+	// the incomplete upload is tried again until its marker comes, without touching the state
+	for i := 0; i < 3; i++ {
+		_, err := ProcessReader(strings.NewReader("reference 105 45\n"+baselineSecondRun), opts)
+		var incompleteErr *IncompleteFileError
+		if !errors.As(err, &incompleteErr) {
+			t.Fatalf("got error %v, want IncompleteFileError", err)
+		}
+		b, _, err := getBaseline(cache, ThermometerLabel, "temp-1")
+		assertError(t, err, nil)
+		assertInt(t, b.Count, 3)
+		if _, ok, _ := loadReference(cache); ok {
+			t.Fatal("reference of the incomplete file saved")
+		}
+	}
+
+	_, err = ProcessReader(strings.NewReader("reference 105 45\n"+baselineSecondRun+"\n# EOF"), opts)
+	assertError(t, err, nil)
+	b, _, err := getBaseline(cache, ThermometerLabel, "temp-1")
+	assertError(t, err, nil)
+	assertInt(t, b.Count, 6)
+	if _, ok, _ := loadReference(cache); !ok {
+		t.Error("reference of the complete file not saved")
+	}
+}
+
+func TestSoundBaseline(t *testing.T) {
+	cache := memStore{}
+	opts := Options{UseBaseline: true, Store: cache}
+	// the same as one file with all the readings
+	for _, log := range []string{"sound snd-1\n2007-04-05T22:00 40\n", "sound snd-1\n2007-04-06T22:00 60\n2007-04-06T22:01 50\n"} {
+		_, err := ProcessReader(strings.NewReader(log), opts)
+		assertError(t, err, nil)
+	}
+	b, _, err := getBaseline(cache, SoundSensorLabel, "snd-1")
+	assertError(t, err, nil)
+	assertInt(t, b.Count, 3)
+	if want := soundLevelMean([]float64{40, 60, 50}); math.Abs(b.Mean-want) > 1e-9 {
+		t.Errorf("got baseline %f, want energy mean %f", b.Mean, want)
+	}
+}
@@ -0,0 +1,42 @@
+package sensors
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Create a log file with the given number of thermometers, each with the given number of readings
+func syntheticLog(sensors, readings int) string {
+	var b strings.Builder
+	b.WriteString("reference 70.0 45.0\n")
+	start := time.Date(2007, 4, 5, 22, 0, 0, 0, time.UTC)
+	for s := 0; s < sensors; s++ {
+		fmt.Fprintf(&b, "thermometer temp-%d\n", s)
+		for r := 0; r < readings; r++ {
+			fmt.Fprintf(&b, "%s %.1f\n", start.Add(time.Duration(r)*time.Minute).Format("2006-01-02T15:04"), 69.5+float64(r%10)/10)
+		}
+	}
+	return b.String()
+}
+
+// Parsing 100 thermometers with 1000 readings each, go test -bench Parse -benchtime 20x:
+//
+//	before: 40 ms/op, 15.8 MB/op, 203515 allocs/op
+//	after:  21 ms/op, 12.0 MB/op, 103215 allocs/op
+//
+// The gain comes from splitting the lines without allocation, the fast path for the most common
+// timestamp format and creating the random generator of reservoir only when it's needed.
+func BenchmarkParse(b *testing.B) {
+	log := syntheticLog(100, 1000)
+	b.SetBytes(int64(len(log)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := parse(strings.NewReader(log), Options{}, func(block) error { return nil })
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package sensors
+
+import (
+	"gonum.org/v1/gonum/stat"
+)
+
+// Stats are the statistics of the readings of a sensor branded by BrandSensor
+type Stats struct {
+	// Count is the number of readings, Mean their mean (the energy mean for sound levels) and StdDev
+	// their std deviation (0 for a single reading); both are 0 without readings
+	Count  int
+	Mean   float64
+	StdDev float64
+	// Confidence of the branding from 0 to 1, see SensorResult.Confidence
+	Confidence float64
+}
+
+// BrandSensor brands the readings of a sensor of the type with the reference values (e.g. "Temperature"
+// and RoomTemperatureKey for a thermometer) and the default thresholds, without any log file. The branding
+// is empty for an unknown sensor type.
+func BrandSensor(sensorType string, ref map[string]float64, readings []float64) (branding string, stats Stats) {
+	s := NewSensor(sensorType, "", Thresholds{})
+	if s == nil {
+		return "", stats
+	}
+	s.Process(ref, readings)
+	stats.Count = len(readings)
+	if len(readings) > 0 {
+		stats.Mean = typeMean(sensorType, readings)
+	}
+	if len(readings) > 1 {
+		stats.StdDev = stat.StdDev(readings, nil)
+	}
+	stats.Confidence = 1
+	if c, ok := s.(scored); ok {
+		stats.Confidence = c.Confidence()
+	}
+	return s.Branding(), stats
+}
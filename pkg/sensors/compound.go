@@ -0,0 +1,101 @@
+package sensors
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// column of compound readings that is not evaluated (e.g. a quantity without sensor type)
+const ignoredChannel = "-"
+
+// channel is one stream of readings belonging to a single sensor
+type channel struct {
+	sensorType string
+	// sensor is nil for the ignored columns
+	sensor   Sensor
+	readings *reservoir
+	// decoder of the raw reading values, nil for plain numbers
+	decoder *Decoder
+	// unit the plain number readings may have appended, see Options.Units
+	unit string
+	// reference value given on the sensor header, overriding the one of the log file
+	reference *float64
+	// time between the consecutive readings (with known timestamps), in seconds
+	intervals []float64
+	lastTime  time.Time
+	// number of readings removed as outliers, see Options.OutlierMAD
+	outliers int
+	// reference the sensor was branded against, see SensorResult.Reference
+	brandedReference map[string]float64
+}
+
+func (c *channel) add(r reading) {
+	c.readings.add(r)
+	if r.time.IsZero() {
+		return
+	}
+	if !c.lastTime.IsZero() {
+		c.intervals = append(c.intervals, r.time.Sub(c.lastTime).Seconds())
+	}
+	c.lastTime = r.time
+}
+
+func newChannel(sensorType, name string, opts Options) *channel {
+	newSensor := NewSensor
+	if opts.newSensor != nil {
+		newSensor = opts.newSensor
+	}
+	c := &channel{
+		sensorType: sensorType,
+		sensor:     newSensor(sensorType, name, opts.Thresholds),
+		readings:   newReservoir(opts.MaxReadings),
+	}
+	if d, ok := opts.Decoders[sensorType]; ok {
+		c.decoder = &d
+	}
+	if u, ok := opts.Units[sensorType]; ok && c.decoder == nil {
+		c.unit = u
+	}
+	// a single humidity reading out of the band may decide the branding, so the sampling must not lose it;
+	// the outliers are not used for the branding anyway
+	if sensorType == HumiditySensorLabel && opts.MaxReadings > 0 && opts.OutlierMAD == 0 {
+		c.readings.extremes = newHumidityExtremes(opts.Thresholds)
+	}
+	return c
+}
+
+// Create the channels of compound device, declared by its header:
+//
+//	compound <device> <type> [<type> ...]
+//
+// Every reading line of the device then has one value per declared type, in the same order:
+//
+//	<timestamp> <value> [<value> ...]
+//
+// Each channel is evaluated as separate sensor named <device>/<type>; use "-" as the type
+// of a column that should not be evaluated.
+func compoundChannels(header []string, opts Options) ([]*channel, error) {
+	if len(header) < 2 {
+		return nil, errors.New(ErrCompoundNoChannels)
+	}
+	device := header[0]
+	ret := make([]*channel, 0, len(header)-1)
+	seen := make(map[string]bool)
+	for _, sensorType := range header[1:] {
+		if sensorType == ignoredChannel {
+			ret = append(ret, &channel{sensorType: sensorType})
+			continue
+		}
+		if _, ok := sensorTypes[sensorType]; !ok {
+			return nil, errors.New(fmt.Sprintf("%s: %q", ErrUnknownChannel, sensorType))
+		}
+		if seen[sensorType] {
+			return nil, errors.New(fmt.Sprintf("%s: %q", ErrDuplicateChannel, sensorType))
+		}
+		seen[sensorType] = true
+		ret = append(ret, newChannel(sensorType, device+"/"+sensorType, opts))
+	}
+	return ret, nil
+}
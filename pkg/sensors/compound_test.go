@@ -0,0 +1,75 @@
+package sensors
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+const compoundDevice = `reference 100 45
+compound dev-1 thermometer humidity -
+2007-04-05T22:00 100 45.1 1013
+2007-04-05T22:01 100.1 45.2 1012
+2007-04-05T22:02 99.9 46 1013
+thermometer temp-1
+2007-04-05T22:00 100`
+
+func TestCompoundReadings(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "sensors")
+	if err != nil {
+		t.Error("Error creating test log file")
+		return
+	}
+	defer os.Remove(tmpFile.Name())
+
+	t.Run("compound device", func(t *testing.T) {
+		if err := writeTestLogFile(tmpFile, compoundDevice); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		val, err := processTestLogFile(tmpFile.Name(), Options{})
+		assertError(t, err, nil)
+		assertString(t, val, `{
+  "dev-1/humidity": "discard",
+  "dev-1/thermometer": "ultra precise",
+  "temp-1": "ultra precise"
+}`)
+	})
+
+	t.Run("missing value", func(t *testing.T) {
+		if err := writeTestLogFile(tmpFile, "reference 100 45\ncompound dev-1 thermometer humidity\n2007-04-05T22:00 100"); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		_, err := processTestLogFile(tmpFile.Name(), Options{})
+		var readingErr *WrongReadingFieldsError
+		if !errors.As(err, &readingErr) {
+			t.Fatalf("got error %v, want WrongReadingFieldsError", err)
+		}
+		assertInt(t, readingErr.Line, 3)
+	})
+
+	t.Run("unknown channel", func(t *testing.T) {
+		if err := writeTestLogFile(tmpFile, "reference 100 45\ncompound dev-1 thermometer pressure"); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		_, err := processTestLogFile(tmpFile.Name(), Options{})
+		var headerErr *InvalidHeaderError
+		if !errors.As(err, &headerErr) {
+			t.Fatalf("got error %v, want InvalidHeaderError", err)
+		}
+		assertInt(t, headerErr.Line, 2)
+		assertErrorMessageSubString(t, err, ErrUnknownChannel)
+	})
+
+	t.Run("no channels", func(t *testing.T) {
+		if err := writeTestLogFile(tmpFile, "reference 100 45\ncompound dev-1"); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		_, err := processTestLogFile(tmpFile.Name(), Options{})
+		assertErrorMessageSubString(t, err, ErrCompoundNoChannels)
+	})
+}
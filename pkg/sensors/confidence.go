@@ -0,0 +1,33 @@
+package sensors
+
+import (
+	"math"
+)
+
+// scored is the sensor that knows how confident its branding is, see SensorResult.Confidence
+type scored interface {
+	Confidence() float64
+}
+
+func (s *sensor) Confidence() float64 {
+	return s.confidence
+}
+
+// Return the confidence of the value at the distance from the center of the band of given half width
+// being inside the band: 1 at the center, falling linearly to 0 at the edge
+func confidenceInside(distance, halfWidth float64) float64 {
+	return clamp01(1 - distance/halfWidth)
+}
+
+// Return the confidence of the value at the distance from the center of the band of given half width
+// being outside the band: 0 at the edge, rising linearly to 1 at twice the half width
+func confidenceOutside(distance, halfWidth float64) float64 {
+	return clamp01(distance/halfWidth - 1)
+}
+
+func clamp01(v float64) float64 {
+	if math.IsNaN(v) {
+		return 0
+	}
+	return math.Max(0, math.Min(1, v))
+}
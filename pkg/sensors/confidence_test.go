@@ -0,0 +1,103 @@
+package sensors
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+const confidenceSensors = `reference 100 45 12
+thermometer temp-tight
+2007-04-05T22:00 100
+2007-04-05T22:01 100.1
+2007-04-05T22:02 99.9
+thermometer temp-loose
+2007-04-05T22:00 98
+2007-04-05T22:01 102.2
+2007-04-05T22:02 99.9
+thermometer temp-off
+2007-04-05T22:00 100.4
+2007-04-05T22:01 100.3
+thermometer temp-far
+2007-04-05T22:00 120
+humidity hum-tight
+2007-04-05T22:00 45
+humidity hum-loose
+2007-04-05T22:00 45.4
+flow flow-tight
+2007-04-05T22:00 12.1
+flow flow-loose
+2007-04-05T22:00 13
+flow flow-high
+2007-04-05T22:00 20`
+
+func assertConfidence(t testing.TB, got, want float64) {
+	t.Helper()
+
+	if math.Abs(got-want) > 0.001 {
+		t.Errorf("got confidence %f, want %f", got, want)
+	}
+}
+
+func TestConfidence(t *testing.T) {
+	res, err := ProcessReader(strings.NewReader(confidenceSensors), Options{})
+	assertError(t, err, nil)
+	confidence := make(map[string]float64)
+	for _, s := range res.Sensors {
+		confidence[s.Name] = s.Confidence
+	}
+
+	// tighter readings of the same branding are more confident
+	tighter := []struct{ tight, loose string }{
+		{"temp-tight", "temp-loose"},
+		{"temp-tight", "temp-off"},
+		{"hum-tight", "hum-loose"},
+		{"flow-tight", "flow-loose"},
+	}
+	for _, c := range tighter {
+		if confidence[c.tight] <= confidence[c.loose] {
+			t.Errorf("got confidence %f of %s, want more than %f of %s", confidence[c.tight], c.tight, confidence[c.loose], c.loose)
+		}
+	}
+
+	// mean 100.35 is 0.15 inside the tolerance of 0.5, the std deviation is far from the limit
+	assertConfidence(t, confidence["temp-off"], 0.3)
+	assertConfidence(t, confidence["hum-tight"], 1)
+	assertConfidence(t, confidence["hum-loose"], 1-0.4/0.45)
+	// clearly outside the band of very precise thermometers and normal flow
+	assertConfidence(t, confidence["temp-far"], 1)
+	assertConfidence(t, confidence["flow-high"], 1)
+}
+
+func TestConfidenceBoundaries(t *testing.T) {
+	cases := []struct {
+		name     string
+		content  string
+		branding string
+		want     float64
+	}{
+		{"thermometer at the std limit", "reference 100 0\nthermometer t\n2007-04-05T22:00 97\n2007-04-05T22:01 100\n2007-04-05T22:02 103", ThermometerVeryPrecise, 0.4},
+		{"thermometer just over the limits", "reference 100 0\nthermometer t\n2007-04-05T22:00 100.6", ThermometerPrecise, 0.2},
+		{"flow at the edge", "reference 0 0 10\nflow f\n2007-04-05T22:00 11", FlowSensorNormal, 0},
+		{"negative humidity", "reference 0 0\nhumidity h\n2007-04-05T22:00 -0.05", HumiditySensorDiscard, 1},
+		{"no readings", "reference 100 0\nthermometer t", ThermometerPrecise, 0},
+		{"quiet", "reference 0 0\nsound s\n2007-04-05T22:00 50", SoundSensorQuiet, 0.5},
+		{"loud in the middle", "reference 0 0\nsound s\n2007-04-05T22:00 60", SoundSensorLoud, 1},
+		{"excessive", "reference 0 0\nsound s\n2007-04-05T22:00 70", SoundSensorExcessive, 0.5},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res, err := ProcessReader(strings.NewReader(c.content), Options{})
+			assertError(t, err, nil)
+			assertString(t, res.Sensors[0].Branding, c.branding)
+			assertConfidence(t, res.Sensors[0].Confidence, c.want)
+		})
+	}
+
+	t.Run("replaced branding is certain", func(t *testing.T) {
+		res, err := ProcessReader(strings.NewReader("reference 100 0\nthermometer t\n2007-04-05T22:00 100.4"), Options{MinReadings: 2})
+		assertError(t, err, nil)
+		assertString(t, res.Sensors[0].Branding, SensorInsufficientData)
+		assertConfidence(t, res.Sensors[0].Confidence, 1)
+	})
+}
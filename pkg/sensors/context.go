@@ -0,0 +1,157 @@
+package sensors
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ProcessLogFileContext is ProcessLogFile which gives up when the context is done, e.g. when the processing
+// of a corrupt file with enormous number of readings takes too long; the error then wraps the context error.
+// The writes to Options.Store are kept until the processing succeeds, the file given up on writes nothing.
+func ProcessLogFileContext(ctx context.Context, filePath string, opts Options) (*Result, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrOpenFile)
+	}
+	defer file.Close()
+	return ProcessReaderContext(ctx, file, opts)
+}
+
+// ProcessReaderContext is ProcessReader which gives up when the context is done, see ProcessLogFileContext
+func ProcessReaderContext(ctx context.Context, r io.Reader, opts Options) (*Result, error) {
+	if opts.Parallelism > 1 && !opts.statefulBranding() {
+		return processParallel(ctx, r, opts)
+	}
+	return ProcessStream(ctx, r, opts, nil)
+}
+
+// ProcessStream is ProcessReaderContext which calls sensorDone with the result of each sensor as soon as its block
+// of readings is complete, before the rest of the log file is read; so the log file still being written, e.g. streamed
+// over HTTP, is branded as it comes. An error of sensorDone stops the processing. The sensors are branded one by one,
+// Options.Parallelism is not used. Nil sensorDone is ProcessReaderContext.
+func ProcessStream(ctx context.Context, r io.Reader, opts Options, sensorDone func(SensorResult) error) (*Result, error) {
+	opts, pending := withPendingStore(ctx, opts)
+	res := &Result{Sensors: make([]SensorResult, 0)}
+	err := parse(&contextReader{ctx: ctx, r: r}, opts, func(b block) error {
+		name, branding, err := brandBlockContext(ctx, b, opts)
+		if err != nil {
+			return err
+		}
+		r, err := sensorResult(b.channel, name, branding, opts)
+		if err != nil {
+			return err
+		}
+		res.Sensors = append(res.Sensors, r)
+		if sensorDone != nil {
+			return sensorDone(r)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := pending.commit(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// pendingStore keeps the writes to Store until the processing succeeds, so that the log file given up on
+// leaves no state behind, not even from the branding left running in the background (see brandBlockContext)
+type pendingStore struct {
+	next Store
+	mu   sync.Mutex
+	// the values written, and their keys in the order of writing
+	values map[string]string
+	keys   []string
+}
+
+// Return the options with the pendingStore in place of the Store, when the processing can give up;
+// the nil pendingStore otherwise, whose commit does nothing
+func withPendingStore(ctx context.Context, opts Options) (Options, *pendingStore) {
+	if ctx.Done() == nil || opts.Store == nil {
+		return opts, nil
+	}
+	s := &pendingStore{next: opts.Store, values: make(map[string]string)}
+	opts.Store = s
+	return opts, s
+}
+
+// Get returns the value written, or the one in the store
+func (s *pendingStore) Get(key string) (string, error) {
+	s.mu.Lock()
+	value, ok := s.values[key]
+	s.mu.Unlock()
+	if ok {
+		return value, nil
+	}
+	return s.next.Get(key)
+}
+
+// Set keeps the value until the commit
+func (s *pendingStore) Set(key, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.values[key]; !ok {
+		s.keys = append(s.keys, key)
+	}
+	s.values[key] = value
+	return nil
+}
+
+// Write the values to the store
+func (s *pendingStore) commit() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, k := range s.keys {
+		if err := s.next.Set(k, s.values[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// contextReader stops reading when the context is done, so that the parsing of huge file gives up as well
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, errors.Wrap(err, ErrProcessingAborted)
+	}
+	return r.r.Read(p)
+}
+
+// brandBlock which gives up when the context is done. The sensor Process can't be interrupted,
+// so it's left running in the background and its result is thrown away, with its writes to the pendingStore.
+func brandBlockContext(ctx context.Context, b block, opts Options) (string, string, error) {
+	if ctx.Done() == nil {
+		// the context is never done
+		return brandBlock(b, opts)
+	}
+	type result struct {
+		name, branding string
+		err            error
+	}
+	done := make(chan result, 1)
+	go func() {
+		name, branding, err := brandBlock(b, opts)
+		done <- result{name: name, branding: branding, err: err}
+	}()
+	select {
+	case r := <-done:
+		return r.name, r.branding, r.err
+	case <-ctx.Done():
+		return "", "", errors.Wrap(ctx.Err(), fmt.Sprintf("%s at sensor %s", ErrProcessingAborted, b.channel.sensor.Name()))
+	}
+}
@@ -0,0 +1,135 @@
+package sensors
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// slowSensor takes its time to process the readings
+type slowSensor struct {
+	sensor
+	delay time.Duration
+}
+
+func (s *slowSensor) Name() string {
+	return s.name
+}
+
+func (s *slowSensor) Branding() string {
+	return s.branding
+}
+
+func (s *slowSensor) Process(referenceValues map[string]float64, readings []float64) {
+	time.Sleep(s.delay)
+}
+
+// Return the options with the slow sensors, which take the delay to process the readings
+func slowSensorOptions(opts Options, delay time.Duration) Options {
+	opts.newSensor = func(sensorType, name string, thresholds Thresholds) Sensor {
+		return &slowSensor{sensor: sensor{branding: sensorTypes[sensorType].defaultBranding, name: name, thresholds: thresholds.withDefaults()}, delay: delay}
+	}
+	return opts
+}
+
+func TestProcessingTimeout(t *testing.T) {
+	t.Run("slow sensor aborted", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		_, err := ProcessReaderContext(ctx, strings.NewReader(tempUltraPrecise), slowSensorOptions(Options{}, time.Second))
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("got error %v, want deadline exceeded", err)
+		}
+		assertErrorMessageSubString(t, err, ErrProcessingAborted+" at sensor temp-1")
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("processing took %s, want it aborted after the timeout", elapsed)
+		}
+	})
+
+	t.Run("aborted file leaves no state", func(t *testing.T) {
+		cache := memStore{}
+		opts := slowSensorOptions(Options{UseBaseline: true, InheritReference: true, Store: cache}, 200*time.Millisecond)
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err := ProcessReaderContext(ctx, strings.NewReader(tempUltraPrecise), opts)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("got error %v, want deadline exceeded", err)
+		}
+		// the branding left in the background is done by now
+		time.Sleep(300 * time.Millisecond)
+		assertInt(t, len(cache), 0)
+
+		// the same file in time
+		ctx, cancel = context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		_, err = ProcessReaderContext(ctx, strings.NewReader(tempUltraPrecise), slowSensorOptions(opts, 0))
+		assertError(t, err, nil)
+		if _, err := cache.Get(baselineKey(ThermometerLabel, "temp-1")); err != nil {
+			t.Errorf("baseline not saved: %v", err)
+		}
+		if _, ok, _ := loadReference(cache); !ok {
+			t.Error("reference not saved")
+		}
+	})
+
+	t.Run("in time", func(t *testing.T) {
+		res, err := ProcessReaderContext(context.Background(), strings.NewReader(tempUltraPrecise), Options{})
+		assertError(t, err, nil)
+		assertString(t, res.Brandings()["temp-1"], ThermometerUltraPrecise)
+	})
+
+	t.Run("reading aborted", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := ProcessReaderContext(ctx, strings.NewReader(tempUltraPrecise), Options{})
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("got error %v, want context canceled", err)
+		}
+		assertErrorMessageSubString(t, err, ErrProcessingAborted)
+	})
+}
+
+func TestProcessStream(t *testing.T) {
+	pr, pw := io.Pipe()
+	done := make(chan SensorResult)
+	type result struct {
+		res *Result
+		err error
+	}
+	finished := make(chan result, 1)
+	go func() {
+		res, err := ProcessStream(context.Background(), pr, Options{}, func(r SensorResult) error {
+			done <- r
+			return nil
+		})
+		finished <- result{res, err}
+	}()
+
+	// the block of temp-1 is complete with the header of the next sensor, while the file is still being written
+	io.WriteString(pw, "reference 100 45\nthermometer temp-1\n2007-04-05T22:00 100\nhumidity hum-1\n")
+	select {
+	case r := <-done:
+		assertString(t, r.Name, "temp-1")
+		assertString(t, r.Branding, ThermometerUltraPrecise)
+	case <-time.After(time.Second):
+		t.Fatal("temp-1 not branded before the end of the file")
+	}
+	io.WriteString(pw, "2007-04-05T22:00 47\n")
+	pw.Close()
+	r := <-done
+	assertString(t, r.Name, "hum-1")
+	assertString(t, r.Branding, HumiditySensorDiscard)
+	f := <-finished
+	assertError(t, f.err, nil)
+	assertInt(t, len(f.res.Sensors), 2)
+
+	_, err := ProcessStream(context.Background(), strings.NewReader(tempUltraPrecise), Options{}, func(r SensorResult) error {
+		return errors.New("stop")
+	})
+	assertErrorMessageSubString(t, err, "stop")
+}
@@ -0,0 +1,75 @@
+package sensors
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	EncodingHex    = "hex"
+	EncodingBase64 = "base64"
+
+	// the raw value must fit into int64/uint64
+	maxEncodedBytes = 8
+)
+
+// Decoder converts the raw reading values logged by embedded devices to numbers: the value is
+// hex or base64 encoded big-endian integer which is multiplied by Scale, e.g. "0fa0" with Scale 0.01
+// is 40.0
+type Decoder struct {
+	// Encoding is EncodingHex or EncodingBase64
+	Encoding string
+	// Scale converts the raw integer to the unit of the sensor; zero means 1
+	Scale float64
+	// Signed interprets the raw integer as two's complement
+	Signed bool
+}
+
+// Convert the encoded reading value to a number
+func (d Decoder) decode(s string) (float64, error) {
+	var raw []byte
+	var err error
+	switch d.Encoding {
+	case EncodingHex:
+		raw, err = hex.DecodeString(s)
+	case EncodingBase64:
+		raw, err = base64.StdEncoding.DecodeString(s)
+	default:
+		return 0, errors.New(fmt.Sprintf("unknown encoding %q", d.Encoding))
+	}
+	if err != nil {
+		return 0, err
+	}
+	if len(raw) == 0 || len(raw) > maxEncodedBytes {
+		return 0, errors.New(fmt.Sprintf("encoded value must have 1 to %d bytes, got %d", maxEncodedBytes, len(raw)))
+	}
+	var u uint64
+	for _, b := range raw {
+		u = u<<8 | uint64(b)
+	}
+	value := float64(u)
+	if d.Signed {
+		// sign-extend from the number of bytes of the value
+		shift := uint(64 - 8*len(raw))
+		value = float64(int64(u<<shift) >> shift)
+	}
+	if d.Scale != 0 {
+		value *= d.Scale
+	}
+	return value, nil
+}
+
+// Convert the reading value of the channel to a number, decoding it if the sensor type has a decoder
+func (c *channel) parseValue(s string) (float64, error) {
+	if c.decoder == nil {
+		if c.unit != "" {
+			return parseWithUnit(s, c.unit)
+		}
+		return strconv.ParseFloat(s, 64)
+	}
+	return c.decoder.decode(s)
+}
@@ -0,0 +1,85 @@
+package sensors
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"testing"
+)
+
+func TestDecoder(t *testing.T) {
+	cases := []struct {
+		decoder Decoder
+		raw     string
+		want    float64
+	}{
+		{Decoder{Encoding: EncodingHex}, "0fa0", 4000},
+		{Decoder{Encoding: EncodingHex, Scale: 0.01}, "0FA0", 40},
+		{Decoder{Encoding: EncodingHex, Scale: 0.01}, "fc18", 645.36},
+		{Decoder{Encoding: EncodingHex, Scale: 0.01, Signed: true}, "fc18", -10},
+		{Decoder{Encoding: EncodingHex, Signed: true}, "7f", 127},
+		{Decoder{Encoding: EncodingBase64, Scale: 0.1}, "AcI=", 45},
+	}
+	for _, c := range cases {
+		t.Run(c.decoder.Encoding+" "+c.raw, func(t *testing.T) {
+			got, err := c.decoder.decode(c.raw)
+			assertError(t, err, nil)
+			if math.Abs(got-c.want) > 1e-9 {
+				t.Errorf("got %f, want %f", got, c.want)
+			}
+		})
+	}
+
+	for _, raw := range []string{"0g", "", "0102030405060708090a"} {
+		if _, err := (Decoder{Encoding: EncodingHex}).decode(raw); err == nil {
+			t.Errorf("decoding %q succeeded, want error", raw)
+		}
+	}
+}
+
+func TestDecodedReadings(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "sensors")
+	if err != nil {
+		t.Error("Error creating test log file")
+		return
+	}
+	defer os.Remove(tmpFile.Name())
+
+	// temperatures 20.00, 20.10 and 19.90, humidity 45.0 and 45.1 (plain numbers)
+	content := `reference 20 45
+thermometer temp-1
+2007-04-05T22:00 07d0
+2007-04-05T22:01 07da
+2007-04-05T22:02 07c6
+humidity hum-1
+2007-04-05T22:00 45
+2007-04-05T22:01 45.1`
+	if err := writeTestLogFile(tmpFile, content); err != nil {
+		t.Error("Error writing test log file")
+		return
+	}
+	opts := Options{Decoders: map[string]Decoder{ThermometerLabel: {Encoding: EncodingHex, Scale: 0.01}}}
+
+	t.Run("hex thermometer", func(t *testing.T) {
+		val, err := processTestLogFile(tmpFile.Name(), opts)
+		assertError(t, err, nil)
+		assertString(t, val, `{
+  "hum-1": "keep",
+  "temp-1": "ultra precise"
+}`)
+	})
+
+	t.Run("not decoded", func(t *testing.T) {
+		_, err := processTestLogFile(tmpFile.Name(), Options{})
+		assertErrorMessageSubString(t, err, ErrReadingNotFloat)
+	})
+
+	t.Run("invalid encoded value", func(t *testing.T) {
+		if err := writeTestLogFile(tmpFile, "reference 20 45\nthermometer temp-1\n2007-04-05T22:00 20.5"); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		_, err := processTestLogFile(tmpFile.Name(), opts)
+		assertErrorMessageSubString(t, err, ErrReadingNotFloat)
+	})
+}
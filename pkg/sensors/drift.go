@@ -0,0 +1,48 @@
+package sensors
+
+import (
+	"gonum.org/v1/gonum/stat"
+)
+
+// Fit a line to the readings with known timestamps and return its slope in units per hour;
+// ok is false when there are not enough readings (at least two distinct timestamps are needed)
+func readingsSlope(readings []reading) (slope float64, ok bool) {
+	var start float64
+	x := make([]float64, 0, len(readings))
+	y := make([]float64, 0, len(readings))
+	distinct := false
+	for _, r := range readings {
+		if r.time.IsZero() {
+			continue
+		}
+		// hours since the first reading, small numbers keep the regression precise
+		hours := float64(r.time.Unix()) / 3600
+		if len(x) == 0 {
+			start = hours
+		} else if hours != start {
+			distinct = true
+		}
+		x = append(x, hours-start)
+		y = append(y, r.value)
+	}
+	if !distinct {
+		return 0, false
+	}
+	_, slope = stat.LinearRegression(x, y, nil, false)
+	return slope, true
+}
+
+// Return the drift branding of the readings whose slope exceeds the threshold (in units per hour),
+// or empty string when the readings are stable
+func driftBranding(readings []reading, threshold float64) string {
+	slope, ok := readingsSlope(readings)
+	switch {
+	case !ok:
+		return ""
+	case slope > threshold:
+		return SensorDriftingUp
+	case slope < -threshold:
+		return SensorDriftingDown
+	}
+	return ""
+}
@@ -0,0 +1,80 @@
+package sensors
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"testing"
+)
+
+func TestReadingsSlope(t *testing.T) {
+	readings := []reading{
+		{time: parseTimestamp("2007-04-05T22:00"), value: 100},
+		{time: parseTimestamp("2007-04-05T22:30"), value: 101},
+		{time: parseTimestamp("2007-04-05T23:00"), value: 102},
+	}
+	slope, ok := readingsSlope(readings)
+	if !ok || math.Abs(slope-2) > 1e-9 {
+		t.Errorf("got slope %f (%t), want 2", slope, ok)
+	}
+	if _, ok := readingsSlope([]reading{{value: 100}, {value: 101}}); ok {
+		t.Error("got slope of readings without timestamps")
+	}
+	if _, ok := readingsSlope(readings[:1]); ok {
+		t.Error("got slope of single reading")
+	}
+}
+
+const tempDrifting = `reference 100 45
+thermometer temp-1
+2007-04-05T22:00 99.8
+2007-04-05T22:10 99.9
+2007-04-05T22:20 100
+2007-04-05T22:30 100.1
+2007-04-05T22:40 100.2
+thermometer temp-2
+2007-04-05T22:00 100.2
+2007-04-05T22:10 100.1
+2007-04-05T22:20 100
+2007-04-05T22:30 99.9
+2007-04-05T22:40 99.8
+thermometer temp-3
+2007-04-05T22:00 100
+2007-04-05T22:10 100.1
+2007-04-05T22:20 99.9
+2007-04-05T22:30 100
+2007-04-05T22:40 100`
+
+func TestDriftDetection(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "sensors")
+	if err != nil {
+		t.Error("Error creating test log file")
+		return
+	}
+	defer os.Remove(tmpFile.Name())
+	if err := writeTestLogFile(tmpFile, tempDrifting); err != nil {
+		t.Error("Error writing test log file")
+		return
+	}
+
+	t.Run("drift detected", func(t *testing.T) {
+		val, err := processTestLogFile(tmpFile.Name(), Options{DriftThreshold: 0.3})
+		assertError(t, err, nil)
+		assertString(t, val, `{
+  "temp-1": "drifting up",
+  "temp-2": "drifting down",
+  "temp-3": "ultra precise"
+}`)
+	})
+
+	// the drift is too small for the standard deviation to notice
+	t.Run("detection disabled", func(t *testing.T) {
+		val, err := processTestLogFile(tmpFile.Name(), Options{})
+		assertError(t, err, nil)
+		assertString(t, val, `{
+  "temp-1": "ultra precise",
+  "temp-2": "ultra precise",
+  "temp-3": "ultra precise"
+}`)
+	})
+}
@@ -0,0 +1,220 @@
+package sensors
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// the longest part of the offending line in the error messages, the lines may be very long
+const maxErrorLineText = 200
+
+// Return the location of the error in the log file for its message: the line number and the line as logged
+func lineContext(line int, text string) string {
+	if len(text) > maxErrorLineText {
+		text = text[:maxErrorLineText] + "..."
+	}
+	return fmt.Sprintf(" (line %d: %q)", line, text)
+}
+
+// WrongRefFieldsError is returned when the reference line has incorrect number of fields
+type WrongRefFieldsError struct {
+	// Line is the number of the offending line, starting from 1
+	Line int
+	// Text is the offending line as logged
+	Text string
+}
+
+func (e *WrongRefFieldsError) Error() string {
+	return ErrWrongNumberRefFields + lineContext(e.Line, e.Text)
+}
+
+// InvalidReferenceError is returned when the labeled reference line is malformed
+type InvalidReferenceError struct {
+	// Line is the number of the offending line, starting from 1
+	Line int
+	// Text is the offending line as logged
+	Text string
+	// Msg describes the problem
+	Msg string
+}
+
+func (e *InvalidReferenceError) Error() string {
+	return e.Msg + lineContext(e.Line, e.Text)
+}
+
+// MissingReferenceError is returned when the reference line in effect for a sensor doesn't give
+// the quantity the sensor is compared against, e.g. the flow left out by the positional line
+type MissingReferenceError struct {
+	// Line is the number of the sensor header, starting from 1, and Text the header as logged
+	Line int
+	Text string
+	// Sensor is the name of the sensor
+	Sensor string
+	// Quantity is the missing reference quantity, e.g. "Humidity"
+	Quantity string
+}
+
+func (e *MissingReferenceError) Error() string {
+	return fmt.Sprintf("%s: %s has no %s reference", ErrMissingReference, e.Sensor, e.Quantity) + lineContext(e.Line, e.Text)
+}
+
+// ConflictingReferenceError is returned in the strict reference mode when a reference line has other
+// values than the previous one of the same section, i.e. with no sensor in between
+type ConflictingReferenceError struct {
+	// Line is the number of the offending line, starting from 1
+	Line int
+	// PreviousLine is the number of the reference line it conflicts with
+	PreviousLine int
+	// Text is the offending line as logged
+	Text string
+}
+
+func (e *ConflictingReferenceError) Error() string {
+	return fmt.Sprintf("%s on line %d", ErrConflictingReference, e.PreviousLine) + lineContext(e.Line, e.Text)
+}
+
+// IncompleteFileError is returned when the log file doesn't end with Options.EndMarker,
+// e.g. because it was still being written when downloaded
+type IncompleteFileError struct {
+	// Marker is the expected last line
+	Marker string
+}
+
+func (e *IncompleteFileError) Error() string {
+	return fmt.Sprintf("%s: missing end marker %q", ErrIncompleteFile, e.Marker)
+}
+
+// LineTooLongError is returned for the line longer than Options.MaxLineLength, usually a file with
+// missing newlines; only the line number is known, the line itself isn't read
+type LineTooLongError struct {
+	// Line is the number of the offending line, starting from 1
+	Line int
+	// MaxLength is the limit in bytes
+	MaxLength int
+}
+
+func (e *LineTooLongError) Error() string {
+	return fmt.Sprintf("%s: line %d is longer than %d bytes", ErrLineTooLong, e.Line, e.MaxLength)
+}
+
+// InvalidJSONLogError is returned for the JSON log file that doesn't follow the format, see InputFormatJSON;
+// the readings of valid items are checked the same way as in the text log file, the error of the text
+// log file (e.g. InvalidValueError) is then Err, reported for the item
+type InvalidJSONLogError struct {
+	// Item is the position of the offending item in the array starting from 1, 0 for the array itself
+	Item int
+	// Msg describes the problem
+	Msg string
+	// Err is the error of the item's reading or reference as of the text log file, nil for the format errors
+	Err error
+}
+
+func (e *InvalidJSONLogError) Error() string {
+	return fmt.Sprintf("%s: item %d: %s", ErrInvalidJSONLog, e.Item, e.Msg)
+}
+
+func (e *InvalidJSONLogError) Unwrap() error {
+	return e.Err
+}
+
+// WrongReadingFieldsError is returned when the line with readings has incorrect number of fields
+type WrongReadingFieldsError struct {
+	// Line is the number of the offending line, starting from 1
+	Line int
+	// Text is the offending line as logged
+	Text string
+}
+
+func (e *WrongReadingFieldsError) Error() string {
+	return ErrWrongNumberRedingFields + lineContext(e.Line, e.Text)
+}
+
+// NonFiniteValueError is returned for NaN or infinite reading, unless Options.NonFinitePolicy allows them
+type NonFiniteValueError struct {
+	// Line is the number of the offending line, starting from 1
+	Line int
+	// Value is the reading as logged, e.g. "NaN" or "+Inf"
+	Value string
+	// Text is the offending line as logged
+	Text string
+}
+
+func (e *NonFiniteValueError) Error() string {
+	return fmt.Sprintf("%s: %q", ErrReadingNotFinite, e.Value) + lineContext(e.Line, e.Text)
+}
+
+// InvalidHeaderError is returned when the sensor header line is malformed
+type InvalidHeaderError struct {
+	// Line is the number of the offending line, starting from 1
+	Line int
+	// Msg describes the problem
+	Msg string
+	// Text is the offending line as logged
+	Text string
+}
+
+func (e *InvalidHeaderError) Error() string {
+	return e.Msg + lineContext(e.Line, e.Text)
+}
+
+// UnknownSensorTypeError is returned for the line that looks like a sensor header, but of unknown
+// sensor type, see Options.UnknownTypePolicy
+type UnknownSensorTypeError struct {
+	// Line is the number of the offending line, starting from 1
+	Line int
+	// Text is the offending line as logged
+	Text string
+	// Type is the unknown sensor type
+	Type string
+}
+
+func (e *UnknownSensorTypeError) Error() string {
+	labels := make([]string, 0, len(sensorTypes)+1)
+	for label := range sensorTypes {
+		labels = append(labels, label)
+	}
+	labels = append(labels, CompoundLabel)
+	sort.Strings(labels)
+	return fmt.Sprintf("%s %q, expected one of %s", ErrUnknownSensorType, e.Type, strings.Join(labels, ", ")) +
+		lineContext(e.Line, e.Text)
+}
+
+// UnitMismatchError is the reason of InvalidValueError for the reading value with other unit than
+// the one in Options.Units
+type UnitMismatchError struct {
+	// Unit is what follows the number of the reading as logged, e.g. "F"
+	Unit string
+	// Expected is the unit of the sensor type in Options.Units, e.g. "C"
+	Expected string
+}
+
+func (e *UnitMismatchError) Error() string {
+	return fmt.Sprintf("unit %q doesn't match the expected unit %q", e.Unit, e.Expected)
+}
+
+// InvalidValueError is returned when a value in the log file cannot be converted to a number
+type InvalidValueError struct {
+	// Line is the number of the offending line, starting from 1
+	Line int
+	// Msg is one of ErrTempNotFloat, ErrHumidityNotFloat, ErrFlowNotFloat, ErrReadingNotFloat
+	// or ErrHeaderRefNotFloat
+	Msg string
+	// Err is the underlying conversion error
+	Err error
+	// Text is the offending line as logged
+	Text string
+}
+
+func (e *InvalidValueError) Error() string {
+	return e.Msg + ": " + e.Err.Error() + lineContext(e.Line, e.Text)
+}
+
+func (e *InvalidValueError) Unwrap() error {
+	return e.Err
+}
+
+// Cause makes the error compatible with github.com/pkg/errors
+func (e *InvalidValueError) Cause() error {
+	return e.Err
+}
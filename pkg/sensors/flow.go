@@ -0,0 +1,42 @@
+package sensors
+
+import (
+	"math"
+
+	"gonum.org/v1/gonum/stat"
+)
+
+type flowSensor struct {
+	sensor
+}
+
+func (s *flowSensor) Name() string {
+	return s.name
+}
+
+func (s *flowSensor) Branding() string {
+	return s.branding
+}
+
+// Process flow sensor (readings in L/min):
+// It is branded "normal" if the mean of the readings is within FlowBand percent of the reference flow,
+// "low" if it's under and "high" if it's over that band.
+func (s *flowSensor) Process(referenceValues map[string]float64, readings []float64) {
+	if len(readings) == 0 {
+		return
+	}
+	referenceFlow := referenceValues["Flow"]
+	band := referenceFlow * s.thresholds.FlowBand / 100
+
+	mean := stat.Mean(readings, nil)
+	if mean < referenceFlow-band {
+		s.branding = FlowSensorLow
+	} else if mean > referenceFlow+band {
+		s.branding = FlowSensorHigh
+	}
+	if s.branding == FlowSensorNormal {
+		s.confidence = confidenceInside(math.Abs(mean-referenceFlow), band)
+	} else {
+		s.confidence = confidenceOutside(math.Abs(mean-referenceFlow), band)
+	}
+}
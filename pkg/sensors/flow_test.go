@@ -0,0 +1,69 @@
+package sensors
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+const flowSensors = `reference 20 45 12.5
+flow flow-normal
+2007-04-05T22:00 12
+2007-04-05T22:01 13
+2007-04-05T22:02 12.6
+flow flow-low
+2007-04-05T22:00 10
+2007-04-05T22:01 11
+flow flow-high
+2007-04-05T22:00 14
+2007-04-05T22:01 14.5
+flow flow-no-data`
+
+func TestFlowSensors(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "sensors")
+	if err != nil {
+		t.Error("Error creating test log file")
+		return
+	}
+	defer os.Remove(tmpFile.Name())
+
+	t.Run("default band", func(t *testing.T) {
+		if err := writeTestLogFile(tmpFile, flowSensors); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		val, err := processTestLogFile(tmpFile.Name(), Options{})
+		assertError(t, err, nil)
+		assertString(t, val, `{
+  "flow-high": "high",
+  "flow-low": "low",
+  "flow-no-data": "normal",
+  "flow-normal": "normal"
+}`)
+	})
+
+	t.Run("wider band", func(t *testing.T) {
+		val, err := brandTestLogFile(tmpFile.Name(), Options{Thresholds: Thresholds{FlowBand: 20}})
+		assertError(t, err, nil)
+		assertString(t, val["flow-low"], FlowSensorNormal)
+		assertString(t, val["flow-high"], FlowSensorNormal)
+	})
+
+	t.Run("invalid reference flow", func(t *testing.T) {
+		if err := writeTestLogFile(tmpFile, "reference 20 45 a"); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		_, err := processTestLogFile(tmpFile.Name(), Options{})
+		assertErrorMessageSubString(t, err, ErrFlowNotFloat)
+	})
+
+	t.Run("too many reference values", func(t *testing.T) {
+		if err := writeTestLogFile(tmpFile, "reference 20 45 12.5 21 1"); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		_, err := processTestLogFile(tmpFile.Name(), Options{})
+		assertErrorMessageSubString(t, err, ErrWrongNumberRefFields)
+	})
+}
@@ -0,0 +1,54 @@
+package sensors
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+const lastBrandingKeyPrefix = "last-branding:"
+
+// the brandings of thermometers from the lowest tier
+var thermometerTiers = []string{ThermometerPrecise, ThermometerVeryPrecise, ThermometerUltraPrecise}
+
+func lastBrandingKey(sensorType, name string) string {
+	return fmt.Sprintf("%s%s:%s", lastBrandingKeyPrefix, sensorType, name)
+}
+
+// Return the tier of the thermometer branding, -1 for the others
+func thermometerTier(branding string) int {
+	for i, b := range thermometerTiers {
+		if b == branding {
+			return i
+		}
+	}
+	return -1
+}
+
+// Apply the hysteresis to the branding of the thermometer channel: when its last branding (kept in the store)
+// is one tier up, the thermometer keeps it unless it misses the std deviation limit of that tier by more than
+// the margin. The channel sensor is then replaced by the one branded with the relaxed limits. The resulting
+// branding is stored for the next log file.
+func applyHysteresis(store Store, c *channel, reference map[string]float64, margin float64, thresholds Thresholds) error {
+	name := c.sensor.Name()
+	key := lastBrandingKey(c.sensorType, name)
+	last, err := store.Get(key)
+	if err != nil && err != ErrNotFound {
+		return errors.Wrap(err, "failed reading last branding of "+name)
+	}
+	tier := thermometerTier(c.sensor.Branding())
+	if tier >= 0 && err == nil && thermometerTier(last) == tier+1 {
+		t := thresholds.withDefaults()
+		t.UltraPreciseStdDev += margin
+		t.VeryPreciseStdDev += margin
+		relaxed := NewSensor(c.sensorType, name, t)
+		relaxed.Process(reference, c.readings.values())
+		if relaxed.Branding() == last {
+			c.sensor = relaxed
+		}
+	}
+	if err := store.Set(key, c.sensor.Branding()); err != nil {
+		return errors.Wrap(err, "failed saving last branding of "+name)
+	}
+	return nil
+}
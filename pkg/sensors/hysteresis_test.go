@@ -0,0 +1,44 @@
+package sensors
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+// log file with a thermometer of the mean 100 and std deviation std
+func hysteresisLog(std float64) string {
+	// the sample std deviation of two readings is their distance from the mean times sqrt(2)
+	d := std / math.Sqrt2
+	return fmt.Sprintf("reference 100 45\nthermometer temp-1\n2007-04-05T22:00 %f\n2007-04-05T22:01 %f\n", 100-d, 100+d)
+}
+
+func TestHysteresis(t *testing.T) {
+	store := memStore{}
+	opts := Options{HysteresisMargin: 0.5, Store: store}
+	runs := []struct {
+		name string
+		std  float64
+		want string
+	}{
+		{"first run", 2.9, ThermometerUltraPrecise},
+		{"borderline keeps the tier", 3.1, ThermometerUltraPrecise},
+		{"over the margin goes down", 3.6, ThermometerVeryPrecise},
+		{"back under the margin stays down", 3.1, ThermometerVeryPrecise},
+		{"going up is immediate", 2.9, ThermometerUltraPrecise},
+	}
+	for _, r := range runs {
+		t.Run(r.name, func(t *testing.T) {
+			res, err := ProcessReader(strings.NewReader(hysteresisLog(r.std)), opts)
+			assertError(t, err, nil)
+			assertString(t, res.Brandings()["temp-1"], r.want)
+			assertString(t, store[lastBrandingKey(ThermometerLabel, "temp-1")], r.want)
+		})
+	}
+
+	// without the hysteresis, the borderline thermometer flips
+	res, err := ProcessReader(strings.NewReader(hysteresisLog(3.1)), Options{})
+	assertError(t, err, nil)
+	assertString(t, res.Brandings()["temp-1"], ThermometerVeryPrecise)
+}
@@ -0,0 +1,238 @@
+package sensors
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// InputFormatText is the space delimited log file, the default
+	InputFormatText = "text"
+	// InputFormatJSON is the JSON array of the reference and reading objects, see jsonLogItem
+	InputFormatJSON = "json"
+	// InputFormatAuto reads the log file starting with '[' as JSON, any other as text
+	InputFormatAuto = "auto"
+)
+
+// jsonLogItem is an element of the JSON log file, which is an array of the reference objects and
+// the reading objects in the order of the text log file:
+//
+//	[
+//	  {"reference": {"temperature": 100, "humidity": 45, "room": 22}},
+//	  {"type": "thermometer", "sensor": "temp-1", "time": "2007-04-05T22:00", "value": 100.2},
+//	  {"type": "humidity", "sensor": "hum-1", "time": "2007-04-05T22:00", "value": 45.1, "expected": 45}
+//	]
+//
+// The reference has the quantities of the labeled reference line (null means missing), the value
+// and the optional expected value (see expectedSensor) are numbers, or strings for the raw values
+// of Options.Decoders. The consecutive readings of the same
+// sensor are its block of readings, as those after its header in the text log file.
+type jsonLogItem struct {
+	Reference map[string]*json.Number `json:"reference"`
+	Type      string                  `json:"type"`
+	Sensor    string                  `json:"sensor"`
+	Time      string                  `json:"time"`
+	Value     json.RawMessage         `json:"value"`
+	Expected  json.RawMessage         `json:"expected"`
+}
+
+// jsonItems are the items of the JSON log file the lines of the converted text one come from, for the errors
+type jsonItems struct {
+	mu sync.Mutex
+	// item of each line, starting from the first one
+	items []int
+}
+
+// Record the item of the next line
+func (j *jsonItems) add(item int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.items = append(j.items, item)
+}
+
+// Return the item of the line starting from 1, 0 when unknown
+func (j *jsonItems) item(line int) int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if line < 1 || line > len(j.items) {
+		return 0
+	}
+	return j.items[line-1]
+}
+
+// Return the error of the converted JSON log file as the InvalidJSONLogError of the item the offending
+// line comes from, instead of the line of the text log file nobody has seen; nil items mean the text log file
+func (j *jsonItems) error(err error) error {
+	if j == nil {
+		return err
+	}
+	line, text, ok := errorLine(err)
+	if !ok {
+		return err
+	}
+	msg := strings.TrimSuffix(err.Error(), lineContext(line, text))
+	return &InvalidJSONLogError{Item: j.item(line), Msg: msg, Err: err}
+}
+
+// Return the line of the log file the parsing error is about, and the line as logged; ok is false
+// for the errors without the line
+func errorLine(err error) (line int, text string, ok bool) {
+	switch e := err.(type) {
+	case *WrongRefFieldsError:
+		return e.Line, e.Text, true
+	case *InvalidReferenceError:
+		return e.Line, e.Text, true
+	case *MissingReferenceError:
+		return e.Line, e.Text, true
+	case *ConflictingReferenceError:
+		return e.Line, e.Text, true
+	case *WrongReadingFieldsError:
+		return e.Line, e.Text, true
+	case *NonFiniteValueError:
+		return e.Line, e.Text, true
+	case *InvalidHeaderError:
+		return e.Line, e.Text, true
+	case *UnknownSensorTypeError:
+		return e.Line, e.Text, true
+	case *InvalidValueError:
+		return e.Line, e.Text, true
+	}
+	return 0, "", false
+}
+
+// Return the reader of the log file in the format given by the options, the JSON log file converted
+// to the text one, which ends with the end marker (if any) as the end of the array proves it's complete;
+// items are those of the converted lines, nil for the text log file, and stop ends the conversion
+func inputReader(r io.Reader, format, endMarker string) (ret io.Reader, items *jsonItems, stop func()) {
+	if format == InputFormatAuto {
+		br := bufio.NewReader(r)
+		r = br
+		format = InputFormatText
+		for {
+			b, err := br.ReadByte()
+			if err != nil {
+				break
+			}
+			if b == ' ' || b == '\t' || b == '\r' || b == '\n' {
+				continue
+			}
+			br.UnreadByte()
+			if b == '[' {
+				format = InputFormatJSON
+			}
+			break
+		}
+	}
+	if format != InputFormatJSON {
+		return r, nil, func() {}
+	}
+	items = &jsonItems{}
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(jsonToText(r, pw, endMarker, items))
+	}()
+	return pr, items, func() { pr.Close() }
+}
+
+// Write the JSON log file read from r as the text log file, followed by the end marker unless it's empty;
+// the item of each line written is added to items
+func jsonToText(r io.Reader, w io.Writer, endMarker string, items *jsonItems) error {
+	d := json.NewDecoder(r)
+	if t, err := d.Token(); err != nil || t != json.Delim('[') {
+		return &InvalidJSONLogError{Item: 0, Msg: "the log file must be an array"}
+	}
+	bw := bufio.NewWriter(w)
+	var sensorType, sensorName string
+	for i := 1; d.More(); i++ {
+		var item jsonLogItem
+		if err := d.Decode(&item); err != nil {
+			return &InvalidJSONLogError{Item: i, Msg: err.Error()}
+		}
+		var line string
+		if item.Reference != nil {
+			if item.Type != "" || item.Sensor != "" || item.Value != nil {
+				return &InvalidJSONLogError{Item: i, Msg: "the reference can't have the reading fields"}
+			}
+			var err error
+			if line, err = jsonReferenceLine(item.Reference); err != nil {
+				return &InvalidJSONLogError{Item: i, Msg: err.Error()}
+			}
+		} else {
+			value, err := jsonReadingValue(item.Value)
+			if err != nil {
+				return &InvalidJSONLogError{Item: i, Msg: err.Error()}
+			}
+			for _, field := range []string{item.Type, item.Sensor, item.Time} {
+				if field == "" || strings.ContainsAny(field, " \t\r\n") {
+					return &InvalidJSONLogError{Item: i, Msg: "the reading must have the type, sensor and time without spaces"}
+				}
+			}
+			if item.Type != sensorType || item.Sensor != sensorName {
+				sensorType, sensorName = item.Type, item.Sensor
+				items.add(i)
+				fmt.Fprintf(bw, "%s %s\n", sensorType, sensorName)
+			}
+			line = item.Time + " " + value
+			if item.Expected != nil {
+				expected, err := jsonReadingValue(item.Expected)
+				if err != nil {
+					return &InvalidJSONLogError{Item: i, Msg: err.Error()}
+				}
+				line += " " + expected
+			}
+		}
+		items.add(i)
+		if _, err := fmt.Fprintln(bw, line); err != nil {
+			return err
+		}
+	}
+	if _, err := d.Token(); err != nil {
+		return &InvalidJSONLogError{Item: 0, Msg: err.Error()}
+	}
+	if endMarker != "" {
+		items.add(0)
+		fmt.Fprintln(bw, endMarker)
+	}
+	return bw.Flush()
+}
+
+// Return the labeled reference line of the JSON reference object
+func jsonReferenceLine(reference map[string]*json.Number) (string, error) {
+	var b bytes.Buffer
+	b.WriteString(ReferenceLabel)
+	// in the order of the labels, so that the errors are the same for the same reference
+	for _, label := range []string{"temperature", "humidity", "flow", "room", "sound"} {
+		if value := reference[label]; value != nil {
+			b.WriteString(" " + label + "=" + value.String())
+		}
+	}
+	for label := range reference {
+		if _, ok := referenceLabels[label]; !ok {
+			return "", errors.New(fmt.Sprintf("%s %q", ErrInvalidReferenceLabel, label))
+		}
+	}
+	return b.String(), nil
+}
+
+// Return the value of the JSON reading as logged in the text log file
+func jsonReadingValue(raw json.RawMessage) (string, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		if s == "" || strings.ContainsAny(s, " \t\r\n") {
+			return "", errors.New(fmt.Sprintf("invalid value %q", s))
+		}
+		return s, nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(raw, &n); err != nil {
+		return "", errors.New("the value must be a number or a string")
+	}
+	return n.String(), nil
+}
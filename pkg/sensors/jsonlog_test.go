@@ -0,0 +1,131 @@
+package sensors
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const jsonLogText = `reference 100 45 12 22
+thermometer temp-1
+2007-04-05T22:00 100
+2007-04-05T22:01 100.1
+humidity hum-1
+2007-04-05T22:00 45.2
+2007-04-05T22:01 45.6
+flow flow-1
+2007-04-05T22:00 12.5
+thermometer temp-2
+2007-04-05T22:00 94
+2007-04-05T22:01 106`
+
+const jsonLog = `[
+  {"reference": {"temperature": 100, "humidity": 45, "flow": 12, "room": 22}},
+  {"type": "thermometer", "sensor": "temp-1", "time": "2007-04-05T22:00", "value": 100},
+  {"type": "thermometer", "sensor": "temp-1", "time": "2007-04-05T22:01", "value": 100.1},
+  {"type": "humidity", "sensor": "hum-1", "time": "2007-04-05T22:00", "value": 45.2},
+  {"type": "humidity", "sensor": "hum-1", "time": "2007-04-05T22:01", "value": 45.6},
+  {"type": "flow", "sensor": "flow-1", "time": "2007-04-05T22:00", "value": 12.5},
+  {"type": "thermometer", "sensor": "temp-2", "time": "2007-04-05T22:00", "value": 94},
+  {"type": "thermometer", "sensor": "temp-2", "time": "2007-04-05T22:01", "value": 106}
+]`
+
+func TestJSONLog(t *testing.T) {
+	text, err := ProcessReader(strings.NewReader(jsonLogText), Options{})
+	assertError(t, err, nil)
+
+	for _, format := range []string{InputFormatJSON, InputFormatAuto} {
+		t.Run(format, func(t *testing.T) {
+			res, err := ProcessReader(strings.NewReader(jsonLog), Options{InputFormat: format})
+			assertError(t, err, nil)
+			assertInt(t, len(res.Sensors), len(text.Sensors))
+			for i, s := range res.Sensors {
+				if s.Name != text.Sensors[i].Name || s.Type != text.Sensors[i].Type || s.Branding != text.Sensors[i].Branding ||
+					s.Count != text.Sensors[i].Count {
+					t.Errorf("got sensor %+v, want %+v", s, text.Sensors[i])
+				}
+			}
+			assertString(t, res.Hash(), text.Hash())
+		})
+	}
+
+	t.Run("text with auto detection", func(t *testing.T) {
+		res, err := ProcessReader(strings.NewReader(jsonLogText), Options{InputFormat: InputFormatAuto})
+		assertError(t, err, nil)
+		assertString(t, res.Hash(), text.Hash())
+	})
+
+	t.Run("partial reference and raw values", func(t *testing.T) {
+		log := `[
+  {"reference": {"temperature": null, "humidity": 45}},
+  {"type": "humidity", "sensor": "hum-1", "time": "2007-04-05T22:00", "value": "11f8", "expected": "1202"}
+]`
+		decoders := map[string]Decoder{HumiditySensorLabel: {Encoding: EncodingHex, Scale: 0.01}}
+		res, err := ProcessReader(strings.NewReader(log), Options{InputFormat: InputFormatJSON, Decoders: decoders})
+		assertError(t, err, nil)
+		// 46 is within the band of the expected 46.1, not of the reference
+		assertString(t, res.Brandings()["hum-1"], HumiditySensorKeep)
+	})
+
+	cases := []struct {
+		name, log string
+		item      int
+	}{
+		{"not an array", `{"reference": {}}`, 0},
+		{"unknown reference label", `[{"reference": {"pressure": 1}}]`, 1},
+		{"missing sensor", `[{"reference": {"temperature": 100, "humidity": 45}}, {"type": "thermometer", "time": "2007-04-05T22:00", "value": 1}]`, 2},
+		{"value not a number", `[{"type": "thermometer", "sensor": "t", "time": "2007-04-05T22:00", "value": true}]`, 1},
+		{"unterminated", `[{"reference": {"temperature": 100, "humidity": 45}}`, 2},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := ProcessReader(strings.NewReader(c.log), Options{InputFormat: InputFormatJSON})
+			var jsonErr *InvalidJSONLogError
+			if !errors.As(err, &jsonErr) {
+				t.Fatalf("got error %v, want InvalidJSONLogError", err)
+			}
+			assertInt(t, jsonErr.Item, c.item)
+		})
+	}
+
+	t.Run("end marker", func(t *testing.T) {
+		res, err := ProcessReader(strings.NewReader(jsonLog), Options{InputFormat: InputFormatJSON, EndMarker: "# EOF"})
+		assertError(t, err, nil)
+		assertString(t, res.Hash(), text.Hash())
+		_, err = ProcessReader(strings.NewReader(jsonLog[:len(jsonLog)-2]), Options{InputFormat: InputFormatJSON, EndMarker: "# EOF"})
+		var jsonErr *InvalidJSONLogError
+		if !errors.As(err, &jsonErr) {
+			t.Fatalf("got error %v, want InvalidJSONLogError", err)
+		}
+	})
+
+	t.Run("invalid reading", func(t *testing.T) {
+		log := `[{"reference": {"temperature": 100, "humidity": 45}}, {"type": "thermometer", "sensor": "t", "time": "2007-04-05T22:00", "value": 1},
+{"type": "thermometer", "sensor": "t", "time": "2007-04-05T22:01", "value": "x"}]`
+		_, err := ProcessReader(strings.NewReader(log), Options{InputFormat: InputFormatJSON})
+		assertErrorMessageSubString(t, err, ErrReadingNotFloat)
+		// the item, not the line of the converted log file
+		var jsonErr *InvalidJSONLogError
+		if !errors.As(err, &jsonErr) {
+			t.Fatalf("got error %v, want InvalidJSONLogError", err)
+		}
+		assertInt(t, jsonErr.Item, 3)
+		if strings.Contains(err.Error(), "line") {
+			t.Errorf("got error %q, want no line of the converted log file", err)
+		}
+		var valueErr *InvalidValueError
+		if !errors.As(err, &valueErr) {
+			t.Errorf("got error %v, want InvalidValueError", err)
+		}
+	})
+
+	t.Run("missing reference quantity", func(t *testing.T) {
+		log := `[{"reference": {"temperature": 100}}, {"type": "humidity", "sensor": "hum-1", "time": "2007-04-05T22:00", "value": 45}]`
+		_, err := ProcessReader(strings.NewReader(log), Options{InputFormat: InputFormatJSON})
+		var jsonErr *InvalidJSONLogError
+		if !errors.As(err, &jsonErr) {
+			t.Fatalf("got error %v, want InvalidJSONLogError", err)
+		}
+		assertInt(t, jsonErr.Item, 2)
+	})
+}
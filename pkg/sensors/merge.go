@@ -0,0 +1,60 @@
+package sensors
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// merged readings of one sensor from several log files
+type mergedSensor struct {
+	channel        *channel
+	reference      map[string]float64
+	referenceFound bool
+}
+
+// MergeLogFiles processes several log files (given from the oldest) as one: the readings of each sensor
+// from all files are combined and the sensor gets single branding. The sensor is evaluated against
+// the reference valid for its readings in the last file it appears in.
+// The sensors of the result are in the order of their first appearance.
+func MergeLogFiles(filePaths []string, opts Options) (*Result, error) {
+	sensors := make(map[string]*mergedSensor)
+	names := make([]string, 0)
+	for _, filePath := range filePaths {
+		err := parseLogFile(filePath, opts, func(b block) error {
+			name := b.channel.sensor.Name()
+			m, ok := sensors[name]
+			if !ok {
+				m = &mergedSensor{channel: newChannel(b.channel.sensorType, name, opts)}
+				sensors[name] = m
+				names = append(names, name)
+			} else if m.channel.sensorType != b.channel.sensorType {
+				return errors.New(fmt.Sprintf("sensor %s is %s in %s, but %s before", name, b.channel.sensorType, filePath, m.channel.sensorType))
+			}
+			for _, r := range b.channel.readings.readings {
+				m.channel.add(r)
+			}
+			m.reference = b.reference
+			m.referenceFound = m.referenceFound || b.referenceFound
+			return nil
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed processing "+filePath)
+		}
+	}
+
+	res := &Result{Sensors: make([]SensorResult, 0, len(names))}
+	for _, name := range names {
+		m := sensors[name]
+		name, branding, err := brandBlock(block{channel: m.channel, reference: m.reference, referenceFound: m.referenceFound}, opts)
+		if err != nil {
+			return nil, err
+		}
+		r, err := sensorResult(m.channel, name, branding, opts)
+		if err != nil {
+			return nil, err
+		}
+		res.Sensors = append(res.Sensors, r)
+	}
+	return res, nil
+}
@@ -0,0 +1,66 @@
+package sensors
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+const mergeFirstDay = `reference 100 45
+thermometer temp-1
+2007-04-05T22:00 100
+2007-04-05T22:01 104
+humidity hum-1
+2007-04-05T22:00 45.1`
+
+const mergeSecondDay = `reference 100 45
+thermometer temp-1
+2007-04-06T22:00 96
+2007-04-06T22:01 100`
+
+func TestMergeLogFiles(t *testing.T) {
+	files := make([]string, 0)
+	for _, content := range []string{mergeFirstDay, mergeSecondDay} {
+		tmpFile, err := ioutil.TempFile("", "sensors")
+		if err != nil {
+			t.Fatal("Error creating test log file")
+		}
+		defer os.Remove(tmpFile.Name())
+		if err := writeTestLogFile(tmpFile, content); err != nil {
+			t.Fatal("Error writing test log file")
+		}
+		files = append(files, tmpFile.Name())
+	}
+
+	t.Run("files on their own", func(t *testing.T) {
+		for _, f := range files {
+			val, err := brandTestLogFile(f, Options{})
+			assertError(t, err, nil)
+			// mean is 102 and 98, out of the tolerance
+			assertString(t, val["temp-1"], ThermometerPrecise)
+		}
+	})
+
+	t.Run("merged", func(t *testing.T) {
+		res, err := MergeLogFiles(files, Options{})
+		assertError(t, err, nil)
+		assertInt(t, len(res.Sensors), 2)
+		assertString(t, res.Sensors[0].Name, "temp-1")
+		assertString(t, res.Sensors[0].Branding, ThermometerVeryPrecise)
+		assertString(t, res.Sensors[1].Name, "hum-1")
+		assertString(t, res.Sensors[1].Branding, HumiditySensorKeep)
+	})
+
+	t.Run("conflicting sensor types", func(t *testing.T) {
+		tmpFile, err := ioutil.TempFile("", "sensors")
+		if err != nil {
+			t.Fatal("Error creating test log file")
+		}
+		defer os.Remove(tmpFile.Name())
+		if err := writeTestLogFile(tmpFile, "reference 100 45\nhumidity temp-1\n2007-04-06T22:00 45"); err != nil {
+			t.Fatal("Error writing test log file")
+		}
+		_, err = MergeLogFiles([]string{files[0], tmpFile.Name()}, Options{})
+		assertErrorMessageSubString(t, err, "sensor temp-1 is humidity")
+	})
+}
@@ -0,0 +1,78 @@
+package sensors
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	NamePolicyNone     = ""
+	NamePolicyReject   = "reject"
+	NamePolicySanitize = "sanitize"
+
+	DefaultNameMaxLength = 64
+)
+
+// characters allowed in sensor names: safe for json keys, redis keys and SQL
+var invalidNameChars = regexp.MustCompile(`[^A-Za-z0-9._:/-]`)
+
+// Validate or sanitize the sensor name according to the policy.
+// The name is always trimmed of the surrounding white space first; with NamePolicyNone
+// it's returned unchanged. Sanitizing replaces every disallowed character by "_" and
+// truncates the name to maxLength characters.
+func normalizeName(name, policy string, maxLength int) (string, error) {
+	if policy == NamePolicyNone {
+		return name, nil
+	}
+	name = strings.TrimSpace(name)
+	switch policy {
+	case NamePolicyReject:
+		if name == "" || invalidNameChars.MatchString(name) {
+			return "", errors.New(fmt.Sprintf("%s %q: allowed characters are letters, digits and ._:/-", ErrInvalidSensorName, name))
+		}
+		if maxLength > 0 && len(name) > maxLength {
+			return "", errors.New(fmt.Sprintf("%s %q: longer than %d characters", ErrInvalidSensorName, name, maxLength))
+		}
+	case NamePolicySanitize:
+		name = invalidNameChars.ReplaceAllString(name, "_")
+		if maxLength > 0 && len(name) > maxLength {
+			name = name[:maxLength]
+		}
+		if name == "" {
+			return "", errors.New(fmt.Sprintf("%s: empty name", ErrInvalidSensorName))
+		}
+	}
+	return name, nil
+}
+
+// characters of the sensor filter given as the list of names, see ParseSensorFilter
+var sensorFilterList = regexp.MustCompile(`^[A-Za-z0-9._:/,-]*$`)
+
+// ParseSensorFilter returns the filter of Options.SensorFilter matching either the comma separated sensor names,
+// e.g. "temp-1,hum-1", or the regular expression, e.g. "temp-.*"; the filter has to match the whole name.
+// The filter consisting only of the name characters (see NamePolicyReject) and commas is the list of names.
+// Empty filter is nil, which matches every sensor.
+func ParseSensorFilter(filter string) (*regexp.Regexp, error) {
+	filter = strings.TrimSpace(filter)
+	if filter == "" {
+		return nil, nil
+	}
+	expr := filter
+	if sensorFilterList.MatchString(filter) {
+		names := make([]string, 0)
+		for _, name := range strings.Split(filter, ",") {
+			if name != "" {
+				names = append(names, regexp.QuoteMeta(name))
+			}
+		}
+		expr = strings.Join(names, "|")
+	}
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("invalid sensor filter %q", filter))
+	}
+	return re, nil
+}
@@ -0,0 +1,194 @@
+package sensors
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestNormalizeName(t *testing.T) {
+	cases := []struct {
+		name      string
+		policy    string
+		want      string
+		wantError bool
+	}{
+		{"temp-1", NamePolicyReject, "temp-1", false},
+		{" temp-1\t", NamePolicyReject, "temp-1", false},
+		{"north room 1", NamePolicyReject, "", true},
+		{"temp-1';DROP TABLE sensors;--", NamePolicyReject, "", true},
+		{"north room 1", NamePolicySanitize, "north_room", false},
+		{"temp\"1\"", NamePolicySanitize, "temp_1_", false},
+		{"12345678901", NamePolicySanitize, "1234567890", false},
+		{"12345678901", NamePolicyReject, "", true},
+		{"north room 1", NamePolicyNone, "north room 1", false},
+	}
+	for _, c := range cases {
+		t.Run(c.policy+" "+c.name, func(t *testing.T) {
+			got, err := normalizeName(c.name, c.policy, 10)
+			if c.wantError {
+				assertErrorMessageSubString(t, err, ErrInvalidSensorName)
+				return
+			}
+			assertError(t, err, nil)
+			assertString(t, got, c.want)
+		})
+	}
+}
+
+func TestSensorNamePolicy(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "sensors")
+	if err != nil {
+		t.Error("Error creating test log file")
+		return
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if err := writeTestLogFile(tmpFile, "reference 100 0\nthermometer temp\"1\t\n2007-04-05T22:00 100"); err != nil {
+		t.Error("Error writing test log file")
+		return
+	}
+
+	t.Run("sanitize", func(t *testing.T) {
+		val, err := processTestLogFile(tmpFile.Name(), Options{NamePolicy: NamePolicySanitize, NameMaxLength: DefaultNameMaxLength})
+		assertError(t, err, nil)
+		assertString(t, val, `{
+  "temp_1": "ultra precise"
+}`)
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		_, err := processTestLogFile(tmpFile.Name(), Options{NamePolicy: NamePolicyReject, NameMaxLength: DefaultNameMaxLength})
+		var headerErr *InvalidHeaderError
+		if !errors.As(err, &headerErr) {
+			t.Fatalf("got error %v, want InvalidHeaderError", err)
+		}
+		assertInt(t, headerErr.Line, 2)
+	})
+
+	t.Run("missing name", func(t *testing.T) {
+		if err := writeTestLogFile(tmpFile, "reference 100 0\nthermometer"); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		_, err := processTestLogFile(tmpFile.Name(), Options{})
+		assertErrorMessageSubString(t, err, ErrMissingSensorName)
+	})
+}
+
+func TestSensorFilter(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "sensors")
+	if err != nil {
+		t.Error("Error creating test log file")
+		return
+	}
+	defer os.Remove(tmpFile.Name())
+
+	log := `reference 100 45
+thermometer temp-1
+2007-04-05T22:00 100
+thermometer temp-2
+2007-04-05T22:00 not-a-number
+humidity hum-1
+2007-04-05T22:00 45.2
+humidity hum-2
+2007-04-05T22:00 47`
+	if err := writeTestLogFile(tmpFile, log); err != nil {
+		t.Error("Error writing test log file")
+		return
+	}
+	// the readings of the sensors filtered out are not parsed, so the invalid one doesn't fail the file
+	for _, filter := range []string{"temp-1,hum-1", "(temp|hum)-1"} {
+		t.Run(filter, func(t *testing.T) {
+			re, err := ParseSensorFilter(filter)
+			assertError(t, err, nil)
+			val, err := processTestLogFile(tmpFile.Name(), Options{SensorFilter: re})
+			assertError(t, err, nil)
+			assertString(t, val, `{
+  "hum-1": "keep",
+  "temp-1": "ultra precise"
+}`)
+		})
+	}
+
+	t.Run("compound", func(t *testing.T) {
+		if err := writeTestLogFile(tmpFile, "reference 100 45\ncompound dev-1 thermometer humidity\n2007-04-05T22:00 100 bad"); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		re, err := ParseSensorFilter("dev-1/thermometer")
+		assertError(t, err, nil)
+		val, err := processTestLogFile(tmpFile.Name(), Options{SensorFilter: re})
+		assertError(t, err, nil)
+		assertString(t, val, `{
+  "dev-1/thermometer": "ultra precise"
+}`)
+	})
+
+	re, err := ParseSensorFilter("temp.1")
+	assertError(t, err, nil)
+	if re.MatchString("temp-1") || !re.MatchString("temp.1") {
+		t.Errorf("got filter %q, want the name temp.1 only", re)
+	}
+	_, err = ParseSensorFilter("temp-(1")
+	assertErrorMessageSubString(t, err, "invalid sensor filter")
+	if re, _ := ParseSensorFilter(" "); re != nil {
+		t.Errorf("got filter %q of empty value, want nil", re)
+	}
+}
+
+func TestQuotedSensorName(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "sensors")
+	if err != nil {
+		t.Error("Error creating test log file")
+		return
+	}
+	defer os.Remove(tmpFile.Name())
+
+	log := `reference 100 45
+thermometer "north room 1"
+2007-04-05T22:00 100
+thermometer "south  room" ref=104
+2007-04-05T22:00 104
+humidity "hum-1"
+2007-04-05T22:00 45
+compound "hall device" thermometer humidity
+2007-04-05T22:00 100 45`
+	if err := writeTestLogFile(tmpFile, log); err != nil {
+		t.Error("Error writing test log file")
+		return
+	}
+	val, err := processTestLogFile(tmpFile.Name(), Options{})
+	assertError(t, err, nil)
+	assertString(t, val, `{
+  "hall device/humidity": "keep",
+  "hall device/thermometer": "ultra precise",
+  "hum-1": "keep",
+  "north room 1": "ultra precise",
+  "south  room": "ultra precise"
+}`)
+
+	val, err = processTestLogFile(tmpFile.Name(), Options{NamePolicy: NamePolicySanitize, NameMaxLength: DefaultNameMaxLength})
+	assertError(t, err, nil)
+	assertString(t, val, `{
+  "hall_device/humidity": "keep",
+  "hall_device/thermometer": "ultra precise",
+  "hum-1": "keep",
+  "north_room_1": "ultra precise",
+  "south__room": "ultra precise"
+}`)
+
+	for _, header := range []string{`thermometer "north room`, `thermometer "`, `thermometer ""`} {
+		if err := writeTestLogFile(tmpFile, "reference 100 45\n"+header+"\n2007-04-05T22:00 100"); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		_, err := processTestLogFile(tmpFile.Name(), Options{})
+		var headerErr *InvalidHeaderError
+		if !errors.As(err, &headerErr) {
+			t.Fatalf("%s: got error %v, want InvalidHeaderError", header, err)
+		}
+		assertInt(t, headerErr.Line, 2)
+	}
+}
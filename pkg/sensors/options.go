@@ -0,0 +1,218 @@
+package sensors
+
+import (
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNotFound is returned by Store.Get when the key is not present
+var ErrNotFound = errors.New("cache miss")
+
+// Store keeps the state between the log files, for the modes that need it (UseBaseline, PreviousReference,
+// InheritReference, HysteresisMargin)
+type Store interface {
+	// Get returns the value stored under the key, or ErrNotFound
+	Get(key string) (string, error)
+	// Set stores the value under the key, without any expiration
+	Set(key, value string) error
+}
+
+// DefaultSensorName is the name of the default sensor, when Options.DefaultSensorName is empty
+const DefaultSensorName = "default"
+
+const (
+	// NonFiniteReject fails the processing on NaN or infinite reading, the default
+	NonFiniteReject = "reject"
+	// NonFiniteDrop ignores NaN and infinite readings
+	NonFiniteDrop = "drop"
+	// NonFiniteKeep uses NaN and infinite readings like any other, which makes the statistics
+	// of the sensor NaN or infinite as well
+	NonFiniteKeep = "keep"
+)
+
+const (
+	// ReadingOrderTimeFirst is the usual reading line, "2007-04-05T22:00 100", the default
+	ReadingOrderTimeFirst = "time-first"
+	// ReadingOrderValueFirst has the value(s) before the timestamp, "100 2007-04-05T22:00"
+	ReadingOrderValueFirst = "value-first"
+	// ReadingOrderAuto detects the order on each line by which field is a timestamp
+	ReadingOrderAuto = "auto"
+)
+
+const (
+	// UnknownTypeReject fails the processing on the header of unknown sensor type, the default
+	UnknownTypeReject = "error"
+	// UnknownTypeSkip ignores the header of unknown sensor type and the readings that follow it
+	UnknownTypeSkip = "skip"
+	// UnknownTypeWarn is UnknownTypeSkip which prints a warning
+	UnknownTypeWarn = "warn"
+)
+
+// DefaultMaxLineLength is the limit of Options.MaxLineLength when it's zero
+const DefaultMaxLineLength = 1024 * 1024
+
+// PostProcessor applies custom rules to the result of each sensor after the standard branding,
+// e.g. downgrades the sensors on a blocklist; it may change the result in place. An error fails
+// the processing.
+type PostProcessor func(r *SensorResult) error
+
+// Options contains the settings of the log file processing. Zero value means the defaults.
+type Options struct {
+	// MaxReadings caps the number of readings kept for each sensor; when a sensor has more readings,
+	// only a uniformly sampled subset of this size is used for the branding, which then becomes
+	// approximate; humidity sensors keep the extremes of all the readings as well, so that their branding
+	// doesn't change (see humidityExtremes). Zero means no limit.
+	MaxReadings int
+
+	// Thresholds are the limits for the sensors branding
+	Thresholds Thresholds
+
+	// GapMultiplier enables the detection of gaps in readings: a sensor with an interval between readings
+	// longer than GapMultiplier times the median interval is branded SensorGappy. Zero disables the detection.
+	GapMultiplier float64
+
+	// DriftThreshold enables the detection of calibration drift: a sensor whose readings follow a trend
+	// steeper than DriftThreshold units per hour (the slope of linear regression over the reading
+	// timestamps) is branded SensorDriftingUp or SensorDriftingDown. Zero disables the detection.
+	DriftThreshold float64
+
+	// FlatlineMinReadings enables the detection of stuck sensors: a sensor with at least FlatlineMinReadings
+	// readings whose std deviation is at most FlatlineStdDev (by default exactly zero) is branded
+	// SensorFlatline, instead of e.g. "ultra precise". Zero disables the detection.
+	FlatlineMinReadings int
+	FlatlineStdDev      float64
+
+	// OutlierMAD enables the removal of outliers before the branding: the readings farther from the median
+	// of the sensor readings than OutlierMAD times their median absolute deviation are not used, so that
+	// a single glitch doesn't decide the branding. Zero disables the removal.
+	OutlierMAD float64
+
+	// MinReadings is the number of readings a sensor needs for the branding; sensors with less readings
+	// are branded SensorInsufficientData regardless of their statistics. Zero disables the check.
+	MinReadings int
+
+	// Decoders of the raw reading values, by sensor type; the readings of other sensor types are plain numbers
+	Decoders map[string]Decoder
+
+	// Units are the units the readings of each sensor type may have appended to the number, e.g. "C"
+	// for thermometer readings like "100C" and "%" for humidity "45.2%"; the readings with other unit
+	// fail the processing with UnitMismatchError, the readings without unit are plain numbers. Not used
+	// for the sensor types with Decoders.
+	Units map[string]string
+
+	// DefaultSensorType makes the readings without any sensor header (e.g. in files of minimal exporters
+	// that log just the reference and the readings) belong to single sensor of this type, named
+	// DefaultSensorName. By default such readings are ignored.
+	DefaultSensorType string
+	DefaultSensorName string
+
+	// UseBaseline enables the comparison of readings with the long-term baseline of each sensor,
+	// kept in Store, for log files without the reference line
+	UseBaseline bool
+
+	// PreviousReference makes the reference of each sensor its mean in the previous log file, kept in Store,
+	// for monitoring the drift from one file to the next; the first log file of a sensor uses the reference
+	// line, or the mean of its own readings without one. Not used with UseBaseline.
+	PreviousReference bool
+
+	// NamePolicy says what to do with the sensor names that contain characters outside of
+	// the allowed set or are longer than NameMaxLength: NamePolicyReject fails the processing,
+	// NamePolicySanitize fixes the name. Empty policy keeps the names as they are.
+	NamePolicy    string
+	NameMaxLength int
+
+	// SensorFilter selects the sensors to brand by their names (after NamePolicy), see ParseSensorFilter; the other
+	// sensors are skipped with their readings, so they are neither parsed nor in the Result. The channels
+	// of compound devices are matched by their names, "device/type". Nil means all the sensors.
+	SensorFilter *regexp.Regexp
+
+	// NonFinitePolicy says what to do with the readings "NaN" and "Inf" (accepted by strconv.ParseFloat):
+	// NonFiniteReject (or empty) fails the processing, NonFiniteDrop ignores them, NonFiniteKeep
+	// uses them
+	NonFinitePolicy string
+
+	// UnknownTypePolicy says what to do with the lines that look like a sensor header of unknown type,
+	// e.g. a misspelled "thermomter temp-1": UnknownTypeReject (or empty) fails the processing with
+	// UnknownSensorTypeError, UnknownTypeSkip and UnknownTypeWarn ignore the sensor with its readings
+	UnknownTypePolicy string
+
+	// ReadingOrder is the order of the fields on the reading lines, ReadingOrderTimeFirst (or empty),
+	// ReadingOrderValueFirst or ReadingOrderAuto. The value-first lines of compound devices have all
+	// the values before the timestamp.
+	ReadingOrder string
+
+	// InputFormat is the format of the log file, InputFormatText (or empty), InputFormatJSON
+	// or InputFormatAuto
+	InputFormat string
+
+	// InheritReference makes the log files without the reference line use the reference values
+	// of the last log file that had them, kept in Store
+	InheritReference bool
+
+	// Reference are the reference values of the log file without the reference line, e.g. read by
+	// ReadReference from a separate file; they take precedence over the inherited ones (InheritReference),
+	// the reference line of the log file overrides them. Nil means no such reference.
+	Reference map[string]float64
+
+	// ReferenceLoader returns the Reference values lazily: it's called only when the first sensor of the log
+	// file has no reference line before it, e.g. so that the separate file is read only for the log files that
+	// need it. Its error fails the processing. Unused when Reference is set, nil means no such reference.
+	ReferenceLoader func() (map[string]float64, error)
+
+	// ReferenceAnywhere makes the sensors before the first reference line use it as well, so that
+	// the reference may be logged after the sensors, e.g. as the last line of the file. The whole
+	// log file is read into memory to find the reference first.
+	ReferenceAnywhere bool
+
+	// StrictReference fails the processing when there are several reference lines with different values
+	// before the same sensors, which probably means a corrupt file; the reference lines that differ
+	// from the previous one are fine when there are some sensors in between.
+	StrictReference bool
+
+	// EndMarker is the line (e.g. "# EOF") every log file must end with; the files without it fail
+	// the processing with IncompleteFileError, as they may be truncated. The sensors are branded only after
+	// the marker is found, so the incomplete file writes nothing to Store (and ProcessStream gets the sensors
+	// at the end of the file); their readings are kept in memory until then. The JSON log file needs no marker,
+	// the end of its array is checked instead. Empty marker disables the check.
+	EndMarker string
+
+	// MaxLineLength is the maximal length of a line in bytes, DefaultMaxLineLength when zero; longer lines
+	// fail the processing with LineTooLongError
+	MaxLineLength int
+
+	// IncludeReadings adds the readings of each sensor to its SensorResult; with MaxReadings, only
+	// the sampled readings are included, in their order in the log file
+	IncludeReadings bool
+
+	// IncludeReference adds the reference values each sensor was branded against to its SensorResult, for the audit
+	// of the files with several reference lines, the sensors with their own reference or UseBaseline
+	IncludeReference bool
+
+	// Parallelism is the number of sensors of the log file branded at the same time, for the files with
+	// many sensors; the result is the same as of the sequential processing (zero or one). Not used with
+	// UseBaseline, PreviousReference and HysteresisMargin, which need the sensors in order for their stored
+	// statistics.
+	Parallelism int
+
+	// HysteresisMargin keeps the thermometers from flapping between the tiers of their branding: when the last
+	// branding of a thermometer (kept in Store) is one tier up, e.g. "ultra precise" for "very precise", the
+	// thermometer keeps it unless its std deviation exceeds the limit of that tier by more than the margin.
+	// Zero disables the hysteresis.
+	HysteresisMargin float64
+
+	// PostProcess is called with the result of each sensor before it's added to the Result, nil means
+	// no post-processing
+	PostProcess PostProcessor
+
+	// Store is the storage used by the modes that need to keep state between the log files.
+	Store Store
+
+	// newSensor creates the sensors instead of NewSensor, for the tests (e.g. a slow sensor); nil means NewSensor
+	newSensor func(sensorType, name string, thresholds Thresholds) Sensor
+}
+
+// Whether the branding of the sensors uses their statistics kept in Store
+func (o Options) statefulBranding() bool {
+	return o.UseBaseline || o.PreviousReference || o.HysteresisMargin > 0
+}
@@ -0,0 +1,45 @@
+package sensors
+
+import (
+	"math"
+	"sort"
+)
+
+// Return the median of the values, which must not be empty; the values are not modified
+func median(values []float64) float64 {
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// Remove the readings farther from the median of the readings than multiplier times their median absolute
+// deviation (MAD) and return how many were removed. Nothing is removed when the MAD is zero, i.e. when most
+// of the readings are the same: any other reading would be infinitely many MADs away.
+func (r *reservoir) removeOutliers(multiplier float64) int {
+	if len(r.readings) == 0 {
+		return 0
+	}
+	values := r.values()
+	m := median(values)
+	deviations := make([]float64, len(values))
+	for i, v := range values {
+		deviations[i] = math.Abs(v - m)
+	}
+	mad := median(deviations)
+	if mad == 0 {
+		return 0
+	}
+	kept := r.readings[:0]
+	for i, reading := range r.readings {
+		if deviations[i] <= multiplier*mad {
+			kept = append(kept, reading)
+		}
+	}
+	removed := len(r.readings) - len(kept)
+	r.readings = kept
+	return removed
+}
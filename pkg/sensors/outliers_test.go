@@ -0,0 +1,38 @@
+package sensors
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOutlierRemoval(t *testing.T) {
+	// a single glitch of 120 among the readings around 100: the mean is 102 with it, 100 without it
+	log := "reference 100 45\nthermometer temp-1\n"
+	for _, v := range []string{"100", "101", "99", "100", "101", "99", "100", "101", "99", "120"} {
+		log += "2007-04-05T22:00 " + v + "\n"
+	}
+	res, err := ProcessReader(strings.NewReader(log), Options{})
+	assertError(t, err, nil)
+	assertString(t, res.Sensors[0].Branding, ThermometerPrecise)
+	assertInt(t, res.Sensors[0].Outliers, 0)
+
+	res, err = ProcessReader(strings.NewReader(log), Options{OutlierMAD: 3})
+	assertError(t, err, nil)
+	assertString(t, res.Sensors[0].Branding, ThermometerUltraPrecise)
+	assertInt(t, res.Sensors[0].Outliers, 1)
+	// all readings were seen, the mean is of those used
+	assertInt(t, res.Sensors[0].Count, 10)
+	if res.Sensors[0].Mean != 100 {
+		t.Errorf("got mean %f, want 100", res.Sensors[0].Mean)
+	}
+}
+
+func TestOutlierRemovalZeroMAD(t *testing.T) {
+	r := newReservoir(0)
+	for _, v := range []float64{100, 100, 100, 105} {
+		r.add(reading{value: v})
+	}
+	// most readings are the same, the MAD is zero
+	assertInt(t, r.removeOutliers(3), 0)
+	assertInt(t, len(r.readings), 4)
+}
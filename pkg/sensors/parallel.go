@@ -0,0 +1,81 @@
+package sensors
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// brandedBlock is the block of a sensor being branded by the worker pool; done is closed once
+// the branding is known
+type brandedBlock struct {
+	block          block
+	name, branding string
+	err            error
+	done           chan struct{}
+}
+
+// Process the log file read from r like ProcessReaderContext, but brand up to opts.Parallelism sensors
+// at the same time. The results are collected in the order of the sensors in the log file and
+// opts.PostProcess is called from one goroutine, so the result is the same as of the sequential processing.
+func processParallel(ctx context.Context, r io.Reader, opts Options) (*Result, error) {
+	opts, store := withPendingStore(ctx, opts)
+	res := &Result{Sensors: make([]SensorResult, 0)}
+	jobs := make(chan *brandedBlock)
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range jobs {
+				b.name, b.branding, b.err = brandBlockContext(ctx, b.block, opts)
+				close(b.done)
+			}
+		}()
+	}
+	// the workers finish the blocks they have even when the processing fails
+	defer wg.Wait()
+	var closeJobs sync.Once
+	defer closeJobs.Do(func() { close(jobs) })
+
+	// blocks being branded, in their order; the blocks waiting for the result are limited, as they
+	// keep all readings of their sensors in memory
+	maxPending := 2 * opts.Parallelism
+	pending := make([]*brandedBlock, 0, maxPending)
+	collect := func() error {
+		b := pending[0]
+		pending = pending[1:]
+		<-b.done
+		if b.err != nil {
+			return b.err
+		}
+		r, err := sensorResult(b.block.channel, b.name, b.branding, opts)
+		if err != nil {
+			return err
+		}
+		res.Sensors = append(res.Sensors, r)
+		return nil
+	}
+	err := parse(&contextReader{ctx: ctx, r: r}, opts, func(blk block) error {
+		b := &brandedBlock{block: blk, done: make(chan struct{})}
+		jobs <- b
+		pending = append(pending, b)
+		if len(pending) >= maxPending {
+			return collect()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	closeJobs.Do(func() { close(jobs) })
+	for len(pending) > 0 {
+		if err := collect(); err != nil {
+			return nil, err
+		}
+	}
+	if err := store.commit(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,80 @@
+package sensors
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParallelBranding(t *testing.T) {
+	var log strings.Builder
+	log.WriteString("reference 100 45 50\n")
+	for i := 0; i < 500; i++ {
+		switch i % 3 {
+		case 0:
+			fmt.Fprintf(&log, "thermometer temp-%d\n", i)
+		case 1:
+			fmt.Fprintf(&log, "humidity hum-%d\n", i)
+		case 2:
+			fmt.Fprintf(&log, "flow flow-%d\n", i)
+		}
+		// the sensors differ by the spread of their readings
+		for j := 0; j < 20; j++ {
+			value := []float64{100, 45, 50}[i%3] + float64((i*j)%11-5)*float64(i%7)/10
+			fmt.Fprintf(&log, "2007-04-05T22:%02d %.2f\n", j, value)
+		}
+	}
+	// the same sensor again, the order of the results matters
+	log.WriteString("thermometer temp-0\n2007-04-05T23:00 90\n")
+
+	postProcessed := make([]string, 0)
+	opts := Options{
+		GapMultiplier: 3,
+		PostProcess: func(r *SensorResult) error {
+			postProcessed = append(postProcessed, r.Name)
+			return nil
+		},
+	}
+	sequential, err := ProcessReader(strings.NewReader(log.String()), opts)
+	assertError(t, err, nil)
+	sequentialOrder := postProcessed
+
+	postProcessed = make([]string, 0)
+	opts.Parallelism = 8
+	parallel, err := ProcessReader(strings.NewReader(log.String()), opts)
+	assertError(t, err, nil)
+
+	assertInt(t, len(parallel.Sensors), 501)
+	if !reflect.DeepEqual(parallel, sequential) {
+		t.Error("got the result of parallel branding different from the sequential one")
+	}
+	if !reflect.DeepEqual(postProcessed, sequentialOrder) {
+		t.Error("got the sensors post-processed in other order than sequentially")
+	}
+	brandings := make(map[string]bool)
+	for _, s := range parallel.Sensors {
+		brandings[s.Branding] = true
+	}
+	if len(brandings) < 4 {
+		t.Errorf("got brandings %v, want the sensors to differ", brandings)
+	}
+}
+
+func TestParallelBrandingError(t *testing.T) {
+	log := "reference 100 45\n"
+	for i := 0; i < 50; i++ {
+		log += fmt.Sprintf("thermometer temp-%d\n2007-04-05T22:00 100\n", i)
+	}
+	log += "thermometer\n"
+	_, err := ProcessReader(strings.NewReader(log), Options{Parallelism: 4})
+	assertErrorMessageSubString(t, err, ErrMissingSensorName)
+
+	_, err = ProcessReader(strings.NewReader(log), Options{Parallelism: 4, PostProcess: func(r *SensorResult) error {
+		if r.Name == "temp-10" {
+			return fmt.Errorf("rejected")
+		}
+		return nil
+	}})
+	assertErrorMessageSubString(t, err, "failed post-processing sensor temp-10")
+}
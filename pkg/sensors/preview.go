@@ -0,0 +1,41 @@
+package sensors
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// Preview returns the branding the sensor of given type would get for the readings under each of
+// the candidate thresholds, in the same order. It's meant for tuning the thresholds: only the readings
+// statistics are evaluated, the other checks (gaps, minimal number of readings, baseline) are not.
+func Preview(sensorType string, reference map[string]float64, readings []float64, candidates []Thresholds) ([]string, error) {
+	ret := make([]string, 0, len(candidates))
+	for _, t := range candidates {
+		s := NewSensor(sensorType, "", t)
+		if s == nil {
+			return nil, errors.New(fmt.Sprintf("unknown sensor type %q", sensorType))
+		}
+		s.Process(reference, readings)
+		ret = append(ret, s.Branding())
+	}
+	return ret, nil
+}
+
+// PreviewLogFile returns the brandings of all sensors in the log file under each of the candidate
+// thresholds, see Preview. The Thresholds of opts are not used.
+func PreviewLogFile(filePath string, opts Options, candidates []Thresholds) (map[string][]string, error) {
+	ret := make(map[string][]string)
+	err := parseLogFile(filePath, opts, func(b block) error {
+		brandings, err := Preview(b.channel.sensorType, b.reference, b.channel.readings.values(), candidates)
+		if err != nil {
+			return err
+		}
+		ret[b.channel.sensor.Name()] = brandings
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
@@ -0,0 +1,49 @@
+package sensors
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestPreview(t *testing.T) {
+	// mean 100, std deviation 4
+	readings := []float64{96, 100, 104}
+	reference := map[string]float64{"Temperature": 100}
+	candidates := []Thresholds{
+		{},
+		{UltraPreciseStdDev: 4, UltraPreciseInclusive: true},
+		{VeryPreciseStdDev: 3.5},
+	}
+
+	t.Run("readings", func(t *testing.T) {
+		got, err := Preview(ThermometerLabel, reference, readings, candidates)
+		assertError(t, err, nil)
+		want := []string{ThermometerVeryPrecise, ThermometerUltraPrecise, ThermometerPrecise}
+		assertInt(t, len(got), len(want))
+		for i := range want {
+			assertString(t, got[i], want[i])
+		}
+	})
+
+	t.Run("unknown sensor type", func(t *testing.T) {
+		_, err := Preview("pressure", reference, readings, candidates)
+		assertErrorMessageSubString(t, err, "unknown sensor type")
+	})
+
+	t.Run("log file", func(t *testing.T) {
+		tmpFile, err := ioutil.TempFile("", "sensors")
+		if err != nil {
+			t.Fatal("Error creating test log file")
+		}
+		defer os.Remove(tmpFile.Name())
+		if err := writeTestLogFile(tmpFile, "reference 100 45\nthermometer temp-1\n2007-04-05T22:00 96\n2007-04-05T22:01 100\n2007-04-05T22:02 104\nhumidity hum-1\n2007-04-05T22:00 45.2"); err != nil {
+			t.Fatal("Error writing test log file")
+		}
+		got, err := PreviewLogFile(tmpFile.Name(), Options{}, candidates)
+		assertError(t, err, nil)
+		assertString(t, got["temp-1"][1], ThermometerUltraPrecise)
+		assertInt(t, len(got["hum-1"]), 3)
+		assertString(t, got["hum-1"][0], HumiditySensorKeep)
+	})
+}
@@ -0,0 +1,59 @@
+package sensors
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+const previousKeyPrefix = "previous:"
+
+// previousStats are the statistics of a sensor in the last log file where it had readings
+type previousStats struct {
+	Mean  float64 `json:"mean"`
+	Count int     `json:"count"`
+}
+
+func previousKey(sensorType, name string) string {
+	return fmt.Sprintf("%s%s:%s", previousKeyPrefix, sensorType, name)
+}
+
+// Return the reference values for a sensor in the previous file mode: the reference quantity of the sensor
+// is its mean in the previous log file, whether the log file has a reference line or not. On the first run
+// of a sensor (nothing stored yet) the reference line is used, or the readings are compared with their own
+// mean when there's none. The means are those of the sensor type, see typeMean. The mean of the current
+// readings is then stored for the next log file; a sensor without readings keeps the stored one.
+func previousReference(store Store, sensorType, name string, referenceValues map[string]float64, referenceFound bool, readings []float64) (map[string]float64, error) {
+	key := previousKey(sensorType, name)
+	var previous previousStats
+	found := false
+	val, err := store.Get(key)
+	if err == nil {
+		if err := json.Unmarshal([]byte(val), &previous); err != nil {
+			return nil, errors.Wrap(err, "invalid previous statistics of "+name)
+		}
+		found = true
+	} else if err != ErrNotFound {
+		return nil, errors.Wrap(err, "failed reading previous statistics of "+name)
+	}
+	if len(readings) == 0 {
+		return referenceValues, nil
+	}
+	mean := typeMean(sensorType, readings)
+
+	ret := referenceValues
+	if found || !referenceFound {
+		ret = copyReference(referenceValues)
+		if found {
+			ret[sensorTypes[sensorType].referenceKey] = previous.Mean
+		} else {
+			ret[sensorTypes[sensorType].referenceKey] = mean
+		}
+	}
+	j, _ := json.Marshal(previousStats{Mean: mean, Count: len(readings)})
+	if err := store.Set(key, string(j)); err != nil {
+		return nil, errors.Wrap(err, "failed saving previous statistics of "+name)
+	}
+	return ret, nil
+}
@@ -0,0 +1,73 @@
+package sensors
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestPreviousReference(t *testing.T) {
+	store := memStore{}
+	opts := Options{PreviousReference: true, Store: store}
+
+	t.Run("first run uses the reference line or own mean", func(t *testing.T) {
+		// temp-1 has no reference yet, temp-2 the reference line of the second section
+		log := `thermometer temp-1
+2007-04-05T22:00 100
+2007-04-05T22:01 100.1
+2007-04-05T22:02 99.9
+reference 90 45
+thermometer temp-2
+2007-04-05T22:00 100
+2007-04-05T22:01 100.1
+2007-04-05T22:02 99.9
+thermometer temp-3
+`
+		res, err := ProcessReader(strings.NewReader(log), opts)
+		assertError(t, err, nil)
+		assertString(t, res.Brandings()["temp-1"], ThermometerUltraPrecise)
+		assertString(t, res.Brandings()["temp-2"], ThermometerPrecise)
+		if _, err := store.Get(previousKey(ThermometerLabel, "temp-1")); err != nil {
+			t.Errorf("mean of temp-1 not saved: %v", err)
+		}
+		// nothing to store for the sensor without readings
+		if _, err := store.Get(previousKey(ThermometerLabel, "temp-3")); err != ErrNotFound {
+			t.Errorf("got %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("next run compares with the previous means", func(t *testing.T) {
+		// the reference line doesn't matter anymore
+		log := `reference 105 45
+thermometer temp-1
+2007-04-06T22:00 105
+2007-04-06T22:01 105.1
+2007-04-06T22:02 104.9
+thermometer temp-2
+2007-04-06T22:00 100.2
+2007-04-06T22:01 100.1
+`
+		res, err := ProcessReader(strings.NewReader(log), opts)
+		assertError(t, err, nil)
+		assertString(t, res.Brandings()["temp-1"], ThermometerPrecise)
+		assertString(t, res.Brandings()["temp-2"], ThermometerUltraPrecise)
+	})
+
+	t.Run("the means move with each file", func(t *testing.T) {
+		res, err := ProcessReader(strings.NewReader("thermometer temp-1\n2007-04-07T22:00 105\n"), opts)
+		assertError(t, err, nil)
+		assertString(t, res.Brandings()["temp-1"], ThermometerUltraPrecise)
+	})
+	t.Run("sound levels are energy means", func(t *testing.T) {
+		_, err := ProcessReader(strings.NewReader("sound snd-1\n2007-04-05T22:00 40\n2007-04-05T22:01 60\n"), opts)
+		assertError(t, err, nil)
+		val, err := store.Get(previousKey(SoundSensorLabel, "snd-1"))
+		assertError(t, err, nil)
+		var previous previousStats
+		assertError(t, json.Unmarshal([]byte(val), &previous), nil)
+		if want := soundLevelMean([]float64{40, 60}); math.Abs(previous.Mean-want) > 1e-9 {
+			t.Errorf("got previous mean %f, want energy mean %f", previous.Mean, want)
+		}
+	})
+}
@@ -0,0 +1,694 @@
+package sensors
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// SensorResult is the outcome of the quality control of a single sensor
+type SensorResult struct {
+	Name     string
+	Type     string
+	Branding string
+	// Confidence of the branding from 0 to 1: how far inside the band of its branding the sensor
+	// statistics are, see README for the formulas. The brandings of the checks that replace the
+	// statistics one (e.g. SensorGappy) are certain, with confidence 1.
+	Confidence float64
+	// Count is the number of readings of the sensor, Mean their mean (the energy mean for sound levels),
+	// 0 without readings; with Options.MaxReadings, the mean of the sampled readings only
+	Count int
+	Mean  float64
+	// Outliers is the number of readings not used for the branding, with Options.OutlierMAD
+	Outliers int
+	// Readings the branding is based on, only with Options.IncludeReadings
+	Readings []Reading
+	// Reference are the reference values the sensor was branded against, by the quantities of its type
+	// (e.g. "Temperature" and RoomTemperatureKey of a thermometer), only with Options.IncludeReference; these are
+	// the values after the override on the sensor header, or of the baseline or the previous file with UseBaseline
+	// and PreviousReference. A sound level sensor without "Sound" was compared with Thresholds.SoundLimit.
+	Reference map[string]float64
+}
+
+// Result is the outcome of processing a log file
+type Result struct {
+	// Sensors in the order they were concluded, i.e. as they appear in the log file
+	Sensors []SensorResult
+}
+
+// Brandings returns the map of sensor names to their branding; when a sensor appears
+// more than once, its last branding wins
+func (r *Result) Brandings() map[string]string {
+	ret := make(map[string]string)
+	for _, s := range r.Sensors {
+		ret[s.Name] = s.Branding
+	}
+	return ret
+}
+
+// Hash returns the sha256 (hex encoded) of the brandings, independent of the order of sensors;
+// log files with the same brandings have the same hash
+func (r *Result) Hash() string {
+	brandings := r.Brandings()
+	names := make([]string, 0, len(brandings))
+	for name := range brandings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	h := sha256.New()
+	for _, name := range names {
+		// the length prefix keeps the pairs apart whatever characters the names contain
+		fmt.Fprintf(h, "%d:%s:%s\n", len(name), name, brandings[name])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ProcessLogFile brands the sensors mentioned in the log file, identified by file path
+func ProcessLogFile(filePath string, opts Options) (*Result, error) {
+	return ProcessLogFileContext(context.Background(), filePath, opts)
+}
+
+// ProcessReader brands the sensors mentioned in the log file read from r
+func ProcessReader(r io.Reader, opts Options) (*Result, error) {
+	return ProcessReaderContext(context.Background(), r, opts)
+}
+
+// Create the result of the sensor read from the channel, post-processed by opts.PostProcess
+func sensorResult(c *channel, name, branding string, opts Options) (SensorResult, error) {
+	ret := SensorResult{Name: name, Type: c.sensorType, Branding: branding, Confidence: 1}
+	if s, ok := c.sensor.(scored); ok && branding == c.sensor.Branding() {
+		ret.Confidence = s.Confidence()
+	}
+	ret.Count = c.readings.seen
+	ret.Outliers = c.outliers
+	if values := c.readings.values(); len(values) > 0 {
+		ret.Mean = typeMean(c.sensorType, values)
+	}
+	if opts.IncludeReadings {
+		ret.Readings = c.readings.exported()
+	}
+	if opts.IncludeReference {
+		ret.Reference = typeReference(c.sensorType, c.brandedReference)
+	}
+	if opts.PostProcess != nil {
+		if err := opts.PostProcess(&ret); err != nil {
+			return ret, errors.Wrap(err, "failed post-processing sensor "+name)
+		}
+	}
+	return ret, nil
+}
+
+// block holds all readings of a single sensor from the log file, together with the reference
+// that was valid for them
+type block struct {
+	channel   *channel
+	reference map[string]float64
+	// referenceFound is false if the log file had no reference line (yet)
+	referenceFound bool
+}
+
+// Decide the branding of the sensor from its block of readings; return the sensor name and its branding
+func brandBlock(b block, opts Options) (string, string, error) {
+	c := b.channel
+	reference := b.reference
+	// before the baseline, so that the outliers do not get into it
+	if opts.OutlierMAD > 0 {
+		c.outliers = c.readings.removeOutliers(opts.OutlierMAD)
+	}
+	if opts.UseBaseline {
+		var err error
+		reference, err = baselineReference(opts.Store, c.sensorType, c.sensor.Name(), b.reference, b.referenceFound, c.readings.values())
+		if err != nil {
+			return "", "", err
+		}
+	} else if opts.PreviousReference {
+		var err error
+		reference, err = previousReference(opts.Store, c.sensorType, c.sensor.Name(), b.reference, b.referenceFound, c.readings.values())
+		if err != nil {
+			return "", "", err
+		}
+	}
+	c.brandedReference = reference
+	values, expected := c.readings.branded()
+	if es, ok := c.sensor.(expectedSensor); ok && expected != nil {
+		es.ProcessExpected(reference, values, expected)
+	} else {
+		c.sensor.Process(reference, values)
+	}
+	if opts.HysteresisMargin > 0 && c.sensorType == ThermometerLabel {
+		if err := applyHysteresis(opts.Store, c, reference, opts.HysteresisMargin, opts.Thresholds); err != nil {
+			return "", "", err
+		}
+	}
+	branding := c.sensor.Branding()
+	if opts.DriftThreshold > 0 {
+		if drift := driftBranding(c.readings.readings, opts.DriftThreshold); drift != "" {
+			branding = drift
+		}
+	}
+	if opts.FlatlineMinReadings > 0 && c.readings.seen >= opts.FlatlineMinReadings && isFlatline(c.readings.values(), opts.FlatlineStdDev) {
+		branding = SensorFlatline
+	}
+	if opts.GapMultiplier > 0 && countGaps(c.intervals, opts.GapMultiplier) > 0 {
+		branding = SensorGappy
+	}
+	if c.readings.seen < opts.MinReadings {
+		branding = SensorInsufficientData
+	}
+	return c.sensor.Name(), branding, nil
+}
+
+// Parse the log file with sensor readings, identified by file path, and call the function
+// with the block of readings of each sensor, as soon as the block is complete
+func parseLogFile(filePath string, opts Options, sensorDone func(block) error) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return errors.Wrap(err, ErrOpenFile)
+	}
+	defer file.Close()
+	return parse(file, opts, sensorDone)
+}
+
+// Parse the log file read from r, see parseLogFile
+func parse(r io.Reader, opts Options, sensorDone func(block) error) error {
+	r, items, closeInput := inputReader(skipBOM(r), opts.InputFormat, opts.EndMarker)
+	defer closeInput()
+	return items.error(parseText(r, opts, sensorDone))
+}
+
+// Parse the text log file read from r, see parse
+func parseText(r io.Reader, opts Options, sensorDone func(block) error) error {
+	var err error
+
+	// Note: if there are more values on reference lines in the future,
+	// it might be better to use an array here so we know the values order...
+	var referenceValues map[string]float64 = newReferenceValues()
+	// readings of currently processed block: one channel for a simple sensor,
+	// or one channel per value column for a compound device
+	var channels []*channel
+	var referenceFound bool
+
+	// with the end marker, the sensors are branded (and the state in Store written) only once the file turns out
+	// complete, so that the incomplete file, which is processed again later, leaves no state behind
+	var deferred []func() error
+	later := func(f func() error) error {
+		if opts.EndMarker == "" {
+			return f()
+		}
+		deferred = append(deferred, f)
+		return nil
+	}
+
+	// start with the reference of previous log files; the reference line overrides it
+	if opts.InheritReference {
+		ref, ok, err := loadReference(opts.Store)
+		if err != nil {
+			return err
+		}
+		if ok {
+			referenceValues = copyReference(ref)
+			referenceFound = true
+		}
+	}
+
+	if opts.Reference != nil {
+		referenceValues = copyReference(opts.Reference)
+		referenceFound = true
+	}
+	// whether the reference of ReferenceLoader is still to be loaded, if the first sensor needs it
+	referenceToLoad := opts.Reference == nil && opts.ReferenceLoader != nil
+
+	// the sensors before the first reference line use it too; it has to be found beforehand
+	if opts.ReferenceAnywhere {
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return errors.Wrap(err, "error reading the file")
+		}
+		if ref, ok := findReference(data, opts.MaxLineLength); ok {
+			referenceValues = ref
+			referenceFound = true
+			referenceToLoad = false
+		}
+		r = bytes.NewReader(data)
+	}
+
+	// the reference valid for currently processed sensors: the one before their header,
+	// reference lines that follow apply to the next sensors only
+	var blockReference map[string]float64
+	var blockReferenceFound bool
+	// line of the last reference line before the sensors that follow, 0 when there's none
+	var sectionReferenceLine int
+
+	lineNumber := 0
+	// the current line, as logged
+	var line string
+	// line where the currently processed sensors start, and its text
+	var blockLine int
+	var blockText string
+
+	// start processing new sensors, with the current reference values
+	startBlock := func(c []*channel) {
+		channels = c
+		blockLine = lineNumber
+		blockText = line
+		// reference values may change later in the file
+		blockReference = copyReference(referenceValues)
+		blockReferenceFound = referenceFound
+		sectionReferenceLine = 0
+	}
+
+	// conclude the state of currently processed sensors (if there are any)
+	finishBlock := func() error {
+		for _, c := range channels {
+			if c.sensor == nil {
+				continue
+			}
+			reference, found := channelReference(c, blockReference, blockReferenceFound)
+			// a reference line may leave out the quantities no sensor needs, e.g. the flow; before any reference
+			// line, the sensors are compared with zero (or the baseline)
+			t := sensorTypes[c.sensorType]
+			if _, ok := reference[t.referenceKey]; !ok && found && !t.optionalReference {
+				return &MissingReferenceError{Line: blockLine, Text: blockText, Sensor: c.sensor.Name(), Quantity: t.referenceKey}
+			}
+			b := block{channel: c, reference: reference, referenceFound: found}
+			if err := later(func() error { return sensorDone(b) }); err != nil {
+				return err
+			}
+		}
+		channels = nil
+		return nil
+	}
+
+	// whether the last line was the end marker
+	var endMarkerSeen bool
+	// whether the readings belong to the sensor of unknown type or filtered out by SensorFilter, which is skipped
+	var skipping bool
+	// fields of the current line, reused between the lines
+	l := make([]string, 0, readingLineValues)
+	scanner := newLineScanner(r, opts.MaxLineLength)
+	for scanner.Scan() {
+		lineNumber++
+		// the scanner strips both LF and CRLF line endings, and the newline after the last line is optional;
+		// empty lines (e.g. the trailing ones) carry no information
+		line = scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if opts.EndMarker != "" {
+			// only the marker on the last line counts, anything after it may be a later append in progress
+			endMarkerSeen = strings.TrimSpace(line) == opts.EndMarker
+			if endMarkerSeen {
+				continue
+			}
+		}
+		l = splitFields(l[:0], line)
+		switch l[0] {
+		case ReferenceLabel:
+			var previous map[string]float64
+			if opts.StrictReference && sectionReferenceLine > 0 {
+				previous = copyReference(referenceValues)
+			}
+			if err := parseReferenceLine(l, lineNumber, line, referenceValues); err != nil {
+				return err
+			}
+			if previous != nil && !sameReference(previous, referenceValues) {
+				return &ConflictingReferenceError{Line: lineNumber, Text: line, PreviousLine: sectionReferenceLine}
+			}
+			sectionReferenceLine = lineNumber
+			printReference(os.Stdout, referenceValues)
+			referenceFound = true
+			referenceToLoad = false
+			if opts.InheritReference {
+				ref := copyReference(referenceValues)
+				if err := later(func() error { return saveReference(opts.Store, ref) }); err != nil {
+					return err
+				}
+			}
+		case ThermometerLabel, HumiditySensorLabel, FlowSensorLabel, SoundSensorLabel, CompoundLabel:
+			// hitting the start of some sensor readings: first we must conclude the state
+			// of previously processed sensor (if there was any)
+			// it would make sense to save the _sensor_ branding into DB now
+			// (instead of saving log file result)
+			if err := finishBlock(); err != nil {
+				return err
+			}
+			if referenceToLoad {
+				ref, err := opts.ReferenceLoader()
+				if err != nil {
+					return errors.Wrap(err, "failed loading the reference")
+				}
+				referenceValues = copyReference(ref)
+				referenceFound = true
+				referenceToLoad = false
+			}
+			skipping = false
+			// and then create a new one
+			if len(l) < 2 {
+				return &InvalidHeaderError{Line: lineNumber, Text: line, Msg: ErrMissingSensorName}
+			}
+			if l, err = unquoteName(l); err != nil {
+				return &InvalidHeaderError{Line: lineNumber, Text: line, Msg: err.Error()}
+			}
+			if l[1], err = normalizeName(l[1], opts.NamePolicy, opts.NameMaxLength); err != nil {
+				return &InvalidHeaderError{Line: lineNumber, Text: line, Msg: err.Error()}
+			}
+			if l[0] == CompoundLabel {
+				compound, err := compoundChannels(l[1:], opts)
+				if err != nil {
+					return &InvalidHeaderError{Line: lineNumber, Text: line, Msg: err.Error()}
+				}
+				if opts.SensorFilter != nil {
+					// the columns of the channels filtered out are ignored
+					for i, c := range compound {
+						if c.sensor != nil && !opts.SensorFilter.MatchString(c.sensor.Name()) {
+							compound[i] = &channel{sensorType: ignoredChannel}
+						}
+					}
+				}
+				startBlock(compound)
+			} else if opts.SensorFilter != nil && !opts.SensorFilter.MatchString(l[1]) {
+				skipping = true
+			} else {
+				c := newChannel(l[0], l[1], opts)
+				// the sensor may have its own reference value on the header
+				ref, ok, err := headerReference(l[2:])
+				if err != nil {
+					return &InvalidValueError{Line: lineNumber, Text: line, Msg: ErrHeaderRefNotFloat, Err: err}
+				}
+				if ok {
+					c.reference = &ref
+				}
+				startBlock([]*channel{c})
+			}
+		default:
+			if looksLikeHeader(l) {
+				if opts.UnknownTypePolicy != UnknownTypeSkip && opts.UnknownTypePolicy != UnknownTypeWarn {
+					return &UnknownSensorTypeError{Line: lineNumber, Text: line, Type: l[0]}
+				}
+				if opts.UnknownTypePolicy == UnknownTypeWarn {
+					fmt.Printf("Warning: skipping sensor of unknown type %q on line %d\n", l[0], lineNumber)
+				}
+				// the readings that follow are not of the previous sensor
+				if err := finishBlock(); err != nil {
+					return err
+				}
+				skipping = true
+				continue
+			}
+			if skipping {
+				continue
+			}
+			l = timestampFirst(l, opts.ReadingOrder)
+			// readings before any sensor header belong to the default sensor, if there's one
+			if len(channels) == 0 && opts.DefaultSensorType != "" {
+				name := opts.DefaultSensorName
+				if name == "" {
+					name = DefaultSensorName
+				}
+				startBlock([]*channel{newChannel(opts.DefaultSensorType, name, opts)})
+			}
+			// otherwise they are ignored
+			if len(channels) == 0 {
+				if len(l) != readingLineValues {
+					return &WrongReadingFieldsError{Line: lineNumber, Text: line}
+				}
+				continue
+			}
+			// a single humidity sensor may have the expected value after the reading, see expectedSensor
+			var expected *float64
+			if len(channels) == 1 && len(l) == readingLineValues+1 {
+				c := channels[0]
+				if _, ok := c.sensor.(expectedSensor); ok {
+					value, err := c.parseValue(l[2])
+					if err != nil {
+						return &InvalidValueError{Line: lineNumber, Text: line, Msg: ErrExpectedNotFloat, Err: err}
+					}
+					if math.IsNaN(value) || math.IsInf(value, 0) {
+						// the reading can't be judged without it
+						if opts.NonFinitePolicy == NonFiniteDrop {
+							continue
+						}
+						return &NonFiniteValueError{Line: lineNumber, Text: line, Value: l[2]}
+					}
+					expected = &value
+					l = l[:readingLineValues]
+				}
+			}
+			if len(l) != len(channels)+1 {
+				return &WrongReadingFieldsError{Line: lineNumber, Text: line}
+			}
+			timestamp := parseTimestamp(l[0])
+			for i, c := range channels {
+				if c.sensor == nil {
+					continue
+				}
+				value, err := c.parseValue(l[i+1])
+				if err != nil {
+					return &InvalidValueError{Line: lineNumber, Text: line, Msg: ErrReadingNotFloat, Err: err}
+				}
+				if math.IsNaN(value) || math.IsInf(value, 0) {
+					switch opts.NonFinitePolicy {
+					case NonFiniteDrop:
+						continue
+					case NonFiniteKeep:
+					default:
+						return &NonFiniteValueError{Line: lineNumber, Text: line, Value: l[i+1]}
+					}
+				}
+				r := reading{time: timestamp, value: value}
+				if expected != nil {
+					r.expected, r.hasExpected = *expected, true
+				}
+				c.add(r)
+			}
+		}
+	}
+	if err := scanner.Err(); err == bufio.ErrTooLong {
+		// the line that didn't fit is the one after the last scanned
+		return &LineTooLongError{Line: lineNumber + 1, MaxLength: maxLineLength(opts.MaxLineLength)}
+	} else if err != nil {
+		return errors.Wrap(err, "error reading the file")
+	}
+	if opts.EndMarker != "" && !endMarkerSeen {
+		return &IncompleteFileError{Marker: opts.EndMarker}
+	}
+
+	// process the last sensor
+	if err := finishBlock(); err != nil {
+		return err
+	}
+	for _, f := range deferred {
+		if err := f(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Create the reference values before any reference line: zero for the required quantities; the optional
+// ones (the flow and the room temperature) are missing until some reference line gives them
+func newReferenceValues() map[string]float64 {
+	ret := make(map[string]float64)
+	for _, q := range referenceQuantities[:requiredReferenceValues] {
+		ret[q] = 0.0
+	}
+	return ret
+}
+
+// Parse the reference line split to fields, the values are set in referenceValues. The values are
+// either positional, in the order of referenceQuantities, or labeled, e.g.
+//
+//	reference temperature=100 flow=12.5
+//
+// Both give exactly the quantities present, the others are removed from referenceValues, e.g. the flow
+// and the room temperature of the previous longer positional line.
+func parseReferenceLine(l []string, lineNumber int, text string, referenceValues map[string]float64) error {
+	if len(l) > 1 && strings.Contains(l[1], "=") {
+		return parseLabeledReference(l[1:], lineNumber, text, referenceValues)
+	}
+	if len(l) < requiredReferenceValues+1 || len(l) > len(referenceQuantities)+1 {
+		return &WrongRefFieldsError{Line: lineNumber, Text: text}
+	}
+	var err error
+	for i, q := range referenceQuantities[:len(l)-1] {
+		referenceValues[q], err = strconv.ParseFloat(l[i+1], 64)
+		if err != nil {
+			return &InvalidValueError{Line: lineNumber, Text: text, Msg: referenceErrors[q], Err: err}
+		}
+	}
+	// quantities of the previous line the line leaves out, and the labeled ones it has no position for
+	for k := range referenceValues {
+		given := false
+		for _, q := range referenceQuantities[:len(l)-1] {
+			given = given || k == q
+		}
+		if !given {
+			delete(referenceValues, k)
+		}
+	}
+	return nil
+}
+
+// Parse the label=value fields of the labeled reference line, see parseReferenceLine
+func parseLabeledReference(fields []string, lineNumber int, text string, referenceValues map[string]float64) error {
+	values := make(map[string]float64, len(fields))
+	for _, f := range fields {
+		kv := strings.SplitN(f, "=", 2)
+		q, ok := referenceLabels[kv[0]]
+		if len(kv) != 2 || !ok {
+			return &InvalidReferenceError{Line: lineNumber, Text: text, Msg: fmt.Sprintf("%s: %q", ErrInvalidReferenceLabel, f)}
+		}
+		if _, ok := values[q]; ok {
+			return &InvalidReferenceError{Line: lineNumber, Text: text, Msg: fmt.Sprintf("%s: %q", ErrDuplicateReferenceLabel, kv[0])}
+		}
+		value, err := strconv.ParseFloat(kv[1], 64)
+		if err != nil {
+			return &InvalidValueError{Line: lineNumber, Text: text, Msg: referenceErrors[q], Err: err}
+		}
+		values[q] = value
+	}
+	for k := range referenceValues {
+		delete(referenceValues, k)
+	}
+	for k, v := range values {
+		referenceValues[k] = v
+	}
+	return nil
+}
+
+// Return the scanner of the lines of the log file, with the lines up to maxLength bytes
+// (DefaultMaxLineLength when zero) instead of the default 64 kB
+func newLineScanner(r io.Reader, maxLength int) *bufio.Scanner {
+	maxLength = maxLineLength(maxLength)
+	// the limit is the larger of the buffer capacity and the maximum
+	capacity := bufio.MaxScanTokenSize
+	if maxLength < capacity {
+		capacity = maxLength
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, capacity), maxLength)
+	return scanner
+}
+
+// the UTF-8 byte order mark, which the files exported by Windows tools start with
+var byteOrderMark = []byte{0xEF, 0xBB, 0xBF}
+
+// Return the reader without the byte order mark at the start, if there's one; otherwise it would
+// stick to the first label, e.g. of the reference line
+func skipBOM(r io.Reader) io.Reader {
+	br := bufio.NewReader(r)
+	if start, err := br.Peek(len(byteOrderMark)); err == nil && bytes.Equal(start, byteOrderMark) {
+		br.Discard(len(byteOrderMark))
+	}
+	return br
+}
+
+// Return the line length limit of the options value
+func maxLineLength(maxLength int) int {
+	if maxLength == 0 {
+		return DefaultMaxLineLength
+	}
+	return maxLength
+}
+
+// Find the first reference line of the log file and return its values; ok is false when there's
+// no valid one. The invalid reference line is reported by the parsing itself.
+func findReference(data []byte, maxLength int) (ref map[string]float64, ok bool) {
+	scanner := newLineScanner(bytes.NewReader(data), maxLength)
+	for scanner.Scan() {
+		l := strings.Split(scanner.Text(), " ")
+		if l[0] != ReferenceLabel {
+			continue
+		}
+		ref = newReferenceValues()
+		return ref, parseReferenceLine(l, 0, scanner.Text(), ref) == nil
+	}
+	return nil, false
+}
+
+// Move the timestamp of the reading line to the first field, from the last one for value-first
+// lines; with ReadingOrderAuto, the line is value-first when only its last field is a timestamp
+func timestampFirst(l []string, order string) []string {
+	last := len(l) - 1
+	switch order {
+	case ReadingOrderValueFirst:
+	case ReadingOrderAuto:
+		if last == 0 || !parseTimestamp(l[0]).IsZero() || parseTimestamp(l[last]).IsZero() {
+			return l
+		}
+	default:
+		return l
+	}
+	ts := l[last]
+	copy(l[1:], l[:last])
+	l[0] = ts
+	return l
+}
+
+// Check if the line split to fields looks like a sensor header rather than the readings: it starts
+// with a word (letters only, but not a number like "NaN") and has no timestamp
+func looksLikeHeader(l []string) bool {
+	if len(l) < 2 {
+		return false
+	}
+	for _, r := range l[0] {
+		if !unicode.IsLetter(r) {
+			return false
+		}
+	}
+	if _, err := strconv.ParseFloat(l[0], 64); err == nil {
+		return false
+	}
+	for _, f := range l {
+		if !parseTimestamp(f).IsZero() {
+			return false
+		}
+	}
+	return true
+}
+
+// Join the fields of the sensor name quoted on the header, e.g. thermometer "north room 1" ref=100, into
+// the unquoted name; the fields of the header with the name unquoted are returned as they are
+func unquoteName(header []string) ([]string, error) {
+	if !strings.HasPrefix(header[1], `"`) {
+		return header, nil
+	}
+	for i := 1; i < len(header); i++ {
+		// the opening quote may be the closing one as well, as in "temp-1"
+		if f := header[i]; (i > 1 || len(f) > 1) && strings.HasSuffix(f, `"`) {
+			name := strings.Join(header[1:i+1], " ")
+			name = name[1 : len(name)-1]
+			if name == "" {
+				return header, errors.New(ErrMissingSensorName)
+			}
+			return append(append(header[:1], name), header[i+1:]...), nil
+		}
+	}
+	return header, errors.New(ErrUnterminatedName)
+}
+
+// Split the line on single spaces into dst, the same as strings.Split(line, " "), but without
+// allocating new slice for every line
+func splitFields(dst []string, line string) []string {
+	for {
+		i := strings.IndexByte(line, ' ')
+		if i < 0 {
+			return append(dst, line)
+		}
+		dst = append(dst, line[:i])
+		line = line[i+1:]
+	}
+}
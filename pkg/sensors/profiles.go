@@ -0,0 +1,55 @@
+package sensors
+
+import (
+	"sort"
+)
+
+// the built-in bundles of thresholds, zero limits are the defaults as usual
+var profiles = map[string]Thresholds{
+	// the limits of the assignment
+	"default": {},
+	// for the acceptance of new sensors: half of the default limits, all exclusive
+	"strict": {
+		MeanTolerance:        0.25,
+		UltraPreciseStdDev:   1.5,
+		VeryPreciseStdDev:    3,
+		FlowBand:             5,
+		SoundExcessiveMargin: 5,
+	},
+	// for the sensors in the field, which are not worth replacing until they get much worse
+	"lenient": {
+		MeanTolerance:         1,
+		MeanInclusive:         true,
+		UltraPreciseStdDev:    4,
+		UltraPreciseInclusive: true,
+		VeryPreciseStdDev:     7,
+		VeryPreciseInclusive:  true,
+		FlowBand:              20,
+		SoundExcessiveMargin:  15,
+	},
+	// for the calibration in the laboratory, with stable conditions and a quiet room
+	"lab": {
+		MeanTolerance:      0.1,
+		UltraPreciseStdDev: 0.5,
+		VeryPreciseStdDev:  1,
+		FlowBand:           2,
+		SoundLimit:         35,
+	},
+}
+
+// Profile returns the thresholds of the built-in profile of given name, false if there's no such
+// profile, see ProfileNames
+func Profile(name string) (Thresholds, bool) {
+	t, ok := profiles[name]
+	return t, ok
+}
+
+// ProfileNames returns the sorted names of the built-in threshold profiles
+func ProfileNames() []string {
+	ret := make([]string, 0, len(profiles))
+	for name := range profiles {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
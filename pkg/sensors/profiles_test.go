@@ -0,0 +1,88 @@
+package sensors
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// the same readings branded differently by the profiles
+const profileSensors = `reference 100 45 10
+thermometer temp-close
+2007-04-05T22:00 100.2
+2007-04-05T22:01 100.2
+thermometer temp-spread
+2007-04-05T22:00 97.5
+2007-04-05T22:01 100
+2007-04-05T22:02 102.5
+thermometer temp-off
+2007-04-05T22:00 100.8
+flow flow-1
+2007-04-05T22:00 10.8
+sound mic-1
+2007-04-05T22:00 40`
+
+func TestProfiles(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "sensors")
+	if err != nil {
+		t.Error("Error creating test log file")
+		return
+	}
+	defer os.Remove(tmpFile.Name())
+	if err := writeTestLogFile(tmpFile, profileSensors); err != nil {
+		t.Error("Error writing test log file")
+		return
+	}
+
+	cases := []struct {
+		profile string
+		want    map[string]string
+	}{
+		{"default", map[string]string{
+			"temp-close":  ThermometerUltraPrecise,
+			"temp-spread": ThermometerUltraPrecise,
+			"temp-off":    ThermometerPrecise,
+			"flow-1":      FlowSensorNormal,
+			"mic-1":       SoundSensorQuiet,
+		}},
+		{"strict", map[string]string{
+			"temp-close":  ThermometerUltraPrecise,
+			"temp-spread": ThermometerVeryPrecise,
+			"temp-off":    ThermometerPrecise,
+			"flow-1":      FlowSensorHigh,
+			"mic-1":       SoundSensorQuiet,
+		}},
+		{"lenient", map[string]string{
+			"temp-close":  ThermometerUltraPrecise,
+			"temp-spread": ThermometerUltraPrecise,
+			"temp-off":    ThermometerUltraPrecise,
+			"flow-1":      FlowSensorNormal,
+			"mic-1":       SoundSensorQuiet,
+		}},
+		{"lab", map[string]string{
+			"temp-close":  ThermometerPrecise,
+			"temp-spread": ThermometerPrecise,
+			"temp-off":    ThermometerPrecise,
+			"flow-1":      FlowSensorHigh,
+			"mic-1":       SoundSensorLoud,
+		}},
+	}
+	for _, c := range cases {
+		t.Run(c.profile, func(t *testing.T) {
+			thresholds, ok := Profile(c.profile)
+			if !ok {
+				t.Fatalf("got no profile %q", c.profile)
+			}
+			val, err := brandTestLogFile(tmpFile.Name(), Options{Thresholds: thresholds})
+			assertError(t, err, nil)
+			for name, want := range c.want {
+				assertString(t, val[name], want)
+			}
+		})
+	}
+	assertInt(t, len(ProfileNames()), len(cases))
+
+	if _, ok := Profile("unknown"); ok {
+		t.Error("got unknown profile")
+	}
+}
@@ -0,0 +1,109 @@
+package sensors
+
+import (
+	"time"
+
+	"gonum.org/v1/gonum/stat"
+)
+
+// formats of the reading timestamps, tried in this order
+var timestampLayouts = []string{
+	"2006-01-02T15:04",
+	"2006-01-02T15:04:05",
+	time.RFC3339,
+}
+
+// reading is a single value of a sensor
+type reading struct {
+	// time is zero when the timestamp could not be parsed
+	time  time.Time
+	value float64
+	// expected value logged with the reading, instead of the reference one, see expectedSensor
+	expected    float64
+	hasExpected bool
+	// position among all the readings of the sensor, set by reservoir to keep their order in the sample
+	index int
+}
+
+// Reading is a single value of a sensor, as reported in SensorResult
+type Reading struct {
+	// Time is zero when the timestamp could not be parsed
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+	// Expected is the expected value logged with the reading, nil when it has none
+	Expected *float64 `json:"expected,omitempty"`
+}
+
+// Parse the timestamp of the reading; return zero time for unknown format, the timestamp
+// is not needed for the basic branding
+func parseTimestamp(s string) time.Time {
+	if t, ok := parseMinuteTimestamp(s); ok {
+		return t
+	}
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// Parse the timestamp in the most common format 2006-01-02T15:04, the same way as time.Parse does,
+// but several times faster; ok is false when the timestamp has other format or is not valid
+func parseMinuteTimestamp(s string) (t time.Time, ok bool) {
+	if len(s) != len(timestampLayouts[0]) || s[4] != '-' || s[7] != '-' || s[10] != 'T' || s[13] != ':' {
+		return time.Time{}, false
+	}
+	year, ok1 := parseDigits(s[0:4])
+	month, ok2 := parseDigits(s[5:7])
+	day, ok3 := parseDigits(s[8:10])
+	hour, ok4 := parseDigits(s[11:13])
+	minute, ok5 := parseDigits(s[14:16])
+	if !ok1 || !ok2 || !ok3 || !ok4 || !ok5 || month < 1 || month > 12 || day < 1 || hour > 23 || minute > 59 {
+		return time.Time{}, false
+	}
+	t = time.Date(year, time.Month(month), day, hour, minute, 0, 0, time.UTC)
+	// time.Date normalizes the days past the end of month, time.Parse rejects them
+	if t.Day() != day {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// Parse the string of decimal digits
+func parseDigits(s string) (int, bool) {
+	n := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return 0, false
+		}
+		n = n*10 + int(s[i]-'0')
+	}
+	return n, true
+}
+
+// Count the intervals between readings longer than multiplier times the median interval,
+// i.e. the places where the sensor probably went offline.
+// At least two intervals are needed for the median to mean anything.
+func countGaps(intervals []float64, multiplier float64) int {
+	if len(intervals) < 2 {
+		return 0
+	}
+	m := median(intervals)
+	gaps := 0
+	for _, interval := range intervals {
+		if interval > multiplier*m {
+			gaps++
+		}
+	}
+	return gaps
+}
+
+// Check if the readings are (nearly) constant, i.e. the sensor is stuck: their std deviation
+// is at most maxStdDev
+func isFlatline(values []float64, maxStdDev float64) bool {
+	if len(values) < 2 {
+		return false
+	}
+	return stat.StdDev(values, nil) <= maxStdDev
+}
@@ -0,0 +1,237 @@
+package sensors
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	for _, s := range []string{"2007-04-05T22:00", "2007-04-05T22:00:30", "2007-04-05T22:00:30+02:00"} {
+		if parseTimestamp(s).IsZero() {
+			t.Errorf("failed parsing timestamp %q", s)
+		}
+	}
+	if !parseTimestamp("2007").IsZero() {
+		t.Errorf("unexpectedly parsed timestamp %q", "2007")
+	}
+}
+
+func TestCountGaps(t *testing.T) {
+	assertInt(t, countGaps([]float64{60, 60, 60, 60}, 3), 0)
+	assertInt(t, countGaps([]float64{60, 60, 600, 60, 60}, 3), 1)
+	assertInt(t, countGaps([]float64{600}, 3), 0)
+}
+
+const tempWithGap = `reference 100 0
+thermometer temp-1
+2007-04-05T22:00 100
+2007-04-05T22:01 100
+2007-04-05T22:02 100.1
+2007-04-05T22:03 99.9
+2007-04-05T23:30 100
+2007-04-05T23:31 100`
+
+func TestGapDetection(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "sensors")
+	if err != nil {
+		t.Error("Error creating test log file")
+		return
+	}
+	defer os.Remove(tmpFile.Name())
+
+	t.Run("gap detected", func(t *testing.T) {
+		if err := writeTestLogFile(tmpFile, tempWithGap); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		val, err := processTestLogFile(tmpFile.Name(), Options{GapMultiplier: 5})
+		assertError(t, err, nil)
+		assertString(t, val, `{
+  "temp-1": "gappy"
+}`)
+	})
+
+	t.Run("detection disabled", func(t *testing.T) {
+		val, err := processTestLogFile(tmpFile.Name(), Options{})
+		assertError(t, err, nil)
+		assertString(t, val, `{
+  "temp-1": "ultra precise"
+}`)
+	})
+
+	t.Run("no gap", func(t *testing.T) {
+		if err := writeTestLogFile(tmpFile, tempUltraPrecise); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		val, err := processTestLogFile(tmpFile.Name(), Options{GapMultiplier: 5})
+		assertError(t, err, nil)
+		assertString(t, val, `{
+  "temp-1": "ultra precise"
+}`)
+	})
+}
+
+func TestParseMinuteTimestamp(t *testing.T) {
+	for _, s := range []string{
+		"2007-04-05T22:00", "2008-02-29T23:59", "2007-02-29T10:00", "2007-04-31T10:00", "2007-13-05T10:00",
+		"2007-04-05T24:00", "2007-04-05T22:60", "2007-04-00T22:00", "2007-04-05 22:00", "2007-4-05T22:00",
+		"+007-04-05T22:00", "2007-04-05T22:0a", "2007-04-05T22:00:00",
+	} {
+		want, err := time.Parse(timestampLayouts[0], s)
+		got, ok := parseMinuteTimestamp(s)
+		if ok != (err == nil) || !got.Equal(want) {
+			t.Errorf("got %s (%t) for %q, want %s (%v)", got, ok, s, want, err)
+		}
+	}
+}
+
+func TestIsFlatline(t *testing.T) {
+	if !isFlatline([]float64{100, 100, 100}, 0) {
+		t.Error("identical readings not detected as flatline")
+	}
+	if isFlatline([]float64{100, 100.1, 100}, 0) {
+		t.Error("varying readings detected as flatline")
+	}
+	if !isFlatline([]float64{100, 100.001, 100}, 0.01) {
+		t.Error("nearly identical readings not detected as flatline")
+	}
+	if isFlatline([]float64{100}, 0) {
+		t.Error("single reading detected as flatline")
+	}
+}
+
+const tempFlatline = `reference 100 45
+thermometer temp-1
+2007-04-05T22:00 100
+2007-04-05T22:01 100
+2007-04-05T22:02 100
+2007-04-05T22:03 100
+thermometer temp-2
+2007-04-05T22:00 100
+2007-04-05T22:01 100.1
+2007-04-05T22:02 99.9
+2007-04-05T22:03 100
+humidity hum-1
+2007-04-05T22:00 45
+2007-04-05T22:01 45`
+
+func TestFlatlineDetection(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "sensors")
+	if err != nil {
+		t.Error("Error creating test log file")
+		return
+	}
+	defer os.Remove(tmpFile.Name())
+	if err := writeTestLogFile(tmpFile, tempFlatline); err != nil {
+		t.Error("Error writing test log file")
+		return
+	}
+
+	t.Run("flatline detected", func(t *testing.T) {
+		val, err := processTestLogFile(tmpFile.Name(), Options{FlatlineMinReadings: 3})
+		assertError(t, err, nil)
+		// hum-1 has too few readings to tell
+		assertString(t, val, `{
+  "hum-1": "keep",
+  "temp-1": "flatline",
+  "temp-2": "ultra precise"
+}`)
+	})
+
+	t.Run("near zero std deviation", func(t *testing.T) {
+		val, err := processTestLogFile(tmpFile.Name(), Options{FlatlineMinReadings: 2, FlatlineStdDev: 0.1})
+		assertError(t, err, nil)
+		assertString(t, val, `{
+  "hum-1": "flatline",
+  "temp-1": "flatline",
+  "temp-2": "flatline"
+}`)
+	})
+
+	t.Run("detection disabled", func(t *testing.T) {
+		val, err := processTestLogFile(tmpFile.Name(), Options{})
+		assertError(t, err, nil)
+		assertString(t, val, `{
+  "hum-1": "keep",
+  "temp-1": "ultra precise",
+  "temp-2": "ultra precise"
+}`)
+	})
+}
+
+func TestReadingOrder(t *testing.T) {
+	timeFirst := `reference 100 45
+thermometer temp-1
+2007-04-05T22:00 100
+2007-04-05T22:01 100.1
+2007-04-05T22:02 99.9
+thermometer temp-2
+2007-04-05T22:00 101
+2007-04-05T22:01 106
+humidity hum-1
+2007-04-05T22:00 45.1
+2007-04-05T22:01 45
+2007-04-05T22:02 45
+2007-04-05T22:10 44.9
+compound dev-1 thermometer humidity
+2007-04-05T22:00 100 45
+2007-04-05T22:01 100.2 47`
+	valueFirst := `reference 100 45
+thermometer temp-1
+100 2007-04-05T22:00
+100.1 2007-04-05T22:01
+99.9 2007-04-05T22:02
+thermometer temp-2
+101 2007-04-05T22:00
+106 2007-04-05T22:01
+humidity hum-1
+45.1 2007-04-05T22:00
+45 2007-04-05T22:01
+45 2007-04-05T22:02
+44.9 2007-04-05T22:10
+compound dev-1 thermometer humidity
+100 45 2007-04-05T22:00
+100.2 47 2007-04-05T22:01`
+	// both orders mixed, as in the files merged from several exporters
+	mixed := strings.Replace(timeFirst, "2007-04-05T22:10 44.9", "44.9 2007-04-05T22:10", 1)
+
+	opts := Options{GapMultiplier: 2, IncludeReadings: true}
+	want, err := ProcessReader(strings.NewReader(timeFirst), opts)
+	assertError(t, err, nil)
+	assertString(t, want.Brandings()["hum-1"], SensorGappy)
+
+	cases := []struct {
+		name, content, order string
+	}{
+		{"value first", valueFirst, ReadingOrderValueFirst},
+		{"auto value first", valueFirst, ReadingOrderAuto},
+		{"auto time first", timeFirst, ReadingOrderAuto},
+		{"auto mixed", mixed, ReadingOrderAuto},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			opts.ReadingOrder = c.order
+			got, err := ProcessReader(strings.NewReader(c.content), opts)
+			assertError(t, err, nil)
+			assertString(t, got.Hash(), want.Hash())
+			assertInt(t, len(got.Sensors), len(want.Sensors))
+			for i, s := range got.Sensors {
+				assertString(t, s.Branding, want.Sensors[i].Branding)
+				for j, r := range s.Readings {
+					if !r.Time.Equal(want.Sensors[i].Readings[j].Time) || r.Value != want.Sensors[i].Readings[j].Value {
+						t.Errorf("got reading %v of %s, want %v", r, s.Name, want.Sensors[i].Readings[j])
+					}
+				}
+			}
+		})
+	}
+
+	t.Run("value first misread as time first", func(t *testing.T) {
+		_, err := ProcessReader(strings.NewReader(valueFirst), Options{})
+		assertErrorMessageSubString(t, err, ErrReadingNotFloat)
+	})
+}
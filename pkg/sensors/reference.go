@@ -0,0 +1,153 @@
+package sensors
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// reference values of the last log file that had them
+	lastReferenceKey = "reference:last"
+
+	// key of the sensor header option overriding the reference value of the sensor
+	headerReferenceOption = "ref"
+)
+
+// ErrNoReference is returned by ReadReference when there's no reference line
+var ErrNoReference = errors.New("no reference line found")
+
+// ReadReference reads the reference values from the first reference line, e.g. of the file with just
+// the reference for the log files that lack it, see Options.Reference. The other lines are ignored.
+func ReadReference(r io.Reader) (map[string]float64, error) {
+	scanner := newLineScanner(skipBOM(r), 0)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		l := splitFields(nil, scanner.Text())
+		if l[0] != ReferenceLabel {
+			continue
+		}
+		ref := newReferenceValues()
+		if err := parseReferenceLine(l, lineNumber, scanner.Text(), ref); err != nil {
+			return nil, err
+		}
+		return ref, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "error reading the reference")
+	}
+	return nil, ErrNoReference
+}
+
+// Read the last known reference values; ok is false when there are none yet
+func loadReference(store Store) (ref map[string]float64, ok bool, err error) {
+	val, err := store.Get(lastReferenceKey)
+	if err == ErrNotFound {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, errors.Wrap(err, "failed reading last reference")
+	}
+	if err := json.Unmarshal([]byte(val), &ref); err != nil {
+		return nil, false, errors.Wrap(err, "invalid last reference")
+	}
+	return ref, true, nil
+}
+
+// Remember the reference values for the following log files
+func saveReference(store Store, ref map[string]float64) error {
+	j, _ := json.Marshal(ref)
+	if err := store.Set(lastReferenceKey, string(j)); err != nil {
+		return errors.Wrap(err, "failed saving last reference")
+	}
+	return nil
+}
+
+// Return the copy of reference values
+func copyReference(ref map[string]float64) map[string]float64 {
+	ret := make(map[string]float64, len(ref))
+	for k, v := range ref {
+		ret[k] = v
+	}
+	return ret
+}
+
+// Print the reference values sorted by the quantity, so that the output is the same for the same reference
+func printReference(w io.Writer, ref map[string]float64) {
+	quantities := make([]string, 0, len(ref))
+	for k := range ref {
+		quantities = append(quantities, k)
+	}
+	sort.Strings(quantities)
+	for _, k := range quantities {
+		fmt.Fprintf(w, "reference value for %s: %.2f\n", k, ref[k])
+	}
+}
+
+// Return the values of the reference quantities the sensor type uses, present in the reference
+func typeReference(sensorType string, reference map[string]float64) map[string]float64 {
+	t := sensorTypes[sensorType]
+	ret := make(map[string]float64)
+	for _, q := range append([]string{t.referenceKey}, t.optionalKeys...) {
+		if v, ok := reference[q]; ok {
+			ret[q] = v
+		}
+	}
+	return ret
+}
+
+// Check if both references have the same quantities with the same values
+func sameReference(a, b map[string]float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || w != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Parse the optional key=value tokens following the sensor name on its header, e.g.
+//
+//	thermometer temp-1 ref=100
+//
+// and return the reference value override of the sensor; ok is false when the header
+// has none. Tokens that are not key=value and unknown keys are ignored.
+func headerReference(options []string) (value float64, ok bool, err error) {
+	for _, o := range options {
+		kv := strings.SplitN(o, "=", 2)
+		if len(kv) != 2 || kv[0] != headerReferenceOption {
+			continue
+		}
+		value, err = strconv.ParseFloat(kv[1], 64)
+		if err != nil {
+			return 0, false, err
+		}
+		ok = true
+	}
+	return value, ok, nil
+}
+
+// Return the reference of the block with the reference value override of the channel applied
+func channelReference(c *channel, reference map[string]float64, referenceFound bool) (map[string]float64, bool) {
+	if c.reference == nil {
+		return reference, referenceFound
+	}
+	ret := make(map[string]float64)
+	for k, v := range reference {
+		ret[k] = v
+	}
+	ret[sensorTypes[c.sensorType].referenceKey] = *c.reference
+	if c.sensorType == ThermometerLabel {
+		// the room temperature of the file doesn't apply to the sensor with its own reference
+		delete(ret, RoomTemperatureKey)
+	}
+	return ret, true
+}
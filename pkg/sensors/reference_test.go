@@ -0,0 +1,579 @@
+package sensors
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestInheritReference(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "sensors")
+	if err != nil {
+		t.Error("Error creating test log file")
+		return
+	}
+	defer os.Remove(tmpFile.Name())
+
+	cache := memStore{}
+	opts := Options{InheritReference: true, Store: cache}
+
+	t.Run("no reference yet", func(t *testing.T) {
+		if err := writeTestLogFile(tmpFile, "thermometer temp-1\n2007-04-05T22:00 100"); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		val, err := processTestLogFile(tmpFile.Name(), opts)
+		assertError(t, err, nil)
+		assertString(t, val, `{
+  "temp-1": "precise"
+}`)
+	})
+
+	t.Run("reference established", func(t *testing.T) {
+		if err := writeTestLogFile(tmpFile, tempUltraPrecise); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		_, err := processTestLogFile(tmpFile.Name(), opts)
+		assertError(t, err, nil)
+		ref, ok, err := loadReference(cache)
+		assertError(t, err, nil)
+		if !ok {
+			t.Fatal("reference not saved")
+		}
+		if ref["Temperature"] != 100 {
+			t.Errorf("got reference temperature %f, want 100", ref["Temperature"])
+		}
+	})
+
+	t.Run("stored reference reused", func(t *testing.T) {
+		if err := writeTestLogFile(tmpFile, "thermometer temp-1\n2007-04-05T22:00 100"); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		val, err := processTestLogFile(tmpFile.Name(), opts)
+		assertError(t, err, nil)
+		assertString(t, val, `{
+  "temp-1": "ultra precise"
+}`)
+	})
+
+	t.Run("room temperature inherited", func(t *testing.T) {
+		if err := writeTestLogFile(tmpFile, "reference 100 45 12 70\nthermometer temp-1\n2007-04-05T22:00 100"); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		_, err := processTestLogFile(tmpFile.Name(), opts)
+		assertError(t, err, nil)
+		if err := writeTestLogFile(tmpFile, "thermometer temp-1\n2007-04-05T22:00 70\n2007-04-05T22:01 70.1"); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		val, err := processTestLogFile(tmpFile.Name(), opts)
+		assertError(t, err, nil)
+		assertString(t, val, `{
+  "temp-1": "very precise"
+}`)
+	})
+}
+
+func TestHeaderReference(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "sensors")
+	if err != nil {
+		t.Error("Error creating test log file")
+		return
+	}
+	defer os.Remove(tmpFile.Name())
+
+	tests := []struct {
+		name, content, want string
+		err                 error
+	}{
+		{"sensor override", `reference 70.0 45.0
+thermometer temp-1 ref=100
+2007-04-05T22:00 100
+2007-04-05T22:01 100
+thermometer temp-2
+2007-04-05T22:02 70
+humidity hum-1 ref=80
+2007-04-05T22:03 80`, `{
+  "hum-1": "keep",
+  "temp-1": "ultra precise",
+  "temp-2": "ultra precise"
+}`, nil},
+		{"without reference line", `thermometer temp-1 ref=100
+2007-04-05T22:00 100`, `{
+  "temp-1": "ultra precise"
+}`, nil},
+		{"other options ignored", `reference 100.0 45.0
+thermometer temp-1 model=x1 extra
+2007-04-05T22:00 100`, `{
+  "temp-1": "ultra precise"
+}`, nil},
+		{"invalid value", `thermometer temp-1 ref=hot
+2007-04-05T22:00 100`, "", &InvalidValueError{Line: 1, Msg: ErrHeaderRefNotFloat}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := writeTestLogFile(tmpFile, tt.content); err != nil {
+				t.Error("Error writing test log file")
+				return
+			}
+			val, err := processTestLogFile(tmpFile.Name(), Options{})
+			if tt.err != nil {
+				var valueErr *InvalidValueError
+				if !errors.As(err, &valueErr) || valueErr.Line != 1 || valueErr.Msg != ErrHeaderRefNotFloat {
+					t.Fatalf("got error %v, want %v", err, tt.err)
+				}
+				return
+			}
+			assertError(t, err, nil)
+			assertString(t, val, tt.want)
+		})
+	}
+}
+
+const trailingReference = `thermometer temp-1
+2007-04-05T22:00 100
+2007-04-05T22:01 100.1
+humidity hum-1
+2007-04-05T22:00 45.1
+reference 100 45`
+
+func TestReferenceAnywhere(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "sensors")
+	if err != nil {
+		t.Error("Error creating test log file")
+		return
+	}
+	defer os.Remove(tmpFile.Name())
+
+	t.Run("trailing reference", func(t *testing.T) {
+		if err := writeTestLogFile(tmpFile, trailingReference); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		val, err := processTestLogFile(tmpFile.Name(), Options{ReferenceAnywhere: true})
+		assertError(t, err, nil)
+		assertString(t, val, `{
+  "hum-1": "keep",
+  "temp-1": "ultra precise"
+}`)
+	})
+
+	t.Run("streaming by default", func(t *testing.T) {
+		val, err := processTestLogFile(tmpFile.Name(), Options{})
+		assertError(t, err, nil)
+		assertString(t, val, `{
+  "hum-1": "discard",
+  "temp-1": "precise"
+}`)
+	})
+
+	t.Run("later reference lines apply as usual", func(t *testing.T) {
+		if err := writeTestLogFile(tmpFile, "thermometer temp-1\n2007-04-05T22:00 100\nreference 100 45\nthermometer temp-2\n2007-04-05T22:00 70\nreference 70 45"); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		val, err := processTestLogFile(tmpFile.Name(), Options{ReferenceAnywhere: true})
+		assertError(t, err, nil)
+		assertString(t, val, `{
+  "temp-1": "ultra precise",
+  "temp-2": "precise"
+}`)
+	})
+
+	t.Run("invalid reference", func(t *testing.T) {
+		if err := writeTestLogFile(tmpFile, "thermometer temp-1\n2007-04-05T22:00 100\nreference 100"); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		_, err := processTestLogFile(tmpFile.Name(), Options{ReferenceAnywhere: true})
+		assertErrorMessageSubString(t, err, ErrWrongNumberRefFields)
+	})
+}
+
+func TestRoomTemperature(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "sensors")
+	if err != nil {
+		t.Error("Error creating test log file")
+		return
+	}
+	defer os.Remove(tmpFile.Name())
+
+	tests := []struct {
+		name, content, want string
+	}{
+		{"very precise vs room only", `reference 100 45 0 70
+thermometer temp-1
+2007-04-05T22:00 70
+2007-04-05T22:01 70.1
+thermometer temp-2
+2007-04-05T22:00 100
+2007-04-05T22:01 100.1
+thermometer temp-3
+2007-04-05T22:00 85`, `{
+  "temp-1": "very precise",
+  "temp-2": "ultra precise",
+  "temp-3": "precise"
+}`},
+		{"without room temperature", `reference 100 45
+thermometer temp-1
+2007-04-05T22:00 70
+thermometer temp-2
+2007-04-05T22:00 97
+2007-04-05T22:01 103`, `{
+  "temp-1": "precise",
+  "temp-2": "very precise"
+}`},
+		{"header override", `reference 100 45 0 70
+thermometer temp-1 ref=50
+2007-04-05T22:00 70`, `{
+  "temp-1": "precise"
+}`},
+		{"shorter line resets room temperature", `reference 100 45 12 70
+thermometer temp-1
+2007-04-05T22:00 70
+2007-04-05T22:01 70.1
+reference 50 45
+thermometer temp-2
+2007-04-05T22:00 70
+2007-04-05T22:01 70.1`, `{
+  "temp-1": "very precise",
+  "temp-2": "precise"
+}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := writeTestLogFile(tmpFile, tt.content); err != nil {
+				t.Error("Error writing test log file")
+				return
+			}
+			val, err := processTestLogFile(tmpFile.Name(), Options{})
+			assertError(t, err, nil)
+			assertString(t, val, tt.want)
+		})
+	}
+
+	t.Run("invalid room temperature", func(t *testing.T) {
+		if err := writeTestLogFile(tmpFile, "reference 100 45 0 warm"); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		_, err := processTestLogFile(tmpFile.Name(), Options{})
+		assertErrorMessageSubString(t, err, ErrRoomTempNotFloat)
+	})
+}
+
+func TestStrictReference(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "sensors")
+	if err != nil {
+		t.Error("Error creating test log file")
+		return
+	}
+	defer os.Remove(tmpFile.Name())
+	opts := Options{StrictReference: true}
+
+	t.Run("conflicting references", func(t *testing.T) {
+		if err := writeTestLogFile(tmpFile, "reference 100 45\nreference 70 45\nthermometer temp-1\n2007-04-05T22:00 100"); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		_, err := processTestLogFile(tmpFile.Name(), opts)
+		var refErr *ConflictingReferenceError
+		if !errors.As(err, &refErr) {
+			t.Fatalf("got error %v, want ConflictingReferenceError", err)
+		}
+		assertInt(t, refErr.Line, 2)
+		assertInt(t, refErr.PreviousLine, 1)
+
+		// the later reference wins without the strict mode
+		val, err := processTestLogFile(tmpFile.Name(), Options{})
+		assertError(t, err, nil)
+		assertString(t, val, `{
+  "temp-1": "precise"
+}`)
+	})
+
+	t.Run("same duplicate reference", func(t *testing.T) {
+		if err := writeTestLogFile(tmpFile, "reference 100 45\nreference 100.0 45\nthermometer temp-1\n2007-04-05T22:00 100"); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		_, err := processTestLogFile(tmpFile.Name(), opts)
+		assertError(t, err, nil)
+	})
+
+	t.Run("references of sections", func(t *testing.T) {
+		if err := writeTestLogFile(tmpFile, "reference 100 45\nthermometer temp-1\n2007-04-05T22:00 100\nreference 70 45\nthermometer temp-2\n2007-04-05T22:00 70"); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		val, err := processTestLogFile(tmpFile.Name(), opts)
+		assertError(t, err, nil)
+		assertString(t, val, `{
+  "temp-1": "ultra precise",
+  "temp-2": "ultra precise"
+}`)
+	})
+}
+
+func TestPartialReference(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "sensors")
+	if err != nil {
+		t.Error("Error creating test log file")
+		return
+	}
+	defer os.Remove(tmpFile.Name())
+
+	t.Run("temperature only", func(t *testing.T) {
+		content := `reference temperature=100
+thermometer temp-1
+2007-04-05T22:00 100
+2007-04-05T22:01 100.1
+thermometer temp-2
+2007-04-05T22:00 106
+2007-04-05T22:01 94`
+		if err := writeTestLogFile(tmpFile, content); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		val, err := processTestLogFile(tmpFile.Name(), Options{})
+		assertError(t, err, nil)
+		assertString(t, val, `{
+  "temp-1": "ultra precise",
+  "temp-2": "precise"
+}`)
+	})
+
+	t.Run("labels in any order", func(t *testing.T) {
+		content := `reference room=70 humidity=45 temperature=100
+thermometer temp-1
+2007-04-05T22:00 70
+2007-04-05T22:01 70.1
+humidity hum-1
+2007-04-05T22:00 45.1`
+		if err := writeTestLogFile(tmpFile, content); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		val, err := processTestLogFile(tmpFile.Name(), Options{})
+		assertError(t, err, nil)
+		assertString(t, val, `{
+  "hum-1": "keep",
+  "temp-1": "very precise"
+}`)
+	})
+
+	t.Run("missing quantity of a sensor", func(t *testing.T) {
+		content := `reference temperature=100
+thermometer temp-1
+2007-04-05T22:00 100
+humidity hum-1
+2007-04-05T22:00 45.1`
+		if err := writeTestLogFile(tmpFile, content); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		_, err := processTestLogFile(tmpFile.Name(), Options{})
+		var refErr *MissingReferenceError
+		if !errors.As(err, &refErr) {
+			t.Fatalf("got error %v, want MissingReferenceError", err)
+		}
+		assertInt(t, refErr.Line, 4)
+		assertString(t, refErr.Sensor, "hum-1")
+		assertString(t, refErr.Quantity, "Humidity")
+	})
+
+	t.Run("flow left out by the positional line", func(t *testing.T) {
+		content := `reference 100 45 12
+flow flow-1
+2007-04-05T22:00 12
+reference 100 45
+flow flow-2
+2007-04-05T22:00 12`
+		if err := writeTestLogFile(tmpFile, content); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		_, err := processTestLogFile(tmpFile.Name(), Options{})
+		var refErr *MissingReferenceError
+		if !errors.As(err, &refErr) {
+			t.Fatalf("got error %v, want MissingReferenceError", err)
+		}
+		assertInt(t, refErr.Line, 5)
+		assertString(t, refErr.Sensor, "flow-2")
+		assertString(t, refErr.Quantity, "Flow")
+	})
+
+	t.Run("missing quantity of the inherited reference", func(t *testing.T) {
+		cache := memStore{}
+		opts := Options{InheritReference: true, Store: cache}
+		if err := writeTestLogFile(tmpFile, "reference temperature=70\nthermometer temp-1\n2007-04-05T22:00 70"); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		_, err := processTestLogFile(tmpFile.Name(), opts)
+		assertError(t, err, nil)
+		if err := writeTestLogFile(tmpFile, "humidity hum-1\n2007-04-05T22:00 45.1"); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		_, err = processTestLogFile(tmpFile.Name(), opts)
+		var refErr *MissingReferenceError
+		if !errors.As(err, &refErr) {
+			t.Fatalf("got error %v, want MissingReferenceError", err)
+		}
+		assertString(t, refErr.Sensor, "hum-1")
+		assertString(t, refErr.Quantity, "Humidity")
+	})
+
+	t.Run("header reference fills the missing quantity", func(t *testing.T) {
+		content := `reference temperature=100
+humidity hum-1 ref=45
+2007-04-05T22:00 45.1
+sound sound-1
+2007-04-05T22:00 40`
+		if err := writeTestLogFile(tmpFile, content); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		val, err := processTestLogFile(tmpFile.Name(), Options{})
+		assertError(t, err, nil)
+		assertString(t, val, `{
+  "hum-1": "keep",
+  "sound-1": "quiet"
+}`)
+	})
+
+	t.Run("positional line after labeled one", func(t *testing.T) {
+		content := `reference temperature=100
+thermometer temp-1
+2007-04-05T22:00 100
+reference 100 45 0
+flow flow-1
+2007-04-05T22:00 0`
+		if err := writeTestLogFile(tmpFile, content); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		val, err := processTestLogFile(tmpFile.Name(), Options{})
+		assertError(t, err, nil)
+		assertString(t, val, `{
+  "flow-1": "normal",
+  "temp-1": "ultra precise"
+}`)
+	})
+
+	t.Run("invalid labeled line", func(t *testing.T) {
+		cases := []struct {
+			content, want string
+		}{
+			{"reference temperature=100 pressure=1013", ErrInvalidReferenceLabel + `: "pressure=1013"`},
+			{"reference temperature=100 45", ErrInvalidReferenceLabel + `: "45"`},
+			{"reference temperature=100 temperature=101", ErrDuplicateReferenceLabel + `: "temperature"`},
+			{"reference temperature=a", ErrTempNotFloat},
+			{"reference sound=loud", ErrSoundNotFloat},
+		}
+		for _, c := range cases {
+			if err := writeTestLogFile(tmpFile, c.content); err != nil {
+				t.Error("Error writing test log file")
+				return
+			}
+			_, err := processTestLogFile(tmpFile.Name(), Options{})
+			assertErrorMessageSubString(t, err, c.want)
+		}
+	})
+}
+
+func TestSidecarReference(t *testing.T) {
+	ref, err := ReadReference(strings.NewReader("# reference of the station\nreference 100 45 20\n"))
+	assertError(t, err, nil)
+	if ref["Temperature"] != 100 || ref["Humidity"] != 45 || ref["Flow"] != 20 {
+		t.Errorf("got reference %v", ref)
+	}
+
+	log := "thermometer temp-1\n2007-04-05T22:00 100.1\n"
+	res, err := ProcessReader(strings.NewReader(log), Options{Reference: ref})
+	assertError(t, err, nil)
+	assertString(t, res.Brandings()["temp-1"], ThermometerUltraPrecise)
+
+	// the reference line of the log file wins
+	res, err = ProcessReader(strings.NewReader("reference 90 45\n"+log), Options{Reference: ref})
+	assertError(t, err, nil)
+	assertString(t, res.Brandings()["temp-1"], ThermometerPrecise)
+
+	// the loader is called only when the first sensor has no reference line before it
+	loads := 0
+	loader := func() (map[string]float64, error) {
+		loads++
+		return ref, nil
+	}
+	res, err = ProcessReader(strings.NewReader("reference 90 45\n"+log), Options{ReferenceLoader: loader})
+	assertError(t, err, nil)
+	assertString(t, res.Brandings()["temp-1"], ThermometerPrecise)
+	assertInt(t, loads, 0)
+	res, err = ProcessReader(strings.NewReader(log+"reference 90 45\n"+log), Options{ReferenceLoader: loader})
+	assertError(t, err, nil)
+	assertString(t, res.Sensors[0].Branding, ThermometerUltraPrecise)
+	assertString(t, res.Sensors[1].Branding, ThermometerPrecise)
+	assertInt(t, loads, 1)
+	_, err = ProcessReader(strings.NewReader(log), Options{ReferenceLoader: func() (map[string]float64, error) {
+		return nil, errors.New("unavailable")
+	}})
+	assertErrorMessageSubString(t, err, "failed loading the reference: unavailable")
+
+	_, err = ReadReference(strings.NewReader("thermometer temp-1\n"))
+	assertError(t, err, ErrNoReference)
+	_, err = ReadReference(strings.NewReader("reference 100\n"))
+	assertErrorMessageSubString(t, err, ErrWrongNumberRefFields)
+}
+
+func TestPrintReference(t *testing.T) {
+	ref := map[string]float64{"Temperature": 100, "Humidity": 45, "Flow": 12, RoomTemperatureKey: 22, "Sound": 40}
+	var want string
+	for i := 0; i < 20; i++ {
+		var b strings.Builder
+		printReference(&b, ref)
+		if i == 0 {
+			want = b.String()
+			continue
+		}
+		assertString(t, b.String(), want)
+	}
+	lines := strings.Split(strings.TrimSpace(want), "\n")
+	if len(lines) != len(ref) || !sort.StringsAreSorted(lines) {
+		t.Errorf("got reference output %q, want the quantities in sorted order", want)
+	}
+	assertString(t, lines[0], "reference value for Flow: 12.00")
+}
+
+func TestIncludeReference(t *testing.T) {
+	log := `reference 100 45
+thermometer temp-1 ref=104
+2007-04-05T22:00 104
+humidity hum-1
+2007-04-05T22:00 45.1
+reference 98 40
+humidity hum-2
+2007-04-05T22:00 40`
+	for _, parallelism := range []int{0, 4} {
+		res, err := ProcessReader(strings.NewReader(log), Options{IncludeReference: true, Parallelism: parallelism})
+		assertError(t, err, nil)
+		want := []map[string]float64{{"Temperature": 104}, {"Humidity": 45}, {"Humidity": 40}}
+		for i, s := range res.Sensors {
+			if !reflect.DeepEqual(s.Reference, want[i]) {
+				t.Errorf("parallelism %d: got reference %v of %s, want %v", parallelism, s.Reference, s.Name, want[i])
+			}
+		}
+	}
+	res, err := ProcessReader(strings.NewReader(log), Options{})
+	assertError(t, err, nil)
+	if res.Sensors[0].Reference != nil {
+		t.Errorf("got reference %v without IncludeReference, want nil", res.Sensors[0].Reference)
+	}
+}
@@ -0,0 +1,49 @@
+package sensors
+
+import (
+	"sort"
+)
+
+// brandings of the checks that may replace the branding of any sensor type, when they are enabled
+var checkBrandings = []string{
+	SensorGappy,
+	SensorDriftingUp,
+	SensorDriftingDown,
+	SensorFlatline,
+	SensorInsufficientData,
+}
+
+// SensorTypeInfo describes a sensor type supported in the log files, for the tooling
+type SensorTypeInfo struct {
+	// Label is the sensor type on the sensor headers, e.g. "thermometer"
+	Label       string `json:"label"`
+	DisplayName string `json:"displayName"`
+	// ReferenceKeys are the reference quantities the sensor needs, OptionalReferenceKeys those it uses
+	// when the reference has them (see RoomTemperatureKey)
+	ReferenceKeys         []string `json:"referenceKeys"`
+	OptionalReferenceKeys []string `json:"optionalReferenceKeys"`
+	// Brandings the sensor can get, its own ones first and then those of the checks like SensorGappy
+	Brandings []string `json:"brandings"`
+}
+
+// SensorTypes returns the descriptions of all supported sensor types, sorted by their label
+func SensorTypes() []SensorTypeInfo {
+	ret := make([]SensorTypeInfo, 0, len(sensorTypes))
+	for label, t := range sensorTypes {
+		info := SensorTypeInfo{
+			Label:                 label,
+			DisplayName:           t.displayName,
+			ReferenceKeys:         make([]string, 0),
+			OptionalReferenceKeys: append([]string{}, t.optionalKeys...),
+			Brandings:             append(append([]string{}, t.brandings...), checkBrandings...),
+		}
+		if t.optionalReference {
+			info.OptionalReferenceKeys = append([]string{t.referenceKey}, info.OptionalReferenceKeys...)
+		} else {
+			info.ReferenceKeys = append(info.ReferenceKeys, t.referenceKey)
+		}
+		ret = append(ret, info)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Label < ret[j].Label })
+	return ret
+}
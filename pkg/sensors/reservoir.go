@@ -0,0 +1,159 @@
+package sensors
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// fixed seed, so that processing the same file twice gives the same branding
+const reservoirSeed = 1
+
+// reservoir collects the readings of one sensor. When the number of readings exceeds max,
+// it keeps a uniformly distributed sample of max readings (reservoir sampling, "algorithm R"),
+// so the memory stays bounded while the statistics remain representative.
+type reservoir struct {
+	max      int
+	seen     int
+	readings []reading
+	rnd      *rand.Rand
+	// extremes of the humidity readings, kept besides the sample; nil for other sensors
+	extremes *humidityExtremes
+}
+
+// create new reservoir; max 0 means all readings are kept
+func newReservoir(max int) *reservoir {
+	return &reservoir{
+		max:      max,
+		readings: make([]reading, 0),
+	}
+}
+
+func (r *reservoir) add(reading reading) {
+	reading.index = r.seen
+	r.seen++
+	if r.extremes != nil {
+		r.extremes.add(reading)
+	}
+	if r.max == 0 || len(r.readings) < r.max {
+		r.readings = append(r.readings, reading)
+		return
+	}
+	// seeding the generator is expensive, so it's created only when the sampling starts
+	if r.rnd == nil {
+		r.rnd = rand.New(rand.NewSource(reservoirSeed))
+	}
+	if i := r.rnd.Intn(r.seen); i < r.max {
+		r.readings[i] = reading
+	}
+}
+
+// Return the values of kept readings
+func (r *reservoir) values() []float64 {
+	return readingValues(r.readings)
+}
+
+// Return the expected values of kept readings, NaN for the readings without one; nil when
+// none of them has it
+func (r *reservoir) expectedValues() []float64 {
+	return expectedValues(r.readings)
+}
+
+// Return the values and the expected values the sensor is branded by: those of the kept readings,
+// with the extremes when the sampling dropped some readings
+func (r *reservoir) branded() (values, expected []float64) {
+	readings := r.readings
+	if r.extremes != nil && r.seen > len(r.readings) {
+		readings = append(append(make([]reading, 0, len(r.readings)+4), r.readings...), r.extremes.readings()...)
+	}
+	return readingValues(readings), expectedValues(readings)
+}
+
+func readingValues(readings []reading) []float64 {
+	ret := make([]float64, len(readings))
+	for i, reading := range readings {
+		ret[i] = reading.value
+	}
+	return ret
+}
+
+func expectedValues(readings []reading) []float64 {
+	var ret []float64
+	for i, reading := range readings {
+		if !reading.hasExpected {
+			continue
+		}
+		if ret == nil {
+			ret = make([]float64, len(readings))
+			for j := range ret {
+				ret[j] = math.NaN()
+			}
+		}
+		ret[i] = reading.expected
+	}
+	return ret
+}
+
+// Return the kept readings, in their order
+func (r *reservoir) exported() []Reading {
+	readings := r.readings
+	if r.seen > len(readings) {
+		// the sample is in the order of the replacements
+		readings = append(make([]reading, 0, len(readings)), readings...)
+		sort.Slice(readings, func(i, j int) bool { return readings[i].index < readings[j].index })
+	}
+	ret := make([]Reading, len(readings))
+	for i, reading := range readings {
+		ret[i] = Reading{Time: reading.time, Value: reading.value}
+		if reading.hasExpected {
+			expected := reading.expected
+			ret[i].Expected = &expected
+		}
+	}
+	return ret
+}
+
+// humidityExtremes are the readings that decide the branding of a humidity sensor, which is discarded by any single
+// reading out of its band; the sample of the reservoir could miss that one. Whatever the reference, the lowest and
+// the highest of the readings without the expected value are the farthest from it; of the readings with the expected
+// value it's the one farthest from it relative to its band, and any negative reading is discarded. So the sample
+// with the extremes is branded the same as all the readings, with the same confidence.
+type humidityExtremes struct {
+	thresholds                          Thresholds
+	lowest, highest, farthest, negative *reading
+	// distance of the farthest reading from its expected value, in the widths of its band
+	distance float64
+}
+
+func newHumidityExtremes(thresholds Thresholds) *humidityExtremes {
+	return &humidityExtremes{thresholds: thresholds.withDefaults()}
+}
+
+func (e *humidityExtremes) add(r reading) {
+	if r.value < 0 && e.negative == nil {
+		e.negative = &r
+	}
+	if r.hasExpected {
+		if d := math.Abs(r.value-r.expected) / e.thresholds.humidityBand(r.expected); e.farthest == nil || d > e.distance {
+			e.farthest, e.distance = &r, d
+		}
+		return
+	}
+	if e.lowest == nil || r.value < e.lowest.value {
+		e.lowest = &r
+	}
+	if e.highest == nil || r.value > e.highest.value {
+		e.highest = &r
+	}
+}
+
+// Return the extreme readings
+func (e *humidityExtremes) readings() []reading {
+	ret := make([]reading, 0, 4)
+	for _, r := range []*reading{e.lowest, e.highest, e.farthest, e.negative} {
+		if r != nil {
+			ret = append(ret, *r)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,134 @@
+package sensors
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+
+	"gonum.org/v1/gonum/stat"
+)
+
+func TestReservoir(t *testing.T) {
+
+	t.Run("unlimited", func(t *testing.T) {
+		r := newReservoir(0)
+		for i := 0; i < 1000; i++ {
+			r.add(reading{value: float64(i)})
+		}
+		if len(r.readings) != 1000 {
+			t.Errorf("got %d readings, want %d", len(r.readings), 1000)
+		}
+	})
+
+	t.Run("bounded with representative statistics", func(t *testing.T) {
+		max := 500
+		r := newReservoir(max)
+		all := make([]float64, 0)
+		rnd := rand.New(rand.NewSource(42))
+		for i := 0; i < 100000; i++ {
+			value := 100 + rnd.NormFloat64()*2
+			all = append(all, value)
+			r.add(reading{value: value})
+		}
+		if len(r.readings) != max {
+			t.Errorf("got %d readings, want %d", len(r.readings), max)
+		}
+		if cap(r.readings) > 2*max {
+			t.Errorf("reservoir grew to capacity %d, want at most %d", cap(r.readings), 2*max)
+		}
+		mean, std := stat.MeanStdDev(all, nil)
+		sampleMean, sampleStd := stat.MeanStdDev(r.values(), nil)
+		if math.Abs(mean-sampleMean) > 0.5 {
+			t.Errorf("got sample mean %.2f, want close to %.2f", sampleMean, mean)
+		}
+		if math.Abs(std-sampleStd) > 0.5 {
+			t.Errorf("got sample std deviation %.2f, want close to %.2f", sampleStd, std)
+		}
+	})
+
+	t.Run("sample exported in the original order", func(t *testing.T) {
+		r := newReservoir(100)
+		for i := 0; i < 10000; i++ {
+			r.add(reading{value: float64(i)})
+		}
+		exported := r.exported()
+		assertInt(t, len(exported), 100)
+		for i := 1; i < len(exported); i++ {
+			if exported[i].Value <= exported[i-1].Value {
+				t.Fatalf("reading %v exported after %v", exported[i].Value, exported[i-1].Value)
+			}
+		}
+	})
+}
+
+func TestMaxReadings(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "sensors")
+	if err != nil {
+		t.Error("Error creating test log file")
+		return
+	}
+	defer os.Remove(tmpFile.Name())
+
+	var content strings.Builder
+	content.WriteString("reference 100 0\nthermometer temp-1\n")
+	for i := 0; i < 10000; i++ {
+		content.WriteString(fmt.Sprintf("2007-04-05T22:00 %.1f\n", 99.9+float64(i%3)/10))
+	}
+	if err := writeTestLogFile(tmpFile, content.String()); err != nil {
+		t.Error("Error writing test log file")
+		return
+	}
+
+	val, err := processTestLogFile(tmpFile.Name(), Options{MaxReadings: 100})
+	assertError(t, err, nil)
+	assertString(t, val, `{
+  "temp-1": "ultra precise"
+}`)
+}
+
+func TestMaxReadingsHumidity(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "sensors")
+	if err != nil {
+		t.Error("Error creating test log file")
+		return
+	}
+	defer os.Remove(tmpFile.Name())
+
+	// a single reading out of the band discards the sensor, wherever it's in the file
+	var content strings.Builder
+	content.WriteString("reference 100 45\nhumidity hum-1\n")
+	for i := 0; i < 10000; i++ {
+		value := 45 + float64(i%3-1)/10
+		if i == 5000 {
+			value = 47
+		}
+		content.WriteString(fmt.Sprintf("2007-04-05T22:00 %.1f\n", value))
+	}
+	content.WriteString("humidity hum-2\n")
+	for i := 0; i < 10000; i++ {
+		value, expected := 50+float64(i%3-1)/10, 50.0
+		if i == 7000 {
+			value = 48
+		}
+		content.WriteString(fmt.Sprintf("2007-04-05T22:00 %.1f %.1f\n", value, expected))
+	}
+	if err := writeTestLogFile(tmpFile, content.String()); err != nil {
+		t.Error("Error writing test log file")
+		return
+	}
+
+	all, err := ProcessLogFile(tmpFile.Name(), Options{})
+	assertError(t, err, nil)
+	sampled, err := ProcessLogFile(tmpFile.Name(), Options{MaxReadings: 100})
+	assertError(t, err, nil)
+	for i, s := range sampled.Sensors {
+		assertString(t, s.Branding, HumiditySensorDiscard)
+		if s.Confidence != all.Sensors[i].Confidence {
+			t.Errorf("%s: got confidence %f, want %f of all the readings", s.Name, s.Confidence, all.Sensors[i].Confidence)
+		}
+	}
+}
@@ -0,0 +1,339 @@
+// Package sensors implements the quality control of sensors: it parses the log files with sensor
+// readings and brands every sensor according to how its readings match the reference values.
+package sensors
+
+import (
+	"math"
+
+	"gonum.org/v1/gonum/stat"
+)
+
+const (
+	ErrOpenFile                = "error opening file"
+	ErrWrongNumberRefFields    = "reference line has incorrect number of fields"
+	ErrWrongNumberRedingFields = "line with readings has incorrect number of fields"
+	ErrTempNotFloat            = "failed converting reference temperature to float"
+	ErrHumidityNotFloat        = "failed converting reference humidity to float"
+	ErrFlowNotFloat            = "failed converting reference flow to float"
+	ErrRoomTempNotFloat        = "failed converting reference room temperature to float"
+	ErrSoundNotFloat           = "failed converting reference sound level to float"
+	ErrInvalidReferenceLabel   = "unknown quantity on labeled reference line"
+	ErrDuplicateReferenceLabel = "duplicate quantity on labeled reference line"
+	ErrMissingReference        = "reference line lacks the quantity of the sensor"
+	ErrReadingNotFloat         = "failed converting current reading to float"
+	ErrExpectedNotFloat        = "failed converting expected value of the reading to float"
+	ErrHeaderRefNotFloat       = "failed converting sensor header reference to float"
+	ErrReadingNotFinite        = "reading is not a finite number"
+	ErrProcessingAborted       = "processing of the log file aborted"
+	ErrIncompleteFile          = "log file is incomplete"
+	ErrLineTooLong             = "line is too long"
+	ErrInvalidJSONLog          = "invalid JSON log file"
+	ErrConflictingReference    = "reference line conflicts with the reference"
+	ErrMissingSensorName       = "sensor header must contain the sensor name"
+	ErrUnterminatedName        = "quoted sensor name must end with the quote"
+	ErrUnknownSensorType       = "unknown sensor type"
+	ErrInvalidSensorName       = "invalid sensor name"
+	ErrCompoundNoChannels      = "compound header must declare the device name and at least one channel"
+	ErrUnknownChannel          = "unknown channel type in compound header"
+	ErrDuplicateChannel        = "duplicate channel type in compound header"
+
+	ThermometerLabel    = "thermometer"
+	HumiditySensorLabel = "humidity"
+	FlowSensorLabel     = "flow"
+	SoundSensorLabel    = "sound"
+	ReferenceLabel      = "reference"
+	CompoundLabel       = "compound"
+
+	ThermometerUltraPrecise = "ultra precise"
+	ThermometerVeryPrecise  = "very precise"
+	ThermometerPrecise      = "precise"
+
+	HumiditySensorKeep    = "keep"
+	HumiditySensorDiscard = "discard"
+
+	FlowSensorNormal = "normal"
+	FlowSensorLow    = "low"
+	FlowSensorHigh   = "high"
+
+	SoundSensorQuiet     = "quiet"
+	SoundSensorLoud      = "loud"
+	SoundSensorExcessive = "excessive"
+
+	// any sensor with gaps in the readings, when the gap detection is enabled
+	SensorGappy = "gappy"
+	// any sensor with the readings trending up or down, when the drift detection is enabled
+	SensorDriftingUp   = "drifting up"
+	SensorDriftingDown = "drifting down"
+	// any sensor reporting the same value all the time, when the flatline detection is enabled
+	SensorFlatline = "flatline"
+	// any sensor with less readings than required, when the minimum is set
+	SensorInsufficientData = "insufficient data"
+
+	readingLineValues       = 2
+	requiredReferenceValues = 2
+
+	// minimal half-width of the humidity band, in humidity percents
+	minHumidityBand = 0.1
+)
+
+// brandings meaning the sensor failed the quality control
+var problemBrandings map[string]bool = map[string]bool{
+	HumiditySensorDiscard:  true,
+	FlowSensorLow:          true,
+	FlowSensorHigh:         true,
+	SoundSensorExcessive:   true,
+	SensorGappy:            true,
+	SensorDriftingUp:       true,
+	SensorDriftingDown:     true,
+	SensorFlatline:         true,
+	SensorInsufficientData: true,
+}
+
+// IsProblem reports whether the branding means the sensor failed the quality control
+func IsProblem(branding string) bool {
+	return problemBrandings[branding]
+}
+
+// sensorType describes a kind of sensor that can appear in the log files
+type sensorType struct {
+	// displayName is the human readable name of the type, for the tooling
+	displayName string
+	// referenceKey is the reference quantity the sensor is compared against
+	referenceKey    string
+	defaultBranding string
+	// brandings the statistics of the sensor can give, see also checkBrandings
+	brandings []string
+	// optionalReference means the sensor can do without its reference quantity
+	optionalReference bool
+	// other reference quantities the sensor uses when the reference has them
+	optionalKeys []string
+	// mean of the readings, when it's not the arithmetic one
+	mean func([]float64) float64
+	// create the sensor of this type from its common part
+	create func(sensor) Sensor
+}
+
+// registry of known sensor types, by the label used in the log files
+var sensorTypes map[string]sensorType = map[string]sensorType{
+	ThermometerLabel: {
+		displayName:     "Thermometer",
+		referenceKey:    "Temperature",
+		defaultBranding: ThermometerPrecise,
+		brandings:       []string{ThermometerUltraPrecise, ThermometerVeryPrecise, ThermometerPrecise},
+		optionalKeys:    []string{RoomTemperatureKey},
+		create:          func(s sensor) Sensor { return &thermometer{sensor: s} },
+	},
+	HumiditySensorLabel: {
+		displayName:     "Humidity sensor",
+		referenceKey:    "Humidity",
+		defaultBranding: HumiditySensorKeep,
+		brandings:       []string{HumiditySensorKeep, HumiditySensorDiscard},
+		create:          func(s sensor) Sensor { return &humiditySensor{sensor: s} },
+	},
+	FlowSensorLabel: {
+		displayName:     "Flow sensor",
+		referenceKey:    "Flow",
+		defaultBranding: FlowSensorNormal,
+		brandings:       []string{FlowSensorNormal, FlowSensorLow, FlowSensorHigh},
+		create:          func(s sensor) Sensor { return &flowSensor{sensor: s} },
+	},
+	SoundSensorLabel: {
+		displayName: "Sound level sensor",
+		// only on the labeled reference line or the ref= option of the header; SoundLimit otherwise
+		referenceKey:      "Sound",
+		defaultBranding:   SoundSensorQuiet,
+		brandings:         []string{SoundSensorQuiet, SoundSensorLoud, SoundSensorExcessive},
+		optionalReference: true,
+		mean:              soundLevelMean,
+		create:            func(s sensor) Sensor { return &soundSensor{sensor: s} },
+	},
+}
+
+// RoomTemperatureKey is the reference quantity of the room temperature, the optional last value
+// of the reference line; without it, the thermometers are compared with the reference temperature only
+const RoomTemperatureKey = "RoomTemperature"
+
+// the values of reference line, in their order; only the first requiredReferenceValues must be present
+var referenceQuantities = []string{"Temperature", "Humidity", "Flow", RoomTemperatureKey}
+
+// reference quantities by their labels on the labeled reference line, which can have the sound level too
+var referenceLabels map[string]string = map[string]string{
+	"temperature": "Temperature",
+	"humidity":    "Humidity",
+	"flow":        "Flow",
+	"room":        RoomTemperatureKey,
+	"sound":       "Sound",
+}
+
+// error messages for the reference values that are not numbers
+var referenceErrors map[string]string = map[string]string{
+	"Temperature":      ErrTempNotFloat,
+	"Humidity":         ErrHumidityNotFloat,
+	"Flow":             ErrFlowNotFloat,
+	RoomTemperatureKey: ErrRoomTempNotFloat,
+	"Sound":            ErrSoundNotFloat,
+}
+
+type sensor struct {
+	branding   string
+	name       string
+	thresholds Thresholds
+	// confidence of the branding, from 0 to 1, set by Process
+	confidence float64
+}
+
+type thermometer struct {
+	sensor
+}
+
+type humiditySensor struct {
+	sensor
+}
+
+type Sensor interface {
+	Process(map[string]float64, []float64)
+	Name() string
+	Branding() string
+}
+
+// expectedSensor is the sensor that can compare each reading with the expected value logged with it
+// instead of the reference one (NaN expected value means the reference one)
+type expectedSensor interface {
+	ProcessExpected(referenceValues map[string]float64, readings, expected []float64)
+}
+
+func (s *humiditySensor) Name() string {
+	return s.name
+}
+
+func (s *humiditySensor) Branding() string {
+	return s.branding
+}
+
+// Process humidity sensor
+// For a humidity sensor, it must be discarded unless it is within 1 humidity percent of the reference value for all readings. (All humidity sensor
+// readings are a decimal value representing percent moisture saturation.)
+// The percent is relative to the reference, or a percentage point with Thresholds.HumidityAbsolute;
+// Thresholds.HumidityBand changes the width of the band.
+//
+// Return value is string of name and branding, already formatted according to the required output format
+func (s *humiditySensor) Process(referenceValues map[string]float64, readings []float64) {
+	s.ProcessExpected(referenceValues, readings, nil)
+}
+
+// ProcessExpected is Process which compares the readings with their expected values, where
+// the protocol logs them; nil expected values or NaN for a reading mean the reference humidity.
+// With Thresholds.InvertHumidity, the sensors within the band are discarded and the others kept.
+func (s *humiditySensor) ProcessExpected(referenceValues map[string]float64, readings, expected []float64) {
+	s.compare(referenceValues, readings, expected)
+	if !s.thresholds.InvertHumidity {
+		return
+	}
+	if s.branding == HumiditySensorDiscard {
+		s.branding = HumiditySensorKeep
+	} else {
+		s.branding = HumiditySensorDiscard
+	}
+}
+
+// Brand the sensor by comparing the readings with their expected values, see ProcessExpected
+func (s *humiditySensor) compare(referenceValues map[string]float64, readings, expected []float64) {
+	referenceHumidity := referenceValues["Humidity"]
+
+	// Note: going through all readings again is not super efficient (we've already went through them when parsing the file)
+	// but having Process method makes the code extensible for future new kind of sensors
+	// negative saturation is physically implausible, even when the band around a zero reference reaches below zero
+	// the confidence is given by the reading farthest from its expected value, relative to the band
+	maxDistance := 0.0
+	for i, reading := range readings {
+		want := referenceHumidity
+		if expected != nil && !math.IsNaN(expected[i]) {
+			want = expected[i]
+		}
+		band := s.thresholds.humidityBand(want)
+		if reading < 0 || reading < want-band || reading > want+band {
+			s.branding = HumiditySensorDiscard
+			if reading < 0 {
+				// no doubt about that one
+				s.confidence = 1
+				return
+			}
+		}
+		maxDistance = math.Max(maxDistance, math.Abs(reading-want)/band)
+	}
+	if len(readings) == 0 {
+		return
+	}
+	if s.branding == HumiditySensorDiscard {
+		s.confidence = confidenceOutside(maxDistance, 1)
+	} else {
+		s.confidence = confidenceInside(maxDistance, 1)
+	}
+}
+
+func (s *thermometer) Name() string {
+	return s.name
+}
+
+func (s *thermometer) Branding() string {
+	return s.branding
+}
+
+// Process thermometer:
+// For a thermometer, it is branded “ultra precise” if the mean of the readings is within 0.5 degrees of the known temperature,
+// and the standard deviation is less than 3.
+// It is branded “very precise” if the mean is within 0.5 degrees of the room, and the standard deviation is under 5.
+// Otherwise, it’s sold as “precise”.
+// (the limits and whether they are inclusive can be changed by Thresholds)
+// The known temperature is the reference temperature, the room one is RoomTemperatureKey of the reference,
+// or the reference temperature as well when the reference has no room temperature.
+//
+// Return value is string of name and branding, already formatted according to the required output format
+func (s *thermometer) Process(referenceValues map[string]float64, readings []float64) {
+	referenceTemperature := referenceValues["Temperature"]
+
+	// we could write the methods for counting mean (trivial) and std deviation (bit more complicated) here,
+	// but who could resist the usage of a library...
+	mean, std := stat.MeanStdDev(readings, nil)
+
+	// with a single reading the (unbiased) std deviation is NaN, which would fail every
+	// comparison below; there's simply no spread in one reading, so treat it as 0
+	if len(readings) == 1 {
+		std = 0
+	}
+
+	roomTemperature, ok := referenceValues[RoomTemperatureKey]
+	if !ok {
+		roomTemperature = referenceTemperature
+	}
+
+	t := s.thresholds
+	if within(math.Abs(mean-referenceTemperature), t.MeanTolerance, t.MeanInclusive) &&
+		within(std, t.UltraPreciseStdDev, t.UltraPreciseInclusive) {
+		s.branding = ThermometerUltraPrecise
+		s.confidence = math.Min(confidenceInside(math.Abs(mean-referenceTemperature), t.MeanTolerance),
+			confidenceInside(std, t.UltraPreciseStdDev))
+	} else if within(math.Abs(mean-roomTemperature), t.MeanTolerance, t.MeanInclusive) &&
+		within(std, t.VeryPreciseStdDev, t.VeryPreciseInclusive) {
+		s.branding = ThermometerVeryPrecise
+		s.confidence = math.Min(confidenceInside(math.Abs(mean-roomTemperature), t.MeanTolerance),
+			confidenceInside(std, t.VeryPreciseStdDev))
+	} else if len(readings) > 0 {
+		// "precise" is certain when either limit of "very precise" is missed by far
+		s.confidence = math.Max(confidenceOutside(math.Abs(mean-roomTemperature), t.MeanTolerance),
+			confidenceOutside(std, t.VeryPreciseStdDev))
+	}
+}
+
+// new sensor factory: return new sensor based on the input type, or nil for unknown type
+func NewSensor(sensorType, name string, thresholds Thresholds) Sensor {
+	t, ok := sensorTypes[sensorType]
+	if !ok {
+		return nil
+	}
+	return t.create(sensor{
+		name:       name,
+		branding:   t.defaultBranding,
+		thresholds: thresholds.withDefaults(),
+	})
+}
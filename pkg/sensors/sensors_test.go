@@ -0,0 +1,624 @@
+package sensors
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func assertError(t testing.TB, got error, want error) {
+	t.Helper()
+
+	if got != want {
+		t.Errorf("got error %q, want %q", got, want)
+	}
+}
+
+func assertErrorMessageSubString(t testing.TB, got error, want string) {
+	t.Helper()
+
+	if !strings.Contains(got.Error(), want) {
+		t.Errorf("got error %q, want to contain %q", got, want)
+	}
+}
+
+func assertSubString(t testing.TB, got string, want string) {
+	t.Helper()
+
+	if !strings.Contains(got, want) {
+		t.Errorf("got %q, want to contain %q", got, want)
+	}
+}
+
+func assertString(t testing.TB, got string, want string) {
+	t.Helper()
+
+	if got != want {
+		t.Errorf("got error %s, want %s", got, want)
+	}
+}
+
+func assertInt(t testing.TB, got int, want int) {
+	t.Helper()
+
+	if got != want {
+		t.Errorf("got %d, want %d", got, want)
+	}
+}
+
+func writeTestLogFile(tmpFile *os.File, content string) error {
+	if err := os.WriteFile(tmpFile.Name(), []byte(content), 0666); err != nil {
+		return err
+	}
+	return nil
+}
+
+// memStore is in-memory Store for the tests
+type memStore map[string]string
+
+func (s memStore) Get(key string) (string, error) {
+	val, ok := s[key]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return val, nil
+}
+
+func (s memStore) Set(key, value string) error {
+	s[key] = value
+	return nil
+}
+
+// Process the log file and format the brandings the way the command line prints them
+func processTestLogFile(filePath string, opts Options) (string, error) {
+	brandings, err := brandTestLogFile(filePath, opts)
+	if err != nil {
+		return "", err
+	}
+	j, _ := json.MarshalIndent(brandings, "", "  ")
+	return string(j), nil
+}
+
+// Process the log file and return the map of sensor names to their branding
+func brandTestLogFile(filePath string, opts Options) (map[string]string, error) {
+	res, err := ProcessLogFile(filePath, opts)
+	if err != nil {
+		return nil, err
+	}
+	return res.Brandings(), nil
+}
+
+const tempUltraPrecise = `reference 100 0
+thermometer temp-1
+2007-04-05T22:00 100
+2007-04-05T22:01 100.1
+2007-04-05T22:02 99.9`
+
+func TestParseErrorTypes(t *testing.T) {
+
+	tmpFile, err := ioutil.TempFile("", "sensors")
+	if err != nil {
+		t.Error("Error creating test log file")
+		return
+	}
+	defer os.Remove(tmpFile.Name())
+
+	t.Run("wrong reference fields", func(t *testing.T) {
+		if err := writeTestLogFile(tmpFile, "thermometer temp-1\nreference 1"); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		_, err := processTestLogFile(tmpFile.Name(), Options{})
+		var refErr *WrongRefFieldsError
+		if !errors.As(err, &refErr) {
+			t.Fatalf("got error %q, want WrongRefFieldsError", err)
+		}
+		assertInt(t, refErr.Line, 2)
+		assertString(t, err.Error(), ErrWrongNumberRefFields+` (line 2: "reference 1")`)
+	})
+
+	t.Run("wrong reading fields", func(t *testing.T) {
+		if err := writeTestLogFile(tmpFile, "reference 100 0\nthermometer temp-1\n2007-04-05T22:00 100 1"); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		_, err := processTestLogFile(tmpFile.Name(), Options{})
+		var readingErr *WrongReadingFieldsError
+		if !errors.As(err, &readingErr) {
+			t.Fatalf("got error %q, want WrongReadingFieldsError", err)
+		}
+		assertInt(t, readingErr.Line, 3)
+	})
+
+	t.Run("invalid reading value", func(t *testing.T) {
+		if err := writeTestLogFile(tmpFile, "reference 100 0\nthermometer temp-1\n2007-04-05T22:00 100\n2007-04-05T22:01 a"); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		_, err := processTestLogFile(tmpFile.Name(), Options{})
+		var valueErr *InvalidValueError
+		if !errors.As(err, &valueErr) {
+			t.Fatalf("got error %q, want InvalidValueError", err)
+		}
+		assertInt(t, valueErr.Line, 4)
+		assertString(t, valueErr.Msg, ErrReadingNotFloat)
+		var numErr *strconv.NumError
+		if !errors.As(err, &numErr) {
+			t.Errorf("got error %q, want it to wrap strconv.NumError", err)
+		}
+	})
+}
+
+func TestParseErrorLines(t *testing.T) {
+	cases := []struct {
+		name string
+		log  string
+		opts Options
+		want string
+	}{
+		{"wrong reference fields", "reference 1", Options{}, `(line 1: "reference 1")`},
+		{"invalid reference label", "reference temperature=100 pressure=1013", Options{},
+			`(line 1: "reference temperature=100 pressure=1013")`},
+		{"invalid reference value", "reference 100 x", Options{}, `(line 1: "reference 100 x")`},
+		{"missing reference", "reference humidity=45\nthermometer temp-1\n2007-04-05T22:00 100", Options{},
+			`(line 2: "thermometer temp-1")`},
+		{"conflicting reference", "reference 100 45\nreference 101 45\nthermometer temp-1", Options{StrictReference: true},
+			`(line 2: "reference 101 45")`},
+		{"invalid header", "reference 100 45\nthermometer", Options{}, `(line 2: "thermometer")`},
+		{"invalid header reference", "reference 100 45\nthermometer temp-1 ref=x", Options{}, `(line 2: "thermometer temp-1 ref=x")`},
+		{"wrong reading fields", "reference 100 45\nthermometer temp-1\n2007-04-05T22:00 100 1", Options{},
+			`(line 3: "2007-04-05T22:00 100 1")`},
+		{"invalid reading", "reference 100 45\nthermometer temp-1\n\n2007-04-05T22:00 x", Options{},
+			`(line 4: "2007-04-05T22:00 x")`},
+		{"non-finite reading", "reference 100 45\nthermometer temp-1\n2007-04-05T22:00 NaN", Options{},
+			`(line 3: "2007-04-05T22:00 NaN")`},
+		{"line too long", "reference 100 45\nthermometer temp-1 " + strings.Repeat("x", 100), Options{MaxLineLength: 50},
+			"line 2 is longer"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := ProcessReader(strings.NewReader(c.log), c.opts)
+			assertErrorMessageSubString(t, err, c.want)
+		})
+	}
+
+	// very long lines are cut short in the message
+	_, err := ProcessReader(strings.NewReader("reference 100 "+strings.Repeat("1", 1000)+" 1 1 1"), Options{})
+	assertErrorMessageSubString(t, err, `(line 1: "reference 100 111`)
+	if len(err.Error()) > maxErrorLineText+100 {
+		t.Errorf("got error message of %d bytes, want it shortened", len(err.Error()))
+	}
+	var refErr *WrongRefFieldsError
+	if !errors.As(err, &refErr) || len(refErr.Text) < 1000 {
+		t.Errorf("got error %v, want WrongRefFieldsError with the whole line", err)
+	}
+}
+
+func TestReadingValueFormats(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "sensors")
+	if err != nil {
+		t.Error("Error creating test log file")
+		return
+	}
+	defer os.Remove(tmpFile.Name())
+
+	cases := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"negative temperatures", "reference -20 0\nthermometer temp-1\n2007-04-05T22:00 -20.1\n2007-04-05T22:01 -19.9\n2007-04-05T22:02 -20", ThermometerUltraPrecise},
+		{"scientific notation", "reference 1.5e2 0\nthermometer temp-1\n2007-04-05T22:00 1.5e2\n2007-04-05T22:01 150.1\n2007-04-05T22:02 1499e-1", ThermometerUltraPrecise},
+		{"negative exponent", "reference 0 4.5E1\nhumidity hum-1\n2007-04-05T22:00 450e-1\n2007-04-05T22:01 45.2", HumiditySensorKeep},
+		{"negative humidity", "reference 0 0\nhumidity hum-1\n2007-04-05T22:00 0\n2007-04-05T22:01 -0.05", HumiditySensorDiscard},
+		{"zero humidity", "reference 0 0\nhumidity hum-1\n2007-04-05T22:00 0\n2007-04-05T22:01 0.05", HumiditySensorKeep},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if err := writeTestLogFile(tmpFile, c.content); err != nil {
+				t.Error("Error writing test log file")
+				return
+			}
+			val, err := brandTestLogFile(tmpFile.Name(), Options{})
+			assertError(t, err, nil)
+			for _, branding := range val {
+				assertString(t, branding, c.want)
+			}
+			assertInt(t, len(val), 1)
+		})
+	}
+}
+
+func TestNonFiniteReadings(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "sensors")
+	if err != nil {
+		t.Error("Error creating test log file")
+		return
+	}
+	defer os.Remove(tmpFile.Name())
+
+	for _, value := range []string{"NaN", "+Inf", "-inf"} {
+		content := "reference 100 0\nthermometer temp-1\n2007-04-05T22:00 100\n2007-04-05T22:01 " + value +
+			"\n2007-04-05T22:02 100.1\n2007-04-05T22:03 99.9"
+		if err := writeTestLogFile(tmpFile, content); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+
+		t.Run(value+" rejected by default", func(t *testing.T) {
+			for _, policy := range []string{"", NonFiniteReject} {
+				_, err := processTestLogFile(tmpFile.Name(), Options{NonFinitePolicy: policy})
+				var valueErr *NonFiniteValueError
+				if !errors.As(err, &valueErr) {
+					t.Fatalf("got error %v, want NonFiniteValueError", err)
+				}
+				assertInt(t, valueErr.Line, 4)
+				assertString(t, valueErr.Value, value)
+				assertString(t, err.Error(), fmt.Sprintf("%s: %q (line 4: \"2007-04-05T22:01 %s\")", ErrReadingNotFinite, value, value))
+			}
+		})
+
+		t.Run(value+" dropped", func(t *testing.T) {
+			res, err := ProcessLogFile(tmpFile.Name(), Options{NonFinitePolicy: NonFiniteDrop, IncludeReadings: true})
+			assertError(t, err, nil)
+			assertInt(t, len(res.Sensors), 1)
+			assertString(t, res.Sensors[0].Branding, ThermometerUltraPrecise)
+			assertInt(t, len(res.Sensors[0].Readings), 3)
+		})
+
+		t.Run(value+" kept", func(t *testing.T) {
+			res, err := ProcessLogFile(tmpFile.Name(), Options{NonFinitePolicy: NonFiniteKeep, IncludeReadings: true})
+			assertError(t, err, nil)
+			assertInt(t, len(res.Sensors), 1)
+			assertInt(t, len(res.Sensors[0].Readings), 4)
+		})
+	}
+}
+
+func TestMinReadings(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "sensors")
+	if err != nil {
+		t.Error("Error creating test log file")
+		return
+	}
+	defer os.Remove(tmpFile.Name())
+
+	content := `reference 100 45
+thermometer temp-none
+thermometer temp-one
+2007-04-05T22:00 100
+humidity hum-two
+2007-04-05T22:00 45
+2007-04-05T22:01 45.1
+thermometer temp-three
+2007-04-05T22:00 100
+2007-04-05T22:01 100.1
+2007-04-05T22:02 99.9`
+	if err := writeTestLogFile(tmpFile, content); err != nil {
+		t.Error("Error writing test log file")
+		return
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		val, err := brandTestLogFile(tmpFile.Name(), Options{})
+		assertError(t, err, nil)
+		assertString(t, val["temp-none"], ThermometerPrecise)
+		assertString(t, val["temp-one"], ThermometerUltraPrecise)
+	})
+
+	t.Run("too few readings", func(t *testing.T) {
+		val, err := processTestLogFile(tmpFile.Name(), Options{MinReadings: 3})
+		assertError(t, err, nil)
+		assertString(t, val, `{
+  "hum-two": "insufficient data",
+  "temp-none": "insufficient data",
+  "temp-one": "insufficient data",
+  "temp-three": "ultra precise"
+}`)
+	})
+
+	t.Run("sampled readings count in full", func(t *testing.T) {
+		val, err := brandTestLogFile(tmpFile.Name(), Options{MinReadings: 3, MaxReadings: 1})
+		assertError(t, err, nil)
+		assertString(t, val["temp-three"], ThermometerUltraPrecise)
+	})
+}
+
+func TestDefaultSensor(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "sensors")
+	if err != nil {
+		t.Error("Error creating test log file")
+		return
+	}
+	defer os.Remove(tmpFile.Name())
+
+	headerless := `reference 100 45
+2007-04-05T22:00 100
+2007-04-05T22:01 100.1
+2007-04-05T22:02 99.9`
+	if err := writeTestLogFile(tmpFile, headerless); err != nil {
+		t.Error("Error writing test log file")
+		return
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		val, err := processTestLogFile(tmpFile.Name(), Options{})
+		assertError(t, err, nil)
+		assertString(t, val, "{}")
+	})
+
+	t.Run("default sensor", func(t *testing.T) {
+		val, err := processTestLogFile(tmpFile.Name(), Options{DefaultSensorType: ThermometerLabel})
+		assertError(t, err, nil)
+		assertString(t, val, `{
+  "default": "ultra precise"
+}`)
+	})
+
+	t.Run("named default sensor followed by header", func(t *testing.T) {
+		if err := writeTestLogFile(tmpFile, headerless+"\nhumidity hum-1\n2007-04-05T22:00 47"); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		val, err := processTestLogFile(tmpFile.Name(), Options{DefaultSensorType: HumiditySensorLabel, DefaultSensorName: "room"})
+		assertError(t, err, nil)
+		assertString(t, val, `{
+  "hum-1": "discard",
+  "room": "discard"
+}`)
+	})
+}
+
+func TestLineEndings(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "sensors")
+	if err != nil {
+		t.Error("Error creating test log file")
+		return
+	}
+	defer os.Remove(tmpFile.Name())
+
+	// the log files by their last line; temp-1 is "ultra precise" only when all its readings are used
+	// against the first reference
+	lastLines := []struct {
+		name  string
+		lines []string
+		want  string
+	}{
+		{"reading", []string{"reference 100 0", "thermometer temp-1", "2007-04-05T22:00 100", "2007-04-05T22:01 101", "2007-04-05T22:02 99.5"},
+			`{
+  "temp-1": "ultra precise"
+}`},
+		{"header", []string{"reference 100 0", "thermometer temp-1", "2007-04-05T22:00 100", "2007-04-05T22:01 99.7", "thermometer temp-2"},
+			`{
+  "temp-1": "ultra precise",
+  "temp-2": "precise"
+}`},
+		{"reference", []string{"reference 100 0", "thermometer temp-1", "2007-04-05T22:00 100", "2007-04-05T22:01 99.7", "reference 50 0"},
+			`{
+  "temp-1": "ultra precise"
+}`},
+	}
+	endings := []struct {
+		name    string
+		newline string
+		last    string
+	}{
+		{"LF", "\n", "\n"},
+		{"no trailing newline", "\n", ""},
+		{"CRLF", "\r\n", "\r\n"},
+		{"CRLF without trailing newline", "\r\n", ""},
+		{"trailing empty lines", "\n", "\n\n\r\n"},
+	}
+	for _, l := range lastLines {
+		for _, e := range endings {
+			t.Run(l.name+" "+e.name, func(t *testing.T) {
+				if err := writeTestLogFile(tmpFile, strings.Join(l.lines, e.newline)+e.last); err != nil {
+					t.Error("Error writing test log file")
+					return
+				}
+				val, err := processTestLogFile(tmpFile.Name(), Options{})
+				assertError(t, err, nil)
+				assertString(t, val, l.want)
+			})
+		}
+	}
+}
+
+func TestSplitFields(t *testing.T) {
+	fields := make([]string, 0)
+	for _, line := range []string{"2007-04-05T22:00 100", "thermometer", "a  b ", " ", "compound dev-1 thermometer humidity -"} {
+		fields = splitFields(fields[:0], line)
+		if want := strings.Split(line, " "); strings.Join(fields, "|") != strings.Join(want, "|") || len(fields) != len(want) {
+			t.Errorf("got fields %q of %q, want %q", fields, line, want)
+		}
+	}
+}
+
+func TestEndMarker(t *testing.T) {
+	complete := tempUltraPrecise + "\n# EOF\n"
+	cases := []struct {
+		name, content string
+		wantErr       bool
+	}{
+		{"complete", complete, false},
+		{"marker with CRLF", strings.ReplaceAll(complete, "\n", "\r\n"), false},
+		{"missing marker", tempUltraPrecise, true},
+		{"truncated before the marker", tempUltraPrecise[:len(tempUltraPrecise)-3], true},
+		{"lines after the marker", complete + "2007-04-05T22:03 100\n", true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res, err := ProcessReader(strings.NewReader(c.content), Options{EndMarker: "# EOF"})
+			if !c.wantErr {
+				assertError(t, err, nil)
+				assertString(t, res.Brandings()["temp-1"], ThermometerUltraPrecise)
+				return
+			}
+			var incompleteErr *IncompleteFileError
+			if !errors.As(err, &incompleteErr) {
+				t.Fatalf("got error %v, want IncompleteFileError", err)
+			}
+			assertString(t, err.Error(), ErrIncompleteFile+`: missing end marker "# EOF"`)
+		})
+	}
+
+	t.Run("marker not expected", func(t *testing.T) {
+		_, err := ProcessReader(strings.NewReader(complete), Options{})
+		// the marker is then just a malformed reading line
+		var valueErr *InvalidValueError
+		if !errors.As(err, &valueErr) {
+			t.Fatalf("got error %v, want InvalidValueError", err)
+		}
+	})
+}
+
+func TestLongLines(t *testing.T) {
+	// all the readings on one line, as logged by an exporter with missing newlines
+	oneLine := "reference 100 0\nthermometer temp-1\n" + strings.Repeat("2007-04-05T22:00 100 ", 5000)
+
+	t.Run("over the default scanner limit", func(t *testing.T) {
+		_, err := ProcessReader(strings.NewReader(oneLine), Options{})
+		// the line is read and found malformed, instead of failing the scanner
+		var fieldsErr *WrongReadingFieldsError
+		if !errors.As(err, &fieldsErr) {
+			t.Fatalf("got error %v, want WrongReadingFieldsError", err)
+		}
+		assertInt(t, fieldsErr.Line, 3)
+	})
+
+	for _, anywhere := range []bool{false, true} {
+		t.Run(fmt.Sprintf("over the limit, reference anywhere %v", anywhere), func(t *testing.T) {
+			_, err := ProcessReader(strings.NewReader(oneLine), Options{MaxLineLength: 1000, ReferenceAnywhere: anywhere})
+			var lineErr *LineTooLongError
+			if !errors.As(err, &lineErr) {
+				t.Fatalf("got error %v, want LineTooLongError", err)
+			}
+			assertInt(t, lineErr.Line, 3)
+			assertString(t, err.Error(), ErrLineTooLong+": line 3 is longer than 1000 bytes")
+		})
+	}
+
+	t.Run("within the limit", func(t *testing.T) {
+		res, err := ProcessReader(strings.NewReader(tempUltraPrecise), Options{MaxLineLength: 100})
+		assertError(t, err, nil)
+		assertString(t, res.Brandings()["temp-1"], ThermometerUltraPrecise)
+	})
+}
+
+func TestHumidityExpectedValues(t *testing.T) {
+	content := `reference 100 45
+humidity hum-tracking
+2007-04-05T22:00 50.2 50
+2007-04-05T22:01 60.3 60.1
+2007-04-05T22:02 45.1
+humidity hum-off
+2007-04-05T22:00 45 50
+humidity hum-reference
+2007-04-05T22:00 45.2
+thermometer temp-1
+2007-04-05T22:00 100`
+	res, err := ProcessReader(strings.NewReader(content), Options{IncludeReadings: true})
+	assertError(t, err, nil)
+	brandings := res.Brandings()
+	// each reading is compared with its own expected value, the one without it with the reference
+	assertString(t, brandings["hum-tracking"], HumiditySensorKeep)
+	// within the band of the reference, but not of the expected value
+	assertString(t, brandings["hum-off"], HumiditySensorDiscard)
+	assertString(t, brandings["hum-reference"], HumiditySensorKeep)
+
+	readings := res.Sensors[0].Readings
+	if readings[0].Expected == nil || *readings[0].Expected != 50 || readings[2].Expected != nil {
+		t.Errorf("got readings %+v, want the expected value of the first reading only", readings)
+	}
+
+	cases := []struct {
+		name, content, want string
+	}{
+		{"expected value of thermometer", "reference 100 45\nthermometer temp-1\n2007-04-05T22:00 100 100", ErrWrongNumberRedingFields},
+		{"expected value not a number", "reference 100 45\nhumidity hum-1\n2007-04-05T22:00 45 x", ErrExpectedNotFloat},
+		{"expected value not finite", "reference 100 45\nhumidity hum-1\n2007-04-05T22:00 45 NaN", ErrReadingNotFinite},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := ProcessReader(strings.NewReader(c.content), Options{})
+			assertErrorMessageSubString(t, err, c.want)
+		})
+	}
+}
+
+func TestUnknownSensorType(t *testing.T) {
+	log := `reference 100 45
+thermometer temp-1
+2007-04-05T22:00 100
+thermomter temp-2
+2007-04-05T22:00 80
+2007-04-05T22:01 120
+humidity hum-1
+2007-04-05T22:00 45
+`
+	for _, policy := range []string{"", UnknownTypeReject} {
+		_, err := ProcessReader(strings.NewReader(log), Options{UnknownTypePolicy: policy})
+		var typeErr *UnknownSensorTypeError
+		if !errors.As(err, &typeErr) {
+			t.Fatalf("got error %v, want UnknownSensorTypeError", err)
+		}
+		assertInt(t, typeErr.Line, 4)
+		assertString(t, typeErr.Type, "thermomter")
+		assertString(t, err.Error(), ErrUnknownSensorType+
+			` "thermomter", expected one of compound, flow, humidity, sound, thermometer (line 4: "thermomter temp-2")`)
+	}
+
+	for _, policy := range []string{UnknownTypeSkip, UnknownTypeWarn} {
+		res, err := ProcessReader(strings.NewReader(log), Options{UnknownTypePolicy: policy})
+		assertError(t, err, nil)
+		// the readings of the unknown sensor don't belong to temp-1
+		assertInt(t, len(res.Sensors), 2)
+		assertString(t, res.Brandings()["temp-1"], ThermometerUltraPrecise)
+		assertInt(t, res.Sensors[0].Count, 1)
+		assertString(t, res.Brandings()["hum-1"], HumiditySensorKeep)
+	}
+
+	// readings of raw values may start with letters, but they have the timestamp
+	res, err := ProcessReader(strings.NewReader("reference 100 45\nthermometer temp-1\nbeef 2007-04-05T22:00\n"), Options{
+		ReadingOrder: ReadingOrderValueFirst,
+		Decoders:     map[string]Decoder{ThermometerLabel: {Encoding: EncodingHex, Scale: 100.0 / 0xbeef}},
+	})
+	assertError(t, err, nil)
+	assertString(t, res.Brandings()["temp-1"], ThermometerUltraPrecise)
+}
+
+func TestByteOrderMark(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "sensors")
+	if err != nil {
+		t.Fatal("Error creating test log file")
+	}
+	defer os.Remove(tmpFile.Name())
+	if err := writeTestLogFile(tmpFile, "\xEF\xBB\xBFreference 100 45\r\nthermometer temp-1\r\n2007-04-05T22:00 100\r\n"); err != nil {
+		t.Fatal("Error writing test log file")
+	}
+	val, err := processTestLogFile(tmpFile.Name(), Options{})
+	assertError(t, err, nil)
+	assertString(t, val, `{
+  "temp-1": "ultra precise"
+}`)
+
+	// the JSON log file is still detected
+	res, err := ProcessReader(strings.NewReader("\xEF\xBB\xBF"+jsonLog), Options{InputFormat: InputFormatAuto})
+	assertError(t, err, nil)
+	want, _ := ProcessReader(strings.NewReader(jsonLog), Options{InputFormat: InputFormatJSON})
+	if !reflect.DeepEqual(res.Brandings(), want.Brandings()) {
+		t.Errorf("got brandings %v, want %v", res.Brandings(), want.Brandings())
+	}
+
+	ref, err := ReadReference(strings.NewReader("\xEF\xBB\xBFreference 70.5 45.0\n"))
+	assertError(t, err, nil)
+	if ref["Temperature"] != 70.5 {
+		t.Errorf("got reference %v, want temperature 70.5", ref)
+	}
+}
@@ -0,0 +1,59 @@
+package sensors
+
+import (
+	"math"
+
+	"gonum.org/v1/gonum/floats"
+)
+
+type soundSensor struct {
+	sensor
+}
+
+func (s *soundSensor) Name() string {
+	return s.name
+}
+
+func (s *soundSensor) Branding() string {
+	return s.branding
+}
+
+// Process sound level sensor (readings in dB, A-weighted by the station; no weighting is applied here):
+// It is branded "quiet" if the mean level is under the reference level, "loud" if it's less than
+// SoundExcessiveMargin over it and "excessive" otherwise. The reference level is the ref= option
+// of the sensor header or sound= of the labeled reference line, or SoundLimit when there's none.
+func (s *soundSensor) Process(referenceValues map[string]float64, readings []float64) {
+	if len(readings) == 0 {
+		return
+	}
+	limit, ok := referenceValues["Sound"]
+	if !ok {
+		limit = s.thresholds.SoundLimit
+	}
+
+	margin := s.thresholds.SoundExcessiveMargin
+	mean := soundLevelMean(readings)
+	if within(mean, limit, false) {
+		s.branding = SoundSensorQuiet
+		// the quiet band has no lower edge, the margin under the limit makes it certain
+		s.confidence = clamp01((limit - mean) / margin)
+	} else if within(mean, limit+margin, false) {
+		s.branding = SoundSensorLoud
+		s.confidence = confidenceInside(math.Abs(mean-(limit+margin/2)), margin/2)
+	} else {
+		s.branding = SoundSensorExcessive
+		s.confidence = clamp01((mean - limit - margin) / margin)
+	}
+}
+
+// Return the mean of the sound levels in dB: decibels are logarithmic, so the levels are averaged
+// in the energy domain, 10*log10(mean(10^(dB/10))). The loudest level is factored out so that
+// the powers don't overflow.
+func soundLevelMean(levels []float64) float64 {
+	max := floats.Max(levels)
+	sum := 0.0
+	for _, l := range levels {
+		sum += math.Pow(10, (l-max)/10)
+	}
+	return max + 10*math.Log10(sum/float64(len(levels)))
+}
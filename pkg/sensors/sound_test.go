@@ -0,0 +1,81 @@
+package sensors
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"testing"
+)
+
+const soundSensors = `reference 20 45
+sound sound-quiet
+2007-04-05T22:00 40
+2007-04-05T22:01 45
+sound sound-loud
+2007-04-05T22:00 58
+2007-04-05T22:01 60
+sound sound-burst
+2007-04-05T22:00 50
+2007-04-05T22:01 70
+sound sound-own-limit ref=35
+2007-04-05T22:00 40
+2007-04-05T22:01 45
+sound sound-no-data`
+
+func TestSoundLevelMean(t *testing.T) {
+	cases := []struct {
+		name   string
+		levels []float64
+		want   float64
+	}{
+		{"single level", []float64{63}, 63},
+		{"same levels", []float64{50, 50, 50}, 50},
+		// doubling the energy adds 3 dB
+		{"double source", []float64{60, 60 + 10*math.Log10(3)}, 63.0103},
+		// the arithmetic mean would be 60
+		{"loud level dominates", []float64{50, 70}, 67.0329},
+		{"no overflow", []float64{4000, 4000}, 4000},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := soundLevelMean(c.levels)
+			if math.Abs(got-c.want) > 0.0001 {
+				t.Errorf("got mean %f, want %f", got, c.want)
+			}
+		})
+	}
+}
+
+func TestSoundSensors(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "sensors")
+	if err != nil {
+		t.Error("Error creating test log file")
+		return
+	}
+	defer os.Remove(tmpFile.Name())
+	if err := writeTestLogFile(tmpFile, soundSensors); err != nil {
+		t.Error("Error writing test log file")
+		return
+	}
+
+	t.Run("default limits", func(t *testing.T) {
+		val, err := processTestLogFile(tmpFile.Name(), Options{})
+		assertError(t, err, nil)
+		// sound-burst is "loud" by the arithmetic mean (60 dB), but the energy mean is 67 dB
+		assertString(t, val, `{
+  "sound-burst": "excessive",
+  "sound-loud": "loud",
+  "sound-no-data": "quiet",
+  "sound-own-limit": "loud",
+  "sound-quiet": "quiet"
+}`)
+	})
+
+	t.Run("custom limits", func(t *testing.T) {
+		val, err := brandTestLogFile(tmpFile.Name(), Options{Thresholds: Thresholds{SoundLimit: 61, SoundExcessiveMargin: 20}})
+		assertError(t, err, nil)
+		assertString(t, val["sound-loud"], SoundSensorQuiet)
+		assertString(t, val["sound-burst"], SoundSensorLoud)
+		assertString(t, val["sound-own-limit"], SoundSensorLoud)
+	})
+}
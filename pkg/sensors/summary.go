@@ -0,0 +1,69 @@
+package sensors
+
+import (
+	"sort"
+)
+
+// StationSummary is the station-wide aggregate of the sensors of a log file, for the files with
+// the sensors of one station
+type StationSummary struct {
+	// Sensors is the number of sensors, Problems the number of those that failed the quality control
+	Sensors  int `json:"sensors"`
+	Problems int `json:"problems"`
+	// Brandings counts the sensors of each type by their branding, e.g. humidity: keep 3, discard 1
+	Brandings map[string]map[string]int `json:"brandings"`
+	// Means is the average of the means of the sensors of each type (e.g. the station temperature),
+	// the sensors without readings don't count; the energy mean of the levels for sound sensors
+	Means map[string]float64 `json:"means"`
+}
+
+// Summary aggregates the results of the sensors over the whole log file; the sensor appearing more
+// than once counts with its last result, as in Brandings
+func (r *Result) Summary() StationSummary {
+	last := make(map[string]SensorResult)
+	for _, s := range r.Sensors {
+		last[s.Name] = s
+	}
+	names := make([]string, 0, len(last))
+	for name := range last {
+		names = append(names, name)
+	}
+	// the same order for the same sensors, floating point sums depend on it
+	sort.Strings(names)
+
+	ret := StationSummary{
+		Brandings: make(map[string]map[string]int),
+		Means:     make(map[string]float64),
+	}
+	means := make(map[string][]float64)
+	for _, name := range names {
+		s := last[name]
+		ret.Sensors++
+		if IsProblem(s.Branding) {
+			ret.Problems++
+		}
+		if ret.Brandings[s.Type] == nil {
+			ret.Brandings[s.Type] = make(map[string]int)
+		}
+		ret.Brandings[s.Type][s.Branding]++
+		if s.Count > 0 {
+			means[s.Type] = append(means[s.Type], s.Mean)
+		}
+	}
+	for sensorType, m := range means {
+		ret.Means[sensorType] = typeMean(sensorType, m)
+	}
+	return ret
+}
+
+// Return the mean of the values of the sensor type, the energy mean for sound levels
+func typeMean(sensorType string, values []float64) float64 {
+	if t, ok := sensorTypes[sensorType]; ok && t.mean != nil {
+		return t.mean(values)
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
@@ -0,0 +1,57 @@
+package sensors
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+const summarySensors = `reference temperature=100 humidity=45 flow=12 room=22 sound=40
+thermometer temp-1
+2007-04-05T22:00 100
+2007-04-05T22:01 100.5
+thermometer temp-2
+2007-04-05T22:00 101
+thermometer temp-3
+humidity hum-1
+2007-04-05T22:00 45
+humidity hum-2
+2007-04-05T22:00 50
+flow flow-1
+2007-04-05T22:00 12
+sound mic-1
+2007-04-05T22:00 30
+2007-04-05T22:01 30
+sound mic-2
+2007-04-05T22:00 30`
+
+func assertMean(t testing.TB, got, want float64) {
+	t.Helper()
+
+	if math.Abs(got-want) > 1e-9 {
+		t.Errorf("got mean %f, want %f", got, want)
+	}
+}
+
+func TestStationSummary(t *testing.T) {
+	res, err := ProcessReader(strings.NewReader(summarySensors), Options{})
+	assertError(t, err, nil)
+	summary := res.Summary()
+
+	assertInt(t, summary.Sensors, 8)
+	assertInt(t, summary.Problems, 1)
+	assertInt(t, summary.Brandings[HumiditySensorLabel][HumiditySensorKeep], 1)
+	assertInt(t, summary.Brandings[HumiditySensorLabel][HumiditySensorDiscard], 1)
+	assertInt(t, summary.Brandings[FlowSensorLabel][FlowSensorNormal], 1)
+	assertInt(t, summary.Brandings[SoundSensorLabel][SoundSensorQuiet], 2)
+
+	// temp-3 without readings doesn't count
+	assertMean(t, summary.Means[ThermometerLabel], 100.625)
+	assertMean(t, summary.Means[HumiditySensorLabel], 47.5)
+	assertMean(t, summary.Means[FlowSensorLabel], 12)
+	// the same levels average to the same level
+	assertMean(t, summary.Means[SoundSensorLabel], 30)
+	if _, ok := summary.Means["unknown"]; ok {
+		t.Error("got mean of sensor type without sensors")
+	}
+}
@@ -0,0 +1,94 @@
+package sensors
+
+import (
+	"math"
+)
+
+// default limits of the thermometer branding, as given by the assignment
+const (
+	defaultMeanTolerance      = 0.5
+	defaultUltraPreciseStdDev = 3
+	defaultVeryPreciseStdDev  = 5
+	defaultFlowBand           = 10
+	// dB, the usual guideline for outdoor noise at daytime
+	defaultSoundLimit           = 55
+	defaultSoundExcessiveMargin = 10
+	// percent of the reference humidity, or percentage points with Thresholds.HumidityAbsolute
+	defaultHumidityBand = 1.0
+)
+
+// Thresholds are the limits used for the branding of sensors.
+// Zero limits mean the default ones. By default all limits are exclusive, i.e. the value must be strictly
+// less than the limit; std deviation of exactly 3.0 is therefore "very precise" and exactly 5.0 is "precise".
+type Thresholds struct {
+	// maximal distance of thermometer readings mean from the reference temperature
+	MeanTolerance float64
+	MeanInclusive bool
+	// maximal std deviation of readings of "ultra precise" thermometer
+	UltraPreciseStdDev    float64
+	UltraPreciseInclusive bool
+	// maximal std deviation of readings of "very precise" thermometer
+	VeryPreciseStdDev    float64
+	VeryPreciseInclusive bool
+
+	// humidity readings must be within HumidityBand (by default 1) humidity percent of the reference: by default
+	// percent of the reference value, with HumidityAbsolute percentage points (e.g. 44..46 for the reference 45,
+	// instead of 44.55..45.45)
+	HumidityBand     float64
+	HumidityAbsolute bool
+	// InvertHumidity flips the keep/discard decision: only the humidity sensors out of the band are kept,
+	// for the failure analysis
+	InvertHumidity bool
+
+	// allowed distance of flow readings mean from the reference flow, in percents of the reference
+	FlowBand float64
+
+	// sound level in dB under which the sound sensor is "quiet", unless it has its own reference level
+	SoundLimit float64
+	// how many dB over the reference level the sound sensor is still "loud" and not "excessive"
+	SoundExcessiveMargin float64
+}
+
+// Return the thresholds with zero limits replaced by the default ones
+func (t Thresholds) withDefaults() Thresholds {
+	if t.MeanTolerance == 0 {
+		t.MeanTolerance = defaultMeanTolerance
+	}
+	if t.UltraPreciseStdDev == 0 {
+		t.UltraPreciseStdDev = defaultUltraPreciseStdDev
+	}
+	if t.VeryPreciseStdDev == 0 {
+		t.VeryPreciseStdDev = defaultVeryPreciseStdDev
+	}
+	if t.HumidityBand == 0 {
+		t.HumidityBand = defaultHumidityBand
+	}
+	if t.FlowBand == 0 {
+		t.FlowBand = defaultFlowBand
+	}
+	if t.SoundLimit == 0 {
+		t.SoundLimit = defaultSoundLimit
+	}
+	if t.SoundExcessiveMargin == 0 {
+		t.SoundExcessiveMargin = defaultSoundExcessiveMargin
+	}
+	return t
+}
+
+// Return the half-width of the band of humidity readings around the expected humidity
+func (t Thresholds) humidityBand(expected float64) float64 {
+	if t.HumidityAbsolute {
+		return t.HumidityBand
+	}
+	// the band is relative to the reference, but for zero (or near zero) reference it would collapse
+	// and no reading could pass, so make it at least minHumidityBand wide on each side
+	return math.Max(math.Abs(expected)*t.HumidityBand/100, minHumidityBand)
+}
+
+// Check if the value is within the limit
+func within(value, limit float64, inclusive bool) bool {
+	if inclusive {
+		return value <= limit
+	}
+	return value < limit
+}
@@ -0,0 +1,156 @@
+package sensors
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+// std deviation of the readings is exactly 3.0
+const tempStdDev3 = `reference 100 0
+thermometer temp-1
+2007-04-05T22:00 97
+2007-04-05T22:01 100
+2007-04-05T22:02 103`
+
+// std deviation of the readings is exactly 5.0
+const tempStdDev5 = `reference 100 0
+thermometer temp-1
+2007-04-05T22:00 95
+2007-04-05T22:01 100
+2007-04-05T22:02 105`
+
+// mean of the readings is exactly 0.5 over the reference
+const tempMeanOnBoundary = `reference 100 0
+thermometer temp-1
+2007-04-05T22:00 100.5`
+
+func TestThresholdBoundaries(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "sensors")
+	if err != nil {
+		t.Error("Error creating test log file")
+		return
+	}
+	defer os.Remove(tmpFile.Name())
+
+	inclusive := Thresholds{MeanInclusive: true, UltraPreciseInclusive: true, VeryPreciseInclusive: true}
+	cases := []struct {
+		name       string
+		content    string
+		thresholds Thresholds
+		want       string
+	}{
+		{"std 3.0 exclusive", tempStdDev3, Thresholds{}, ThermometerVeryPrecise},
+		{"std 3.0 inclusive", tempStdDev3, inclusive, ThermometerUltraPrecise},
+		{"std 5.0 exclusive", tempStdDev5, Thresholds{}, ThermometerPrecise},
+		{"std 5.0 inclusive", tempStdDev5, inclusive, ThermometerVeryPrecise},
+		{"mean +0.5 exclusive", tempMeanOnBoundary, Thresholds{}, ThermometerPrecise},
+		{"mean +0.5 inclusive", tempMeanOnBoundary, inclusive, ThermometerUltraPrecise},
+		{"custom limits", tempStdDev5, Thresholds{UltraPreciseStdDev: 6}, ThermometerUltraPrecise},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if err := writeTestLogFile(tmpFile, c.content); err != nil {
+				t.Error("Error writing test log file")
+				return
+			}
+			val, err := brandTestLogFile(tmpFile.Name(), Options{Thresholds: c.thresholds})
+			assertError(t, err, nil)
+			assertString(t, val["temp-1"], c.want)
+		})
+	}
+}
+
+func TestHumidityAbsolute(t *testing.T) {
+	cases := []struct {
+		reading            string
+		relative, absolute string
+	}{
+		// 1 % of 45 is 0.45, 1 percentage point is 1
+		{"44.6", HumiditySensorKeep, HumiditySensorKeep},
+		{"45.8", HumiditySensorDiscard, HumiditySensorKeep},
+		{"44.1", HumiditySensorDiscard, HumiditySensorKeep},
+		{"46.2", HumiditySensorDiscard, HumiditySensorDiscard},
+	}
+	for _, c := range cases {
+		t.Run(c.reading, func(t *testing.T) {
+			log := "reference 100 45\nhumidity hum-1\n2007-04-05T22:00 " + c.reading
+			res, err := ProcessReader(strings.NewReader(log), Options{})
+			assertError(t, err, nil)
+			assertString(t, res.Brandings()["hum-1"], c.relative)
+
+			res, err = ProcessReader(strings.NewReader(log), Options{Thresholds: Thresholds{HumidityAbsolute: true}})
+			assertError(t, err, nil)
+			assertString(t, res.Brandings()["hum-1"], c.absolute)
+		})
+	}
+}
+
+func TestParseTolerances(t *testing.T) {
+	config := `{"thermometer": {"mean": 0.3, "veryPreciseStd": 4}, "humidity": {"band": 2}, "flow": {"band": 5}}`
+	thresholds, err := ParseTolerances([]byte(config), Thresholds{UltraPreciseStdDev: 2, SoundLimit: 45})
+	assertError(t, err, nil)
+	want := Thresholds{MeanTolerance: 0.3, UltraPreciseStdDev: 2, VeryPreciseStdDev: 4, HumidityBand: 2, FlowBand: 5, SoundLimit: 45}
+	if thresholds != want {
+		t.Errorf("got thresholds %+v, want %+v", thresholds, want)
+	}
+
+	// reference 100 45 50: each sensor passes only with the tolerances of the config
+	log := `reference 100 45 50
+thermometer temp-1
+2007-04-05T22:00 100.4
+2007-04-05T22:01 100.4
+humidity hum-1
+2007-04-05T22:00 45.8
+flow flow-1
+2007-04-05T22:00 53
+`
+	res, err := ProcessReader(strings.NewReader(log), Options{})
+	assertError(t, err, nil)
+	assertString(t, res.Brandings()["temp-1"], ThermometerUltraPrecise)
+	assertString(t, res.Brandings()["hum-1"], HumiditySensorDiscard)
+	assertString(t, res.Brandings()["flow-1"], FlowSensorNormal)
+
+	res, err = ProcessReader(strings.NewReader(log), Options{Thresholds: thresholds})
+	assertError(t, err, nil)
+	assertString(t, res.Brandings()["temp-1"], ThermometerPrecise)
+	assertString(t, res.Brandings()["hum-1"], HumiditySensorKeep)
+	assertString(t, res.Brandings()["flow-1"], FlowSensorHigh)
+
+	_, err = ParseTolerances([]byte(`{"pressure": {"band": 1}}`), Thresholds{})
+	assertErrorMessageSubString(t, err, `unknown field "pressure"`)
+	_, err = ParseTolerances([]byte(`{"flow": {"band": -1}}`), Thresholds{})
+	assertErrorMessageSubString(t, err, "flow.band must be positive")
+	// zero would replace the base limit by the default one
+	base := Thresholds{UltraPreciseStdDev: 2}
+	got, err := ParseTolerances([]byte(`{"thermometer": {"ultraPreciseStd": 0}}`), base)
+	assertErrorMessageSubString(t, err, "thermometer.ultraPreciseStd must be positive")
+	if got != base {
+		t.Errorf("got thresholds %+v on error, want the base %+v", got, base)
+	}
+}
+
+func TestInvertHumidity(t *testing.T) {
+	log := `reference 100 45
+humidity hum-1
+2007-04-05T22:00 45.1
+humidity hum-2
+2007-04-05T22:00 47
+humidity hum-3
+2007-04-05T22:00 -1
+`
+	res, err := ProcessReader(strings.NewReader(log), Options{})
+	assertError(t, err, nil)
+	assertString(t, res.Brandings()["hum-1"], HumiditySensorKeep)
+	assertString(t, res.Brandings()["hum-2"], HumiditySensorDiscard)
+	assertString(t, res.Brandings()["hum-3"], HumiditySensorDiscard)
+
+	res, err = ProcessReader(strings.NewReader(log), Options{Thresholds: Thresholds{InvertHumidity: true}})
+	assertError(t, err, nil)
+	assertString(t, res.Brandings()["hum-1"], HumiditySensorDiscard)
+	assertString(t, res.Brandings()["hum-2"], HumiditySensorKeep)
+	assertString(t, res.Brandings()["hum-3"], HumiditySensorKeep)
+	// the decision is as certain as before
+	assertConfidence(t, res.Sensors[1].Confidence, 1)
+}
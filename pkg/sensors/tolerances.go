@@ -0,0 +1,105 @@
+package sensors
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// the tolerances of one sensor type in the tolerances config; nil values keep the base thresholds
+type thermometerTolerances struct {
+	Mean                  *float64 `json:"mean"`
+	MeanInclusive         *bool    `json:"meanInclusive"`
+	UltraPreciseStd       *float64 `json:"ultraPreciseStd"`
+	UltraPreciseInclusive *bool    `json:"ultraPreciseInclusive"`
+	VeryPreciseStd        *float64 `json:"veryPreciseStd"`
+	VeryPreciseInclusive  *bool    `json:"veryPreciseInclusive"`
+}
+
+type humidityTolerances struct {
+	Band     *float64 `json:"band"`
+	Absolute *bool    `json:"absolute"`
+}
+
+type flowTolerances struct {
+	Band *float64 `json:"band"`
+}
+
+type soundTolerances struct {
+	Limit           *float64 `json:"limit"`
+	ExcessiveMargin *float64 `json:"excessiveMargin"`
+}
+
+// the tolerances config, by the sensor type labels
+type tolerances struct {
+	Thermometer *thermometerTolerances `json:"thermometer"`
+	Humidity    *humidityTolerances    `json:"humidity"`
+	Flow        *flowTolerances        `json:"flow"`
+	Sound       *soundTolerances       `json:"sound"`
+}
+
+// toleranceLimit is a limit of the tolerances config, with the threshold it sets
+type toleranceLimit struct {
+	name          string
+	value, target *float64
+}
+
+// ParseTolerances applies the tolerances config, a JSON object with the limits of each sensor type, on top of
+// the base thresholds, e.g.
+//
+//	{"thermometer": {"mean": 0.3, "meanInclusive": true, "ultraPreciseStd": 2, "veryPreciseStd": 4},
+//	 "humidity": {"band": 2, "absolute": true}, "flow": {"band": 5}, "sound": {"limit": 45, "excessiveMargin": 10}}
+//
+// The sensor types and limits missing in the config keep their base values. Unknown sensor types or limits
+// and limits that are not positive are errors.
+func ParseTolerances(data []byte, base Thresholds) (Thresholds, error) {
+	var cfg tolerances
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return base, errors.Wrap(err, "invalid tolerances config")
+	}
+	t := base
+	// the limits are validated all together, so that the base is returned untouched on error
+	limits := make([]toleranceLimit, 0)
+	limit := func(sensorType, name string, value, target *float64) {
+		limits = append(limits, toleranceLimit{sensorType + "." + name, value, target})
+	}
+	flag := func(value *bool, target *bool) {
+		if value != nil {
+			*target = *value
+		}
+	}
+	if c := cfg.Thermometer; c != nil {
+		limit(ThermometerLabel, "mean", c.Mean, &t.MeanTolerance)
+		limit(ThermometerLabel, "ultraPreciseStd", c.UltraPreciseStd, &t.UltraPreciseStdDev)
+		limit(ThermometerLabel, "veryPreciseStd", c.VeryPreciseStd, &t.VeryPreciseStdDev)
+		flag(c.MeanInclusive, &t.MeanInclusive)
+		flag(c.UltraPreciseInclusive, &t.UltraPreciseInclusive)
+		flag(c.VeryPreciseInclusive, &t.VeryPreciseInclusive)
+	}
+	if c := cfg.Humidity; c != nil {
+		limit(HumiditySensorLabel, "band", c.Band, &t.HumidityBand)
+		flag(c.Absolute, &t.HumidityAbsolute)
+	}
+	if c := cfg.Flow; c != nil {
+		limit(FlowSensorLabel, "band", c.Band, &t.FlowBand)
+	}
+	if c := cfg.Sound; c != nil {
+		limit(SoundSensorLabel, "limit", c.Limit, &t.SoundLimit)
+		limit(SoundSensorLabel, "excessiveMargin", c.ExcessiveMargin, &t.SoundExcessiveMargin)
+	}
+	for _, l := range limits {
+		if l.value == nil {
+			continue
+		}
+		// zero limit would mean the default one, see Thresholds.withDefaults, rather than the base
+		if *l.value <= 0 {
+			return base, errors.New(fmt.Sprintf("invalid tolerances config: %s must be positive", l.name))
+		}
+		*l.target = *l.value
+	}
+	return t, nil
+}
@@ -0,0 +1,27 @@
+package sensors
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Convert the reading value that may have the unit appended, e.g. "100C" or "45.2%", to a number;
+// a different unit is UnitMismatchError
+func parseWithUnit(s, unit string) (float64, error) {
+	if number := strings.TrimSuffix(s, unit); number != s {
+		return strconv.ParseFloat(number, 64)
+	}
+	value, err := strconv.ParseFloat(s, 64)
+	if err == nil {
+		return value, nil
+	}
+	// the unit is whatever follows the last digit
+	i := strings.LastIndexAny(s, "0123456789.") + 1
+	if i == 0 || i == len(s) {
+		return 0, err
+	}
+	if _, numErr := strconv.ParseFloat(s[:i], 64); numErr != nil {
+		return 0, err
+	}
+	return 0, &UnitMismatchError{Unit: s[i:], Expected: unit}
+}
@@ -0,0 +1,63 @@
+package sensors
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestParseWithUnit(t *testing.T) {
+	for raw, want := range map[string]float64{"100C": 100, "-1.5C": -1.5, "100": 100, "1e2C": 100} {
+		got, err := parseWithUnit(raw, "C")
+		assertError(t, err, nil)
+		if got != want {
+			t.Errorf("%q: got %f, want %f", raw, got, want)
+		}
+	}
+
+	_, err := parseWithUnit("212F", "C")
+	var unitErr *UnitMismatchError
+	if !errors.As(err, &unitErr) {
+		t.Fatalf("got error %v, want UnitMismatchError", err)
+	}
+	assertString(t, unitErr.Unit, "F")
+	assertString(t, err.Error(), `unit "F" doesn't match the expected unit "C"`)
+
+	// not a number with unit
+	for _, raw := range []string{"C", "abc", "1.2.3C", ""} {
+		if _, err := parseWithUnit(raw, "C"); err == nil || errors.As(err, &unitErr) {
+			t.Errorf("%q: got error %v, want parse error", raw, err)
+		}
+	}
+}
+
+func TestReadingsWithUnits(t *testing.T) {
+	log := `reference 70.0 45.0
+thermometer temp-1
+2007-04-05T22:00 70.1C
+2007-04-05T22:01 69.9C
+2007-04-05T22:02 70
+humidity hum-1
+2007-04-05T22:04 45.2%
+2007-04-05T22:05 45.3% 45%
+`
+	opts := Options{Units: map[string]string{ThermometerLabel: "C", HumiditySensorLabel: "%"}}
+	res, err := ProcessReader(strings.NewReader(log), opts)
+	assertError(t, err, nil)
+	assertString(t, res.Brandings()["temp-1"], ThermometerUltraPrecise)
+	assertString(t, res.Brandings()["hum-1"], HumiditySensorKeep)
+	assertInt(t, res.Sensors[0].Count, 3)
+
+	// wrong unit
+	_, err = ProcessReader(strings.NewReader(strings.Replace(log, "69.9C", "69.9F", 1)), opts)
+	assertErrorMessageSubString(t, err, `unit "F" doesn't match the expected unit "C" (line 4: "2007-04-05T22:01 69.9F")`)
+	var valueErr *InvalidValueError
+	if !errors.As(err, &valueErr) {
+		t.Errorf("got error %v, want InvalidValueError", err)
+	}
+
+	// without the units, the readings are not numbers
+	_, err = ProcessReader(strings.NewReader(log), Options{})
+	assertErrorMessageSubString(t, err, `invalid syntax (line 3: "2007-04-05T22:00 70.1C")`)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"sensors/pkg/sensors"
+)
+
+// Parse the candidate thresholds given as comma separated NAME=value pairs, using the names
+// of the environment variables, e.g. THERMOMETER_ULTRA_PRECISE_STD=2,THERMOMETER_MEAN_INCLUSIVE=true.
+// Empty spec means the default thresholds.
+func parseThresholds(spec string) (sensors.Thresholds, error) {
+	values := make(map[string]string)
+	for _, item := range strings.Split(spec, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 {
+			return sensors.Thresholds{}, errors.New(fmt.Sprintf("invalid thresholds %q: %q is not a NAME=value pair", spec, item))
+		}
+		values[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	known := make(map[string]bool)
+	t, err := thresholdsFrom(func(name string) (string, bool) {
+		known[name] = true
+		val, ok := values[name]
+		return val, ok
+	})
+	if err != nil {
+		return t, err
+	}
+	for name := range values {
+		if !known[name] {
+			return t, errors.New(fmt.Sprintf("invalid thresholds %q: unknown threshold %s", spec, name))
+		}
+	}
+	return t, nil
+}
+
+// preview subcommand: print the brandings of sensors from the log file under each of the candidate thresholds
+func runPreview(args []string, out io.Writer) error {
+	if len(args) < 2 {
+		return errors.New("usage: sensors preview FILE THRESHOLDS...")
+	}
+	cfg, err := configFromEnv()
+	if err != nil {
+		return errors.Wrap(err, "Error reading configuration")
+	}
+	candidates := make([]sensors.Thresholds, 0, len(args)-1)
+	for _, spec := range args[1:] {
+		t, err := parseThresholds(spec)
+		if err != nil {
+			return err
+		}
+		candidates = append(candidates, t)
+	}
+	brandings, err := sensors.PreviewLogFile(args[0], cfg.Options, candidates)
+	if err != nil {
+		return err
+	}
+	j, _ := json.MarshalIndent(brandings, "", outputIndent)
+	fmt.Fprintln(out, string(j))
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"sensors/pkg/sensors"
+)
+
+func TestParseThresholds(t *testing.T) {
+	th, err := parseThresholds("THERMOMETER_ULTRA_PRECISE_STD=4, THERMOMETER_ULTRA_PRECISE_INCLUSIVE=true")
+	assertError(t, err, nil)
+	if th != (sensors.Thresholds{UltraPreciseStdDev: 4, UltraPreciseInclusive: true}) {
+		t.Errorf("got thresholds %+v", th)
+	}
+	th, err = parseThresholds("")
+	assertError(t, err, nil)
+	if th != (sensors.Thresholds{}) {
+		t.Errorf("got thresholds %+v, want defaults", th)
+	}
+	_, err = parseThresholds("ULTRA=4")
+	assertErrorMessageSubString(t, err, "unknown threshold ULTRA")
+	_, err = parseThresholds("FLOW_BAND")
+	assertErrorMessageSubString(t, err, "not a NAME=value pair")
+}
+
+func TestPreviewCommand(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "sensors")
+	if err != nil {
+		t.Fatal("Error creating test log file")
+	}
+	defer os.Remove(tmpFile.Name())
+	if err := writeTestLogFile(tmpFile, "reference 100 0\nthermometer temp-1\n2007-04-05T22:00 96\n2007-04-05T22:01 100\n2007-04-05T22:02 104"); err != nil {
+		t.Fatal("Error writing test log file")
+	}
+
+	var out bytes.Buffer
+	err = run([]string{"preview", tmpFile.Name(), "", "THERMOMETER_ULTRA_PRECISE_STD=4,THERMOMETER_ULTRA_PRECISE_INCLUSIVE=true", "THERMOMETER_VERY_PRECISE_STD=3.5"}, &out)
+	assertError(t, err, nil)
+	assertString(t, out.String(), `{
+  "temp-1": [
+    "very precise",
+    "ultra precise",
+    "precise"
+  ]
+}
+`)
+
+	err = run([]string{"preview", tmpFile.Name()}, &out)
+	assertErrorMessageSubString(t, err, "usage")
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// process subcommand: print the branding of sensors from the local log file, without REDIS or the remote directory
+func runProcess(args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("usage: sensors process FILE")
+	}
+	cfg, err := configFromEnv()
+	if err != nil {
+		return errors.Wrap(err, "Error reading configuration")
+	}
+	// there's no REDIS to keep the state between the files
+	if cfg.UseBaseline || cfg.PreviousReference || cfg.InheritReference || cfg.HysteresisMargin > 0 {
+		return errors.New("USE_BASELINE, PREVIOUS_REFERENCE, INHERIT_REFERENCE and HYSTERESIS_MARGIN are not supported by the process command")
+	}
+	processed, err := processLogFileWithConfig(args[0], cfg)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, processed)
+	return nil
+}
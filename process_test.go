@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestProcessCommand(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "sensors")
+	if err != nil {
+		t.Fatal("Error creating test log file")
+	}
+	defer os.Remove(tmpFile.Name())
+	if err := writeTestLogFile(tmpFile, mergeFirstDay); err != nil {
+		t.Fatal("Error writing test log file")
+	}
+
+	t.Run("processed", func(t *testing.T) {
+		var out bytes.Buffer
+		err := run([]string{"process", tmpFile.Name()}, &out)
+		assertError(t, err, nil)
+		assertString(t, out.String(), `{
+  "hum-1": "keep",
+  "temp-1": "precise"
+}
+`)
+	})
+
+	t.Run("output settings apply", func(t *testing.T) {
+		os.Setenv("OUTPUT_FILTER", OutputFilterProblems)
+		defer os.Unsetenv("OUTPUT_FILTER")
+		var out bytes.Buffer
+		err := run([]string{"process", tmpFile.Name()}, &out)
+		assertError(t, err, nil)
+		assertString(t, out.String(), "{}\n")
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		err := run([]string{"process", tmpFile.Name() + ".missing"}, ioutil.Discard)
+		assertErrorMessageSubString(t, err, "error opening file")
+	})
+
+	t.Run("usage", func(t *testing.T) {
+		err := run([]string{"process"}, ioutil.Discard)
+		assertErrorMessageSubString(t, err, "usage")
+	})
+
+	t.Run("state between files", func(t *testing.T) {
+		os.Setenv("USE_BASELINE", "true")
+		defer os.Unsetenv("USE_BASELINE")
+		err := run([]string{"process", tmpFile.Name()}, ioutil.Discard)
+		assertErrorMessageSubString(t, err, "not supported")
+	})
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// format of the dates given to the replay subcommand
+	replayDateLayout = "2006-01-02"
+	// format of the date in log file names, log-YYYYMMDD...
+	logFileDateLayout = "20060102"
+)
+
+// noCache is Cache without any values; LogSource.Unprocessed lists all the log files with it
+type noCache struct{}
+
+func (noCache) Get(key string) (string, error)                              { return "", ErrCacheMiss }
+func (noCache) Set(key, value string) error                                 { return nil }
+func (noCache) Prepend(key, value string, max int) error                    { return nil }
+func (noCache) List(key string, n int) ([]string, error)                    { return nil, nil }
+func (noCache) AddScored(key, member string, score, min float64) error      { return nil }
+func (noCache) RangeScored(key string, min float64) ([]scoredMember, error) { return nil, nil }
+
+// Return the date of the log file named log-YYYYMMDD...; ok is false for other names
+func logFileDate(name string) (date time.Time, ok bool) {
+	if !strings.HasPrefix(name, logFilePrefix) {
+		return time.Time{}, false
+	}
+	rest := strings.TrimPrefix(name, logFilePrefix)
+	if len(rest) < len(logFileDateLayout) {
+		return time.Time{}, false
+	}
+	date, err := time.Parse(logFileDateLayout, rest[:len(logFileDateLayout)])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return date, true
+}
+
+// Return the log files dated from start to end (both inclusive), from the oldest one;
+// the files of the same day are sorted by name
+func selectDateRange(files []string, start, end time.Time) []string {
+	type datedFile struct {
+		name string
+		date time.Time
+	}
+	selected := make([]datedFile, 0)
+	for _, f := range files {
+		date, ok := logFileDate(f)
+		if !ok || date.Before(start) || date.After(end) {
+			continue
+		}
+		selected = append(selected, datedFile{name: f, date: date})
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		if !selected[i].date.Equal(selected[j].date) {
+			return selected[i].date.Before(selected[j].date)
+		}
+		return selected[i].name < selected[j].name
+	})
+	ret := make([]string, len(selected))
+	for i, f := range selected {
+		ret[i] = f.name
+	}
+	return ret
+}
+
+// Process the log files in the given order, whether they were processed before or not
+func (w *worker) replay(files []string) error {
+	p := newProgress(w.out, "processed", len(files), w.progressInterval)
+	for _, f := range files {
+		if err := w.processFile(f); err != nil {
+			return err
+		}
+		p.step()
+	}
+	return nil
+}
+
+// replay subcommand: process again the log files of the remote directory dated within the range
+func runReplay(args []string, out io.Writer) error {
+	if len(args) != 2 {
+		return errors.New("usage: sensors replay START END (dates as YYYY-MM-DD, both inclusive)")
+	}
+	start, err := time.Parse(replayDateLayout, args[0])
+	if err != nil {
+		return errors.Wrap(err, "invalid start date")
+	}
+	end, err := time.Parse(replayDateLayout, args[1])
+	if err != nil {
+		return errors.Wrap(err, "invalid end date")
+	}
+	if end.Before(start) {
+		return errors.New("end date is before start date")
+	}
+	remoteDir, exists := os.LookupEnv("REMOTE_LOGS_DIR")
+	if !exists {
+		return errors.New("Remote directory with log files not provided!")
+	}
+	cfg, err := configFromEnv()
+	if err != nil {
+		return errors.Wrap(err, "Error reading configuration")
+	}
+	rdb := getRedis()
+	if _, err := rdb.Ping().Result(); err != nil {
+		return errors.Wrap(err, "Error connecting to REDIS")
+	}
+	cache := newRedisCache(rdb, redisKeyPrefix(envString("REDIS_KEY_PREFIX", ""), remoteDir))
+	cfg.Store = cache
+	source, err := newLogSource(cfg, newHTTPClient(cfg.HTTPHeaders, cfg.RateLimit), remoteDir)
+	if err != nil {
+		return errors.Wrap(err, "Error reading configuration")
+	}
+	sink, err := newOutputSink(cfg.OutputSink, cfg.OutputCompression)
+	if err != nil {
+		return errors.Wrap(err, "Error reading configuration")
+	}
+
+	files, err := source.Unprocessed(noCache{})
+	if err != nil && err != ErrEmptyListing {
+		return errors.Wrap(err, "Error fetching log files")
+	}
+	files = selectDateRange(files, start, end)
+	if len(files) == 0 {
+		fmt.Fprintf(out, "no log files from %s to %s\n", args[0], args[1])
+		return nil
+	}
+
+	tmpDir, err := ioutil.TempDir("", "sensor-logs")
+	if err != nil {
+		return errors.Wrap(err, "Error while creating temp directory")
+	}
+	defer os.RemoveAll(tmpDir)
+	w := &worker{
+		cfg:    cfg,
+		cache:  cache,
+		source: source,
+		alerts: newAlerter(cfg.AlertWebhookURL, cfg.AlertBrandings),
+		sink:   sink,
+		tmpDir: tmpDir,
+		out:    out,
+
+		progressInterval: progressInterval,
+	}
+	// the alerts of the last files may still be queued
+	defer w.alerts.close()
+	return w.replay(files)
+}
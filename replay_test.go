@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSelectDateRange(t *testing.T) {
+	files := []string{
+		"log-20211105.txt", "log-20211101.txt", "log-20211103-b.txt", "log-20211103-a.txt",
+		"log-20211031.txt", "log-latest.txt", "index.html", "log-2021.txt",
+	}
+	start := time.Date(2021, 11, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2021, 11, 3, 0, 0, 0, 0, time.UTC)
+	assertString(t, strings.Join(selectDateRange(files, start, end), ","),
+		"log-20211101.txt,log-20211103-a.txt,log-20211103-b.txt")
+
+	assertInt(t, len(selectDateRange(files, end.AddDate(1, 0, 0), end.AddDate(1, 0, 1))), 0)
+}
+
+func TestReplay(t *testing.T) {
+	files := []string{"log-20211103.txt", "log-20211102.txt", "log-20211101.txt"}
+	server := newTestRemoteDir(files, tempUltraPrecise)
+	defer server.Close()
+
+	w := newTestWorker(t, &htmlSource{client: newHTTPClient(nil, 0), dirURL: server.URL + "/"})
+	// already processed files are processed again
+	w.cache.Set("log-20211102.txt", "stale")
+	all, err := w.source.Unprocessed(noCache{})
+	assertError(t, err, nil)
+	selected := selectDateRange(all, time.Date(2021, 11, 2, 0, 0, 0, 0, time.UTC), time.Date(2021, 11, 3, 0, 0, 0, 0, time.UTC))
+	assertString(t, strings.Join(selected, ","), "log-20211102.txt,log-20211103.txt")
+
+	err = w.replay(selected)
+	assertError(t, err, nil)
+	val, _ := w.cache.Get("log-20211102.txt")
+	assertString(t, val, `{
+  "temp-1": "ultra precise"
+}`)
+	_, err = w.cache.Get("log-20211101.txt")
+	assertError(t, err, ErrCacheMiss)
+}
+
+func TestReplayArguments(t *testing.T) {
+	err := runReplay([]string{"2021-11-01"}, nil)
+	assertErrorMessageSubString(t, err, "usage: sensors replay")
+	err = runReplay([]string{"2021-11-01", "yesterday"}, nil)
+	assertErrorMessageSubString(t, err, "invalid end date")
+	err = runReplay([]string{"2021-11-03", "2021-11-01"}, nil)
+	assertErrorMessageSubString(t, err, "end date is before start date")
+}
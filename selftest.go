@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"sensors/pkg/sensors"
+)
+
+// fixture logs of the self-test: every <name>.log comes with <name>.json holding the expected output
+// (with the default configuration)
+//
+//go:embed selftest
+var selfTestFixtures embed.FS
+
+const selfTestDir = "selftest"
+
+// Brand the embedded fixture logs and compare the results with the expected ones
+func selfTest(out io.Writer) error {
+	return checkFixtures(selfTestFixtures, selfTestDir, out)
+}
+
+// Brand every log file of the fixtures directory and compare the result with the expected one;
+// the mismatches are reported to out
+func checkFixtures(fsys fs.FS, dir string, out io.Writer) error {
+	logs, err := fs.Glob(fsys, path.Join(dir, "*.log"))
+	if err != nil {
+		return errors.Wrap(err, "failed listing self-test fixtures")
+	}
+	if len(logs) == 0 {
+		return errors.New("no self-test fixtures found")
+	}
+	failed := make([]string, 0)
+	for _, logFile := range logs {
+		content, err := fs.ReadFile(fsys, logFile)
+		if err != nil {
+			return errors.Wrap(err, "failed reading self-test fixture")
+		}
+		expected, err := fs.ReadFile(fsys, strings.TrimSuffix(logFile, ".log")+".json")
+		if err != nil {
+			return errors.Wrap(err, "failed reading expected output of self-test fixture")
+		}
+		got := ""
+		res, err := sensors.ProcessReader(bytes.NewReader(content), sensors.Options{})
+		if err != nil {
+			got = err.Error()
+		} else {
+			got = formatBrandings(res.Brandings())
+		}
+		if got != strings.TrimSpace(string(expected)) {
+			fmt.Fprintf(out, "self-test %s: got\n%s\nwant\n%s\n", logFile, got, strings.TrimSpace(string(expected)))
+			failed = append(failed, path.Base(logFile))
+		}
+	}
+	if len(failed) > 0 {
+		return errors.New(fmt.Sprintf("self-test failed for %s", strings.Join(failed, ", ")))
+	}
+	fmt.Fprintf(out, "self-test passed (%d fixtures)\n", len(logs))
+	return nil
+}
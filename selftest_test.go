@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSelfTest(t *testing.T) {
+
+	t.Run("embedded fixtures", func(t *testing.T) {
+		var out bytes.Buffer
+		err := run([]string{"selftest"}, &out)
+		assertError(t, err, nil)
+		assertSubString(t, out.String(), "self-test passed")
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"fixtures/ok.log":    {Data: []byte(tempUltraPrecise)},
+			"fixtures/ok.json":   {Data: []byte("{\n  \"temp-1\": \"ultra precise\"\n}\n")},
+			"fixtures/fail.log":  {Data: []byte(tempVeryPrecise)},
+			"fixtures/fail.json": {Data: []byte("{\n  \"temp-1\": \"ultra precise\"\n}\n")},
+		}
+		var out bytes.Buffer
+		err := checkFixtures(fsys, "fixtures", &out)
+		assertErrorMessageSubString(t, err, "self-test failed for fail.log")
+		assertSubString(t, out.String(), `"temp-1": "very precise"`)
+	})
+
+	t.Run("no fixtures", func(t *testing.T) {
+		err := checkFixtures(fstest.MapFS{}, "fixtures", ioutil.Discard)
+		assertErrorMessageSubString(t, err, "no self-test fixtures")
+	})
+}
@@ -1,230 +1,103 @@
 package main
 
 import (
-	"bufio"
-	"encoding/json"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
 	"path"
 	"path/filepath"
-	"strconv"
+	"sort"
 	"strings"
-	"time"
+	"syscall"
 
 	"github.com/go-redis/redis"
 	"github.com/pkg/errors"
-	"gonum.org/v1/gonum/stat"
-
+	"go.opentelemetry.io/otel"
 	"golang.org/x/net/html"
-)
 
-const (
-	ErrOpenFile                = "error opening file"
-	ErrWrongNumberRefFields    = "reference line has incorrect number of fields"
-	ErrWrongNumberRedingFields = "line with readings has incorrect number of fields"
-	ErrTempNotFloat            = "failed converting reference temperature to float"
-	ErrHumidityNotFloat        = "failed converting reference humidity to float"
-
-	ThermometerLabel    = "thermometer"
-	HumiditySensorLabel = "humidity"
-	ReferenceLabel      = "reference"
-
-	ThermometerUltraPrecise = "ultra precise"
-	ThermometerVeryPrecise  = "very precise"
-	ThermometerPrecise      = "precise"
-
-	HumiditySensorKeep    = "keep"
-	HumiditySensorDiscard = "discard"
-
-	readingLineValues = 2
-	outputIndent      = "  "
-	logFilePrefix     = "log-"
+	"sensors/pkg/sensors"
 )
 
-var defaultBranding map[string]string = map[string]string{
-	ThermometerLabel:    ThermometerPrecise,
-	HumiditySensorLabel: HumiditySensorKeep,
-}
-
-type sensor struct {
-	branding string
-	name     string
-}
-
-type thermometer struct {
-	sensor
-}
-
-type humiditySensor struct {
-	sensor
-}
-
-type Sensor interface {
-	Process(map[string]float64, []float64)
-	Name() string
-	Branding() string
-}
-
-func (s *humiditySensor) Name() string {
-	return s.name
-}
+const (
+	ErrSensorsDiscarded = "some sensors were discarded"
+	ErrFileTooLarge     = "log file too large"
 
-func (s *humiditySensor) Branding() string {
-	return s.branding
-}
+	outputIndent  = "  "
+	logFilePrefix = "log-"
+)
 
-// Process humidity sensor
-// For a humidity sensor, it must be discarded unless it is within 1 humidity percent of the reference value for all readings. (All humidity sensor
-// readings are a decimal value representing percent moisture saturation.)
-//
-// Return value is string of name and branding, already formatted according to the required output format
-func (s *humiditySensor) Process(referenceValues map[string]float64, readings []float64) {
-	referenceHumidity := referenceValues["Humidity"]
-	minHumidity := referenceHumidity - referenceHumidity/100
-	maxHumidity := referenceHumidity + referenceHumidity/100
-
-	// Note: going through all readings again is not super efficient (we've already went through them when parsing the file)
-	// but having Process method makes the code extensible for future new kind of sensors
-	for _, reading := range readings {
-		if reading < minHumidity || reading > maxHumidity {
-			s.branding = HumiditySensorDiscard
-			break
-		}
+// Check if the file of the listing is a log file: it has the log file prefix and one of the extensions,
+// any extension when there are none
+func isLogFileName(name string, extensions []string) bool {
+	if !strings.HasPrefix(name, logFilePrefix) {
+		return false
 	}
-}
-
-func (s *thermometer) Name() string {
-	return s.name
-}
-
-func (s *thermometer) Branding() string {
-	return s.branding
-}
-
-// Process thermometer:
-// For a thermometer, it is branded “ultra precise” if the mean of the readings is within 0.5 degrees of the known temperature,
-// and the standard deviation is less than 3.
-// It is branded “very precise” if the mean is within 0.5 degrees of the room, and the standard deviation is under 5.
-// Otherwise, it’s sold as “precise”.
-//
-// Return value is string of name and branding, already formatted according to the required output format
-func (s *thermometer) Process(referenceValues map[string]float64, readings []float64) {
-	referenceTemperature := referenceValues["Temperature"]
-
-	// we could write the methods for counting mean (trivial) and std deviation (bit more complicated) here,
-	// but who could resist the usage of a library...
-	mean, std := stat.MeanStdDev(readings, nil)
-
-	if mean > referenceTemperature-0.5 && mean < referenceTemperature+0.5 {
-		if std < 3 {
-			s.branding = ThermometerUltraPrecise
-		} else if std < 5 {
-			s.branding = ThermometerVeryPrecise
+	if len(extensions) == 0 {
+		return true
+	}
+	for _, ext := range extensions {
+		if strings.HasSuffix(name, ext) {
+			return true
 		}
 	}
+	return false
 }
 
-// new sensor factory: return new sensor based on the input type
-func NewSensor(sensorType, name string) Sensor {
-	if sensorType == ThermometerLabel {
-		return &thermometer{
-			sensor: sensor{
-				name:     name,
-				branding: defaultBranding[sensorType],
-			},
-		}
-	} else {
-		return &humiditySensor{
-			sensor: sensor{
-				name:     name,
-				branding: defaultBranding[sensorType],
-			},
-		}
-	}
+// Process the log file with sensor readings, identified by file path, using the default configuration.
+// Return the text summarizing the branding of sensors mentioned in the log file
+func processLogFile(filePath string) (string, error) {
+	return processLogFileWithConfig(filePath, Config{})
 }
 
 // Process the log file with sensor readings, identified by file path.
 // Return the text summarizing the branding of sensors mentioned in the log file
-func processLogFile(filePath string) (ret string, err error) {
-	file, err := os.Open(filePath)
+func processLogFileWithConfig(filePath string, cfg Config) (string, error) {
+	cfg = withSidecarReference(cfg)
+	res, err := brandLogFile(context.Background(), filePath, cfg)
 	if err != nil {
-		return ret, errors.Wrap(err, ErrOpenFile)
-	}
-	defer file.Close()
-
-	// Note: if there are more values on reference lines in the future,
-	// it might be better to use an array here so we know the values order...
-	var referenceValues map[string]float64 = map[string]float64{
-		"Temperature": 0.0,
-		"Humidity":    0.0,
-	}
-	var currentReadings []float64 = make([]float64, 0)
-	var currentSensor Sensor
-	var retMap map[string]string = make(map[string]string)
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		l := strings.Split(line, " ")
-		switch l[0] {
-		case ReferenceLabel:
-			if len(l) != len(referenceValues)+1 {
-				return ret, errors.New(fmt.Sprintf(ErrWrongNumberRefFields))
-			}
-			referenceValues["Temperature"], err = strconv.ParseFloat(l[1], 64)
-			if err != nil {
-				return ret, errors.Wrap(err, ErrTempNotFloat)
-			}
-			referenceValues["Humidity"], err = strconv.ParseFloat(l[2], 64)
-			if err != nil {
-				return ret, errors.Wrap(err, ErrHumidityNotFloat)
-			}
-			for k, v := range referenceValues {
-				fmt.Printf("reference value for %s: %.2f\n", k, v)
-			}
-		case ThermometerLabel, HumiditySensorLabel:
-			// hitting the start of some sensor readings: first we must conclude the state
-			// of previously processed sensor (if there was any)
-			if currentSensor != nil {
-				currentSensor.Process(referenceValues, currentReadings)
-				retMap[currentSensor.Name()] = currentSensor.Branding()
-				// it would make sense to save the _sensor_ branding into DB now
-				// (instead of saving log file result)
-			}
-			// and then create a new one
-			currentSensor = NewSensor(l[0], l[1])
-			currentReadings = nil
-		default:
-			if len(l) != readingLineValues {
-				return ret, errors.New(fmt.Sprintf(ErrWrongNumberRedingFields))
-			}
-			reading, err := strconv.ParseFloat(l[1], 64)
-			if err != nil {
-				return ret, errors.Wrap(err, "failed converting current reading to float")
-			}
-			currentReadings = append(currentReadings, reading)
+		return "", err
+	}
+	if cfg.FailOnDiscard {
+		if err := checkDiscarded(res.Brandings()); err != nil {
+			return "", err
+		}
+	}
+	return formatResult(res, cfg), nil
+}
 
+// Return DiscardedSensorsError if any of the sensors has one of the problem brandings
+func checkDiscarded(brandings map[string]string) error {
+	discarded := make([]string, 0)
+	for name, branding := range brandings {
+		if sensors.IsProblem(branding) {
+			discarded = append(discarded, name)
 		}
 	}
-	if err := scanner.Err(); err != nil {
-		return ret, errors.Wrap(err, "error reading the file")
+	if len(discarded) == 0 {
+		return nil
 	}
+	sort.Strings(discarded)
+	return &DiscardedSensorsError{Sensors: discarded}
+}
 
-	// process the last sensor
-	if currentSensor != nil {
-		currentSensor.Process(referenceValues, currentReadings)
-		retMap[currentSensor.Name()] = currentSensor.Branding()
+// Process the log file with sensor readings, identified by file path, until the context is done.
+// Return the branding of the sensors
+func brandLogFile(ctx context.Context, filePath string, cfg Config) (*sensors.Result, error) {
+	if cfg.ProcessingTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.ProcessingTimeout)
+		defer cancel()
 	}
-
-	// is the output format supposed to be a json?
-	// Note: when using retMap, we lose the original order of the sensors in the log file ...
-	j, _ := json.MarshalIndent(retMap, "", outputIndent)
-	ret = string(j)
-	return string(j), nil
+	return sensors.ProcessLogFileContext(ctx, filePath, cfg.Options)
 }
 
 func getRedis() *redis.Client {
@@ -265,11 +138,12 @@ func getHref(t html.Token) (ok bool, href string) {
 // Parse the page like an apache directory listing and look for specific pattern
 // matching the log files.
 // Only return the list of files that were not processed yet.
-// Working with assumption that the files are listed from newest to oldest.
-func getUprocessedLogFiles(dirURL string, rdb *redis.Client) ([]string, error) {
+// Working with assumption that the files are listed from newest to oldest, unless the listing is unsorted:
+// then the whole listing is read and the files are sorted by the date in their names, see sortNewestFirst.
+// Only the files with one of the extensions are the log files, see isLogFileName.
+func getUprocessedLogFiles(client *http.Client, dirURL string, cache Cache, unsorted bool, extensions []string) ([]string, error) {
 	ret := make([]string, 0)
 
-	client := &http.Client{}
 	req, err := http.NewRequest("GET", dirURL, nil)
 	if err != nil {
 		return ret, err
@@ -293,11 +167,23 @@ func getUprocessedLogFiles(dirURL string, rdb *redis.Client) ([]string, error) {
 	defer resp.Body.Close()
 
 	z := html.NewTokenizer(body)
+	// number of links to log files, processed or not
+	candidates := 0
 
 	for {
 		tt := z.Next()
 		switch {
 		case tt == html.ErrorToken:
+			if z.Err() != io.EOF {
+				return ret, errors.Wrap(z.Err(), "failed to read url "+dirURL)
+			}
+			// the page was read fine, but it doesn't look like the directory listing
+			if candidates == 0 {
+				return ret, ErrEmptyListing
+			}
+			if unsorted {
+				sortNewestFirst(ret)
+			}
 			return ret, nil
 		case tt == html.StartTagToken:
 			t := z.Token()
@@ -310,56 +196,149 @@ func getUprocessedLogFiles(dirURL string, rdb *redis.Client) ([]string, error) {
 			if !ok {
 				continue
 			}
-			// Make sure the url begines with right prefix
-			if strings.Index(url, logFilePrefix) != 0 {
+			// Make sure the url begines with right prefix and has the right extension
+			if !isLogFileName(url, extensions) {
 				continue
 			}
+			candidates++
 			// save only items that are not yet cached in redis
-			_, err := rdb.Get(url).Result()
-			if err == redis.Nil {
+			_, err := cache.Get(url)
+			if err == ErrCacheMiss {
 				ret = append(ret, url)
 			} else if err != nil {
 				return ret, errors.Wrap(err, fmt.Sprintf("Error while fetching %s from redis", url))
-			} else {
+			} else if !unsorted {
 				// found the first processed file -> exit the scraping method
-                                // Note: this only works with the assumption about the way files are sorted!!!
+				// Note: this only works with the assumption about the way files are sorted!!!
 				return ret, nil
 			}
 		}
 	}
 }
 
+// Sort the log file names from the newest to the oldest by the date in their names (log-YYYYMMDD...),
+// the names of the same date in reverse order of the names; the names without the date are the oldest ones
+func sortNewestFirst(logFiles []string) {
+	sort.SliceStable(logFiles, func(i, j int) bool {
+		di, iok := logFileDate(logFiles[i])
+		dj, jok := logFileDate(logFiles[j])
+		if iok != jok {
+			return iok
+		}
+		if !di.Equal(dj) {
+			return di.After(dj)
+		}
+		return logFiles[i] > logFiles[j]
+	})
+}
+
 // downloads the given url as a file with "name" under "directory"
-func DownloadFile(url, name, directory string) error {
+// maxSize limits the size of the file in bytes, 0 means no limit
+// An existing file of the same size as the remote one is considered complete and is not downloaded again.
+func DownloadFile(client *http.Client, url, name, directory string, maxSize int64) error {
+	return downloadFile(context.Background(), client, url, name, directory, maxSize)
+}
+
+// DownloadFile with the requests canceled by the context
+func downloadFile(ctx context.Context, client *http.Client, url, name, directory string, maxSize int64) error {
+
+	filePath := path.Join(directory, name)
+	if hasLocalCopy(ctx, client, url, filePath) {
+		return nil
+	}
 
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return err
 	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
 	if resp.StatusCode == http.StatusNotFound {
 		return errors.New(url + " not found")
 	}
-	defer resp.Body.Close()
+	body, err := decodedBody(resp)
+	if err != nil {
+		return errors.Wrap(err, "Failed decoding "+url)
+	}
+	defer body.Close()
+	// Content-Length of compressed body says nothing about the size of the file
+	if maxSize > 0 && body == resp.Body && resp.ContentLength > maxSize {
+		return &FileTooLargeError{URL: url, MaxSize: maxSize}
+	}
 
-	out, err := os.Create(path.Join(directory, name))
+	out, err := os.Create(filePath)
 	if err != nil {
 		return err
 	}
 	defer out.Close()
 
-	_, err = io.Copy(out, resp.Body)
+	if maxSize == 0 {
+		_, err = io.Copy(out, body)
+		if err != nil {
+			out.Close()
+			os.Remove(filePath)
+		}
+		return err
+	}
+	// Content-Length might be missing (or lie), so read at most one byte over the limit to find out;
+	// the limit applies to the decompressed content
+	written, err := io.Copy(out, io.LimitReader(body, maxSize+1))
+	if err == nil && written > maxSize {
+		err = &FileTooLargeError{URL: url, MaxSize: maxSize}
+	}
+	if err != nil {
+		out.Close()
+		os.Remove(filePath)
+	}
 	return err
 }
 
+// Return the body of the response, decompressed when it has gzip Content-Encoding. The transport
+// decompresses it only when it asked for gzip itself, not when Accept-Encoding is among HTTP_HEADERS
+// or the server compresses unasked; the compressed bytes would be stored as the log file then.
+func decodedBody(resp *http.Response) (io.ReadCloser, error) {
+	switch strings.ToLower(resp.Header.Get("Content-Encoding")) {
+	case "gzip", "x-gzip":
+		if resp.Uncompressed {
+			return resp.Body, nil
+		}
+		return gzip.NewReader(resp.Body)
+	default:
+		return resp.Body, nil
+	}
+}
+
+// Check if the file was already downloaded (e.g. before the restart of the worker): it must exist
+// and have the size reported by the server. Any failure means the file has to be downloaded.
+func hasLocalCopy(ctx context.Context, client *http.Client, url, filePath string) bool {
+	info, err := os.Stat(filePath)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return false
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK && resp.ContentLength >= 0 && resp.ContentLength == info.Size()
+}
+
 // from the list of log files, find the oldest one not yet processed
-func findOldestLogFile(logFiles []string, rdb *redis.Client) (string, error) {
+func findOldestLogFile(logFiles []string, cache Cache) (string, error) {
 
 	fileName := ""
 	// we just need to process the list of log files with reverse order
 	for i := len(logFiles) - 1; i >= 0; i-- {
 		logFile := logFiles[i]
-		_, err := rdb.Get(logFile).Result()
-		if err == redis.Nil {
+		_, err := cache.Get(logFile)
+		if err == ErrCacheMiss {
 			fileName = logFile
 			break
 		} else if err != nil {
@@ -370,9 +349,13 @@ func findOldestLogFile(logFiles []string, rdb *redis.Client) (string, error) {
 }
 
 // Fetch the file from remote location and return full path to downloaded file
-func fetchLogFile(logFile, dirURL, tmpDir string) (string, error) {
+func fetchLogFile(ctx context.Context, client *http.Client, logFile, dirURL, tmpDir string, maxSize int64) (string, error) {
 
-	u, err := url.Parse(dirURL + "/")
+	// the log file is relative to the directory, so the URL must end with slash
+	if !strings.HasSuffix(dirURL, "/") {
+		dirURL += "/"
+	}
+	u, err := url.Parse(dirURL)
 	if err != nil {
 		return "", errors.Wrap(err, "Failed parsing URL")
 	}
@@ -380,20 +363,124 @@ func fetchLogFile(logFile, dirURL, tmpDir string) (string, error) {
 	if err != nil {
 		return "", errors.Wrap(err, "Failed parsing URL")
 	}
-	if err := DownloadFile(u.String(), logFile, tmpDir); err != nil {
+	name, err := localFileName(logFile)
+	if err != nil {
+		return "", err
+	}
+	if err := downloadFile(ctx, client, u.String(), name, tmpDir, maxSize); err != nil {
 		return "", errors.Wrap(err, fmt.Sprintf("Failed downloading remote file %s", u.String()))
 	}
-	return filepath.Join(tmpDir, logFile), nil
+	return filepath.Join(tmpDir, name), nil
+}
+
+// Return the name of the local copy of the log file given by its href, in the download directory: the href
+// itself when it's a plain file name, otherwise its path with the segments joined by "_" and prefixed with
+// the short hash of the whole href, so that e.g. a/log-1.txt, b/log-1.txt and log-1.txt?v=2 don't point
+// outside of the directory nor to the same file
+func localFileName(href string) (string, error) {
+	u, err := url.Parse(href)
+	if err != nil {
+		return "", errors.Wrap(err, "Failed parsing URL")
+	}
+	name := path.Base(u.Path)
+	// path.Base drops the trailing slash, but such link is a directory
+	if strings.HasSuffix(u.Path, "/") || name == "." || name == ".." || strings.Contains(name, `\`) {
+		return "", errors.New(fmt.Sprintf("no file name in the link %q", href))
+	}
+	if name == href {
+		return name, nil
+	}
+	segments := strings.FieldsFunc(u.Path, func(r rune) bool { return r == '/' })
+	for i, s := range segments {
+		if s == "." || s == ".." {
+			// the dots would make the name hidden or look like a path
+			segments[i] = "_"
+		}
+	}
+	hash := sha256.Sum256([]byte(href))
+	return hex.EncodeToString(hash[:4]) + "_" + strings.Join(segments, "_"), nil
 }
 
 func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+}
 
-	tmpDir, err := ioutil.TempDir("", "sensor-logs")
+// Parse the command line and run the application
+func run(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("sensors", flag.ContinueOnError)
+	flags.SetOutput(out)
+	showVersion := flags.Bool("version", false, "print the version and exit")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *showVersion {
+		fmt.Fprintln(out, versionString())
+		return nil
+	}
+	if flags.NArg() > 0 {
+		switch flags.Arg(0) {
+		case "process":
+			return runProcess(flags.Args()[1:], out)
+		case "merge":
+			return runMerge(flags.Args()[1:], out)
+		case "preview":
+			return runPreview(flags.Args()[1:], out)
+		case "mqtt":
+			return runMQTT(flags.Args()[1:], out)
+		case "replay":
+			return runReplay(flags.Args()[1:], out)
+		case "stream":
+			return runStream(flags.Args()[1:], out)
+		case "invalidate":
+			return runInvalidate(flags.Args()[1:], out)
+		case "selftest":
+			return selfTest(out)
+		default:
+			return errors.New(fmt.Sprintf("unknown command %q", flags.Arg(0)))
+		}
+	}
+	// refuse to start when the branding doesn't work as expected in this environment
+	check, err := envBool("SELF_TEST", false)
 	if err != nil {
-		fmt.Printf("Error while creating temp directory: %s\n", err.Error())
+		return err
+	}
+	if check {
+		if err := selfTest(out); err != nil {
+			return err
+		}
+	}
+	runWorker()
+	return nil
+}
+
+// Process the log files from remote directory as they appear, forever
+func runWorker() {
+
+	var err error
+	// persistent download directory lets the worker reuse the files downloaded before restart
+	tmpDir, exists := os.LookupEnv("DOWNLOAD_DIR")
+	if exists {
+		if err := os.MkdirAll(tmpDir, 0755); err != nil {
+			fmt.Printf("Error while creating download directory: %s\n", err.Error())
+			return
+		}
+	} else {
+		tmpDir, err = ioutil.TempDir("", "sensor-logs")
+		if err != nil {
+			fmt.Printf("Error while creating temp directory: %s\n", err.Error())
+			return
+		}
+		defer os.RemoveAll(tmpDir)
+	}
+
+	remoteDir, exists := os.LookupEnv("REMOTE_LOGS_DIR")
+	if !exists {
+		fmt.Println("Remote directory with log files not provided!")
 		return
 	}
-	defer os.RemoveAll(tmpDir)
 
 	// Use redis for storing the output and checking if given file was already processed
 	// NOTE better design would use some locking to prevent processing the same file by multiple workers
@@ -405,54 +492,103 @@ func main() {
 		return
 	}
 
-	remoteDir, exists := os.LookupEnv("REMOTE_LOGS_DIR")
-	if !exists {
-		fmt.Println("Remote directory with log files not provided!")
+	cfg, err := configFromEnv()
+	if err != nil {
+		fmt.Printf("Error reading configuration: %s\n", err.Error())
+		return
+	}
+	redisStore := newRedisCache(rdb, redisKeyPrefix(envString("REDIS_KEY_PREFIX", ""), remoteDir))
+	var cache Cache = newTimedCache(redisStore, cfg.RedisSlowThreshold)
+	if cfg.RedisMaxConcurrency > 0 {
+		cache = newLimitedCache(cache, cfg.RedisMaxConcurrency)
+	}
+	if cfg.RedisBufferSize > 0 {
+		cache = newBufferedCache(cache, cfg.RedisBufferSize)
+	}
+	tracerProvider, err := newTracerProvider(context.Background())
+	if err != nil {
+		fmt.Printf("Error reading configuration: %s\n", err.Error())
 		return
 	}
+	if tracerProvider != nil {
+		otel.SetTracerProvider(tracerProvider)
+		defer tracerProvider.Shutdown(context.Background())
+	}
 
-	// Note: main loop is missing some health check method...
-	// (probably by running http server via goroutine)
-	for {
-		time.Sleep(10 * time.Second)
-		logFiles, err := getUprocessedLogFiles(remoteDir, rdb)
-		if err != nil {
-			fmt.Printf("Error fetching log files: %s\n", err.Error())
+	port, exists := os.LookupEnv("HTTP_PORT")
+	if !exists {
+		port = defaultHTTPPort
+	}
+	// the results rarely change once stored, so the endpoint can keep the recent ones in memory for a while;
+	// the results of the invalidated files are processed again (and written by the worker past this cache)
+	var results Cache = cache
+	cacheSize, err := envInt("RESULTS_CACHE_SIZE", defaultResultsCacheSize)
+	if err != nil {
+		fmt.Printf("Error reading configuration: %s\n", err.Error())
+		return
+	}
+	cacheTTL, err := envDuration("RESULTS_CACHE_TTL", defaultResultsCacheTTL)
+	if err != nil {
+		fmt.Printf("Error reading configuration: %s\n", err.Error())
+		return
+	}
+	if cacheTTL < 0 {
+		fmt.Println("Error reading configuration: RESULTS_CACHE_TTL must not be negative")
+		return
+	}
+	if cacheSize > 0 {
+		if results, err = newLRUCache(cache, cacheSize, cacheTTL); err != nil {
+			fmt.Println(err.Error())
 			return
 		}
-		fmt.Printf("got log files: %v\n", logFiles)
-		if len(logFiles) == 0 {
-			fmt.Println("no new log files")
-			time.Sleep(10 * time.Second)
-			continue
+	}
+	go func() {
+		if err := http.ListenAndServe(":"+port, newServer(results)); err != nil {
+			fmt.Printf("Error running HTTP server: %s\n", err.Error())
 		}
+	}()
 
-		fileName, err := findOldestLogFile(logFiles, rdb)
-		if err != nil {
-			fmt.Printf("Failed checking available the log files: %s\n", err.Error())
-			return
-		}
-		if fileName == "" {
-			fmt.Println("no new log file")
-			time.Sleep(10 * time.Second)
-			continue
-		}
-		filePath, err := fetchLogFile(fileName, remoteDir, tmpDir)
-		if err != nil {
-			fmt.Printf("Failed fetching latest log file: %s\n", err.Error())
-			return
-		}
+	cfg.Store = cache
+	if cfg.IncludeReadings {
+		fmt.Println("Warning: INCLUDE_READINGS is set, the results contain all readings and may get very large")
+	}
 
-		processed, err := processLogFile(filePath)
+	source, err := newLogSource(cfg, newHTTPClient(cfg.HTTPHeaders, cfg.RateLimit), remoteDir)
+	if err != nil {
+		fmt.Printf("Error reading configuration: %s\n", err.Error())
+		return
+	}
+	sink, err := newOutputSink(cfg.OutputSink, cfg.OutputCompression)
+	if err != nil {
+		fmt.Printf("Error reading configuration: %s\n", err.Error())
+		return
+	}
+	w := &worker{
+		cfg:    cfg,
+		cache:  cache,
+		source: newBreakerSource(source, cfg.BreakerFailures, cfg.BreakerTimeout),
+		alerts: newAlerter(cfg.AlertWebhookURL, cfg.AlertBrandings),
+		sink:   sink,
+		tmpDir: tmpDir,
+		out:    os.Stdout,
+
+		remoteDir:        remoteDir,
+		pollInterval:     pollInterval,
+		progressInterval: progressInterval,
+	}
 
-		if err != nil {
-			fmt.Printf("Error processing log file: %s\n", err.Error())
-			// should we exit now or just proceed with next one?
-			// actually let's write the error, otherwise we'll loop on this one forever
-			rdb.Set(fileName, err.Error(), 0)
-		} else {
-			rdb.Set(fileName, processed, 0)
-			fmt.Println(processed)
-		}
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		fmt.Println("shutting down after the current log file")
+		close(stop)
+	}()
+	w.stop = stop
+	if cfg.HeartbeatInterval > 0 {
+		go runHeartbeat(redisStore, workerID(), cfg.HeartbeatInterval, stop)
 	}
+	w.run()
+	w.alerts.close()
 }
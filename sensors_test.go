@@ -1,10 +1,21 @@
 package main
 
 import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
+
+	"sensors/pkg/sensors"
 )
 
 func assertError(t testing.TB, got error, want error) {
@@ -23,6 +34,14 @@ func assertErrorMessageSubString(t testing.TB, got error, want string) {
 	}
 }
 
+func assertSubString(t testing.TB, got string, want string) {
+	t.Helper()
+
+	if !strings.Contains(got, want) {
+		t.Errorf("got %q, want to contain %q", got, want)
+	}
+}
+
 func assertString(t testing.TB, got string, want string) {
 	t.Helper()
 
@@ -31,6 +50,14 @@ func assertString(t testing.TB, got string, want string) {
 	}
 }
 
+func assertInt(t testing.TB, got int, want int) {
+	t.Helper()
+
+	if got != want {
+		t.Errorf("got %d, want %d", got, want)
+	}
+}
+
 func writeTestLogFile(tmpFile *os.File, content string) error {
 	if err := os.WriteFile(tmpFile.Name(), []byte(content), 0666); err != nil {
 		return err
@@ -42,7 +69,7 @@ func TestLogFileErrors(t *testing.T) {
 
 	t.Run("no such file", func(t *testing.T) {
 		_, err := processLogFile("nofile.txt")
-		assertErrorMessageSubString(t, err, ErrOpenFile)
+		assertErrorMessageSubString(t, err, sensors.ErrOpenFile)
 	})
 }
 
@@ -61,7 +88,7 @@ func TestReferenceLineErrors(t *testing.T) {
 			return
 		}
 		_, err := processLogFile(tmpFile.Name())
-		assertErrorMessageSubString(t, err, ErrWrongNumberRefFields)
+		assertErrorMessageSubString(t, err, sensors.ErrWrongNumberRefFields)
 	})
 
 	t.Run("wrong temp type", func(t *testing.T) {
@@ -70,7 +97,7 @@ func TestReferenceLineErrors(t *testing.T) {
 			return
 		}
 		_, err := processLogFile(tmpFile.Name())
-		assertErrorMessageSubString(t, err, ErrTempNotFloat)
+		assertErrorMessageSubString(t, err, sensors.ErrTempNotFloat)
 	})
 
 	t.Run("wrong humidity type", func(t *testing.T) {
@@ -79,7 +106,7 @@ func TestReferenceLineErrors(t *testing.T) {
 			return
 		}
 		_, err := processLogFile(tmpFile.Name())
-		assertErrorMessageSubString(t, err, ErrHumidityNotFloat)
+		assertErrorMessageSubString(t, err, sensors.ErrHumidityNotFloat)
 	})
 }
 
@@ -100,6 +127,9 @@ thermometer temp-1
 2007-04-05T22:02 0`
 const tempPrecise02 = `reference 100 0
 thermometer temp-1`
+const tempSingleReading = `reference 100 0
+thermometer temp-1
+2007-04-05T22:00 100`
 
 func TestThermometers(t *testing.T) {
 	tmpFile, err := ioutil.TempFile("", "sensors")
@@ -167,6 +197,18 @@ func TestThermometers(t *testing.T) {
 }`)
 	})
 
+	t.Run("temp ultra precise (single reading)", func(t *testing.T) {
+		if err := writeTestLogFile(tmpFile, tempSingleReading); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		val, err := processLogFile(tmpFile.Name())
+		assertError(t, err, nil)
+		assertString(t, val, `{
+  "temp-1": "ultra precise"
+}`)
+	})
+
 }
 
 const humSensorKeep01 = `reference 0 45
@@ -182,6 +224,21 @@ const humSensorDiscard01 = `reference 0 45
 humidity hum-1
 2007 45.5`
 
+const humSensorZeroRefKeep = `reference 0 0
+humidity hum-1
+2007 0.05
+2007 0.1`
+
+const humSensorZeroRefDiscard = `reference 0 0
+humidity hum-1
+2007 0.05
+2007 0.5`
+
+const humSensorSmallRefKeep = `reference 0 5
+humidity hum-1
+2007 5.1
+2007 4.9`
+
 func TestHumiditySensors(t *testing.T) {
 	tmpFile, err := ioutil.TempFile("", "sensors")
 	if err != nil {
@@ -214,6 +271,42 @@ func TestHumiditySensors(t *testing.T) {
 }`)
 	})
 
+	t.Run("humidity sensor keep (zero reference)", func(t *testing.T) {
+		if err := writeTestLogFile(tmpFile, humSensorZeroRefKeep); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		val, err := processLogFile(tmpFile.Name())
+		assertError(t, err, nil)
+		assertString(t, val, `{
+  "hum-1": "keep"
+}`)
+	})
+
+	t.Run("humidity sensor discard (zero reference)", func(t *testing.T) {
+		if err := writeTestLogFile(tmpFile, humSensorZeroRefDiscard); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		val, err := processLogFile(tmpFile.Name())
+		assertError(t, err, nil)
+		assertString(t, val, `{
+  "hum-1": "discard"
+}`)
+	})
+
+	t.Run("humidity sensor keep (minimal band)", func(t *testing.T) {
+		if err := writeTestLogFile(tmpFile, humSensorSmallRefKeep); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		val, err := processLogFile(tmpFile.Name())
+		assertError(t, err, nil)
+		assertString(t, val, `{
+  "hum-1": "keep"
+}`)
+	})
+
 	t.Run("humidity sensor discard", func(t *testing.T) {
 		if err := writeTestLogFile(tmpFile, humSensorDiscard01); err != nil {
 			t.Error("Error writing test log file")
@@ -226,3 +319,249 @@ func TestHumiditySensors(t *testing.T) {
 }`)
 	})
 }
+
+func TestFailOnDiscard(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "sensors")
+	if err != nil {
+		t.Error("Error creating test log file")
+		return
+	}
+	defer os.Remove(tmpFile.Name())
+
+	cfg := Config{FailOnDiscard: true}
+
+	t.Run("sensor discarded", func(t *testing.T) {
+		if err := writeTestLogFile(tmpFile, humSensorDiscard01); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		_, err := processLogFileWithConfig(tmpFile.Name(), cfg)
+		var discardErr *DiscardedSensorsError
+		if !errors.As(err, &discardErr) {
+			t.Fatalf("got error %v, want DiscardedSensorsError", err)
+		}
+		assertInt(t, len(discardErr.Sensors), 1)
+		assertString(t, discardErr.Sensors[0], "hum-1")
+		assertErrorMessageSubString(t, err, ErrSensorsDiscarded)
+	})
+
+	t.Run("no sensor discarded", func(t *testing.T) {
+		if err := writeTestLogFile(tmpFile, humSensorKeep01); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		_, err := processLogFileWithConfig(tmpFile.Name(), cfg)
+		assertError(t, err, nil)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		if err := writeTestLogFile(tmpFile, humSensorDiscard01); err != nil {
+			t.Error("Error writing test log file")
+			return
+		}
+		_, err := processLogFile(tmpFile.Name())
+		assertError(t, err, nil)
+	})
+}
+
+func TestDownloadFileSize(t *testing.T) {
+	content := strings.Repeat("2007-04-05T22:00 100\n", 100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/chunked" {
+			// no Content-Length when flushing before writing the whole body
+			w.(http.Flusher).Flush()
+		}
+		fmt.Fprint(w, content)
+	}))
+	defer server.Close()
+
+	tmpDir, err := ioutil.TempDir("", "sensor-logs")
+	if err != nil {
+		t.Fatal("Error creating temp directory")
+	}
+	defer os.RemoveAll(tmpDir)
+	client := newHTTPClient(nil, 0)
+
+	t.Run("within limit", func(t *testing.T) {
+		err := DownloadFile(client, server.URL+"/plain", "log-1.txt", tmpDir, int64(len(content)))
+		assertError(t, err, nil)
+	})
+
+	for _, p := range []string{"/plain", "/chunked"} {
+		t.Run("over limit "+p, func(t *testing.T) {
+			err := DownloadFile(client, server.URL+p, "log-2.txt", tmpDir, int64(len(content)-1))
+			var sizeErr *FileTooLargeError
+			if !errors.As(err, &sizeErr) {
+				t.Fatalf("got error %v, want FileTooLargeError", err)
+			}
+			assertErrorMessageSubString(t, err, ErrFileTooLarge)
+			if _, err := os.Stat(filepath.Join(tmpDir, "log-2.txt")); !os.IsNotExist(err) {
+				t.Error("partially downloaded file was not removed")
+			}
+		})
+	}
+}
+
+func TestDownloadExistingFile(t *testing.T) {
+	content := tempUltraPrecise
+	gets := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			gets++
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		fmt.Fprint(w, content)
+	}))
+	defer server.Close()
+
+	tmpDir, err := ioutil.TempDir("", "sensor-logs")
+	if err != nil {
+		t.Fatal("Error creating temp directory")
+	}
+	defer os.RemoveAll(tmpDir)
+	client := newHTTPClient(nil, 0)
+	filePath := filepath.Join(tmpDir, "log-1.txt")
+
+	t.Run("complete local copy", func(t *testing.T) {
+		if err := os.WriteFile(filePath, []byte(content), 0666); err != nil {
+			t.Fatal("Error writing local copy")
+		}
+		err := DownloadFile(client, server.URL+"/log-1.txt", "log-1.txt", tmpDir, 0)
+		assertError(t, err, nil)
+		assertInt(t, gets, 0)
+	})
+
+	t.Run("incomplete local copy", func(t *testing.T) {
+		if err := os.WriteFile(filePath, []byte(content[:10]), 0666); err != nil {
+			t.Fatal("Error writing local copy")
+		}
+		err := DownloadFile(client, server.URL+"/log-1.txt", "log-1.txt", tmpDir, 0)
+		assertError(t, err, nil)
+		assertInt(t, gets, 1)
+		got, _ := os.ReadFile(filePath)
+		assertString(t, string(got), content)
+	})
+}
+
+func TestDownloadGzipEncoded(t *testing.T) {
+	content := tempUltraPrecise
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// compressed regardless of Accept-Encoding, as some servers do for .txt files
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		fmt.Fprint(zw, content)
+		zw.Close()
+	}))
+	defer server.Close()
+
+	tmpDir, err := ioutil.TempDir("", "sensor-logs")
+	if err != nil {
+		t.Fatal("Error creating temp directory")
+	}
+	defer os.RemoveAll(tmpDir)
+	filePath := filepath.Join(tmpDir, "log-1.txt")
+
+	clients := map[string]*http.Client{
+		"transport asking for gzip": newHTTPClient(nil, 0),
+		"header asking for gzip":    newHTTPClient(map[string]string{"Accept-Encoding": "gzip"}, 0),
+		"no compression asked":      {Transport: &http.Transport{DisableCompression: true}},
+	}
+	for name, client := range clients {
+		t.Run(name, func(t *testing.T) {
+			os.Remove(filePath)
+			err := DownloadFile(client, server.URL+"/log-1.txt", "log-1.txt", tmpDir, int64(len(content)))
+			assertError(t, err, nil)
+			got, _ := os.ReadFile(filePath)
+			assertString(t, string(got), content)
+		})
+	}
+
+	t.Run("limit of the decompressed size", func(t *testing.T) {
+		os.Remove(filePath)
+		client := clients["header asking for gzip"]
+		err := DownloadFile(client, server.URL+"/log-1.txt", "log-1.txt", tmpDir, int64(len(content)-1))
+		var sizeErr *FileTooLargeError
+		if !errors.As(err, &sizeErr) {
+			t.Fatalf("got error %v, want FileTooLargeError", err)
+		}
+	})
+}
+
+func TestFetchLogFileName(t *testing.T) {
+	requested := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.RequestURI())
+		fmt.Fprint(w, tempUltraPrecise)
+	}))
+	defer server.Close()
+
+	tmpDir, err := ioutil.TempDir("", "sensor-logs")
+	if err != nil {
+		t.Fatal("Error creating temp directory")
+	}
+	defer os.RemoveAll(tmpDir)
+	client := newHTTPClient(nil, 0)
+
+	cases := []struct {
+		href, wantURI, wantName string
+	}{
+		{"log-1.txt", "/logs/log-1.txt", "log-1.txt"},
+		{"sub/log-2.txt", "/logs/sub/log-2.txt", "24772d62_sub_log-2.txt"},
+		{"log-3.txt?v=2", "/logs/log-3.txt?v=2", "cc045b32_log-3.txt"},
+		{"../log-4.txt", "/log-4.txt", "ee6567ad___log-4.txt"},
+		{"/archive/log-5.txt?v=1#top", "/archive/log-5.txt?v=1", "b4bcc099_archive_log-5.txt"},
+	}
+	for _, c := range cases {
+		t.Run(c.href, func(t *testing.T) {
+			requested = requested[:0]
+			filePath, err := fetchLogFile(context.Background(), client, c.href, server.URL+"/logs", tmpDir, 0)
+			assertError(t, err, nil)
+			// the full URL is downloaded, into the file of its own in the download directory
+			assertString(t, strings.Join(requested, " "), c.wantURI)
+			assertString(t, filePath, filepath.Join(tmpDir, c.wantName))
+			got, _ := os.ReadFile(filePath)
+			assertString(t, string(got), tempUltraPrecise)
+		})
+	}
+
+	t.Run("no collisions", func(t *testing.T) {
+		names := make(map[string]string)
+		for _, href := range []string{"log-1.txt", "a/log-1.txt", "b/log-1.txt", "log-1.txt?v=1", "log-1.txt?v=2", "a_log-1.txt"} {
+			name, err := localFileName(href)
+			assertError(t, err, nil)
+			if other, ok := names[name]; ok {
+				t.Errorf("hrefs %q and %q have the same local file %q", other, href, name)
+			}
+			names[name] = href
+		}
+	})
+
+	for _, href := range []string{"sub/", "?v=2", ".."} {
+		t.Run("no file name "+href, func(t *testing.T) {
+			_, err := fetchLogFile(context.Background(), client, href, server.URL+"/logs", tmpDir, 0)
+			assertErrorMessageSubString(t, err, "no file name")
+		})
+	}
+}
+
+func TestProcessingTimeout(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "sensors")
+	if err != nil {
+		t.Error("Error creating test log file")
+		return
+	}
+	defer os.Remove(tmpFile.Name())
+	if err := writeTestLogFile(tmpFile, tempUltraPrecise); err != nil {
+		t.Error("Error writing test log file")
+		return
+	}
+
+	_, err = processLogFileWithConfig(tmpFile.Name(), Config{ProcessingTimeout: time.Nanosecond})
+	assertErrorMessageSubString(t, err, sensors.ErrProcessingAborted)
+
+	val, err := processLogFileWithConfig(tmpFile.Name(), Config{ProcessingTimeout: time.Minute})
+	assertError(t, err, nil)
+	assertString(t, val, `{
+  "temp-1": "ultra precise"
+}`)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	resultsPath     = "/results"
+	healthPath      = "/healthz"
+	defaultHTTPPort = "8080"
+	// header with the hash of the brandings, see sensors.Result.Hash
+	resultHashHeader = "X-Result-Hash"
+	// number of results kept in memory by the endpoint, and for how long
+	defaultResultsCacheSize = 1000
+	defaultResultsCacheTTL  = time.Minute
+)
+
+// Build the HTTP handler serving the stored results:
+//
+//	/results         lists the recently processed files (newest first)
+//	/results/{file}  returns the branding stored for given file, with its hash in X-Result-Hash header
+//	/brandings/{branding}?since=168h
+//	                 lists the sensors with the branding in the recent log files, see BRANDING_INDEX
+//	/healthz         health check, also reporting the version
+//	/metrics         Prometheus metrics
+func newServer(cache Cache) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(healthPath, health)
+	mux.Handle(metricsPath, promhttp.Handler())
+	mux.HandleFunc(resultsPath, func(w http.ResponseWriter, r *http.Request) {
+		listResults(w, r, cache)
+	})
+	mux.HandleFunc(resultsPath+"/", func(w http.ResponseWriter, r *http.Request) {
+		getResult(w, r, cache)
+	})
+	mux.HandleFunc(brandingsPath+"/", func(w http.ResponseWriter, r *http.Request) {
+		listBranding(w, r, cache)
+	})
+	return mux
+}
+
+func listResults(w http.ResponseWriter, r *http.Request, cache Cache) {
+	files, err := cache.List(recentFilesKey, maxRecentFiles)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed reading recent files: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	if files == nil {
+		files = make([]string, 0)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(files)
+}
+
+func getResult(w http.ResponseWriter, r *http.Request, cache Cache) {
+	file := strings.TrimPrefix(r.URL.Path, resultsPath+"/")
+	if file == "" {
+		listResults(w, r, cache)
+		return
+	}
+	val, err := cache.Get(file)
+	if err == ErrCacheMiss {
+		http.Error(w, fmt.Sprintf("no result for %s", file), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("failed reading result of %s: %s", file, err.Error()), http.StatusInternalServerError)
+		return
+	}
+	if hash, err := cache.Get(resultHashKeyPrefix + file); err == nil {
+		w.Header().Set(resultHashHeader, hash)
+	}
+	// files that failed processing have the error message stored instead of json
+	if json.Valid([]byte(val)) {
+		w.Header().Set("Content-Type", "application/json")
+	} else {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	fmt.Fprint(w, val)
+}
+
+type healthStatus struct {
+	Status  string `json:"status"`
+	Version string `json:"version"`
+	Commit  string `json:"commit"`
+}
+
+func health(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(healthStatus{Status: "ok", Version: version, Commit: commit})
+}
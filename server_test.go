@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResultsEndpoint(t *testing.T) {
+	cache := newMemCache()
+	storeResult(cache, "log-1.txt", `{"temp-1": "precise"}`)
+	storeResult(cache, "log-2.txt", "reference line has incorrect number of fields")
+	server := newServer(cache)
+
+	t.Run("get result", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest("GET", "/results/log-1.txt", nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("got status %d, want %d", rec.Code, http.StatusOK)
+		}
+		assertString(t, rec.Header().Get("Content-Type"), "application/json")
+		assertString(t, rec.Body.String(), `{"temp-1": "precise"}`)
+	})
+
+	t.Run("get failed result", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest("GET", "/results/log-2.txt", nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("got status %d, want %d", rec.Code, http.StatusOK)
+		}
+		assertString(t, rec.Body.String(), "reference line has incorrect number of fields")
+	})
+
+	t.Run("unknown file", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest("GET", "/results/log-3.txt", nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("got status %d, want %d", rec.Code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("list results", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest("GET", "/results", nil))
+		var files []string
+		if err := json.Unmarshal(rec.Body.Bytes(), &files); err != nil {
+			t.Fatalf("failed decoding response %q: %s", rec.Body.String(), err)
+		}
+		if len(files) != 2 {
+			t.Fatalf("got %d files, want 2", len(files))
+		}
+		assertString(t, files[0], "log-2.txt")
+		assertString(t, files[1], "log-1.txt")
+	})
+}
+
+func TestHealthEndpoint(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newServer(newMemCache()).ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("got status %d, want %d", rec.Code, http.StatusOK)
+	}
+	var status healthStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatalf("failed decoding response %q: %s", rec.Body.String(), err)
+	}
+	assertString(t, status.Status, "ok")
+	assertString(t, status.Version, version)
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const defaultSFTPPort = "22"
+
+// remoteFS is the part of SFTP client used by sftpSource; having an interface here makes it possible
+// to test the source without SFTP server
+type remoteFS interface {
+	ReadDir(p string) ([]os.FileInfo, error)
+	Open(p string) (io.ReadCloser, error)
+	Close() error
+}
+
+// sftpFS is remoteFS of SFTP server
+type sftpFS struct {
+	conn   *ssh.Client
+	client *sftp.Client
+}
+
+func (fs *sftpFS) ReadDir(p string) ([]os.FileInfo, error) {
+	return fs.client.ReadDir(p)
+}
+
+func (fs *sftpFS) Open(p string) (io.ReadCloser, error) {
+	return fs.client.Open(p)
+}
+
+func (fs *sftpFS) Close() error {
+	fs.client.Close()
+	return fs.conn.Close()
+}
+
+// sftpSource is the directory on SFTP server; the log files are sorted by the modification time.
+// It's safe for concurrent use, the session is established again when the server drops it.
+type sftpSource struct {
+	// connect opens new session; nil means the session can't be established again
+	connect     func() (remoteFS, error)
+	mu          sync.Mutex
+	fs          remoteFS
+	dir         string
+	maxFileSize int64
+	extensions  []string
+}
+
+// Connect to SFTP server given by URL like sftp://user@host:22/path/to/logs, authenticating by the password
+// or private key from the configuration. The server's host key must be in the known hosts file.
+func newSFTPSource(cfg Config, dirURL string) (*sftpSource, error) {
+	u, err := url.Parse(dirURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed parsing URL")
+	}
+	if u.Scheme != SourceSFTP || u.User == nil {
+		return nil, errors.New(fmt.Sprintf("invalid SFTP URL %q, expected sftp://user@host/path", dirURL))
+	}
+	if cfg.SFTPKnownHosts == "" {
+		return nil, errors.New("SFTP_KNOWN_HOSTS must be set for the SFTP log source")
+	}
+	hostKeyCallback, err := knownhosts.New(cfg.SFTPKnownHosts)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed reading SFTP known hosts")
+	}
+	auth := make([]ssh.AuthMethod, 0)
+	if cfg.SFTPKeyFile != "" {
+		key, err := ioutil.ReadFile(cfg.SFTPKeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed reading SFTP key")
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed parsing SFTP key")
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if cfg.SFTPPassword != "" {
+		auth = append(auth, ssh.Password(cfg.SFTPPassword))
+	}
+	if len(auth) == 0 {
+		return nil, errors.New("SFTP_PASSWORD or SFTP_KEY_FILE must be set for the SFTP log source")
+	}
+
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), defaultSFTPPort)
+	}
+	connect := func() (remoteFS, error) {
+		conn, err := ssh.Dial("tcp", host, &ssh.ClientConfig{
+			User:            u.User.Username(),
+			Auth:            auth,
+			HostKeyCallback: hostKeyCallback,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed connecting to SFTP server "+host)
+		}
+		client, err := sftp.NewClient(conn)
+		if err != nil {
+			conn.Close()
+			return nil, errors.Wrap(err, "failed starting SFTP session")
+		}
+		return &sftpFS{conn: conn, client: client}, nil
+	}
+	fs, err := connect()
+	if err != nil {
+		return nil, err
+	}
+	return &sftpSource{connect: connect, fs: fs, dir: u.Path, maxFileSize: cfg.MaxFileSize,
+		extensions: cfg.LogFileExtensions}, nil
+}
+
+// Return the current session
+func (s *sftpSource) session() remoteFS {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fs
+}
+
+// Establish new session in place of the failed one, unless the error is the answer of the server (like
+// a missing file) and the session is fine; tell if the failed operation should be retried
+func (s *sftpSource) reconnect(failed remoteFS, err error) bool {
+	var status *sftp.StatusError
+	if s.connect == nil || errors.As(err, &status) || os.IsNotExist(err) || os.IsPermission(err) {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fs != failed {
+		// already established again by concurrent operation
+		return true
+	}
+	fs, cerr := s.connect()
+	if cerr != nil {
+		fmt.Printf("Error reconnecting to SFTP server: %s\n", cerr.Error())
+		return false
+	}
+	failed.Close()
+	s.fs = fs
+	return true
+}
+
+func (s *sftpSource) Unprocessed(cache Cache) ([]string, error) {
+	fs := s.session()
+	files, err := fs.ReadDir(s.dir)
+	if err != nil && s.reconnect(fs, err) {
+		files, err = s.session().ReadDir(s.dir)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed listing SFTP directory "+s.dir)
+	}
+	// newest first, same as the html listing
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].ModTime().After(files[j].ModTime())
+	})
+
+	ret := make([]string, 0)
+	candidates := 0
+	for _, f := range files {
+		if !f.Mode().IsRegular() || !isLogFileName(f.Name(), s.extensions) {
+			continue
+		}
+		candidates++
+		_, err := cache.Get(f.Name())
+		if err == ErrCacheMiss {
+			ret = append(ret, f.Name())
+		} else if err != nil {
+			return ret, errors.Wrap(err, fmt.Sprintf("Error while fetching %s from redis", f.Name()))
+		}
+	}
+	if candidates == 0 {
+		return ret, ErrEmptyListing
+	}
+	return ret, nil
+}
+
+// The SFTP client can't cancel the download, so the context is not used
+func (s *sftpSource) Fetch(ctx context.Context, logFile, dir string) (string, error) {
+	remotePath := path.Join(s.dir, logFile)
+	fs := s.session()
+	in, err := fs.Open(remotePath)
+	if err != nil && s.reconnect(fs, err) {
+		in, err = s.session().Open(remotePath)
+	}
+	if err != nil {
+		return "", errors.Wrap(err, "Failed opening remote file "+remotePath)
+	}
+	defer in.Close()
+
+	filePath := filepath.Join(dir, logFile)
+	out, err := os.Create(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+
+	var r io.Reader = in
+	if s.maxFileSize > 0 {
+		// read at most one byte over the limit to find out
+		r = io.LimitReader(in, s.maxFileSize+1)
+	}
+	written, err := io.Copy(out, r)
+	if err == nil && s.maxFileSize > 0 && written > s.maxFileSize {
+		err = &FileTooLargeError{URL: remotePath, MaxSize: s.maxFileSize}
+	}
+	if err != nil {
+		out.Close()
+		os.Remove(filePath)
+		return "", err
+	}
+	return filePath, nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+)
+
+// localFS is remoteFS of local directory, standing in for the SFTP server
+type localFS struct{}
+
+func (localFS) ReadDir(p string) ([]os.FileInfo, error) {
+	return ioutil.ReadDir(p)
+}
+
+func (localFS) Open(p string) (io.ReadCloser, error) {
+	return os.Open(p)
+}
+
+func (localFS) Close() error {
+	return nil
+}
+
+// droppedFS is remoteFS whose session was dropped by the server
+type droppedFS struct {
+	closed *bool
+}
+
+func (droppedFS) ReadDir(p string) ([]os.FileInfo, error) {
+	return nil, sftp.ErrSSHFxConnectionLost
+}
+
+func (droppedFS) Open(p string) (io.ReadCloser, error) {
+	return nil, sftp.ErrSSHFxConnectionLost
+}
+
+func (fs droppedFS) Close() error {
+	*fs.closed = true
+	return nil
+}
+
+func TestSFTPSource(t *testing.T) {
+	remoteDir, err := ioutil.TempDir("", "sftp-remote")
+	if err != nil {
+		t.Fatal("Error creating temp directory")
+	}
+	defer os.RemoveAll(remoteDir)
+	modified := time.Date(2021, 11, 1, 10, 0, 0, 0, time.UTC)
+	for i, name := range []string{"log-2.txt", "log-1.txt", "notes.txt", "log-3.txt"} {
+		filePath := filepath.Join(remoteDir, name)
+		if err := os.WriteFile(filePath, []byte(tempUltraPrecise), 0666); err != nil {
+			t.Fatal("Error writing remote file")
+		}
+		os.Chtimes(filePath, modified, modified.Add(time.Duration(i)*time.Hour))
+	}
+	if err := os.Mkdir(filepath.Join(remoteDir, "log-dir"), 0755); err != nil {
+		t.Fatal("Error creating remote directory")
+	}
+	source := &sftpSource{fs: localFS{}, dir: remoteDir}
+
+	t.Run("sorted by modification time", func(t *testing.T) {
+		cache := newMemCache()
+		cache.Set("log-1.txt", "{}")
+		logFiles, err := source.Unprocessed(cache)
+		assertError(t, err, nil)
+		assertString(t, strings.Join(logFiles, ","), "log-3.txt,log-2.txt")
+	})
+
+	t.Run("fetch", func(t *testing.T) {
+		w := newTestWorker(t, source)
+		err := w.processFile("log-3.txt")
+		assertError(t, err, nil)
+		val, _ := w.cache.Get("log-3.txt")
+		assertString(t, val, `{
+  "temp-1": "ultra precise"
+}`)
+	})
+
+	t.Run("file too large", func(t *testing.T) {
+		tmpDir, err := ioutil.TempDir("", "sensor-logs")
+		if err != nil {
+			t.Fatal("Error creating temp directory")
+		}
+		defer os.RemoveAll(tmpDir)
+		limited := &sftpSource{fs: localFS{}, dir: remoteDir, maxFileSize: 10}
+		_, err = limited.Fetch(context.Background(), "log-3.txt", tmpDir)
+		var sizeErr *FileTooLargeError
+		if !errors.As(err, &sizeErr) {
+			t.Fatalf("got error %v, want FileTooLargeError", err)
+		}
+		if _, err := os.Stat(filepath.Join(tmpDir, "log-3.txt")); !os.IsNotExist(err) {
+			t.Error("partially downloaded file was not removed")
+		}
+	})
+
+	t.Run("session dropped", func(t *testing.T) {
+		closed := false
+		connects := 0
+		dropped := &sftpSource{fs: droppedFS{closed: &closed}, dir: remoteDir, connect: func() (remoteFS, error) {
+			connects++
+			return localFS{}, nil
+		}}
+		logFiles, err := dropped.Unprocessed(newMemCache())
+		assertError(t, err, nil)
+		assertInt(t, len(logFiles), 3)
+		assertInt(t, connects, 1)
+		if !closed {
+			t.Error("dropped session was not closed")
+		}
+
+		dropped.fs = droppedFS{closed: &closed}
+		w := newTestWorker(t, dropped)
+		assertError(t, w.processFile("log-3.txt"), nil)
+		assertInt(t, connects, 2)
+	})
+
+	t.Run("missing file keeps the session", func(t *testing.T) {
+		connects := 0
+		reconnecting := &sftpSource{fs: localFS{}, dir: remoteDir, connect: func() (remoteFS, error) {
+			connects++
+			return localFS{}, nil
+		}}
+		w := newTestWorker(t, reconnecting)
+		if _, err := reconnecting.Fetch(context.Background(), "log-4.txt", w.tmpDir); err == nil {
+			t.Error("expected error fetching missing file")
+		}
+		assertInt(t, connects, 0)
+	})
+
+	t.Run("configuration", func(t *testing.T) {
+		_, err := newLogSource(Config{SourceType: SourceSFTP}, nil, "sftp://host/logs")
+		assertErrorMessageSubString(t, err, "invalid SFTP URL")
+		_, err = newLogSource(Config{SourceType: SourceSFTP, SFTPPassword: "secret"}, nil, "sftp://user@host/logs")
+		assertErrorMessageSubString(t, err, "SFTP_KNOWN_HOSTS")
+	})
+}
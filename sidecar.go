@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"sensors/pkg/sensors"
+)
+
+// how long the request for the sidecar file may take, so that a hanging server doesn't hang the worker
+const sidecarTimeout = 10 * time.Second
+
+// Read the reference values from the sidecar file cfg.ReferenceFile, a local path or http(s) URL;
+// the requests carry cfg.HTTPHeaders, as the sidecar is usually next to the log files
+func loadSidecarReference(cfg Config) (map[string]float64, error) {
+	var r io.ReadCloser
+	if strings.HasPrefix(cfg.ReferenceFile, "http://") || strings.HasPrefix(cfg.ReferenceFile, "https://") {
+		client := newHTTPClient(cfg.HTTPHeaders, 0)
+		client.Timeout = sidecarTimeout
+		resp, err := client.Get(cfg.ReferenceFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read url "+cfg.ReferenceFile)
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, errors.New(fmt.Sprintf("failed to read url %s: %s", cfg.ReferenceFile, resp.Status))
+		}
+		r = resp.Body
+	} else {
+		f, err := os.Open(cfg.ReferenceFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed opening reference file")
+		}
+		r = f
+	}
+	defer r.Close()
+	ref, err := sensors.ReadReference(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid reference file "+cfg.ReferenceFile)
+	}
+	return ref, nil
+}
+
+// Return the configuration with the reference values of the sidecar file, when there's one; it's read
+// again for every log file, so that the changes apply without restart, but only for the log files
+// without the reference line, see sensors.Options.ReferenceLoader. Its failure is SidecarReferenceError.
+func withSidecarReference(cfg Config) Config {
+	if cfg.ReferenceFile == "" {
+		return cfg
+	}
+	sidecar := cfg
+	cfg.ReferenceLoader = func() (map[string]float64, error) {
+		ref, err := loadSidecarReference(sidecar)
+		if err != nil {
+			return nil, &SidecarReferenceError{Err: err}
+		}
+		return ref, nil
+	}
+	return cfg
+}
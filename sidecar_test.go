@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestSidecarReference(t *testing.T) {
+	// temp-1 is ultra precise only against the reference of the sidecar
+	log := "thermometer temp-1\n2007-04-05T22:00 70.1\n2007-04-05T22:01 69.9\n"
+	reference := "reference 70.0 45.0\n"
+	available := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<html><body><a href="log-1.txt">log-1.txt</a></body></html>`)
+		case "/log-1.txt":
+			fmt.Fprint(w, log)
+		case "/reference.txt":
+			if !available {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			fmt.Fprint(w, reference)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	t.Run("url", func(t *testing.T) {
+		w := newTestWorker(t, &htmlSource{client: newHTTPClient(nil, 0), dirURL: server.URL + "/"})
+		w.cfg.ReferenceFile = server.URL + "/reference.txt"
+		assertError(t, w.processFile("log-1.txt"), nil)
+		val, _ := w.cache.Get("log-1.txt")
+		assertString(t, val, `{
+  "temp-1": "ultra precise"
+}`)
+	})
+
+	t.Run("unavailable", func(t *testing.T) {
+		available = false
+		defer func() { available = true }()
+		w := newTestWorker(t, &htmlSource{client: newHTTPClient(nil, 0), dirURL: server.URL + "/"})
+		w.cfg.ReferenceFile = server.URL + "/reference.txt"
+		err := w.processFile("log-1.txt")
+		assertErrorMessageSubString(t, err, "503")
+		// tried again later
+		_, err = w.cache.Get("log-1.txt")
+		assertError(t, err, ErrCacheMiss)
+	})
+
+	t.Run("unavailable but not needed", func(t *testing.T) {
+		available = false
+		defer func() { available = true }()
+		log = reference + log
+		defer func() { log = log[len(reference):] }()
+		w := newTestWorker(t, &htmlSource{client: newHTTPClient(nil, 0), dirURL: server.URL + "/"})
+		w.cfg.ReferenceFile = server.URL + "/reference.txt"
+		assertError(t, w.processFile("log-1.txt"), nil)
+		val, _ := w.cache.Get("log-1.txt")
+		assertString(t, val, `{
+  "temp-1": "ultra precise"
+}`)
+	})
+
+	t.Run("local file", func(t *testing.T) {
+		refFile, err := ioutil.TempFile("", "reference")
+		if err != nil {
+			t.Fatal("Error creating reference file")
+		}
+		defer os.Remove(refFile.Name())
+		if err := writeTestLogFile(refFile, reference); err != nil {
+			t.Fatal("Error writing reference file")
+		}
+		logFile, err := ioutil.TempFile("", "sensors")
+		if err != nil {
+			t.Fatal("Error creating test log file")
+		}
+		defer os.Remove(logFile.Name())
+		if err := writeTestLogFile(logFile, log); err != nil {
+			t.Fatal("Error writing test log file")
+		}
+		val, err := processLogFileWithConfig(logFile.Name(), Config{ReferenceFile: refFile.Name()})
+		assertError(t, err, nil)
+		assertString(t, val, `{
+  "temp-1": "ultra precise"
+}`)
+		_, err = processLogFileWithConfig(logFile.Name(), Config{ReferenceFile: logFile.Name()})
+		assertErrorMessageSubString(t, err, "invalid reference file")
+	})
+}
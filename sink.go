@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	SinkStdout = "stdout"
+	// prefix of the file sink, followed by the path template
+	sinkFilePrefix = "file:"
+	// placeholder in the path template of the file sink, replaced by the log file name
+	sinkFileNamePlaceholder = "{name}"
+)
+
+const (
+	// CompressionNone writes the results as they are, the default
+	CompressionNone = "none"
+	// CompressionGzip gzips the results written to files and POSTed to URLs
+	CompressionGzip = "gzip"
+)
+
+// OutputSink is where the results of processed log files are written, besides the cache
+type OutputSink interface {
+	// Write the result of the log file; the context cancels the write
+	Write(ctx context.Context, logFile, result string) error
+}
+
+// Create the output sink given by its specification: comma separated list (see splitList) of SinkStdout,
+// file:<path template> or http(s) URL to POST the results to. With several sinks, each result
+// is written to all of them. With CompressionGzip, the file and http sinks gzip the results; stdout
+// stays readable.
+func newOutputSink(spec, compression string) (OutputSink, error) {
+	specs := splitList(spec)
+	if len(specs) <= 1 {
+		return newSingleSink(strings.Join(specs, ""), compression)
+	}
+	ret := &multiSink{}
+	for _, s := range specs {
+		sink, err := newSingleSink(s, compression)
+		if err != nil {
+			return nil, err
+		}
+		ret.specs = append(ret.specs, s)
+		ret.sinks = append(ret.sinks, sink)
+	}
+	return ret, nil
+}
+
+// Create one output sink of the OUTPUT_SINK list
+func newSingleSink(spec, compression string) (OutputSink, error) {
+	gzipped := compression == CompressionGzip
+	switch {
+	case spec == SinkStdout:
+		return &writerSink{out: os.Stdout}, nil
+	case strings.HasPrefix(spec, sinkFilePrefix):
+		template := strings.TrimPrefix(spec, sinkFilePrefix)
+		if !strings.Contains(template, sinkFileNamePlaceholder) {
+			return nil, errors.New(fmt.Sprintf("invalid value of OUTPUT_SINK: path template %q must contain %s", template, sinkFileNamePlaceholder))
+		}
+		return &fileSink{template: template, gzip: gzipped}, nil
+	case strings.HasPrefix(spec, "http://"), strings.HasPrefix(spec, "https://"):
+		return &httpSink{url: spec, client: &http.Client{Timeout: 10 * time.Second}, gzip: gzipped}, nil
+	}
+	return nil, errors.New(fmt.Sprintf("invalid value of OUTPUT_SINK: %q", spec))
+}
+
+// multiSink writes the results to several sinks; a failing sink doesn't keep the result from the others
+type multiSink struct {
+	// specs of the sinks, for the error messages
+	specs []string
+	sinks []OutputSink
+}
+
+func (s *multiSink) Write(ctx context.Context, logFile, result string) error {
+	failed := make([]string, 0)
+	for i, sink := range s.sinks {
+		if err := sink.Write(ctx, logFile, result); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", s.specs[i], err.Error()))
+		}
+	}
+	if len(failed) > 0 {
+		return errors.New(fmt.Sprintf("%d of %d sinks failed: %s", len(failed), len(s.sinks), strings.Join(failed, "; ")))
+	}
+	return nil
+}
+
+// writerSink prints the results, one after another
+type writerSink struct {
+	out io.Writer
+}
+
+func (s *writerSink) Write(ctx context.Context, logFile, result string) error {
+	_, err := fmt.Fprintln(s.out, result)
+	return err
+}
+
+// fileSink writes each result into its own file, named by the template with the log file name
+// in place of {name}, e.g. /var/results/{name}.json
+type fileSink struct {
+	template string
+	// gzip the files; the template should then end with .gz
+	gzip bool
+}
+
+func (s *fileSink) Write(ctx context.Context, logFile, result string) error {
+	filePath := strings.ReplaceAll(s.template, sinkFileNamePlaceholder, filepath.Base(logFile))
+	data := []byte(result)
+	if s.gzip {
+		var err error
+		if data, err = gzipBytes(data); err != nil {
+			return errors.Wrap(err, "failed compressing result")
+		}
+	}
+	if err := os.WriteFile(filePath, data, 0644); err != nil {
+		return errors.Wrap(err, "failed writing result")
+	}
+	return nil
+}
+
+// payload POSTed by httpSink
+type sinkResult struct {
+	File   string `json:"file"`
+	Result string `json:"result"`
+}
+
+// httpSink POSTs each result to the URL
+type httpSink struct {
+	url    string
+	client *http.Client
+	// gzip the payload, sent with Content-Encoding: gzip
+	gzip bool
+}
+
+func (s *httpSink) Write(ctx context.Context, logFile, result string) error {
+	body, err := json.Marshal(sinkResult{File: logFile, Result: result})
+	if err != nil {
+		return errors.Wrap(err, "failed creating result payload")
+	}
+	if s.gzip {
+		if body, err = gzipBytes(body); err != nil {
+			return errors.Wrap(err, "failed compressing result payload")
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "failed creating request to "+s.url)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed sending result to "+s.url)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.New(fmt.Sprintf("failed sending result to %s: unexpected response status %s", s.url, resp.Status))
+	}
+	return nil
+}
+
+// gzipBytes returns the data compressed by gzip
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
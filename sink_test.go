@@ -0,0 +1,151 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileSink(t *testing.T) {
+	files := []string{"log-1.txt"}
+	server := newTestRemoteDir(files, tempUltraPrecise)
+	defer server.Close()
+	outDir, err := ioutil.TempDir("", "sensor-results")
+	if err != nil {
+		t.Fatal("Error creating temp directory")
+	}
+	defer os.RemoveAll(outDir)
+
+	sink, err := newOutputSink("file:"+filepath.Join(outDir, "{name}.json"), CompressionNone)
+	assertError(t, err, nil)
+	w := newTestWorker(t, &htmlSource{client: newHTTPClient(nil, 0), dirURL: server.URL + "/"})
+	w.sink = sink
+	err = w.processFile("log-1.txt")
+	assertError(t, err, nil)
+
+	result, err := os.ReadFile(filepath.Join(outDir, "log-1.txt.json"))
+	assertError(t, err, nil)
+	assertString(t, string(result), `{
+  "temp-1": "ultra precise"
+}`)
+}
+
+func TestHTTPSink(t *testing.T) {
+	var received sinkResult
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &received); err != nil {
+			t.Errorf("failed decoding result %q: %s", body, err)
+		}
+	}))
+	defer server.Close()
+
+	sink, err := newOutputSink(server.URL+"/results", CompressionNone)
+	assertError(t, err, nil)
+	err = sink.Write(context.Background(), "log-1.txt", `{"temp-1": "precise"}`)
+	assertError(t, err, nil)
+	assertString(t, received.File, "log-1.txt")
+	assertString(t, received.Result, `{"temp-1": "precise"}`)
+
+	t.Run("error status", func(t *testing.T) {
+		failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer failing.Close()
+		sink, _ := newOutputSink(failing.URL, CompressionNone)
+		err := sink.Write(context.Background(), "log-1.txt", "{}")
+		assertErrorMessageSubString(t, err, "unexpected response status 500")
+	})
+}
+
+func TestMultiSink(t *testing.T) {
+	outDir, err := ioutil.TempDir("", "sensor-results")
+	if err != nil {
+		t.Fatal("Error creating temp directory")
+	}
+	defer os.RemoveAll(outDir)
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	// the failing sink goes first, so that the file sink is written only if the failure is isolated
+	sink, err := newOutputSink(failing.URL+", file:"+filepath.Join(outDir, "{name}.json"), CompressionNone)
+	assertError(t, err, nil)
+	err = sink.Write(context.Background(), "log-1.txt", `{"temp-1": "precise"}`)
+	assertErrorMessageSubString(t, err, "1 of 2 sinks failed: "+failing.URL+": ")
+	assertErrorMessageSubString(t, err, "unexpected response status 500")
+
+	result, err := os.ReadFile(filepath.Join(outDir, "log-1.txt.json"))
+	assertError(t, err, nil)
+	assertString(t, string(result), `{"temp-1": "precise"}`)
+}
+
+func TestCompressedSinks(t *testing.T) {
+	outDir, err := ioutil.TempDir("", "sensor-results")
+	if err != nil {
+		t.Fatal("Error creating temp directory")
+	}
+	defer os.RemoveAll(outDir)
+	var received sinkResult
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "gzip" {
+			t.Errorf("got Content-Encoding %q, want gzip", r.Header.Get("Content-Encoding"))
+		}
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Errorf("failed reading compressed result: %s", err)
+			return
+		}
+		if err := json.NewDecoder(zr).Decode(&received); err != nil {
+			t.Errorf("failed decoding result: %s", err)
+		}
+	}))
+	defer server.Close()
+
+	sink, err := newOutputSink(server.URL+", file:"+filepath.Join(outDir, "{name}.json.gz"), CompressionGzip)
+	assertError(t, err, nil)
+	err = sink.Write(context.Background(), "log-1.txt", `{"temp-1": "precise"}`)
+	assertError(t, err, nil)
+	assertString(t, received.File, "log-1.txt")
+	assertString(t, received.Result, `{"temp-1": "precise"}`)
+
+	f, err := os.Open(filepath.Join(outDir, "log-1.txt.json.gz"))
+	assertError(t, err, nil)
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	assertError(t, err, nil)
+	result, err := ioutil.ReadAll(zr)
+	assertError(t, err, nil)
+	assertString(t, string(result), `{"temp-1": "precise"}`)
+}
+
+func TestOutputSinkSpec(t *testing.T) {
+	_, err := newOutputSink(SinkStdout, CompressionNone)
+	assertError(t, err, nil)
+	_, err = newOutputSink("file:/tmp/result.json", CompressionNone)
+	assertErrorMessageSubString(t, err, "must contain {name}")
+	_, err = newOutputSink("kafka://broker", CompressionNone)
+	assertErrorMessageSubString(t, err, "invalid value of OUTPUT_SINK")
+	_, err = newOutputSink("stdout,kafka://broker", CompressionNone)
+	assertErrorMessageSubString(t, err, `invalid value of OUTPUT_SINK: "kafka://broker"`)
+
+	// the commas of the URLs are escaped
+	sink, err := newOutputSink(`http://example.com/results?sensors=1\,2`, CompressionNone)
+	assertError(t, err, nil)
+	assertString(t, sink.(*httpSink).url, "http://example.com/results?sensors=1,2")
+	sink, err = newOutputSink(`stdout, http://example.com/results?sensors=1\,2`, CompressionNone)
+	assertError(t, err, nil)
+	assertInt(t, len(sink.(*multiSink).sinks), 2)
+	assertString(t, sink.(*multiSink).specs[1], "http://example.com/results?sensors=1,2")
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	SourceHTML = "html"
+	SourceJSON = "json"
+	SourceSFTP = "sftp"
+)
+
+// ErrEmptyListing is returned by LogSource.Unprocessed when the listing was read fine but there are
+// no log files at all, as opposed to no unprocessed ones; it may mean a wrong URL or a server
+// serving some other page
+var ErrEmptyListing = errors.New("remote directory listing contains no log files")
+
+// LogSource is the remote location with log files
+type LogSource interface {
+	// Unprocessed returns the log files that were not processed yet, newest first, or ErrEmptyListing
+	// when there are no log files in the listing
+	Unprocessed(cache Cache) ([]string, error)
+	// Fetch downloads the log file into the directory and returns the path to the downloaded file;
+	// the context cancels the download
+	Fetch(ctx context.Context, logFile, dir string) (string, error)
+}
+
+// Create the log source of the configured type
+func newLogSource(cfg Config, client *http.Client, dirURL string) (LogSource, error) {
+	switch cfg.SourceType {
+	case SourceHTML:
+		return &htmlSource{client: client, dirURL: dirURL, maxFileSize: cfg.MaxFileSize, unsorted: cfg.UnsortedListing,
+			extensions: cfg.LogFileExtensions}, nil
+	case SourceJSON:
+		return &jsonSource{client: client, dirURL: dirURL, maxFileSize: cfg.MaxFileSize, extensions: cfg.LogFileExtensions}, nil
+	case SourceSFTP:
+		return newSFTPSource(cfg, dirURL)
+	}
+	return nil, errors.New(fmt.Sprintf("unknown log source type %q", cfg.SourceType))
+}
+
+// htmlSource is the directory listing like the one served by apache, with files sorted from newest to oldest,
+// unless it's unsorted
+type htmlSource struct {
+	client      *http.Client
+	dirURL      string
+	maxFileSize int64
+	unsorted    bool
+	extensions  []string
+}
+
+func (s *htmlSource) Unprocessed(cache Cache) ([]string, error) {
+	return getUprocessedLogFiles(s.client, s.dirURL, cache, s.unsorted, s.extensions)
+}
+
+func (s *htmlSource) Fetch(ctx context.Context, logFile, dir string) (string, error) {
+	return fetchLogFile(ctx, s.client, logFile, s.dirURL, dir, s.maxFileSize)
+}
+
+// jsonSource is the directory index served as json array of the files with their modification time:
+//
+//	[{"name": "log-1.txt", "modified": "2021-11-05T20:51:38Z"}, ...]
+//
+// The order of the files in the index doesn't matter, they are sorted by the modification time.
+type jsonSource struct {
+	client      *http.Client
+	dirURL      string
+	maxFileSize int64
+	extensions  []string
+}
+
+type jsonIndexEntry struct {
+	Name     string    `json:"name"`
+	Modified time.Time `json:"modified"`
+}
+
+func (s *jsonSource) Unprocessed(cache Cache) ([]string, error) {
+	resp, err := s.client.Get(s.dirURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read url "+s.dirURL)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(fmt.Sprintf("failed to read url %s: %s", s.dirURL, resp.Status))
+	}
+
+	var index []jsonIndexEntry
+	if err := json.NewDecoder(resp.Body).Decode(&index); err != nil {
+		return nil, errors.Wrap(err, "failed decoding json index "+s.dirURL)
+	}
+	// newest first, same as the html listing
+	sort.SliceStable(index, func(i, j int) bool {
+		return index[i].Modified.After(index[j].Modified)
+	})
+
+	ret := make([]string, 0)
+	candidates := 0
+	for _, entry := range index {
+		if !isLogFileName(entry.Name, s.extensions) {
+			continue
+		}
+		candidates++
+		_, err := cache.Get(entry.Name)
+		if err == ErrCacheMiss {
+			ret = append(ret, entry.Name)
+		} else if err != nil {
+			return ret, errors.Wrap(err, fmt.Sprintf("Error while fetching %s from redis", entry.Name))
+		}
+	}
+	if candidates == 0 {
+		return ret, ErrEmptyListing
+	}
+	return ret, nil
+}
+
+func (s *jsonSource) Fetch(ctx context.Context, logFile, dir string) (string, error) {
+	return fetchLogFile(ctx, s.client, logFile, s.dirURL, dir, s.maxFileSize)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const jsonIndex = `[
+  {"name": "log-1.txt", "modified": "2021-11-01T10:00:00Z"},
+  {"name": "log-3.txt", "modified": "2021-11-03T10:00:00Z"},
+  {"name": "index.html", "modified": "2021-11-04T10:00:00Z"},
+  {"name": "log-2.txt", "modified": "2021-11-02T10:00:00Z"}
+]`
+
+func TestJSONSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/files/":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, jsonIndex)
+		case "/files/log-3.txt":
+			fmt.Fprint(w, tempUltraPrecise)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	source, err := newLogSource(Config{SourceType: SourceJSON}, newHTTPClient(nil, 0), server.URL+"/files/")
+	assertError(t, err, nil)
+
+	t.Run("sorted by modification time", func(t *testing.T) {
+		cache := newMemCache()
+		cache.Set("log-2.txt", "{}")
+		logFiles, err := source.Unprocessed(cache)
+		assertError(t, err, nil)
+		assertString(t, strings.Join(logFiles, ","), "log-3.txt,log-1.txt")
+	})
+
+	t.Run("fetch", func(t *testing.T) {
+		w := newTestWorker(t, source)
+		err := w.processFile("log-3.txt")
+		assertError(t, err, nil)
+		val, _ := w.cache.Get("log-3.txt")
+		assertString(t, val, `{
+  "temp-1": "ultra precise"
+}`)
+	})
+
+	t.Run("unknown source type", func(t *testing.T) {
+		_, err := newLogSource(Config{SourceType: "xml"}, newHTTPClient(nil, 0), server.URL)
+		assertErrorMessageSubString(t, err, "unknown log source type")
+	})
+}
+
+func TestEmptyListing(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/unrelated/":
+			fmt.Fprint(w, `<html><body><a href="/">Home</a> <a href="index.html">Index</a></body></html>`)
+		case "/processed/":
+			fmt.Fprint(w, `<html><body><a href="log-1.txt">log-1.txt</a></body></html>`)
+		case "/unrelated.json":
+			fmt.Fprint(w, `[{"name": "index.html", "modified": "2021-11-04T10:00:00Z"}]`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	cache := newMemCache()
+	cache.Set("log-1.txt", "{}")
+
+	t.Run("only unrelated links", func(t *testing.T) {
+		source, _ := newLogSource(Config{SourceType: SourceHTML}, newHTTPClient(nil, 0), server.URL+"/unrelated/")
+		_, err := source.Unprocessed(cache)
+		assertError(t, err, ErrEmptyListing)
+	})
+
+	t.Run("all processed", func(t *testing.T) {
+		source, _ := newLogSource(Config{SourceType: SourceHTML}, newHTTPClient(nil, 0), server.URL+"/processed/")
+		logFiles, err := source.Unprocessed(cache)
+		assertError(t, err, nil)
+		assertInt(t, len(logFiles), 0)
+	})
+
+	t.Run("json index", func(t *testing.T) {
+		source, _ := newLogSource(Config{SourceType: SourceJSON}, newHTTPClient(nil, 0), server.URL+"/unrelated.json")
+		_, err := source.Unprocessed(cache)
+		assertError(t, err, ErrEmptyListing)
+	})
+}
+
+func TestUnsortedListing(t *testing.T) {
+	// oldest first, with a processed file in the middle
+	listing := `<html><body>
+<a href="log-20211101-b.txt">log-20211101-b.txt</a>
+<a href="log-20211102.txt">log-20211102.txt</a>
+<a href="log-old.txt">log-old.txt</a>
+<a href="log-20211103.txt">log-20211103.txt</a>
+<a href="log-20211101-a.txt">log-20211101-a.txt</a>
+<a href="log-20211104.txt">log-20211104.txt</a>
+</body></html>`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, listing)
+	}))
+	defer server.Close()
+	cache := newMemCache()
+	cache.Set("log-20211103.txt", "{}")
+
+	t.Run("sorted", func(t *testing.T) {
+		source, err := newLogSource(Config{SourceType: SourceHTML}, newHTTPClient(nil, 0), server.URL+"/")
+		assertError(t, err, nil)
+		logFiles, err := source.Unprocessed(cache)
+		assertError(t, err, nil)
+		// stops at the processed file
+		assertString(t, strings.Join(logFiles, ","), "log-20211101-b.txt,log-20211102.txt,log-old.txt")
+	})
+
+	t.Run("unsorted", func(t *testing.T) {
+		source, err := newLogSource(Config{SourceType: SourceHTML, UnsortedListing: true}, newHTTPClient(nil, 0), server.URL+"/")
+		assertError(t, err, nil)
+		logFiles, err := source.Unprocessed(cache)
+		assertError(t, err, nil)
+		assertString(t, strings.Join(logFiles, ","), "log-20211104.txt,log-20211102.txt,log-20211101-b.txt,log-20211101-a.txt,log-old.txt")
+	})
+}
+
+func TestLogFileExtensions(t *testing.T) {
+	listing := `<html><body>
+<a href="log-index.html">log-index.html</a>
+<a href="log-3.txt">log-3.txt</a>
+<a href="log-readme.md">log-readme.md</a>
+<a href="log-2.log">log-2.log</a>
+<a href="log-1.txt.gz">log-1.txt.gz</a>
+</body></html>`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, listing)
+	}))
+	defer server.Close()
+
+	cfg := Config{SourceType: SourceHTML, LogFileExtensions: []string{".txt", ".log", ".gz"}}
+	source, err := newLogSource(cfg, newHTTPClient(nil, 0), server.URL+"/")
+	assertError(t, err, nil)
+	logFiles, err := source.Unprocessed(newMemCache())
+	assertError(t, err, nil)
+	assertString(t, strings.Join(logFiles, ","), "log-3.txt,log-2.log,log-1.txt.gz")
+
+	// any extension by default
+	source, err = newLogSource(Config{SourceType: SourceHTML}, newHTTPClient(nil, 0), server.URL+"/")
+	assertError(t, err, nil)
+	logFiles, err = source.Unprocessed(newMemCache())
+	assertError(t, err, nil)
+	assertInt(t, len(logFiles), 5)
+}
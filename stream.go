@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/pkg/errors"
+
+	"sensors/pkg/sensors"
+)
+
+// sizeLimitReader fails with FileTooLargeError once more than maxSize bytes were read
+type sizeLimitReader struct {
+	r       io.Reader
+	url     string
+	maxSize int64
+	read    int64
+}
+
+func (r *sizeLimitReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.read += int64(n)
+	if r.read > r.maxSize {
+		return n, &FileTooLargeError{URL: r.url, MaxSize: r.maxSize}
+	}
+	return n, err
+}
+
+// stream subcommand: brand the sensors of the log file as its body comes over HTTP, without downloading it first,
+// e.g. the file still being written; the branding of each sensor is printed as soon as its block of readings
+// is complete, then the whole result as the process command does
+func runStream(args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("usage: sensors stream URL")
+	}
+	cfg, err := configFromEnv()
+	if err != nil {
+		return errors.Wrap(err, "Error reading configuration")
+	}
+	// there's no REDIS to keep the state between the files
+	if cfg.UseBaseline || cfg.PreviousReference || cfg.InheritReference || cfg.HysteresisMargin > 0 {
+		return errors.New("USE_BASELINE, PREVIOUS_REFERENCE, INHERIT_REFERENCE and HYSTERESIS_MARGIN are not supported by the stream command")
+	}
+	// both wait for the whole file, the sensors wouldn't be branded as they come
+	if cfg.EndMarker != "" || cfg.ReferenceAnywhere {
+		return errors.New("END_MARKER and REFERENCE_ANYWHERE are not supported by the stream command")
+	}
+	cfg = withSidecarReference(cfg)
+	res, err := streamLogFile(newHTTPClient(cfg.HTTPHeaders, cfg.RateLimit), args[0], cfg, func(r sensors.SensorResult) error {
+		if len(filterBrandings(map[string]string{r.Name: r.Branding}, cfg.OutputFilter)) == 0 {
+			return nil
+		}
+		name := r.Name
+		if cfg.anonymizer != nil {
+			name = cfg.anonymizer.hash(name)
+		}
+		_, err := fmt.Fprintf(out, "%s: %s\n", name, r.Branding)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if cfg.FailOnDiscard {
+		if err := checkDiscarded(res.Brandings()); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintln(out, formatResult(res, cfg))
+	return nil
+}
+
+// Brand the sensors of the log file at the URL while its body is read, see sensors.ProcessStream; the limit
+// of MAX_FILE_SIZE applies to the streamed body as to the downloaded file. PROCESSING_TIMEOUT is not used,
+// the file still being written may take any time to complete.
+func streamLogFile(client *http.Client, url string, cfg Config, sensorDone func(sensors.SensorResult) error) (*sensors.Result, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read url "+url)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(fmt.Sprintf("failed to read url %s: %s", url, resp.Status))
+	}
+	body, err := decodedBody(resp)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed decoding "+url)
+	}
+	defer body.Close()
+	var r io.Reader = body
+	if cfg.MaxFileSize > 0 {
+		r = &sizeLimitReader{r: body, url: url, maxSize: cfg.MaxFileSize}
+	}
+	return sensors.ProcessStream(context.Background(), r, cfg.Options, sensorDone)
+}
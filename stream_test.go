@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+// notifyingWriter sends each write to the channel
+type notifyingWriter chan string
+
+func (w notifyingWriter) Write(p []byte) (int, error) {
+	w <- string(p)
+	return len(p), nil
+}
+
+func TestStreamCommand(t *testing.T) {
+	branded := make(chan bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the chunked body, with the rest of the file written only after temp-1 was branded
+		fmt.Fprint(w, "reference 100 45\nthermometer temp-1\n2007-04-05T22:00 100\nhumidity hum-1\n")
+		w.(http.Flusher).Flush()
+		select {
+		case <-branded:
+		case <-time.After(5 * time.Second):
+		}
+		fmt.Fprint(w, "2007-04-05T22:00 47\n")
+	}))
+	defer server.Close()
+
+	t.Run("branded as it comes", func(t *testing.T) {
+		out := make(notifyingWriter, 10)
+		done := make(chan error, 1)
+		go func() { done <- run([]string{"stream", server.URL + "/log-1.txt"}, out) }()
+		select {
+		case line := <-out:
+			assertString(t, line, "temp-1: ultra precise\n")
+		case <-time.After(2 * time.Second):
+			t.Fatal("temp-1 not branded before the end of the body")
+		}
+		close(branded)
+		assertError(t, <-done, nil)
+		assertString(t, <-out, "hum-1: discard\n")
+		assertString(t, <-out, `{
+  "hum-1": "discard",
+  "temp-1": "ultra precise"
+}
+`)
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		os.Setenv("MAX_FILE_SIZE", "10")
+		defer os.Unsetenv("MAX_FILE_SIZE")
+		err := run([]string{"stream", server.URL + "/log-1.txt"}, ioutil.Discard)
+		var sizeErr *FileTooLargeError
+		if !errors.As(err, &sizeErr) {
+			t.Errorf("got error %v, want FileTooLargeError", err)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		missing := httptest.NewServer(http.NotFoundHandler())
+		defer missing.Close()
+		err := run([]string{"stream", missing.URL + "/log-1.txt"}, ioutil.Discard)
+		assertErrorMessageSubString(t, err, "404")
+	})
+
+	t.Run("whole file settings", func(t *testing.T) {
+		for _, name := range []string{"END_MARKER", "REFERENCE_ANYWHERE"} {
+			os.Setenv(name, "true")
+			err := run([]string{"stream", server.URL + "/log-1.txt"}, ioutil.Discard)
+			os.Unsetenv(name)
+			assertErrorMessageSubString(t, err, "not supported by the stream command")
+		}
+	})
+
+	t.Run("usage", func(t *testing.T) {
+		err := run([]string{"stream"}, ioutil.Discard)
+		assertErrorMessageSubString(t, err, "usage")
+	})
+}
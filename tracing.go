@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// name of the tracer, and the service name of the spans unless OTEL_SERVICE_NAME is set
+const tracerName = "sensors"
+
+// Create the tracer provider exporting the spans over OTLP/HTTP, configured by the standard
+// OTEL_EXPORTER_OTLP_* environment variables; nil when there's no OTLP endpoint, the spans then go nowhere
+func newTracerProvider(ctx context.Context) (*sdktrace.TracerProvider, error) {
+	if envString("OTEL_EXPORTER_OTLP_ENDPOINT", "") == "" && envString("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "") == "" {
+		return nil, nil
+	}
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed creating OTLP exporter")
+	}
+	res, err := resource.New(ctx, resource.WithAttributes(semconv.ServiceNameKey.String(tracerName)), resource.WithFromEnv())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed creating OpenTelemetry resource")
+	}
+	return sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res)), nil
+}
+
+// Start the span of a stage of the worker, as a child of the span in the context
+func (w *worker) startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	tracer := w.tracer
+	if tracer == nil {
+		tracer = otel.Tracer(tracerName)
+	}
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End the span of a stage, which failed if err is not nil
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
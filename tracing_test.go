@@ -0,0 +1,108 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracing(t *testing.T) {
+	server := newTestRemoteDir([]string{"log-1.txt"}, tempUltraPrecise)
+	defer server.Close()
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	w := newTestWorker(t, &htmlSource{client: newHTTPClient(nil, 0), dirURL: server.URL + "/"})
+	w.tracer = provider.Tracer(tracerName)
+
+	err := w.processFile("log-1.txt")
+	assertError(t, err, nil)
+
+	// the stages end before the whole processing
+	spans := exporter.GetSpans()
+	assertInt(t, len(spans), 4)
+	root := spans[3]
+	assertString(t, root.Name, "process log file")
+	for i, name := range []string{"download", "brand", "store"} {
+		assertString(t, spans[i].Name, name)
+		if spans[i].Parent.SpanID() != root.SpanContext.SpanID() {
+			t.Errorf("span %s is not a child of the processing span", name)
+		}
+	}
+	found := false
+	for _, a := range root.Attributes {
+		if string(a.Key) == "log.file" && a.Value.AsString() == "log-1.txt" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("got attributes %v, want the log file", root.Attributes)
+	}
+}
+
+func TestTracingFailures(t *testing.T) {
+	server := newTestRemoteDir([]string{"log-1.txt"}, "reference 100\nthermometer temp-1\n2007-04-05T22:00 100\n")
+	defer server.Close()
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	t.Run("processing failed", func(t *testing.T) {
+		exporter.Reset()
+		w := newTestWorker(t, &htmlSource{client: newHTTPClient(nil, 0), dirURL: server.URL + "/"})
+		w.tracer = provider.Tracer(tracerName)
+		// the file is marked as processed with the failure
+		assertError(t, w.processFile("log-1.txt"), nil)
+		spans := exporter.GetSpans()
+		assertInt(t, len(spans), 4)
+		assertString(t, spans[3].Name, "process log file")
+		if spans[3].Status.Code != codes.Error {
+			t.Errorf("got status %v of the processing span, want error", spans[3].Status)
+		}
+	})
+
+	t.Run("store failed", func(t *testing.T) {
+		exporter.Reset()
+		w := newTestWorker(t, &htmlSource{client: newHTTPClient(nil, 0), dirURL: server.URL + "/"})
+		w.tracer = provider.Tracer(tracerName)
+		w.cache = &flakyCache{memCache: newMemCache(), down: true}
+		assertError(t, w.processFile("log-1.txt"), nil)
+		spans := exporter.GetSpans()
+		assertInt(t, len(spans), 4)
+		for _, s := range spans[2:] {
+			if s.Status.Code != codes.Error {
+				t.Errorf("got status %v of the span %s, want error", s.Status, s.Name)
+			}
+		}
+	})
+}
+
+func TestTracingPoll(t *testing.T) {
+	server := newTestRemoteDir([]string{"log-2.txt", "log-1.txt"}, tempUltraPrecise)
+	defer server.Close()
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	w := newTestWorker(t, &htmlSource{client: newHTTPClient(nil, 0), dirURL: server.URL + "/"})
+	w.tracer = provider.Tracer(tracerName)
+
+	if !w.poll(newBackoff(time.Millisecond, time.Millisecond)) {
+		t.Fatal("worker exited")
+	}
+	// the listing and the processing of each file are in the trace of the poll
+	spans := exporter.GetSpans()
+	assertInt(t, len(spans), 1+1+2*4)
+	poll := spans[len(spans)-1]
+	assertString(t, poll.Name, "poll")
+	children := 0
+	for _, s := range spans {
+		if s.Parent.SpanID() == poll.SpanContext.SpanID() {
+			children++
+		}
+		if s.SpanContext.TraceID() != poll.SpanContext.TraceID() {
+			t.Errorf("span %s is not in the trace of the poll", s.Name)
+		}
+	}
+	assertInt(t, children, 3)
+}
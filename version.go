@@ -0,0 +1,17 @@
+package main
+
+import (
+	"fmt"
+)
+
+// build metadata, set at build time with
+// -ldflags "-X main.version=... -X main.commit=... -X main.buildDate=..."
+var (
+	version   = "dev"
+	commit    = "unknown"
+	buildDate = "unknown"
+)
+
+func versionString() string {
+	return fmt.Sprintf("sensors %s (commit %s, built %s)", version, commit, buildDate)
+}
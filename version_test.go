@@ -0,0 +1,20 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestVersion(t *testing.T) {
+	if version == "" {
+		t.Error("version must not be empty")
+	}
+
+	// would start the worker (and fail connecting to redis) if --version didn't short-circuit
+	var out bytes.Buffer
+	err := run([]string{"--version"}, &out)
+	assertError(t, err, nil)
+	assertString(t, strings.TrimSpace(out.String()), versionString())
+	assertSubString(t, out.String(), version)
+}
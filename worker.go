@@ -0,0 +1,412 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sony/gobreaker"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"sensors/pkg/sensors"
+)
+
+const (
+	// how often to report the progress of processing the backlog
+	progressInterval = 30 * time.Second
+	// wait between the polls of the remote directory
+	pollInterval = 10 * time.Second
+	// longest wait between the polls while REDIS is unavailable
+	maxRedisBackoff = 5 * time.Minute
+	// how long the log file without the end marker is waited for by default
+	defaultIncompleteTimeout = 10 * time.Minute
+)
+
+// worker fetches the log files from the remote directory, processes them and saves the results
+type worker struct {
+	cfg    Config
+	cache  Cache
+	source LogSource
+	alerts *alerter
+	sink   OutputSink
+	tmpDir string
+	// where and how often to report the progress
+	out              io.Writer
+	progressInterval time.Duration
+	// tracer of the spans of processing the log files, the global one when nil
+	tracer trace.Tracer
+	// remote directory, for the messages
+	remoteDir    string
+	pollInterval time.Duration
+	// closed to shut the worker down once the current log file is done, nil runs forever
+	stop <-chan struct{}
+	// what the worker processed since the start, for the summary at shutdown
+	stats runStats
+	// when the log files still without the end marker were found incomplete first
+	incomplete map[string]time.Time
+}
+
+// runStats are the statistics of the whole run of the worker
+type runStats struct {
+	start     time.Time
+	processed int
+	failed    int
+	// number of sensors by branding
+	brandings map[string]int
+}
+
+// Count the log file processed, with the brandings of its sensors, or failed
+func (s *runStats) add(brandings map[string]string, err error) {
+	if err != nil {
+		s.failed++
+		return
+	}
+	s.processed++
+	if s.brandings == nil {
+		s.brandings = make(map[string]int)
+	}
+	for _, b := range brandings {
+		s.brandings[b]++
+	}
+}
+
+// Write the summary of the run, e.g.
+//
+//	worker stopped after 2h0m0s: processed 12 log files, 1 failed
+//	  discard: 2
+//	  keep: 10
+func (s *runStats) write(out io.Writer) {
+	fmt.Fprintf(out, "worker stopped after %s: processed %d log files, %d failed\n",
+		time.Since(s.start).Round(time.Second), s.processed, s.failed)
+	brandings := make([]string, 0, len(s.brandings))
+	for b := range s.brandings {
+		brandings = append(brandings, b)
+	}
+	sort.Strings(brandings)
+	lines := make([]string, 0, len(brandings))
+	for _, b := range brandings {
+		lines = append(lines, fmt.Sprintf("  %s: %d\n", b, s.brandings[b]))
+	}
+	fmt.Fprint(out, strings.Join(lines, ""))
+}
+
+// Poll the remote directory and process the new log files until stopped; the summary of the run
+// is written at the end, also when the worker exits on the REDIS outage
+func (w *worker) run() {
+	w.stats.start = time.Now()
+	defer w.stats.write(w.out)
+	redisBackoff := newBackoff(w.pollInterval, maxRedisBackoff)
+	for w.sleep(w.pollInterval) {
+		if !w.poll(redisBackoff) {
+			return
+		}
+	}
+}
+
+// List the remote directory and process the new log files, traced as the span with the listing and
+// the processing of each file; false means the worker should exit
+func (w *worker) poll(redisBackoff *backoff) bool {
+	ctx, span := w.startSpan(context.Background(), "poll")
+	defer span.End()
+	_, listSpan := w.startSpan(ctx, "list log files")
+	logFiles, err := w.source.Unprocessed(w.cache)
+	endSpan(listSpan, err)
+	if err == ErrEmptyListing {
+		fmt.Printf("no log files in the listing of %s, check REMOTE_LOGS_DIR\n", w.remoteDir)
+		w.sleep(w.pollInterval)
+		return true
+	}
+	if isCacheUnavailable(err) {
+		if w.cfg.RedisOutage == RedisOutageExit {
+			fmt.Printf("Error fetching log files: %s\n", err.Error())
+			return false
+		}
+		wait := redisBackoff.next()
+		fmt.Printf("Error fetching log files: %s, retrying in %s\n", err.Error(), wait)
+		w.sleep(wait)
+		return true
+	}
+	redisBackoff.reset()
+	if err == gobreaker.ErrOpenState {
+		fmt.Println("remote server unavailable, waiting for the circuit breaker to close")
+		return true
+	}
+	if err != nil {
+		// the circuit breaker decides when to give the remote server a rest
+		fmt.Printf("Error fetching log files: %s\n", err.Error())
+		return true
+	}
+	fmt.Printf("got log files: %v\n", logFiles)
+	if len(logFiles) == 0 {
+		fmt.Println("no new log files")
+		w.sleep(w.pollInterval)
+		return true
+	}
+
+	// failed downloads are tried again on the next poll, unless the circuit breaker opens
+	if err := w.processBacklog(ctx, logFiles); err != nil {
+		fmt.Println(err.Error())
+		if isCacheUnavailable(err) && w.cfg.RedisOutage == RedisOutageExit {
+			return false
+		}
+	}
+	return true
+}
+
+// Wait for the duration, or until the worker is stopped; false means stopped
+func (w *worker) sleep(d time.Duration) bool {
+	select {
+	case <-w.stop:
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// Whether the worker was stopped
+func (w *worker) stopped() bool {
+	select {
+	case <-w.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// Process all the unprocessed log files (listed from newest to oldest), starting with the oldest one;
+// the spans of the files are children of the span in the context
+func (w *worker) processBacklog(ctx context.Context, logFiles []string) error {
+	fetch := w.source.Fetch
+	if w.cfg.DownloadConcurrency > 1 && len(logFiles) > 1 {
+		oldestFirst := make([]string, 0, len(logFiles))
+		for i := len(logFiles) - 1; i >= 0; i-- {
+			oldestFirst = append(oldestFirst, logFiles[i])
+		}
+		// the downloads report their progress from other goroutines
+		out := &syncWriter{w: w.out}
+		w.out = out
+		defer func() { w.out = out.w }()
+		downloads := newPrefetcher(w.source, w.tmpDir, oldestFirst, w.cfg.DownloadConcurrency,
+			newProgress(out, "downloaded", len(logFiles), w.progressInterval))
+		defer downloads.close()
+		fetch = downloads.Fetch
+	}
+	p := newProgress(w.out, "processed", len(logFiles), w.progressInterval)
+	for {
+		fileName, err := findOldestLogFile(logFiles, w.cache)
+		if err != nil {
+			return errors.Wrap(err, "Failed checking available the log files")
+		}
+		if fileName == "" || w.stopped() {
+			return nil
+		}
+		if err := w.processFetched(ctx, fileName, fetch); err != nil {
+			return err
+		}
+		p.step()
+	}
+}
+
+// Fetch, process and save the result of one log file, traced as the span with the spans of those stages
+func (w *worker) processFile(fileName string) error {
+	return w.processFetched(context.Background(), fileName, w.source.Fetch)
+}
+
+// processFile with the log file fetched by the function, e.g. downloaded ahead by prefetcher; the span
+// is the child of the span in the context
+func (w *worker) processFetched(ctx context.Context, fileName string, fetch func(ctx context.Context, logFile, dir string) (string, error)) (err error) {
+	ctx, span := w.startSpan(ctx, "process log file", attribute.String("log.file", fileName))
+	// the failure of the file marked as processed with it fails the span too, though nil is returned
+	var failed error
+	defer func() {
+		if err != nil {
+			failed = err
+		}
+		endSpan(span, failed)
+	}()
+
+	downloadCtx, downloadSpan := w.startSpan(ctx, "download")
+	filePath, err := fetch(downloadCtx, fileName, w.tmpDir)
+	endSpan(downloadSpan, err)
+	var sizeErr *FileTooLargeError
+	if errors.As(err, &sizeErr) {
+		// retrying won't help, so mark the file the same way as the file that failed processing
+		fmt.Printf("Error fetching log file: %s\n", err.Error())
+		if err := storeResult(w.cache, fileName, err.Error()); err != nil {
+			fmt.Printf("Error saving the result: %s\n", err.Error())
+		}
+		w.stats.add(nil, err)
+		failed = err
+		return nil
+	} else if err != nil {
+		return errors.Wrap(err, "Failed fetching latest log file")
+	}
+
+	var processed string
+	brandCtx, brandSpan := w.startSpan(ctx, "brand")
+	res, err := brandLogFile(brandCtx, filePath, withSidecarReference(w.cfg))
+	if res != nil {
+		brandSpan.SetAttributes(attribute.Int("sensors.count", len(res.Sensors)))
+	}
+	endSpan(brandSpan, err)
+
+	var sidecarErr *SidecarReferenceError
+	if errors.As(err, &sidecarErr) {
+		// the file isn't marked as processed without the reference, the worker tries again later
+		return errors.Wrap(err, "Log file "+fileName+" not processed")
+	}
+
+	var incompleteErr *sensors.IncompleteFileError
+	if errors.As(err, &incompleteErr) && w.incompleteFor(fileName) < w.cfg.IncompleteTimeout {
+		// not marked as processed, so it's tried again once the upload is complete; the newer files
+		// wait for it, to keep the order of processing
+		return errors.Wrap(err, "Log file "+fileName+" not processed")
+	}
+	// complete, or the exporter gave up on it (e.g. crashed), so let's mark it as incomplete below
+	delete(w.incomplete, fileName)
+	if err == nil && w.cfg.FailOnDiscard {
+		// stored as the failure like in the one-shot processing
+		err = checkDiscarded(res.Brandings())
+	}
+	storeCtx, storeSpan := w.startSpan(ctx, "store")
+	if err != nil {
+		fmt.Printf("Error processing log file: %s\n", err.Error())
+		// should we exit now or just proceed with next one?
+		// actually let's write the error, otherwise we'll loop on this one forever
+		processed = err.Error()
+		w.stats.add(nil, err)
+		failed = err
+	} else {
+		w.stats.add(res.Brandings(), nil)
+		processed = formatResult(res, w.cfg)
+		if err := w.sink.Write(storeCtx, fileName, processed); err != nil {
+			fmt.Printf("Error writing the result: %s\n", err.Error())
+		}
+		if len(w.cfg.Manifest) > 0 {
+			if report := manifestReport(fileName, w.cfg.Manifest, res); report != "" {
+				fmt.Fprintln(w.out, report)
+			}
+		}
+		if len(w.cfg.BrandingIndex) > 0 {
+			brandings := res.Brandings()
+			if w.cfg.anonymizer != nil {
+				// the index is served like the results
+				brandings = w.cfg.anonymizer.anonymize(res).Brandings()
+			}
+			if err := indexBrandings(w.cache, fileName, brandings, w.cfg.BrandingIndex, w.cfg.BrandingIndexRetention, time.Now()); err != nil {
+				fmt.Printf("Error updating the branding index: %s\n", err.Error())
+			}
+		}
+		if w.cfg.BrandingHistory > 0 {
+			flips, err := recordBrandingHistory(w.cache, fileName, res.Brandings(), w.cfg.BrandingHistory)
+			if err != nil {
+				fmt.Printf("Error saving the branding history: %s\n", err.Error())
+			}
+			if report := historyReport(fileName, flips); report != "" {
+				fmt.Fprintln(w.out, report)
+			}
+		}
+		if err := storeResultHash(w.cache, fileName, res.Hash()); err != nil {
+			fmt.Printf("Error saving the result: %s\n", err.Error())
+		}
+		if err := w.alerts.check(fileName, res.Brandings()); err != nil {
+			fmt.Printf("Error sending alert: %s\n", err.Error())
+		}
+	}
+	storeErr := storeResult(w.cache, fileName, processed)
+	endSpan(storeSpan, storeErr)
+	if storeErr != nil {
+		fmt.Printf("Error saving the result: %s\n", storeErr.Error())
+		failed = storeErr
+		return nil
+	}
+	// the downloaded copy is needed only until the result is stored, also in the kept DOWNLOAD_DIR
+	if err := os.Remove(filePath); err != nil {
+		fmt.Printf("Error removing the downloaded log file: %s\n", err.Error())
+	}
+	return nil
+}
+
+// Return how long the log file has been found incomplete, since the first attempt
+func (w *worker) incompleteFor(fileName string) time.Duration {
+	if w.incomplete == nil {
+		w.incomplete = make(map[string]time.Time)
+	}
+	since, ok := w.incomplete[fileName]
+	if !ok {
+		since = time.Now()
+		w.incomplete[fileName] = since
+	}
+	return time.Since(since)
+}
+
+// progress reports how far we are with processing (or other action on) the backlog of log files
+type progress struct {
+	out      io.Writer
+	action   string
+	total    int
+	done     int
+	start    time.Time
+	last     time.Time
+	interval time.Duration
+}
+
+func newProgress(out io.Writer, action string, total int, interval time.Duration) *progress {
+	now := time.Now()
+	return &progress{
+		out:      out,
+		action:   action,
+		total:    total,
+		start:    now,
+		last:     now,
+		interval: interval,
+	}
+}
+
+// Mark one more file as done; report the progress if the interval elapsed since the last report,
+// or when the whole backlog is done. Single file is not really a backlog, so it's not reported.
+func (p *progress) step() {
+	p.done++
+	now := time.Now()
+	if p.total < 2 || (now.Sub(p.last) < p.interval && p.done < p.total) {
+		return
+	}
+	p.last = now
+	elapsed := now.Sub(p.start)
+	eta := time.Duration(float64(elapsed) / float64(p.done) * float64(p.total-p.done))
+	fmt.Fprintf(p.out, "%s %d/%d log files (%d%%), ETA %s\n",
+		p.action, p.done, p.total, p.done*100/p.total, eta.Round(time.Second))
+}
+
+// backoff doubles the wait after every failure, up to the maximum
+type backoff struct {
+	initial, max, wait time.Duration
+}
+
+func newBackoff(initial, max time.Duration) *backoff {
+	return &backoff{initial: initial, max: max}
+}
+
+// Return how long to wait after another failure
+func (b *backoff) next() time.Duration {
+	if b.wait == 0 {
+		b.wait = b.initial
+	} else {
+		b.wait *= 2
+	}
+	if b.wait > b.max {
+		b.wait = b.max
+	}
+	return b.wait
+}
+
+// Start over after a success
+func (b *backoff) reset() {
+	b.wait = 0
+}
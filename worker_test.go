@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"sensors/pkg/sensors"
+)
+
+// Serve the directory listing with given files (newest first), each containing the same log
+func newTestRemoteDir(files []string, content string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, "<html><body>")
+			for _, f := range files {
+				fmt.Fprintf(w, `<a href="%s">%s</a>`, f, f)
+			}
+			fmt.Fprint(w, "</body></html>")
+			return
+		}
+		for _, f := range files {
+			if r.URL.Path == "/"+f {
+				fmt.Fprint(w, content)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+}
+
+func newTestWorker(t *testing.T, source LogSource) *worker {
+	tmpDir, err := ioutil.TempDir("", "sensor-logs")
+	if err != nil {
+		t.Fatal("Error creating temp directory")
+	}
+	t.Cleanup(func() { os.RemoveAll(tmpDir) })
+	cache := newMemCache()
+	return &worker{
+		cfg:    Config{Options: sensors.Options{Store: cache}},
+		cache:  cache,
+		source: source,
+		alerts: newAlerter("", nil),
+		sink:   &writerSink{out: ioutil.Discard},
+		tmpDir: tmpDir,
+		out:    &bytes.Buffer{},
+	}
+}
+
+func TestProcessBacklog(t *testing.T) {
+	files := []string{"log-3.txt", "log-2.txt", "log-1.txt"}
+	server := newTestRemoteDir(files, tempUltraPrecise)
+	defer server.Close()
+
+	w := newTestWorker(t, &htmlSource{client: newHTTPClient(nil, 0), dirURL: server.URL + "/"})
+	logFiles, err := w.source.Unprocessed(w.cache)
+	assertError(t, err, nil)
+	assertInt(t, len(logFiles), 3)
+
+	err = w.processBacklog(context.Background(), logFiles)
+	assertError(t, err, nil)
+
+	// files are processed from the oldest one
+	recent, _ := w.cache.List(recentFilesKey, maxRecentFiles)
+	assertString(t, strings.Join(recent, ","), strings.Join(files, ","))
+
+	lines := strings.Split(strings.TrimSpace(w.out.(*bytes.Buffer).String()), "\n")
+	assertInt(t, len(lines), 3)
+	assertSubString(t, lines[0], "processed 1/3 log files (33%), ETA")
+	assertSubString(t, lines[2], "processed 3/3 log files (100%), ETA 0s")
+}
+
+func TestResultHash(t *testing.T) {
+	files := []string{"log-2.txt", "log-1.txt"}
+	server := newTestRemoteDir(files, tempUltraPrecise)
+	defer server.Close()
+
+	w := newTestWorker(t, &htmlSource{client: newHTTPClient(nil, 0), dirURL: server.URL + "/"})
+	for _, f := range files {
+		assertError(t, w.processFile(f), nil)
+	}
+	hash1, err := w.cache.Get(resultHashKeyPrefix + "log-1.txt")
+	assertError(t, err, nil)
+	hash2, err := w.cache.Get(resultHashKeyPrefix + "log-2.txt")
+	assertError(t, err, nil)
+	// the same brandings give the same hash
+	assertString(t, hash2, hash1)
+
+	rec := httptest.NewRecorder()
+	newServer(w.cache).ServeHTTP(rec, httptest.NewRequest("GET", "/results/log-1.txt", nil))
+	assertString(t, rec.Header().Get(resultHashHeader), hash1)
+}
+
+func TestDownloadRemovedWhenStored(t *testing.T) {
+	server := newTestRemoteDir([]string{"log-1.txt"}, tempUltraPrecise)
+	defer server.Close()
+	w := newTestWorker(t, &htmlSource{client: newHTTPClient(nil, 0), dirURL: server.URL + "/"})
+	assertError(t, w.processFile("log-1.txt"), nil)
+
+	if _, err := os.Stat(filepath.Join(w.tmpDir, "log-1.txt")); !os.IsNotExist(err) {
+		t.Error("downloaded log file was not removed")
+	}
+}
+
+func TestWorkerFailOnDiscard(t *testing.T) {
+	server := newTestRemoteDir([]string{"log-1.txt"}, humSensorDiscard01)
+	defer server.Close()
+	w := newTestWorker(t, &htmlSource{client: newHTTPClient(nil, 0), dirURL: server.URL + "/"})
+	w.cfg.FailOnDiscard = true
+	assertError(t, w.processFile("log-1.txt"), nil)
+
+	// the file is marked as processed with the failure
+	val, err := w.cache.Get("log-1.txt")
+	assertError(t, err, nil)
+	assertString(t, val, ErrSensorsDiscarded+": hum-1")
+}
+
+func TestIncompleteLogFile(t *testing.T) {
+	files := []string{"log-2.txt", "log-1.txt"}
+	server := newTestRemoteDir(files, tempUltraPrecise)
+	defer server.Close()
+
+	w := newTestWorker(t, &htmlSource{client: newHTTPClient(nil, 0), dirURL: server.URL + "/"})
+	w.cfg.EndMarker = "# EOF"
+	w.cfg.IncompleteTimeout = time.Hour
+	err := w.processBacklog(context.Background(), files)
+	assertErrorMessageSubString(t, err, "Log file log-1.txt not processed: "+sensors.ErrIncompleteFile)
+	// neither the incomplete file, nor the newer one are marked as processed
+	for _, f := range files {
+		_, err := w.cache.Get(f)
+		assertError(t, err, ErrCacheMiss)
+	}
+
+	// the file that never gets the marker doesn't block the newer ones forever
+	w.incomplete["log-1.txt"] = time.Now().Add(-2 * time.Hour)
+	err = w.processBacklog(context.Background(), files)
+	assertErrorMessageSubString(t, err, "Log file log-2.txt not processed: "+sensors.ErrIncompleteFile)
+	result, err := w.cache.Get("log-1.txt")
+	assertError(t, err, nil)
+	assertSubString(t, result, sensors.ErrIncompleteFile)
+	assertInt(t, w.stats.failed, 1)
+}
+
+// stoppingSource shuts the worker down once there are no unprocessed log files
+type stoppingSource struct {
+	LogSource
+	stop chan struct{}
+}
+
+func (s *stoppingSource) Unprocessed(cache Cache) ([]string, error) {
+	files, err := s.LogSource.Unprocessed(cache)
+	if err == nil && len(files) == 0 {
+		close(s.stop)
+	}
+	return files, err
+}
+
+func TestShutdownSummary(t *testing.T) {
+	logs := map[string]string{
+		"log-2.txt": "reference 100 45\nthermomter temp-1\n2007-04-05T22:00 100\n",
+		"log-1.txt": mixedSensors,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, `<html><body><a href="log-2.txt">log-2.txt</a><a href="log-1.txt">log-1.txt</a></body></html>`)
+			return
+		}
+		fmt.Fprint(w, logs[strings.TrimPrefix(r.URL.Path, "/")])
+	}))
+	defer server.Close()
+
+	stop := make(chan struct{})
+	w := newTestWorker(t, &stoppingSource{
+		LogSource: &htmlSource{client: newHTTPClient(nil, 0), dirURL: server.URL + "/"},
+		stop:      stop,
+	})
+	w.pollInterval = 10 * time.Millisecond
+	w.stop = stop
+	out := &bytes.Buffer{}
+	w.out = out
+	done := make(chan struct{})
+	go func() {
+		w.run()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("worker not stopped")
+	}
+
+	summary := out.String()
+	assertSubString(t, summary, "processed 1 log files, 1 failed\n")
+	assertSubString(t, summary, "  discard: 1\n  keep: 1\n  ultra precise: 1\n")
+}
+
+// unavailableSource fails listing as if REDIS was down
+type unavailableSource struct {
+	LogSource
+}
+
+func (s *unavailableSource) Unprocessed(cache Cache) ([]string, error) {
+	return nil, redisError(errors.New("connection refused"))
+}
+
+func TestShutdownSummaryOnRedisOutage(t *testing.T) {
+	w := newTestWorker(t, &unavailableSource{})
+	w.pollInterval = time.Millisecond
+	w.cfg.RedisOutage = RedisOutageExit
+	w.run()
+	assertSubString(t, w.out.(*bytes.Buffer).String(), "worker stopped after 0s: processed 0 log files, 0 failed\n")
+}